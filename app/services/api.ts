@@ -13,6 +13,7 @@ export interface LeaderboardEntry {
   rank: number;
   username: string;
   rating: number;
+  is_self?: boolean;
 }
 
 export interface SearchResult {
@@ -1,3 +1,5 @@
 module matiks-backend
 
 go 1.21
+
+require golang.org/x/text v0.14.0
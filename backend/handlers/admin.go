@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"matiks-backend/models"
+	"matiks-backend/services"
+)
+
+// RequireAdmin only lets requests through that carry
+// "Authorization: Bearer <AdminToken>". When no token is configured the
+// admin API is disabled and every request is refused.
+func (h *Handler) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := h.leaderboardService.Config().AdminToken
+		if token == "" {
+			http.Error(w, "Admin API disabled", http.StatusForbidden)
+			return
+		}
+
+		if !h.isAdmin(r) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// isAdmin reports whether r carries the admin token. Without a configured
+// token nobody is an admin.
+func (h *Handler) isAdmin(r *http.Request) bool {
+	token := h.leaderboardService.Config().AdminToken
+	if token == "" {
+		return false
+	}
+
+	provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+func (h *Handler) SelfBench(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	op := r.URL.Query().Get("op")
+	if op == "" {
+		op = "search"
+	}
+
+	n := 10000 // default
+	if nStr := r.URL.Query().Get("n"); nStr != "" {
+		parsedN, err := strconv.Atoi(nStr)
+		if err != nil {
+			http.Error(w, "Invalid n parameter", http.StatusBadRequest)
+			return
+		}
+		n = parsedN
+	}
+
+	result, err := h.leaderboardService.SelfBenchmark(op, n)
+	if errors.Is(err, services.ErrSelfBenchBusy) {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, r, result)
+}
+
+// Import replaces the whole population with a JSON array of users. Every
+// invalid row is reported in a 422; with ?partial=true the valid rows are
+// imported anyway and the invalid ones are listed under "rejected".
+// ?duplicates=reject|keep-last|keep-highest chooses how rows sharing an ID
+// are handled; reject (the default) treats them as invalid.
+func (h *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	partial, ok := parsePartialParam(w, r)
+	if !ok {
+		return
+	}
+
+	policy, err := services.ParseDuplicatePolicy(r.URL.Query().Get("duplicates"))
+	if err != nil {
+		http.Error(w, "Invalid duplicates parameter", http.StatusBadRequest)
+		return
+	}
+
+	var seeds []models.UserSeed
+	if err := json.NewDecoder(r.Body).Decode(&seeds); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	keep, resolved := services.ResolveDuplicateIDs(seeds, policy)
+	kept := make([]models.UserSeed, len(keep))
+	for i, index := range keep {
+		kept[i] = seeds[index]
+	}
+
+	// Report errors against the rows as the client sent them
+	errs := h.leaderboardService.ValidateUsers(kept)
+	for i := range errs {
+		errs[i].Index = keep[errs[i].Index]
+	}
+	if len(errs) > 0 && !partial {
+		h.writeValidationErrors(w, r, errs)
+		return
+	}
+
+	rejected := errs.Rows()
+	valid := kept[:0:0]
+	for i, seed := range kept {
+		if !rejected[keep[i]] {
+			valid = append(valid, seed)
+		}
+	}
+
+	if err := h.leaderboardService.ReplaceAll(valid); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	h.writeEncoded(w, r, map[string]interface{}{
+		"imported": len(valid),
+		"rejected": nonNilErrors(errs),
+		"duplicates": map[string]interface{}{
+			"policy":   policy,
+			"resolved": resolved,
+		},
+	})
+}
+
+// AddUsers adds a JSON array of new users to the population. With
+// Config.MaxUsers set, the coldest existing users are evicted to make room.
+// Invalid rows, taken IDs and rows beyond the cap are reported in a 422.
+func (h *Handler) AddUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var seeds []models.UserSeed
+	if err := json.NewDecoder(r.Body).Decode(&seeds); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := h.leaderboardService.AddUsers(seeds)
+	var errs services.ValidationErrors
+	if errors.As(err, &errs) {
+		h.writeValidationErrors(w, r, errs)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	h.writeJSON(w, r, map[string]interface{}{
+		"added":       len(seeds),
+		"total_users": h.leaderboardService.GetSnapshot().TotalUsers(),
+	})
+}
+
+// Rename changes a user's username: POST {"username": "new_name"} to
+// ?user_id=N. Blocked names, and names in use under
+// services.Config.UniqueUsernames, are rejected with 422.
+func (h *Handler) Rename(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID <= 0 {
+		http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Username == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err = h.leaderboardService.UpdateUsername(userID, req.Username)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrUnknownUser):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrUsernameBlocked), errors.Is(err, services.ErrUsernameTaken):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	h.writeJSON(w, r, renameResponse{UserID: userID, Username: req.Username})
+}
+
+type renameResponse struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// Exclude hides a user from leaderboard and search listings (POST) or makes
+// them visible again (DELETE). The user is given as ?user_id=N.
+func (h *Handler) Exclude(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID <= 0 {
+		http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		h.leaderboardService.Unexclude(userID)
+	} else if err := h.leaderboardService.Exclude(userID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	h.writeJSON(w, r, exclusionResponse{
+		UserID:   userID,
+		Excluded: h.leaderboardService.IsExcluded(userID),
+	})
+}
+
+type exclusionResponse struct {
+	UserID   int  `json:"user_id"`
+	Excluded bool `json:"excluded"`
+}
+
+// PauseSimulator freezes the random update simulator (POST) or resumes it
+// (DELETE). Submitted rating updates keep applying either way.
+func (h *Handler) PauseSimulator(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		h.leaderboardService.ResumeSimulator()
+	} else {
+		h.leaderboardService.PauseSimulator()
+	}
+
+	h.writeJSON(w, r, map[string]interface{}{
+		"simulator_paused": h.leaderboardService.SimulatorPaused(),
+	})
+}
+
+// Rebuild publishes a fresh snapshot immediately and reports its version.
+func (h *Handler) Rebuild(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.leaderboardService.ForceRebuild(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	h.writeJSON(w, r, map[string]interface{}{
+		"snapshot_version": h.leaderboardService.GetSnapshot().Version,
+	})
+}
+
+// Config reports the service's effective configuration, with secrets
+// redacted, for checking what a deployment is actually running with.
+func (h *Handler) Config(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.writeJSON(w, r, h.leaderboardService.EffectiveConfig())
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"matiks-backend/services"
+)
+
+// DebugMux returns the profiling endpoints, meant to be served on a
+// separate listener (Config.DebugAddr) rather than the public mux:
+// net/http/pprof under /debug/pprof/ plus /debug/gc and /debug/ratings.
+// Every route requires
+// the admin token. When DebugAddr is empty the mux has no routes, so every
+// request gets a 404.
+func (h *Handler) DebugMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	if h.leaderboardService.Config().DebugAddr == "" {
+		return mux
+	}
+
+	mux.HandleFunc("/debug/pprof/", h.RequireAdmin(pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", h.RequireAdmin(pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", h.RequireAdmin(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", h.RequireAdmin(pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", h.RequireAdmin(pprof.Trace))
+	mux.HandleFunc("/debug/gc", h.RequireAdmin(h.ForceGC))
+	mux.HandleFunc("/debug/ratings", h.RequireAdmin(h.DebugRatings))
+
+	return mux
+}
+
+// ForceGC runs a garbage collection and reports memory statistics
+// afterwards.
+func (h *Handler) ForceGC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	start := time.Now()
+	runtime.GC()
+	elapsed := time.Since(start)
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, r, map[string]interface{}{
+		"gc_duration_ms":   float64(elapsed.Microseconds()) / 1000,
+		"heap_alloc_bytes": mem.HeapAlloc,
+		"heap_inuse_bytes": mem.HeapInuse,
+		"heap_objects":     mem.HeapObjects,
+		"sys_bytes":        mem.Sys,
+		"num_gc":           mem.NumGC,
+		"pause_total_ns":   mem.PauseTotalNs,
+		"next_gc_bytes":    mem.NextGC,
+		"goroutines":       runtime.NumGoroutine(),
+	})
+}
+
+// Limits on /debug/ratings: how many adjacent ratings one request may span
+// and how many users are listed per rating.
+const (
+	MaxDebugRatingRange = 100
+	MaxDebugBucketUsers = 100
+)
+
+type debugBucket struct {
+	Rating    int         `json:"rating"`
+	Count     int         `json:"count"`
+	Users     []debugUser `json:"users"`
+	Truncated bool        `json:"truncated"`
+}
+
+type debugRatingsResponse struct {
+	Min     int           `json:"min"`
+	Max     int           `json:"max"`
+	Buckets []debugBucket `json:"buckets"`
+}
+
+type debugUser struct {
+	ID        int        `json:"id"`
+	Username  string     `json:"username"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// DebugRatings lists the current snapshot's UsersByRating buckets for
+// ?min=N&max=N, highest rating first and in listing order, for diagnosing
+// ordering and tie-break issues. Excluded users are included. Empty
+// ratings are omitted.
+func (h *Handler) DebugRatings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	minRating, ok := parsePositiveParam(w, r, "min", 0, services.MaxRating)
+	if !ok {
+		return
+	}
+	maxRating, ok := parsePositiveParam(w, r, "max", 0, services.MaxRating)
+	if !ok {
+		return
+	}
+	if minRating == 0 || maxRating == 0 || minRating > maxRating {
+		http.Error(w, "min and max are required, with min <= max", http.StatusBadRequest)
+		return
+	}
+	if maxRating-minRating >= MaxDebugRatingRange {
+		http.Error(w, fmt.Sprintf("At most %d ratings per request", MaxDebugRatingRange), http.StatusBadRequest)
+		return
+	}
+
+	snap := h.leaderboardService.GetSnapshot()
+
+	buckets := []debugBucket{}
+	for rating := maxRating; rating >= minRating; rating-- {
+		users := snap.UsersByRating[rating]
+		if len(users) == 0 {
+			continue
+		}
+
+		bucket := debugBucket{
+			Rating:    rating,
+			Count:     len(users),
+			Truncated: len(users) > MaxDebugBucketUsers,
+		}
+		for _, user := range users[:min(len(users), MaxDebugBucketUsers)] {
+			entry := debugUser{ID: user.ID, Username: user.Username}
+			if user.UpdatedAt != 0 {
+				updatedAt := time.Unix(0, user.UpdatedAt).UTC()
+				entry.UpdatedAt = &updatedAt
+			}
+			bucket.Users = append(bucket.Users, entry)
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, r, debugRatingsResponse{
+		Min:     minRating,
+		Max:     maxRating,
+		Buckets: buckets,
+	})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"matiks-backend/msgpack"
+)
+
+// Encoder writes a response body in one wire format.
+type Encoder interface {
+	ContentType() string
+	Encode(w io.Writer, v interface{}) error
+}
+
+// jsonEncoder writes JSON. With stringIDs set, user IDs are quoted (see
+// stringIDs and services.Config.StringIDs).
+type jsonEncoder struct {
+	stringIDs bool
+}
+
+func (jsonEncoder) ContentType() string { return "application/json" }
+
+func (e jsonEncoder) Encode(w io.Writer, v interface{}) error {
+	if e.stringIDs {
+		v = stringIDs(v)
+	}
+	return json.NewEncoder(w).Encode(v)
+}
+
+type msgpackEncoder struct{}
+
+func (msgpackEncoder) ContentType() string { return msgpack.ContentType }
+
+func (msgpackEncoder) Encode(w io.Writer, v interface{}) error {
+	return msgpack.NewEncoder(w).Encode(v)
+}
+
+// negotiateEncoder picks the response encoding from the Accept header.
+// MessagePack is used when the client asks for it; everything else,
+// including a missing header, gets JSON.
+func (h *Handler) negotiateEncoder(r *http.Request) Encoder {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(accepted, ";", 2)[0])
+		switch mediaType {
+		case msgpack.ContentType, "application/msgpack":
+			return msgpackEncoder{}
+		case "application/json":
+			return h.jsonEncoder()
+		}
+	}
+	return h.jsonEncoder()
+}
+
+// writeEncoded sets the negotiated Content-Type and encodes v. Extra headers
+// such as Cache-Control must be set by the caller beforehand.
+func (h *Handler) writeEncoded(w http.ResponseWriter, r *http.Request, v interface{}) {
+	h.writeEncodedStatus(w, r, http.StatusOK, v)
+}
+
+// writeEncodedStatus is writeEncoded with a status other than 200.
+func (h *Handler) writeEncodedStatus(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	encoder := h.negotiateEncoder(r)
+
+	w.Header().Set("Content-Type", encoder.ContentType())
+	w.Header().Add("Vary", "Accept")
+
+	encodeResponse(w, r, encoder, status, v)
+}
+
+// writeJSON is writeEncoded for endpoints that always answer in JSON.
+func (h *Handler) writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	encodeResponse(w, r, h.jsonEncoder(), http.StatusOK, v)
+}
+
+func (h *Handler) jsonEncoder() jsonEncoder {
+	return jsonEncoder{stringIDs: h.leaderboardService.Config().StringIDs}
+}
+
+// encodeResponse encodes v with status, reporting a 500 only while that is
+// still possible. Once any part of the body has been handed to the
+// connection the status line is already sent, so a failure (typically a
+// client that disconnected mid-response) is logged and the response
+// abandoned instead.
+func encodeResponse(w http.ResponseWriter, r *http.Request, encoder Encoder, status int, v interface{}) {
+	tracked := &trackingWriter{ResponseWriter: w, status: status}
+
+	err := encoder.Encode(tracked, v)
+	if err == nil {
+		return
+	}
+
+	if tracked.wrote {
+		slog.Warn("response aborted", "method", r.Method, "path", r.URL.Path, "err", err)
+		return
+	}
+
+	http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+}
+
+// trackingWriter records whether the body has started to be written, and
+// sends status just before it.
+type trackingWriter struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+func (t *trackingWriter) Write(p []byte) (int, error) {
+	if !t.wrote {
+		t.wrote = true
+		t.ResponseWriter.WriteHeader(t.status)
+	}
+	return t.ResponseWriter.Write(p)
+}
@@ -1,52 +1,517 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"matiks-backend/models"
 	"matiks-backend/services"
 )
 
 type Handler struct {
 	leaderboardService *services.LeaderboardService
+
+	// boards maps board names to their services for /leaderboard/multi.
+	// leaderboardService is registered as DefaultBoard.
+	boards map[string]*services.LeaderboardService
+
+	// Rolling response times per route (see RecordLatency)
+	latency *latencyTracker
 }
 
+// DefaultBoard names the handler's own leaderboard among its boards.
+const DefaultBoard = "global"
+
 func NewHandler(service *services.LeaderboardService) *Handler {
 	return &Handler{
 		leaderboardService: service,
+		boards:             map[string]*services.LeaderboardService{DefaultBoard: service},
+		latency:            newLatencyTracker(),
 	}
 }
 
+// RegisterBoard makes another leaderboard, e.g. a separate game mode,
+// available to /leaderboard/multi under name. Boards must be registered
+// before the handler serves requests.
+func (h *Handler) RegisterBoard(name string, service *services.LeaderboardService) {
+	h.boards[name] = service
+}
+
+// setCacheHeaders lets browsers and CDNs reuse a response for ttl.
+// max-age only has whole-second granularity, so ttl is rounded up; a zero
+// ttl marks the response as uncacheable.
+func setCacheHeaders(w http.ResponseWriter, ttl time.Duration) {
+	if ttl <= 0 {
+		w.Header().Set("Cache-Control", "no-cache")
+		return
+	}
+
+	seconds := int((ttl + time.Second - 1) / time.Second)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, s-maxage=%d", seconds, seconds))
+	w.Header().Set("CDN-Cache-Control", fmt.Sprintf("max-age=%d", seconds))
+}
+
+// UserIDHeader carries the caller's user ID as established by the
+// authenticating gateway in front of this service.
+const UserIDHeader = "X-User-ID"
+
+// parseViewer returns the authenticated user ID when the request asks for
+// ?include_self=true, so their own entry is shown (and marked) even if they
+// are excluded. Otherwise the viewer is anonymous (0).
+func parseViewer(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("include_self")
+	if value == "" {
+		return 0, true
+	}
+
+	includeSelf, err := strconv.ParseBool(value)
+	if err != nil {
+		http.Error(w, "Invalid include_self parameter", http.StatusBadRequest)
+		return 0, false
+	}
+	if !includeSelf {
+		return 0, true
+	}
+
+	viewerID, err := strconv.Atoi(r.Header.Get(UserIDHeader))
+	if err != nil || viewerID <= 0 {
+		http.Error(w, "include_self requires an authenticated user", http.StatusUnauthorized)
+		return 0, false
+	}
+	return viewerID, true
+}
+
+// APIKeyHeader identifies the API client, whose tier (see
+// Config.APIKeys) bounds the leaderboard limits it may use.
+const APIKeyHeader = "X-API-Key"
+
+// MaxStalenessHeader lets a client accept an older snapshot, e.g. "500"
+// for anything generated in the last half second.
+const MaxStalenessHeader = "X-Max-Staleness-Ms"
+
+// parseMaxStaleness reads the optional MaxStalenessHeader, writing a 400
+// and returning false if it is malformed. Absent means zero: the current
+// snapshot.
+func parseMaxStaleness(w http.ResponseWriter, r *http.Request) (time.Duration, bool) {
+	value := r.Header.Get(MaxStalenessHeader)
+	if value == "" {
+		return 0, true
+	}
+
+	ms, err := strconv.Atoi(value)
+	if err != nil || ms < 0 {
+		http.Error(w, "Invalid "+MaxStalenessHeader+" header", http.StatusBadRequest)
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// parseRankBase reads the optional ?rank_base: 1 (the default) or 0 for
+// clients that expect 0-based positions. It returns the amount to subtract
+// from every rank in the response.
+func parseRankBase(w http.ResponseWriter, r *http.Request) (int, bool) {
+	switch r.URL.Query().Get("rank_base") {
+	case "", "1":
+		return 0, true
+	case "0":
+		return 1, true
+	default:
+		http.Error(w, "Invalid rank_base parameter", http.StatusBadRequest)
+		return 0, false
+	}
+}
+
+// parseInclude reads the optional ?include=country,avatar list of user
+// metadata fields to add to entries, writing a 400 for unknown fields.
+func parseInclude(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	fields, err := services.ParseMetadataFields(r.URL.Query().Get("include"))
+	if err != nil {
+		http.Error(w, "Invalid include parameter: "+err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return fields, true
+}
+
+// rebaseRanks subtracts offset (from parseRankBase) from every entry's
+// rank in place. Entries must be owned by the caller, not a snapshot.
+func rebaseRanks(entries []models.LeaderboardEntry, offset int) {
+	if offset == 0 {
+		return
+	}
+	for i := range entries {
+		entries[i].Rank -= offset
+	}
+}
+
+// setViewerCacheHeaders is setCacheHeaders for responses that may be
+// personalised: a response built for a specific viewer must not be shared.
+func setViewerCacheHeaders(w http.ResponseWriter, viewerID int, ttl time.Duration) {
+	if viewerID != 0 {
+		w.Header().Set("Cache-Control", "private, no-store")
+		return
+	}
+	setCacheHeaders(w, ttl)
+}
+
 func (h *Handler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	limitStr := r.URL.Query().Get("limit")
-	limit := 100 // default
+	limit, ok := h.parseLimit(w, r)
+	if !ok {
+		return
+	}
+
+	viewerID, ok := parseViewer(w, r)
+	if !ok {
+		return
+	}
+
+	minRating, ok := parsePositiveParam(w, r, "min_rating", 0, services.MaxRating)
+	if !ok {
+		return
+	}
+
+	maxStaleness, ok := parseMaxStaleness(w, r)
+	if !ok {
+		return
+	}
 
-	if limitStr != "" {
-		parsedLimit, err := strconv.Atoi(limitStr)
-		if err != nil || parsedLimit <= 0 {
-			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+	rankOffset, ok := parseRankBase(w, r)
+	if !ok {
+		return
+	}
+
+	// Optional ?envelope=true wraps the entries as {data, count, has_more},
+	// so clients can tell a short list from a truncated one. Without it the
+	// bare array is returned as before.
+	envelope, ok := parseBoolParam(w, r, "envelope")
+	if !ok {
+		return
+	}
+
+	include, ok := parseInclude(w, r)
+	if !ok {
+		return
+	}
+
+	offset, ok := parseOffset(w, r)
+	if !ok {
+		return
+	}
+
+	opts := services.LeaderboardOptions{
+		Limit:        limit,
+		ViewerID:     viewerID,
+		MinRating:    minRating,
+		MaxStaleness: maxStaleness,
+		Include:      include,
+		Offset:       offset,
+	}
+
+	setViewerCacheHeaders(w, viewerID, h.leaderboardService.Config().LeaderboardCacheTTL)
+
+	// ?offset= pages through the leaderboard limit entries at a time, with
+	// a Link header to the neighbouring pages
+	if r.URL.Query().Has("offset") {
+		leaderboard, total := h.leaderboardService.GetLeaderboardPage(opts)
+		rebaseRanks(leaderboard, rankOffset)
+		h.leaderboardService.MaskEntries(leaderboard)
+		pageSize := limit
+		switch {
+		case limit == services.LimitAll:
+			pageSize = total
+		case limit <= 0:
+			pageSize = services.DefaultLeaderboardLimit
+		}
+		setOffsetLinkHeader(w, r, offset, pageSize, total)
+		if !envelope {
+			h.writeEncoded(w, r, leaderboard)
 			return
 		}
-		limit = parsedLimit
+		h.writeEncoded(w, r, map[string]interface{}{
+			"data":     leaderboard,
+			"count":    len(leaderboard),
+			"has_more": offset+len(leaderboard) < total,
+		})
+		return
+	}
+
+	if !envelope {
+		leaderboard := h.leaderboardService.GetLeaderboardWithOptions(opts)
+		rebaseRanks(leaderboard, rankOffset)
+		h.leaderboardService.MaskEntries(leaderboard)
+		h.writeEncoded(w, r, leaderboard)
+		return
 	}
 
-	leaderboard := h.leaderboardService.GetLeaderboard(limit)
+	// Looking one entry further can miss the top-N cache, so only
+	// enveloped responses pay for it
+	leaderboard, hasMore := h.leaderboardService.GetLeaderboardWithMore(opts)
+	rebaseRanks(leaderboard, rankOffset)
+	h.leaderboardService.MaskEntries(leaderboard)
+	h.writeEncoded(w, r, map[string]interface{}{
+		"data":     leaderboard,
+		"count":    len(leaderboard),
+		"has_more": hasMore,
+	})
+}
 
-	// Cache for 2 seconds (matches our snapshot rebuild interval)
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Cache-Control", "public, max-age=2, s-maxage=2")
-	w.Header().Set("CDN-Cache-Control", "max-age=2")
+// parseLimit reads ?limit= for /leaderboard under the client's tier: none
+// means the tier's default and larger limits are clamped to its maximum.
+// "all" (or -1) asks for every entry, which clients of unlimited tiers and
+// admins get as services.LimitAll; anyone else gets the tier's maximum.
+func (h *Handler) parseLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	tier := h.leaderboardService.ClientTierFor(r.Header.Get(APIKeyHeader))
 
-	if err := json.NewEncoder(w).Encode(leaderboard); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	if value := r.URL.Query().Get("limit"); value == "all" || value == strconv.Itoa(services.LimitAll) {
+		if tier.MaxLimit == 0 || h.isAdmin(r) {
+			return services.LimitAll, true
+		}
+		return tier.MaxLimit, true
+	}
+
+	limit, ok := parsePositiveParam(w, r, "limit", 0, 0)
+	if !ok {
+		return 0, false
+	}
+	return tier.ClampLimit(limit), true
+}
+
+// GetLeaderboardDelta serves /leaderboard/delta?since=V&limit=N: the
+// entries of the top N that changed since snapshot version V, plus the user
+// IDs that left it. Omit since (or pass 0) for a full response to start
+// from; each response carries the version to pass next.
+func (h *Handler) GetLeaderboardDelta(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var since uint64
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		parsed, err := strconv.ParseUint(sinceStr, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid since parameter", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	tier := h.leaderboardService.ClientTierFor(r.Header.Get(APIKeyHeader))
+	limit, ok := parsePositiveParam(w, r, "limit", 0, 0)
+	if !ok {
+		return
+	}
+	limit = tier.ClampLimit(limit)
+
+	rankOffset, ok := parseRankBase(w, r)
+	if !ok {
 		return
 	}
+
+	delta := h.leaderboardService.LeaderboardDelta(since, limit)
+	for i := range delta.Changed {
+		delta.Changed[i].Rank -= rankOffset
+		delta.Changed[i].Username = h.leaderboardService.MaskUsername(delta.Changed[i].Username)
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	h.writeEncoded(w, r, delta)
+}
+
+// GetMovers returns the users whose rank rose or fell the most over the last
+// window snapshots, e.g. /leaderboard/movers?window=5&limit=10.
+func (h *Handler) GetMovers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	window, ok := parsePositiveParam(w, r, "window", 1, 0)
+	if !ok {
+		return
+	}
+	limit, ok := parsePositiveParam(w, r, "limit", 10, 100)
+	if !ok {
+		return
+	}
+
+	rankOffset, ok := parseRankBase(w, r)
+	if !ok {
+		return
+	}
+
+	movers := h.leaderboardService.TopMovers(window, limit)
+	for _, list := range [][]services.Mover{movers.Climbers, movers.Decliners} {
+		for i := range list {
+			list[i].Rank -= rankOffset
+			list[i].PreviousRank -= rankOffset
+			list[i].Username = h.leaderboardService.MaskUsername(list[i].Username)
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	h.writeEncoded(w, r, movers)
+}
+
+// FilterLeaderboard returns the leaderboard entries for the posted user IDs,
+// e.g. a friends list, keeping their global ranks.
+func (h *Handler) FilterLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rankOffset, ok := parseRankBase(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		UserIDs []int `json:"user_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.UserIDs) > services.MaxFilterUsers {
+		http.Error(w, fmt.Sprintf("At most %d user_ids allowed", services.MaxFilterUsers), http.StatusBadRequest)
+		return
+	}
+
+	entries := h.leaderboardService.GetLeaderboardForUsers(req.UserIDs)
+	rebaseRanks(entries, rankOffset)
+	h.leaderboardService.MaskEntries(entries)
+
+	h.writeEncoded(w, r, map[string]interface{}{
+		"data":  entries,
+		"count": len(entries),
+	})
+}
+
+// MaxBoardsPerRequest bounds how many boards one /leaderboard/multi
+// request may ask for.
+const MaxBoardsPerRequest = 20
+
+// MultiLeaderboard returns the top entries of several boards at once, for
+// dashboards: POST {"boards": ["blitz", "classic"], "limit": N}. Each board
+// is read from a single snapshot of its own. Unknown boards get an error
+// entry instead of failing the request.
+func (h *Handler) MultiLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rankOffset, ok := parseRankBase(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Boards []string `json:"boards"`
+		Limit  int      `json:"limit"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Boards) == 0 || len(req.Boards) > MaxBoardsPerRequest {
+		http.Error(w, fmt.Sprintf("Between 1 and %d boards required", MaxBoardsPerRequest), http.StatusBadRequest)
+		return
+	}
+	if req.Limit < 0 {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+	// Each board is a leaderboard listing, limited by the client's tier as
+	// /leaderboard is
+	limit := h.leaderboardService.ClientTierFor(r.Header.Get(APIKeyHeader)).ClampLimit(req.Limit)
+
+	boards := make(map[string]interface{}, len(req.Boards))
+	for _, name := range req.Boards {
+		service, ok := h.boards[name]
+		if !ok {
+			boards[name] = map[string]string{"error": "unknown board"}
+			continue
+		}
+
+		entries := service.GetLeaderboard(limit)
+		rebaseRanks(entries, rankOffset)
+		service.MaskEntries(entries)
+		boards[name] = map[string]interface{}{
+			"data":  entries,
+			"count": len(entries),
+		}
+	}
+
+	h.writeEncoded(w, r, map[string]interface{}{
+		"boards": boards,
+	})
+}
+
+// MaxRankRangesPerRequest bounds how many ranges one /leaderboard/ranges
+// request may ask for.
+const MaxRankRangesPerRequest = 20
+
+// GetRankRanges returns the users in several rank windows at once, for
+// analytics: POST {"ranges": [{"from": 1, "to": 10}, {"from": 100, "to": 110}]}.
+// Every window is read from the same snapshot; see services.GetRankRanges
+// for the cap on total entries.
+func (h *Handler) GetRankRanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rankOffset, ok := parseRankBase(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Ranges []services.RankRange `json:"ranges"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Ranges) == 0 || len(req.Ranges) > MaxRankRangesPerRequest {
+		http.Error(w, fmt.Sprintf("Between 1 and %d ranges required", MaxRankRangesPerRequest), http.StatusBadRequest)
+		return
+	}
+
+	ranges := make([]services.RankRange, len(req.Ranges))
+	for i, rr := range req.Ranges {
+		if rr.From+rankOffset < 1 || rr.To < rr.From {
+			http.Error(w, fmt.Sprintf("Invalid range %d: from must be a valid rank and to must not be below it", i), http.StatusBadRequest)
+			return
+		}
+		ranges[i] = services.RankRange{From: rr.From + rankOffset, To: rr.To + rankOffset}
+	}
+
+	results, truncated := h.leaderboardService.GetRankRanges(ranges)
+	for i := range results {
+		results[i].RankRange = req.Ranges[i] // as requested, in the caller's rank base
+		rebaseRanks(results[i].Entries, rankOffset)
+		h.leaderboardService.MaskEntries(results[i].Entries)
+	}
+
+	h.writeEncoded(w, r, map[string]interface{}{
+		"data":      results,
+		"truncated": truncated,
+	})
 }
 
 func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
@@ -55,47 +520,515 @@ func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	query, ok := parseQueryParam(w, r)
+	if !ok {
+		return
+	}
+
+	order := h.leaderboardService.Config().DefaultSearchOrder
+	if sortStr := r.URL.Query().Get("sort"); sortStr != "" {
+		parsedOrder, err := services.ParseSearchOrder(sortStr)
+		if err != nil {
+			http.Error(w, "Invalid sort parameter", http.StatusBadRequest)
+			return
+		}
+		order = parsedOrder
+	}
+
+	// Optional response time budget, e.g. ?timeout=50ms. When it runs out
+	// the matches verified so far are returned with truncated=true.
+	ctx := r.Context()
+	if timeoutStr := r.URL.Query().Get("timeout"); timeoutStr != "" {
+		timeout, err := time.ParseDuration(timeoutStr)
+		if err != nil || timeout <= 0 {
+			http.Error(w, "Invalid timeout parameter", http.StatusBadRequest)
+			return
+		}
+
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Optional pagination, e.g. ?page=2&page_size=50. Without either
+	// parameter every match is returned as before.
+	paginate := r.URL.Query().Has("page") || r.URL.Query().Has("page_size")
+	page, ok := parsePositiveParam(w, r, "page", 1, 0)
+	if !ok {
+		return
+	}
+	pageSize, ok := parsePositiveParam(w, r, "page_size", services.DefaultPageSize, services.MaxPageSize)
+	if !ok {
+		return
+	}
+
+	viewerID, ok := parseViewer(w, r)
+	if !ok {
+		return
+	}
+
+	// Optional ?group=username collapses users sharing a username into
+	// {username, count, best_rank, best_rating}
+	group := r.URL.Query().Get("group")
+	if group != "" && group != "username" {
+		http.Error(w, "Invalid group parameter", http.StatusBadRequest)
+		return
+	}
+
+	rankOffset, ok := parseRankBase(w, r)
+	if !ok {
+		return
+	}
+
+	include, ok := parseInclude(w, r)
+	if !ok {
+		return
+	}
+
+	results, truncated, version := h.leaderboardService.SearchWithMetadata(ctx, query, order, viewerID, include)
+	if len(results) == 0 && !truncated && h.leaderboardService.Config().EmptySearchNoContent {
+		setViewerCacheHeaders(w, viewerID, h.leaderboardService.Config().SearchCacheTTL)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	rebaseRanks(results, rankOffset)
+
+	response := map[string]interface{}{
+		"query":     query,
+		"truncated": truncated,
+		"version":   version,
+	}
+	// Matching and grouping use the real usernames; only output is masked
+	if group == "username" {
+		groups := services.GroupByUsername(results)
+		for i := range groups {
+			groups[i].Username = h.leaderboardService.MaskUsername(groups[i].Username)
+		}
+		response["group"] = group
+		meta := setPage(response, groups, paginate, page, pageSize)
+		setLinkHeader(w, r, meta)
+	} else {
+		h.leaderboardService.MaskEntries(results)
+		meta := setPage(response, results, paginate, page, pageSize)
+		if boundary, ok := services.PageTieBoundary(results, meta); ok {
+			response["boundary"] = boundary
+		}
+		setLinkHeader(w, r, meta)
+	}
+
+	setViewerCacheHeaders(w, viewerID, h.leaderboardService.Config().SearchCacheTTL)
+
+	h.writeEncoded(w, r, response)
+}
+
+// setPage stores items, or the requested page of them with its metadata,
+// as the response's data and count. The metadata is returned too; it is
+// zero without paginate.
+func setPage[T any](response map[string]interface{}, items []T, paginate bool, page, pageSize int) (meta services.Page) {
+	if paginate {
+		items, meta = services.Paginate(items, page, pageSize)
+		response["page"] = meta.Page
+		response["page_size"] = meta.PageSize
+		response["total_results"] = meta.TotalResults
+		response["total_pages"] = meta.TotalPages
+	}
+	response["data"] = items
+	response["count"] = len(items)
+	return meta
+}
+
+// setLinkHeader adds RFC 8288 Link relations to a paginated response so
+// generic HTTP clients can walk the pages: first and last always, prev and
+// next when those pages exist. A page past the end links back to the last.
+// The URLs are the request's own (base path included) with only page
+// changed. Nothing is added to unpaginated responses (zero meta).
+func setLinkHeader(w http.ResponseWriter, r *http.Request, meta services.Page) {
+	if meta.Page == 0 {
+		return
+	}
+
+	link := linkTo(r, "page")
+	last := max(meta.TotalPages, 1)
+	links := []string{link(1, "first")}
+	if meta.Page > 1 {
+		links = append(links, link(min(meta.Page-1, last), "prev"))
+	}
+	if meta.Page < last {
+		links = append(links, link(meta.Page+1, "next"))
+	}
+	links = append(links, link(last, "last"))
+
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
+
+// setOffsetLinkHeader is setLinkHeader for responses paged by offset
+// rather than page number: the links step offset by limit through total
+// entries, and the last one starts the final full or partial page.
+func setOffsetLinkHeader(w http.ResponseWriter, r *http.Request, offset, limit, total int) {
+	limit = max(limit, 1)
+	link := linkTo(r, "offset")
+	last := max(total-1, 0) / limit * limit
+
+	links := []string{link(0, "first")}
+	if offset > 0 {
+		links = append(links, link(min(max(offset-limit, 0), last), "prev"))
+	}
+	if offset < total-limit {
+		links = append(links, link(offset+limit, "next"))
+	}
+	links = append(links, link(last, "last"))
+
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
+
+// linkTo returns a formatter for Link relations to the request's own URL
+// (base path included) with only param changed.
+func linkTo(r *http.Request, param string) func(value int, rel string) string {
+	// RequestURI keeps the base path that routing stripped from URL.Path
+	u, err := url.ParseRequestURI(r.RequestURI)
+	if err != nil {
+		u = r.URL
+	}
+	return func(value int, rel string) string {
+		query := u.Query()
+		query.Set(param, strconv.Itoa(value))
+		return fmt.Sprintf("<%s?%s>; rel=%q", u.Path, query.Encode(), rel)
+	}
+}
+
+// parseOffset reads the optional ?offset= parameter: how many entries to
+// skip, zero when absent. Offsets past the end, however large, are valid
+// and give an empty page.
+func parseOffset(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("offset")
+	if value == "" {
+		return 0, true
+	}
+
+	offset, err := strconv.Atoi(value)
+	if err != nil || offset < 0 {
+		http.Error(w, "Invalid offset parameter", http.StatusBadRequest)
+		return 0, false
+	}
+	return offset, true
+}
+
+// SearchSummary reports how many users matching the query fall in each
+// rank bucket, without listing them.
+func (h *Handler) SearchSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, ok := parseQueryParam(w, r)
+	if !ok {
+		return
+	}
+
+	summary := h.leaderboardService.SearchRankSummary(query)
+
+	setCacheHeaders(w, h.leaderboardService.Config().SearchCacheTTL)
+
+	h.writeEncoded(w, r, summary)
+}
+
+// Suggest returns "did you mean" usernames closest to the query, for use
+// when a search comes back empty.
+func (h *Handler) Suggest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, ok := parseQueryParam(w, r)
+	if !ok {
+		return
+	}
+
+	k, ok := parsePositiveParam(w, r, "k", services.DefaultSuggestions, services.MaxSuggestions)
+	if !ok {
+		return
+	}
+
+	suggestions := h.leaderboardService.Suggest(query, k)
+	for i := range suggestions {
+		suggestions[i].Username = h.leaderboardService.MaskUsername(suggestions[i].Username)
+	}
+
+	setCacheHeaders(w, h.leaderboardService.Config().SearchCacheTTL)
+
+	h.writeEncoded(w, r, map[string]interface{}{
+		"data":  suggestions,
+		"count": len(suggestions),
+		"query": query,
+	})
+}
+
+// parseQueryParam reads the required search query, rejecting bytes that
+// are not valid UTF-8 before they reach case folding and gram generation.
+func parseQueryParam(w http.ResponseWriter, r *http.Request) (string, bool) {
 	query := r.URL.Query().Get("query")
 	if query == "" {
 		http.Error(w, "Query parameter is required", http.StatusBadRequest)
+		return "", false
+	}
+	if !utf8.ValidString(query) {
+		http.Error(w, "Query parameter must be valid UTF-8", http.StatusBadRequest)
+		return "", false
+	}
+	return query, true
+}
+
+// parsePositiveParam reads an optional positive integer query parameter,
+// writing a 400 and returning false if it is malformed or above max
+// (when max > 0).
+func parsePositiveParam(w http.ResponseWriter, r *http.Request, name string, fallback, max int) (int, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, true
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 || (max > 0 && parsed > max) {
+		http.Error(w, fmt.Sprintf("Invalid %s parameter", name), http.StatusBadRequest)
+		return 0, false
+	}
+	return parsed, true
+}
+
+func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	results := h.leaderboardService.Search(query)
+	stats := h.leaderboardService.GetStats()
 
-	// Add cache headers (shorter TTL for search since results change)
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Cache-Control", "public, max-age=1, s-maxage=1")
-	w.Header().Set("CDN-Cache-Control", "max-age=1")
+	h.writeJSON(w, r, stats)
+}
 
-	if err := json.NewEncoder(w).Encode(map[string]interface{}{
-		"data":  results,
-		"count": len(results),
-		"query": query,
-	}); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+func (h *Handler) GetTierDistribution(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	distribution := h.leaderboardService.GetTierDistribution()
+
+	h.writeJSON(w, r, distribution)
+}
+
+// GetUsernameLengths reports the username length histogram and the n-grams
+// it implies, for predicting search index memory.
+func (h *Handler) GetUsernameLengths(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+
+	h.writeJSON(w, r, h.leaderboardService.UsernameLengths())
 }
 
-func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+// GetIndexByLength reports the distinct grams and postings of each gram
+// length in the search index, for tuning which lengths to index.
+func (h *Handler) GetIndexByLength(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	stats := h.leaderboardService.GetStats()
+	h.writeJSON(w, r, map[string]interface{}{
+		"data": h.leaderboardService.IndexByLength(),
+	})
+}
+
+// MaxNearRankRadius bounds the radius of one /leaderboard/near request.
+const MaxNearRankRadius = 500
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(stats); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+// GetUsersNearRank returns the users ranked nearest a target rank, e.g.
+// /leaderboard/near?rank=500&radius=10 for ranks 490 to 510. rank is in
+// the requested rank_base.
+func (h *Handler) GetUsersNearRank(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rankOffset, ok := parseRankBase(w, r)
+	if !ok {
+		return
+	}
+
+	target, err := strconv.Atoi(r.URL.Query().Get("rank"))
+	if err != nil || target+rankOffset < 1 {
+		http.Error(w, "Invalid rank parameter", http.StatusBadRequest)
+		return
+	}
+
+	radius, ok := parsePositiveParam(w, r, "radius", 10, MaxNearRankRadius)
+	if !ok {
 		return
 	}
+
+	entries, truncated := h.leaderboardService.GetUsersNearRank(target+rankOffset, radius)
+	rebaseRanks(entries, rankOffset)
+	h.leaderboardService.MaskEntries(entries)
+
+	setCacheHeaders(w, h.leaderboardService.Config().LeaderboardCacheTTL)
+
+	h.writeEncoded(w, r, map[string]interface{}{
+		"rank":      target,
+		"radius":    radius,
+		"data":      entries,
+		"count":     len(entries),
+		"truncated": truncated,
+	})
+}
+
+// GetRatingLadder lists every occupied rating level, highest first, with
+// its user count and rank.
+func (h *Handler) GetRatingLadder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rankOffset, ok := parseRankBase(w, r)
+	if !ok {
+		return
+	}
+
+	ladder := h.leaderboardService.GetRatingLadder()
+	for i := range ladder {
+		ladder[i].Rank -= rankOffset
+	}
+
+	setCacheHeaders(w, h.leaderboardService.Config().LeaderboardCacheTTL)
+
+	h.writeEncoded(w, r, map[string]interface{}{
+		"levels": ladder,
+		"count":  len(ladder),
+	})
 }
 
+// GetTierLeaderboard serves /leaderboard/tier?name=Diamond&limit=N: the
+// top of one rating tier, with global ranks. limit follows the client's
+// tier like /leaderboard.
+func (h *Handler) GetTierLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "Missing name parameter", http.StatusBadRequest)
+		return
+	}
+
+	limit, ok := h.parseLimit(w, r)
+	if !ok {
+		return
+	}
+
+	rankOffset, ok := parseRankBase(w, r)
+	if !ok {
+		return
+	}
+
+	tier, entries, err := h.leaderboardService.GetTierLeaderboard(name, limit)
+	if errors.Is(err, services.ErrUnknownTier) {
+		http.Error(w, fmt.Sprintf("Unknown tier %q", name), http.StatusBadRequest)
+		return
+	}
+	rebaseRanks(entries, rankOffset)
+	h.leaderboardService.MaskEntries(entries)
+
+	setCacheHeaders(w, h.leaderboardService.Config().LeaderboardCacheTTL)
+
+	h.writeEncoded(w, r, map[string]interface{}{
+		"tier":  tier,
+		"data":  entries,
+		"count": len(entries),
+	})
+}
+
+// GetRatingCount reports how many users have a rating in [min, max], e.g.
+// /stats/count?min=3000&max=4000. Either bound may be omitted to leave
+// that side open.
+func (h *Handler) GetRatingCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	minRating, ok := parsePositiveParam(w, r, "min", services.MinRating, services.MaxRating)
+	if !ok {
+		return
+	}
+	maxRating, ok := parsePositiveParam(w, r, "max", services.MaxRating, services.MaxRating)
+	if !ok {
+		return
+	}
+	if minRating < services.MinRating || maxRating < services.MinRating {
+		http.Error(w, fmt.Sprintf("Ratings must be between %d and %d", services.MinRating, services.MaxRating), http.StatusBadRequest)
+		return
+	}
+	if minRating > maxRating {
+		http.Error(w, "min must not exceed max", http.StatusBadRequest)
+		return
+	}
+
+	h.writeJSON(w, r, map[string]int{
+		"min":   minRating,
+		"max":   maxRating,
+		"count": h.leaderboardService.CountInRange(minRating, maxRating),
+	})
+}
+
+// WouldBeTop reports whether ?rating=N would beat the current #1, for
+// "beat the top score" prompts.
+func (h *Handler) WouldBeTop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rating, err := strconv.Atoi(r.URL.Query().Get("rating"))
+	if err != nil || rating < services.MinRating || rating > services.MaxRating {
+		http.Error(w, fmt.Sprintf("rating must be between %d and %d", services.MinRating, services.MaxRating), http.StatusBadRequest)
+		return
+	}
+
+	setCacheHeaders(w, h.leaderboardService.Config().LeaderboardCacheTTL)
+
+	h.writeJSON(w, r, h.leaderboardService.WouldBeTop(rating))
+}
+
+// HealthCheck reports that the process is up, and with "ready" whether the
+// first snapshot has been published (see services.Config.AsyncInit).
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
+	h.writeJSON(w, r, map[string]interface{}{
 		"status": "healthy",
+		"ready":  h.leaderboardService.Ready(),
+	})
+}
+
+// warmingExempt are the paths served before the service is ready.
+var warmingExempt = map[string]bool{
+	"/health": true,
+}
+
+// RequireReady answers 503 until the first snapshot is published, rather
+// than serving an empty leaderboard while an asynchronous startup loads.
+func (h *Handler) RequireReady(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !warmingExempt[r.URL.Path] && !h.leaderboardService.Ready() {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Service warming up", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,2946 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"matiks-backend/models"
+	"matiks-backend/msgpack"
+	"matiks-backend/services"
+	"matiks-backend/websocket"
+)
+
+// newTestHandler creates a handler backed by a service without the update
+// simulator, stopped automatically when the test ends.
+func newTestHandler(t testing.TB, configure func(*services.Config)) *Handler {
+	t.Helper()
+
+	config := services.DefaultConfig()
+	config.DisableSimulator = true
+	if configure != nil {
+		configure(&config)
+	}
+
+	service := services.NewLeaderboardServiceWithConfig(config)
+	t.Cleanup(service.Stop)
+
+	return NewHandler(service)
+}
+
+// =============================================================================
+// ADMIN TESTS
+// =============================================================================
+
+func TestSelfBench(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.AdminToken = "secret"
+	})
+	endpoint := handler.RequireAdmin(handler.SelfBench)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/selfbench?op=search&n=500", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+
+	endpoint(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	for _, field := range []string{"p50_us", "p90_us", "p95_us", "p99_us", "min_us", "max_us", "mean_us"} {
+		if _, ok := result[field].(float64); !ok {
+			t.Errorf("Missing or non-numeric field %q in %v", field, result)
+		}
+	}
+
+	if result["iterations"].(float64) != 500 {
+		t.Errorf("Expected 500 iterations, got %v", result["iterations"])
+	}
+
+	if result["p50_us"].(float64) > result["p99_us"].(float64) {
+		t.Errorf("p50 (%v) should not exceed p99 (%v)", result["p50_us"], result["p99_us"])
+	}
+}
+
+func TestSelfBench_RequiresAdmin(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.AdminToken = "secret"
+	})
+	endpoint := handler.RequireAdmin(handler.SelfBench)
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer nope", http.StatusUnauthorized},
+		{"invalid op", "Bearer secret", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/selfbench?op=bogus", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			endpoint(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestSelfBench_DisabledWithoutToken(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/selfbench", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+
+	handler.RequireAdmin(handler.SelfBench)(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 when admin API is disabled, got %d", rec.Code)
+	}
+}
+
+func TestAdminConfig_ReflectsConstructionAndRedactsSecrets(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.AdminToken = "secret"
+		c.TopNCacheSize = 250
+	})
+	endpoint := handler.RequireAdmin(handler.Config)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+
+	endpoint(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var config map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&config); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if config["top_n_cache_size"] != float64(250) {
+		t.Errorf("Expected top_n_cache_size 250, got %v", config["top_n_cache_size"])
+	}
+	if config["simulator_enabled"] != false {
+		t.Errorf("Expected simulator_enabled false, got %v", config["simulator_enabled"])
+	}
+	if config["max_rating"] != float64(services.MaxRating) {
+		t.Errorf("Expected max_rating %d, got %v", services.MaxRating, config["max_rating"])
+	}
+	if strings.Contains(rec.Body.String(), "secret") {
+		t.Errorf("Admin token leaked in config: %s", rec.Body.String())
+	}
+}
+
+func TestAdminRebuild_PublishesSnapshot(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) { c.AdminToken = "secret" })
+	before := handler.leaderboardService.GetSnapshot().Version
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/rebuild", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+
+	handler.RequireAdmin(handler.Rebuild)(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Version uint64 `json:"snapshot_version"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Version <= before || resp.Version != handler.leaderboardService.GetSnapshot().Version {
+		t.Errorf("Expected a version after %d, got %d", before, resp.Version)
+	}
+}
+
+// =============================================================================
+// CONTENT NEGOTIATION TESTS
+// =============================================================================
+
+func TestGetLeaderboard_Msgpack(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/leaderboard?limit=5", nil)
+	req.Header.Set("Accept", "application/x-msgpack")
+	rec := httptest.NewRecorder()
+
+	handler.GetLeaderboard(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != msgpack.ContentType {
+		t.Fatalf("Expected Content-Type %q, got %q", msgpack.ContentType, ct)
+	}
+
+	decoded, err := msgpack.Unmarshal(rec.Body.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to decode msgpack body: %v", err)
+	}
+
+	expected := handler.leaderboardService.GetLeaderboard(5)
+	entries, ok := decoded.([]interface{})
+	if !ok || len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %#v", len(expected), decoded)
+	}
+
+	for i, raw := range entries {
+		entry := raw.(map[string]interface{})
+		if entry["username"] != expected[i].Username ||
+			entry["rank"] != int64(expected[i].Rank) ||
+			entry["rating"] != int64(expected[i].Rating) {
+			t.Errorf("Entry %d: got %v, want %+v", i, entry, expected[i])
+		}
+	}
+}
+
+func TestSearch_MsgpackEnvelope(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/search?query=user", nil)
+	req.Header.Set("Accept", "application/x-msgpack")
+	rec := httptest.NewRecorder()
+
+	handler.Search(rec, req)
+
+	decoded, err := msgpack.Unmarshal(rec.Body.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to decode msgpack body: %v", err)
+	}
+
+	envelope := decoded.(map[string]interface{})
+	if envelope["query"] != "user" {
+		t.Errorf("Expected query 'user', got %v", envelope["query"])
+	}
+	if int(envelope["count"].(int64)) != len(envelope["data"].([]interface{})) {
+		t.Errorf("count %v does not match data length", envelope["count"])
+	}
+}
+
+func TestWriteResponses_Msgpack(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.AdminToken = "secret"
+	})
+
+	tests := []struct {
+		name   string
+		handle http.HandlerFunc
+		target string
+		body   string
+		status int
+		key    string
+	}{
+		{"update", handler.SubmitUpdate, "/update", `{"user_id": 1, "rating": 3000}`, http.StatusAccepted, "status"},
+		{"batch", handler.SubmitBatch, "/update/batch", `[{"user_id": 2, "rating": 3000}]`, http.StatusOK, "applied"},
+		{"invalid batch", handler.SubmitBatch, "/update/batch", `[{"user_id": 3, "rating": 99999}]`, http.StatusUnprocessableEntity, "errors"},
+		{"import", handler.RequireAdmin(handler.Import), "/admin/import", `[{"id": 1, "username": "alice", "rating": 3000}]`, http.StatusOK, "imported"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+		req.Header.Set("Accept", msgpack.ContentType)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+
+		tt.handle(rec, req)
+
+		if rec.Code != tt.status {
+			t.Fatalf("%s: expected %d, got %d: %s", tt.name, tt.status, rec.Code, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != msgpack.ContentType {
+			t.Errorf("%s: expected Content-Type %q, got %q", tt.name, msgpack.ContentType, ct)
+		}
+		decoded, err := msgpack.Unmarshal(rec.Body.Bytes())
+		if err != nil {
+			t.Fatalf("%s: failed to decode msgpack body: %v", tt.name, err)
+		}
+		if _, ok := decoded.(map[string]interface{})[tt.key]; !ok {
+			t.Errorf("%s: expected %q in %v", tt.name, tt.key, decoded)
+		}
+	}
+}
+
+func TestGetLeaderboard_DefaultsToJSON(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	for _, accept := range []string{"", "*/*", "text/html, application/json;q=0.9"} {
+		req := httptest.NewRequest(http.MethodGet, "/leaderboard?limit=3", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+
+		handler.GetLeaderboard(rec, req)
+
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Accept %q: expected JSON, got %q", accept, ct)
+		}
+
+		var entries []map[string]interface{}
+		if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil || len(entries) != 3 {
+			t.Errorf("Accept %q: failed to decode 3 JSON entries: %v", accept, err)
+		}
+	}
+}
+
+// =============================================================================
+// CACHE HEADER TESTS
+// =============================================================================
+
+func TestCacheHeaders_ConfiguredTTL(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.LeaderboardCacheTTL = 5 * time.Second
+		c.SearchCacheTTL = 1500 * time.Millisecond
+	})
+
+	rec := httptest.NewRecorder()
+	handler.GetLeaderboard(rec, httptest.NewRequest(http.MethodGet, "/leaderboard", nil))
+
+	if got := rec.Header().Get("Cache-Control"); got != "public, max-age=5, s-maxage=5" {
+		t.Errorf("Leaderboard Cache-Control: got %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.Search(rec, httptest.NewRequest(http.MethodGet, "/search?query=user", nil))
+
+	// Sub-second remainders round up to the next whole second
+	if got := rec.Header().Get("Cache-Control"); got != "public, max-age=2, s-maxage=2" {
+		t.Errorf("Search Cache-Control: got %q", got)
+	}
+}
+
+func TestCacheHeaders_ZeroTTLDisablesCaching(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.LeaderboardCacheTTL = 0
+	})
+
+	rec := httptest.NewRecorder()
+	handler.GetLeaderboard(rec, httptest.NewRequest(http.MethodGet, "/leaderboard", nil))
+
+	if got := rec.Header().Get("Cache-Control"); got != "no-cache" {
+		t.Errorf("Expected no-cache, got %q", got)
+	}
+	if got := rec.Header().Get("CDN-Cache-Control"); got != "" {
+		t.Errorf("Expected no CDN-Cache-Control header, got %q", got)
+	}
+}
+
+// =============================================================================
+// SEARCH PAGINATION TESTS
+// =============================================================================
+
+func TestSearch_Paginated(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	seeds := make([]models.UserSeed, 250)
+	for i := range seeds {
+		seeds[i] = models.UserSeed{ID: i + 1, Username: "player_" + strconv.Itoa(i+1), Rating: 100 + i}
+	}
+	if err := handler.leaderboardService.ReplaceAll(seeds); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	type pageResponse struct {
+		Data         []models.LeaderboardEntry `json:"data"`
+		Page         int                       `json:"page"`
+		PageSize     int                       `json:"page_size"`
+		TotalResults int                       `json:"total_results"`
+		TotalPages   int                       `json:"total_pages"`
+	}
+
+	fetch := func(page int) pageResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/search?query=player&page_size=100&page="+strconv.Itoa(page), nil)
+		rec := httptest.NewRecorder()
+		handler.Search(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Page %d: expected 200, got %d: %s", page, rec.Code, rec.Body.String())
+		}
+
+		var resp pageResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	seen := make(map[string]bool)
+	for page, wantLen := range map[int]int{1: 100, 2: 100, 3: 50} {
+		resp := fetch(page)
+
+		if resp.TotalResults != 250 || resp.TotalPages != 3 {
+			t.Errorf("Page %d: expected 250 results over 3 pages, got %d over %d", page, resp.TotalResults, resp.TotalPages)
+		}
+		if resp.Page != page || resp.PageSize != 100 {
+			t.Errorf("Page %d: unexpected page metadata %d/%d", page, resp.Page, resp.PageSize)
+		}
+		if len(resp.Data) != wantLen {
+			t.Errorf("Page %d: expected %d entries, got %d", page, wantLen, len(resp.Data))
+		}
+
+		for _, entry := range resp.Data {
+			if seen[entry.Username] {
+				t.Errorf("Page %d: %s already returned on another page", page, entry.Username)
+			}
+			seen[entry.Username] = true
+		}
+	}
+
+	if len(seen) != 250 {
+		t.Errorf("Expected pages to cover all 250 users, got %d", len(seen))
+	}
+
+	if resp := fetch(4); len(resp.Data) != 0 || resp.TotalPages != 3 {
+		t.Errorf("Expected empty page past the end, got %d entries", len(resp.Data))
+	}
+}
+
+func TestGetLeaderboard_OffsetLinkHeader(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	seeds := make([]models.UserSeed, 250)
+	for i := range seeds {
+		seeds[i] = models.UserSeed{ID: i + 1, Username: "player_" + strconv.Itoa(i+1), Rating: 100 + i}
+	}
+	if err := handler.leaderboardService.ReplaceAll(seeds); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	get := func(target string) ([]models.LeaderboardEntry, string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.GetLeaderboard(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var entries []models.LeaderboardEntry
+		if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return entries, rec.Header().Get("Link")
+	}
+
+	// A middle page holds ranks 101-200 and links both ways, keeping the
+	// other parameters
+	entries, link := get("/leaderboard?limit=100&offset=100")
+	if len(entries) != 100 || entries[0].Rank != 101 || entries[99].Rank != 200 {
+		t.Fatalf("Expected ranks 101-200, got %d entries", len(entries))
+	}
+	want := `</leaderboard?limit=100&offset=0>; rel="first", ` +
+		`</leaderboard?limit=100&offset=0>; rel="prev", ` +
+		`</leaderboard?limit=100&offset=200>; rel="next", ` +
+		`</leaderboard?limit=100&offset=200>; rel="last"`
+	if link != want {
+		t.Errorf("Expected Link:\n%s\ngot:\n%s", want, link)
+	}
+
+	// The ends only link inwards; the last page is short
+	if _, link := get("/leaderboard?limit=100&offset=0"); strings.Contains(link, `rel="prev"`) || !strings.Contains(link, `offset=100>; rel="next"`) {
+		t.Errorf("Expected no prev link on the first page, got %s", link)
+	}
+	entries, link = get("/leaderboard?limit=100&offset=200")
+	if len(entries) != 50 || strings.Contains(link, `rel="next"`) || !strings.Contains(link, `offset=100>; rel="prev"`) {
+		t.Errorf("Expected 50 entries and no next link on the last page, got %d: %s", len(entries), link)
+	}
+
+	// The total counts only visible entries: hiding one user moves the
+	// last page's start
+	if err := handler.leaderboardService.Exclude(250); err != nil {
+		t.Fatalf("Exclude failed: %v", err)
+	}
+	if _, link := get("/leaderboard?limit=83&offset=83"); !strings.Contains(link, `offset=166>; rel="last"`) {
+		t.Errorf("Expected the last page at offset 166 of 249 entries, got %s", link)
+	}
+	if _, link := get("/leaderboard?limit=83&offset=83&min_rating=200"); !strings.Contains(link, `offset=83>; rel="last"`) || strings.Contains(link, `rel="next"`) {
+		t.Errorf("Expected 149 entries rated 200+ to end on this page, got %s", link)
+	}
+
+	// The base path is kept
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/leaderboard?limit=100&offset=100", nil)
+	req.URL.Path = "/leaderboard" // as stripped by the router
+	rec := httptest.NewRecorder()
+	handler.GetLeaderboard(rec, req)
+	if got := rec.Header().Get("Link"); !strings.Contains(got, `</api/v1/leaderboard?limit=100&offset=0>; rel="prev"`) {
+		t.Errorf("Expected links under the base path, got %s", got)
+	}
+
+	// Unpaged leaderboards have no links; paginated searches link by page
+	if _, link := get("/leaderboard?limit=100"); link != "" {
+		t.Errorf("Expected no Link header without an offset, got %s", link)
+	}
+	rec = httptest.NewRecorder()
+	handler.Search(rec, httptest.NewRequest(http.MethodGet, "/search?query=player&page_size=100&page=2", nil))
+	if got := rec.Header().Get("Link"); !strings.Contains(got, `</search?page=3&page_size=100&query=player>; rel="next"`) {
+		t.Errorf("Expected search pages to link by page number, got %s", got)
+	}
+}
+
+func TestGetLeaderboard_InvalidOffset(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	for _, offset := range []string{"-1", "abc"} {
+		rec := httptest.NewRecorder()
+		handler.GetLeaderboard(rec, httptest.NewRequest(http.MethodGet, "/leaderboard?offset="+offset, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for offset=%s, got %d", offset, rec.Code)
+		}
+	}
+}
+
+func TestGetLeaderboard_HugeOffset(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	for _, target := range []string{
+		"/leaderboard?offset=9223372036854775800&limit=100",
+		"/leaderboard?offset=9223372036854775807&limit=all",
+		"/leaderboard?offset=9223372036854775800&limit=100&min_rating=4600",
+	} {
+		rec := httptest.NewRecorder()
+		handler.GetLeaderboard(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200 for %s, got %d: %s", target, rec.Code, rec.Body.String())
+		}
+
+		var entries []models.LeaderboardEntry
+		if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil {
+			t.Fatalf("Failed to decode %s: %v", target, err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("Expected an empty page for %s, got %d entries", target, len(entries))
+		}
+		if link := rec.Header().Get("Link"); strings.Contains(link, `rel="next"`) {
+			t.Errorf("Expected no next link past the end, got %s", link)
+		}
+	}
+}
+
+func TestSearch_PaginatedTieAcrossPages(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	// 5 users at rank 1, 12 tied at rank 2, 8 at rank 3: with 10 per page
+	// the rank 2 group starts on page 1 and ends on page 2
+	var seeds []models.UserSeed
+	for _, group := range []struct{ size, rating int }{{5, 4000}, {12, 3000}, {8, 2000}} {
+		for i := 0; i < group.size; i++ {
+			id := len(seeds) + 1
+			seeds = append(seeds, models.UserSeed{ID: id, Username: "tied_" + strconv.Itoa(id), Rating: group.rating})
+		}
+	}
+	if err := handler.leaderboardService.ReplaceAll(seeds); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	type pageResponse struct {
+		Data     []models.LeaderboardEntry `json:"data"`
+		Boundary *services.TieBoundary     `json:"boundary"`
+	}
+
+	wantBoundaries := map[int]services.TieBoundary{
+		1: {Rank: 2, TieSize: 12, Later: 7},
+		2: {Rank: 3, TieSize: 8, Later: 5},
+		3: {Rank: 3, TieSize: 8, Later: 0},
+	}
+
+	seen := make(map[string]bool)
+	for page := 1; page <= 3; page++ {
+		req := httptest.NewRequest(http.MethodGet, "/search?query=tied&page_size=10&page="+strconv.Itoa(page), nil)
+		rec := httptest.NewRecorder()
+		handler.Search(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Page %d: expected 200, got %d: %s", page, rec.Code, rec.Body.String())
+		}
+
+		var resp pageResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+
+		for _, entry := range resp.Data {
+			if seen[entry.Username] {
+				t.Errorf("Page %d: %s already returned on another page", page, entry.Username)
+			}
+			seen[entry.Username] = true
+		}
+		if resp.Boundary == nil || *resp.Boundary != wantBoundaries[page] {
+			t.Errorf("Page %d: expected boundary %+v, got %+v", page, wantBoundaries[page], resp.Boundary)
+		}
+	}
+
+	if len(seen) != len(seeds) {
+		t.Errorf("Expected pages to cover all %d users, got %d", len(seeds), len(seen))
+	}
+}
+
+func TestSearch_InvalidPageParameters(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	for _, params := range []string{"page=0", "page=-1", "page=x", "page_size=0", "page_size=100000"} {
+		req := httptest.NewRequest(http.MethodGet, "/search?query=rahul&"+params, nil)
+		rec := httptest.NewRecorder()
+		handler.Search(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", params, rec.Code)
+		}
+	}
+}
+
+func TestSearch_FieldDirectionSort(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	tests := []struct {
+		sort string
+		code int
+	}{
+		{"rating:desc", http.StatusOK},
+		{"username:asc,rating:desc", http.StatusOK},
+		{"score:desc", http.StatusBadRequest},
+		{"rating:sideways", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/search?query=ra&sort="+tt.sort, nil)
+		rec := httptest.NewRecorder()
+		handler.Search(rec, req)
+
+		if rec.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d", tt.sort, tt.code, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/search?query=ra&sort=rating:asc", nil)
+	rec := httptest.NewRecorder()
+	handler.Search(rec, req)
+
+	var resp struct {
+		Data []models.LeaderboardEntry `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	for i := 1; i < len(resp.Data); i++ {
+		if resp.Data[i-1].Rating > resp.Data[i].Rating {
+			t.Fatalf("Results not in ascending rating order at %d: %v", i, resp.Data[i-1:i+1])
+		}
+	}
+}
+
+// FuzzSearchParams feeds arbitrary values to every /search parameter: each
+// request must be answered with 200 or 400, never a panic or a 500.
+func FuzzSearchParams(f *testing.F) {
+	f.Add("rahul", "rating:desc,username:asc", "2", "50", "username", "50ms", "0")
+	f.Add("ra", "relevance", "", "", "", "", "")
+	f.Add("a", "rank:sideways", "-1", "0", "user", "-5s", "2")
+	f.Add("日本", "rank:asc,rank:desc", "9223372036854775807", "1000", "", "1h", "1")
+	f.Add("\xff\xfe", ",", "1e9", "abc", "username", "1", "x")
+	f.Add("r", "", "4611686018427387905", "2", "", "", "") // (page-1)*page_size overflows
+
+	handler := newTestHandler(f, nil)
+
+	f.Fuzz(func(t *testing.T, query, sort, page, pageSize, group, timeout, rankBase string) {
+		params := url.Values{"query": {query}}
+		for name, value := range map[string]string{
+			"sort": sort, "page": page, "page_size": pageSize,
+			"group": group, "timeout": timeout, "rank_base": rankBase,
+		} {
+			if value != "" {
+				params.Set(name, value)
+			}
+		}
+
+		rec := httptest.NewRecorder()
+		handler.Search(rec, httptest.NewRequest(http.MethodGet, "/search?"+params.Encode(), nil))
+		if rec.Code != http.StatusOK && rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 200 or 400, got %d: %s", params.Encode(), rec.Code, rec.Body.String())
+		}
+	})
+}
+
+func TestSearch_GroupByUsername(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	err := handler.leaderboardService.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "neha", Rating: 3000},
+		{ID: 2, Username: "neha", Rating: 4000},
+		{ID: 3, Username: "neha_s", Rating: 3500},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/search?query=neha&group=username", nil)
+	rec := httptest.NewRecorder()
+	handler.Search(rec, req)
+
+	var resp struct {
+		Data  []services.UsernameGroup `json:"data"`
+		Count int                      `json:"count"`
+		Group string                   `json:"group"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	want := []services.UsernameGroup{
+		{Username: "neha", Count: 2, BestRank: 1, BestRating: 4000},
+		{Username: "neha_s", Count: 1, BestRank: 2, BestRating: 3500},
+	}
+	if resp.Group != "username" || resp.Count != 2 || len(resp.Data) != 2 || resp.Data[0] != want[0] || resp.Data[1] != want[1] {
+		t.Errorf("Expected groups %+v, got %+v", want, resp)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/search?query=neha&group=rating", nil)
+	rec = httptest.NewRecorder()
+	handler.Search(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown group, got %d", rec.Code)
+	}
+}
+
+// =============================================================================
+// UPDATE TESTS
+// =============================================================================
+
+func TestSubmitUpdate_RateLimited(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.UserUpdateRate = 1
+		c.UserUpdateBurst = 2
+	})
+
+	submit := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/update", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.SubmitUpdate(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := submit(`{"user_id": 5, "rating": 3000}`); rec.Code != http.StatusAccepted {
+			t.Fatalf("Update %d: expected 202, got %d: %s", i, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := submit(`{"user_id": 5, "rating": 3001}`)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 once the burst is spent, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header on 429")
+	}
+
+	if rec := submit(`{"user_id": 6, "rating": 3000}`); rec.Code != http.StatusAccepted {
+		t.Errorf("Expected another user's update to be accepted, got %d", rec.Code)
+	}
+
+	if rec := submit(`{"user_id": 6, "rating": 99999}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for out of range rating, got %d", rec.Code)
+	}
+}
+
+func TestSubmitUpdate_IdempotencyKey(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	submit := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/update", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, "match-42")
+		rec := httptest.NewRecorder()
+		handler.SubmitUpdate(rec, req)
+		return rec
+	}
+
+	first := submit(`{"user_id": 5, "rating": 3000}`)
+	if first.Code != http.StatusAccepted || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("First submission: got %d replayed=%q", first.Code, first.Header().Get("Idempotent-Replayed"))
+	}
+
+	retry := submit(`{"user_id": 5, "rating": 3000}`)
+	if retry.Code != http.StatusAccepted || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Retry: expected replayed 202, got %d replayed=%q", retry.Code, retry.Header().Get("Idempotent-Replayed"))
+	}
+
+	if conflict := submit(`{"user_id": 5, "rating": 3100}`); conflict.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for key reuse with a different update, got %d", conflict.Code)
+	}
+}
+
+func TestSubmitDelta_RetryAppliesOnce(t *testing.T) {
+	handler := newTestHandler(t, nil)
+	service := handler.leaderboardService
+	start := service.GetSnapshot().GetUserRating(5)
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/update/delta", strings.NewReader(`{"user_id": 5, "delta": -20}`))
+		req.Header.Set(IdempotencyKeyHeader, "match-7-user-5")
+		rec := httptest.NewRecorder()
+		handler.SubmitDelta(rec, req)
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("Submission %d: expected 202, got %d", i+1, rec.Code)
+		}
+	}
+
+	service.Stop()
+
+	if got, want := service.GetSnapshot().GetUserRating(5), max(start-20, services.MinRating); got != want {
+		t.Errorf("Expected rating %d after one delta, got %d", want, got)
+	}
+}
+
+// =============================================================================
+// BULK VALIDATION TESTS
+// =============================================================================
+
+func TestImport_ReportsAllInvalidRows(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.AdminToken = "secret"
+	})
+	endpoint := handler.RequireAdmin(handler.Import)
+
+	body := `[
+		{"id": 1, "username": "alice", "rating": 3000},
+		{"id": 2, "username": "", "rating": 3000},
+		{"id": 3, "username": "carol", "rating": 99999},
+		{"id": 1, "username": "dave", "rating": 3000},
+		{"id": 5, "username": "erin", "rating": 2000}
+	]`
+
+	importUsers := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/import"+query, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		endpoint(rec, req)
+		return rec
+	}
+
+	rec := importUsers("")
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var failed struct {
+		Errors []services.ValidationError `json:"errors"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&failed); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	wantFields := map[int]string{1: "username", 2: "rating", 3: "id"}
+	if len(failed.Errors) != len(wantFields) {
+		t.Fatalf("Expected %d errors, got %v", len(wantFields), failed.Errors)
+	}
+	for _, e := range failed.Errors {
+		if wantFields[e.Index] != e.Field || e.Message == "" {
+			t.Errorf("Unexpected error %+v", e)
+		}
+	}
+
+	if total := handler.leaderboardService.GetSnapshot().TotalUsers(); total != services.InitialUsers {
+		t.Errorf("Rejected import must not replace the population, got %d users", total)
+	}
+
+	rec = importUsers("?partial=true")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for partial import, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var partial struct {
+		Imported int                        `json:"imported"`
+		Rejected []services.ValidationError `json:"rejected"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&partial); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if partial.Imported != 2 || len(partial.Rejected) != 3 {
+		t.Errorf("Expected 2 imported and 3 rejected, got %+v", partial)
+	}
+	if total := handler.leaderboardService.GetSnapshot().TotalUsers(); total != 2 {
+		t.Errorf("Expected 2 users after partial import, got %d", total)
+	}
+}
+
+func TestImport_DuplicatePolicies(t *testing.T) {
+	body := `[
+		{"id": 1, "username": "alice", "rating": 3000},
+		{"id": 2, "username": "bob", "rating": 2000},
+		{"id": 1, "username": "alice", "rating": 4000},
+		{"id": 1, "username": "alice", "rating": 3500}
+	]`
+
+	tests := []struct {
+		policy     string
+		wantStatus int
+		wantRating int // user 1 after import
+	}{
+		{"reject", http.StatusUnprocessableEntity, 0},
+		{"keep-last", http.StatusOK, 3500},
+		{"keep-highest", http.StatusOK, 4000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			handler := newTestHandler(t, func(c *services.Config) {
+				c.AdminToken = "secret"
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/import?duplicates="+tt.policy, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer secret")
+			rec := httptest.NewRecorder()
+			handler.RequireAdmin(handler.Import)(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				var failed struct {
+					Errors []services.ValidationError `json:"errors"`
+				}
+				json.NewDecoder(rec.Body).Decode(&failed)
+				if len(failed.Errors) != 2 || failed.Errors[0].Index != 2 || failed.Errors[1].Index != 3 {
+					t.Errorf("Expected duplicate errors on rows 2 and 3, got %v", failed.Errors)
+				}
+				return
+			}
+
+			var resp struct {
+				Imported   int `json:"imported"`
+				Duplicates struct {
+					Policy   string `json:"policy"`
+					Resolved int    `json:"resolved"`
+				} `json:"duplicates"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if resp.Imported != 2 || resp.Duplicates.Policy != tt.policy || resp.Duplicates.Resolved != 2 {
+				t.Errorf("Unexpected response %+v", resp)
+			}
+			if got := handler.leaderboardService.GetSnapshot().GetUserRating(1); got != tt.wantRating {
+				t.Errorf("Expected user 1 rating %d, got %d", tt.wantRating, got)
+			}
+		})
+	}
+}
+
+func TestSubmitBatch_ReportsAllInvalidRows(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	body := `[
+		{"user_id": 1, "rating": 3000},
+		{"user_id": -4, "rating": 3000},
+		{"user_id": 2, "rating": 0},
+		{"user_id": 3, "rating": 3100}
+	]`
+
+	req := httptest.NewRequest(http.MethodPost, "/update/batch", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.SubmitBatch(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/update/batch?partial=true", strings.NewReader(body))
+	rec = httptest.NewRecorder()
+	handler.SubmitBatch(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for partial batch, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	snap := handler.leaderboardService.GetSnapshot()
+	if snap.GetUserRating(1) != 3000 || snap.GetUserRating(3) != 3100 {
+		t.Errorf("Expected valid rows applied, got ratings %d and %d", snap.GetUserRating(1), snap.GetUserRating(3))
+	}
+}
+
+func TestSubmitBatch_RateLimitedLikeSingleUpdates(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.UserUpdateRate = 0.01
+		c.UserUpdateBurst = 1
+	})
+
+	post := func(handle http.HandlerFunc, target, body string) int {
+		rec := httptest.NewRecorder()
+		handle(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+		return rec.Code
+	}
+
+	if code := post(handler.SubmitUpdate, "/update", `{"user_id": 1, "rating": 3000}`); code != http.StatusAccepted {
+		t.Fatalf("Expected the first update accepted, got %d", code)
+	}
+	if code := post(handler.SubmitUpdate, "/update", `{"user_id": 1, "rating": 3100}`); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected user 1 throttled, got %d", code)
+	}
+
+	// Wrapping the update in a batch does not get around the limit, and the
+	// other user's token is not spent on the rejected batch
+	if code := post(handler.SubmitBatch, "/update/batch", `[{"user_id": 2, "rating": 3200}, {"user_id": 1, "rating": 3100}]`); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the batch throttled, got %d", code)
+	}
+	if code := post(handler.SubmitBatch, "/update/batch", `[{"user_id": 2, "rating": 3200}]`); code != http.StatusOK {
+		t.Errorf("Expected a batch for user 2 applied, got %d", code)
+	}
+	if got := handler.leaderboardService.GetSnapshot().GetUserRating(2); got != 3200 {
+		t.Errorf("Expected user 2 at 3200, got %d", got)
+	}
+}
+
+// =============================================================================
+// ENCODING FAILURE TESTS
+// =============================================================================
+
+// disconnectingWriter accepts the first budget bytes and then fails every
+// write, like a connection whose client went away mid-response.
+type disconnectingWriter struct {
+	header   http.Header
+	budget   int
+	statuses []int
+	body     int
+}
+
+func (d *disconnectingWriter) Header() http.Header { return d.header }
+
+func (d *disconnectingWriter) WriteHeader(status int) {
+	d.statuses = append(d.statuses, status)
+}
+
+func (d *disconnectingWriter) Write(p []byte) (int, error) {
+	if len(d.statuses) == 0 {
+		d.WriteHeader(http.StatusOK)
+	}
+	if d.body+len(p) > d.budget {
+		n := d.budget - d.body
+		d.body = d.budget
+		return n, errors.New("connection reset by peer")
+	}
+	d.body += len(p)
+	return len(p), nil
+}
+
+func TestGetLeaderboard_ClientDisconnectMidResponse(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	w := &disconnectingWriter{header: make(http.Header), budget: 512}
+	req := httptest.NewRequest(http.MethodGet, "/leaderboard?limit=5000", nil)
+
+	handler.GetLeaderboard(w, req) // must not panic
+
+	if len(w.statuses) != 1 || w.statuses[0] != http.StatusOK {
+		t.Errorf("Expected only the original 200 status, got %v", w.statuses)
+	}
+	if w.header.Get("Content-Type") != "application/json" {
+		t.Errorf("Headers must not be rewritten for an error, got Content-Type %q", w.header.Get("Content-Type"))
+	}
+}
+
+// =============================================================================
+// DEBUG ENDPOINT TESTS
+// =============================================================================
+
+func TestDebugMux_Enabled(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.AdminToken = "secret"
+		c.DebugAddr = "localhost:0"
+	})
+	mux := handler.DebugMux()
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/pprof/heap", "/debug/gc"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d", path, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without admin token, got %d", rec.Code)
+	}
+}
+
+func TestDebugMux_DisabledByDefault(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.AdminToken = "secret"
+	})
+	mux := handler.DebugMux()
+
+	for _, path := range []string{"/debug/pprof/", "/debug/gc"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404 when disabled, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestDebugRatings_Membership(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.AdminToken = "secret"
+		c.DebugAddr = "localhost:0"
+	})
+	if err := handler.leaderboardService.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "alice", Rating: 4005},
+		{ID: 2, Username: "bob", Rating: 4010},
+		{ID: 3, Username: "carol", Rating: 4005},
+		{ID: 4, Username: "dave", Rating: 4011},
+		{ID: 5, Username: "erin", Rating: 3999},
+	}); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+	mux := handler.DebugMux()
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/debug/ratings?"+query, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("min=4000&max=4010")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Buckets []struct {
+			Rating int `json:"rating"`
+			Count  int `json:"count"`
+			Users  []struct {
+				ID int `json:"id"`
+			} `json:"users"`
+		} `json:"buckets"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	got := map[int][]int{}
+	var order []int
+	for _, bucket := range resp.Buckets {
+		order = append(order, bucket.Rating)
+		for _, user := range bucket.Users {
+			got[bucket.Rating] = append(got[bucket.Rating], user.ID)
+		}
+		if bucket.Count != len(bucket.Users) {
+			t.Errorf("Rating %d: count %d but %d users listed", bucket.Rating, bucket.Count, len(bucket.Users))
+		}
+	}
+	if !slices.Equal(order, []int{4010, 4005}) {
+		t.Errorf("Expected buckets 4010 and 4005, highest first, got %v", order)
+	}
+	if !slices.Equal(got[4010], []int{2}) || !slices.Equal(got[4005], []int{1, 3}) {
+		t.Errorf("Unexpected membership %v", got)
+	}
+
+	for _, query := range []string{"min=4010&max=4000", "min=100&max=5000", "max=4010"} {
+		if rec := get(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+// =============================================================================
+// INCLUDE SELF TESTS
+// =============================================================================
+
+func TestSearch_IncludeSelf(t *testing.T) {
+	handler := newTestHandler(t, nil)
+	service := handler.leaderboardService
+
+	err := service.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "hidden_player", Rating: 3000},
+		{ID: 2, Username: "visible_player", Rating: 2000},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+	if err := service.Exclude(1); err != nil {
+		t.Fatalf("Exclude failed: %v", err)
+	}
+
+	search := func(query, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/search?query=player"+query, nil)
+		if userID != "" {
+			req.Header.Set(UserIDHeader, userID)
+		}
+		rec := httptest.NewRecorder()
+		handler.Search(rec, req)
+		return rec
+	}
+
+	var resp struct {
+		Data []models.LeaderboardEntry `json:"data"`
+	}
+
+	rec := search("&include_self=true", "1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if len(resp.Data) != 2 || resp.Data[0].Username != "hidden_player" || !resp.Data[0].IsSelf {
+		t.Errorf("Expected own excluded entry first and marked, got %v", resp.Data)
+	}
+	if cc := rec.Header().Get("Cache-Control"); !strings.Contains(cc, "private") {
+		t.Errorf("Personalised response must not be publicly cached, got %q", cc)
+	}
+
+	rec = search("", "1")
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if len(resp.Data) != 1 || resp.Data[0].Username != "visible_player" {
+		t.Errorf("Expected excluded user hidden without include_self, got %v", resp.Data)
+	}
+
+	if rec := search("&include_self=true", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without an authenticated user, got %d", rec.Code)
+	}
+}
+
+func TestGetLeaderboard_MaxStalenessHeader(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	for _, tt := range []struct {
+		header string
+		want   int
+	}{
+		{"", http.StatusOK},
+		{"500", http.StatusOK},
+		{"0", http.StatusOK},
+		{"-1", http.StatusBadRequest},
+		{"soon", http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/leaderboard?limit=5", nil)
+		if tt.header != "" {
+			req.Header.Set(MaxStalenessHeader, tt.header)
+		}
+		rec := httptest.NewRecorder()
+
+		handler.GetLeaderboard(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s %q: expected %d, got %d", MaxStalenessHeader, tt.header, tt.want, rec.Code)
+		}
+	}
+}
+
+// =============================================================================
+// STREAM TESTS
+// =============================================================================
+
+func TestStreamLeaderboard_RejectsBeyondCap(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.MaxSubscribers = 1
+	})
+	subscribers := func() interface{} {
+		return handler.leaderboardService.GetStats()["subscribers"]
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := httptest.NewRequest(http.MethodGet, "/leaderboard/stream?limit=3", nil).WithContext(ctx)
+		handler.StreamLeaderboard(first, req)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for subscribers() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("First stream never subscribed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	rejected := httptest.NewRecorder()
+	handler.StreamLeaderboard(rejected, httptest.NewRequest(http.MethodGet, "/leaderboard/stream", nil))
+	if rejected.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 beyond the subscriber cap, got %d", rejected.Code)
+	}
+
+	// An abrupt disconnect frees the slot
+	cancel()
+	<-done
+	if got := subscribers(); got != 0 {
+		t.Errorf("Expected 0 subscribers after disconnect, got %v", got)
+	}
+
+	if ct := first.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %q", ct)
+	}
+	if !strings.HasPrefix(first.Body.String(), "event: leaderboard\ndata: [") {
+		t.Errorf("Expected an initial leaderboard event, got %q", first.Body.String())
+	}
+}
+
+func TestStreamLeaderboardDeltas_KeyframeThenDelta(t *testing.T) {
+	handler := newTestHandler(t, nil)
+	if err := handler.leaderboardService.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "alice", Rating: 4900},
+		{ID: 2, Username: "bob", Rating: 4800},
+		{ID: 3, Username: "carol", Rating: 4700},
+	}); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler.StreamLeaderboardDeltas))
+	defer server.Close()
+
+	conn, err := websocket.Dial("ws" + strings.TrimPrefix(server.URL, "http") + "?limit=3")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	readFrame := func() services.LeaderboardDelta {
+		t.Helper()
+		opcode, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage failed: %v", err)
+		}
+		if opcode != websocket.BinaryMessage {
+			t.Fatalf("Expected a binary frame, got opcode %d", opcode)
+		}
+		var delta services.LeaderboardDelta
+		if err := delta.UnmarshalBinary(data); err != nil {
+			t.Fatalf("Failed to decode frame: %v", err)
+		}
+		return delta
+	}
+
+	keyframe := readFrame()
+	if !keyframe.Full || len(keyframe.Changed) != 3 || keyframe.Changed[0].Username != "alice" {
+		t.Fatalf("Expected a keyframe with the top 3, got %+v", keyframe)
+	}
+
+	// carol overtakes everyone
+	if err := handler.leaderboardService.ApplyBatch([]services.RatingUpdate{{UserID: 3, NewRating: 5000}}); err != nil {
+		t.Fatalf("ApplyBatch failed: %v", err)
+	}
+
+	delta := readFrame()
+	if delta.Full || delta.Version <= keyframe.Version {
+		t.Fatalf("Expected a delta after version %d, got %+v", keyframe.Version, delta)
+	}
+
+	// Every rank moved, so all three entries changed; applying them to the
+	// keyframe reconstructs the new leaderboard
+	board := make(map[int]services.DeltaEntry)
+	for _, entry := range keyframe.Changed {
+		board[entry.ID] = entry
+	}
+	for _, entry := range delta.Changed {
+		board[entry.ID] = entry
+	}
+	for _, userID := range delta.Removed {
+		delete(board, userID)
+	}
+	want := map[int]int{3: 1, 1: 2, 2: 3}
+	for userID, rank := range want {
+		if board[userID].Rank != rank {
+			t.Errorf("Expected user %d at rank %d, got %+v", userID, rank, board[userID])
+		}
+	}
+}
+
+// =============================================================================
+// MULTI-BOARD TESTS
+// =============================================================================
+
+func TestMultiLeaderboard_TwoBoardsInOneCall(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	blitz := services.NewLeaderboardServiceWithConfig(services.Config{DisableSimulator: true})
+	t.Cleanup(blitz.Stop)
+	if err := blitz.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "magnus", Rating: 4900},
+		{ID: 2, Username: "hikaru", Rating: 4800},
+	}); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+	handler.RegisterBoard("blitz", blitz)
+
+	body := `{"boards": ["global", "blitz", "bullet"], "limit": 5}`
+	req := httptest.NewRequest(http.MethodPost, "/leaderboard/multi", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handler.MultiLeaderboard(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Boards map[string]struct {
+			Data  []models.LeaderboardEntry `json:"data"`
+			Count int                       `json:"count"`
+			Error string                    `json:"error"`
+		} `json:"boards"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if global := resp.Boards["global"]; global.Count != 5 || len(global.Data) != 5 {
+		t.Errorf("Expected 5 global entries, got %+v", global)
+	}
+	if got := resp.Boards["blitz"].Data; len(got) != 2 || got[0].Username != "magnus" || got[1].Rank != 2 {
+		t.Errorf("Expected the blitz board's own ranking, got %+v", got)
+	}
+	if resp.Boards["bullet"].Error == "" {
+		t.Errorf("Expected an error entry for the unknown board, got %+v", resp.Boards["bullet"])
+	}
+}
+
+func TestMultiLeaderboard_ClientTierLimits(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.APIKeys = map[string]string{"premium-key": "premium"}
+	})
+
+	counts := func(limit int, apiKey string) int {
+		t.Helper()
+		body := `{"boards": ["global"], "limit": ` + strconv.Itoa(limit) + `}`
+		req := httptest.NewRequest(http.MethodPost, "/leaderboard/multi", strings.NewReader(body))
+		if apiKey != "" {
+			req.Header.Set(APIKeyHeader, apiKey)
+		}
+		rec := httptest.NewRecorder()
+		handler.MultiLeaderboard(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+
+		var resp struct {
+			Boards map[string]struct {
+				Count int `json:"count"`
+			} `json:"boards"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp.Boards["global"].Count
+	}
+
+	if got := counts(10000, ""); got != 1000 {
+		t.Errorf("Expected a free client clamped to 1000 entries, got %d", got)
+	}
+	if got := counts(5000, "premium-key"); got != 5000 {
+		t.Errorf("Expected a premium client to get 5000 entries, got %d", got)
+	}
+	if got := counts(0, ""); got != 100 {
+		t.Errorf("Expected the tier's default limit of 100, got %d", got)
+	}
+}
+
+// =============================================================================
+// RANK BASE TESTS
+// =============================================================================
+
+func TestRankBase_AppliedToEveryEndpoint(t *testing.T) {
+	handler := newTestHandler(t, nil)
+	if err := handler.leaderboardService.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "alice", Rating: 4900},
+		{ID: 2, Username: "alicia", Rating: 4800},
+		{ID: 3, Username: "bob", Rating: 4700},
+	}); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	ranks := func(target string, serve http.HandlerFunc) []int {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		serve(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", target, rec.Code, rec.Body.String())
+		}
+
+		// /leaderboard is a bare array, the others an object with data
+		// (search) or a single rank (profile, rank by username)
+		var resp struct {
+			Rank int                       `json:"rank"`
+			Data []models.LeaderboardEntry `json:"data"`
+		}
+		body := rec.Body.Bytes()
+		if strings.HasPrefix(string(body), "[") {
+			body = []byte(`{"data":` + string(body) + `}`)
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", target, err)
+		}
+		if resp.Data == nil {
+			return []int{resp.Rank}
+		}
+		got := make([]int, len(resp.Data))
+		for i, entry := range resp.Data {
+			got[i] = entry.Rank
+		}
+		return got
+	}
+
+	tests := []struct {
+		name  string
+		path  string
+		serve http.HandlerFunc
+		want  []int
+	}{
+		{"leaderboard", "/leaderboard?limit=3", handler.GetLeaderboard, []int{1, 2, 3}},
+		{"search", "/search?query=ali", handler.Search, []int{1, 2}},
+		{"profile", "/users/3", handler.UserRoutes, []int{3}},
+		{"rank by username", "/rank?username=BOB", handler.GetRankByUsername, []int{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ranks(tt.path, tt.serve); !slices.Equal(got, tt.want) {
+				t.Errorf("Default ranks: expected %v, got %v", tt.want, got)
+			}
+
+			want := make([]int, len(tt.want))
+			for i, rank := range tt.want {
+				want[i] = rank - 1
+			}
+			sep := "?"
+			if strings.Contains(tt.path, "?") {
+				sep = "&"
+			}
+			if got := ranks(tt.path+sep+"rank_base=0", tt.serve); !slices.Equal(got, want) {
+				t.Errorf("rank_base=0 ranks: expected %v, got %v", want, got)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	handler.GetLeaderboard(rec, httptest.NewRequest(http.MethodGet, "/leaderboard?rank_base=2", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for rank_base=2, got %d", rec.Code)
+	}
+}
+
+// =============================================================================
+// RATING COUNT TESTS
+// =============================================================================
+
+func TestGetRatingCount_ValidatesRange(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	tests := []struct {
+		query string
+		code  int
+	}{
+		{"min=3000&max=4000", http.StatusOK},
+		{"", http.StatusOK},
+		{"min=4000&max=3000", http.StatusBadRequest},
+		{"min=50", http.StatusBadRequest},
+		{"max=5001", http.StatusBadRequest},
+		{"min=abc", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.GetRatingCount(rec, httptest.NewRequest(http.MethodGet, "/stats/count?"+tt.query, nil))
+		if rec.Code != tt.code {
+			t.Errorf("%q: expected %d, got %d: %s", tt.query, tt.code, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.GetRatingCount(rec, httptest.NewRequest(http.MethodGet, "/stats/count", nil))
+	var resp map[string]int
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if want := handler.leaderboardService.GetSnapshot().TotalUsers(); resp["count"] != want {
+		t.Errorf("Expected the full range to count all %d users, got %d", want, resp["count"])
+	}
+}
+
+// =============================================================================
+// WOULD BE TOP TESTS
+// =============================================================================
+
+func TestWouldBeTop_AtAndBelowTop(t *testing.T) {
+	handler := newTestHandler(t, nil)
+	top, ok := handler.leaderboardService.GetSnapshot().HighestRating()
+	if !ok {
+		t.Fatal("Expected a populated leaderboard")
+	}
+
+	check := func(rating int) services.TopCheck {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.WouldBeTop(rec, httptest.NewRequest(http.MethodGet, "/would-be-top?rating="+strconv.Itoa(rating), nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp services.TopCheck
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	if at := check(top); at.WouldBeTop || at.TopRating == nil || *at.TopRating != top || *at.Margin != 0 {
+		t.Errorf("Expected matching the top rating %d not to beat it, got %+v", top, at)
+	}
+	if below := check(top - 1); below.WouldBeTop || *below.Margin != -1 {
+		t.Errorf("Expected a rating just below the top to miss by 1, got %+v", below)
+	}
+
+	for _, query := range []string{"", "rating=abc", "rating=50", "rating=5001"} {
+		rec := httptest.NewRecorder()
+		handler.WouldBeTop(rec, httptest.NewRequest(http.MethodGet, "/would-be-top?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+// =============================================================================
+// CLIENT TIER TESTS
+// =============================================================================
+
+func TestGetLeaderboard_ClientTierLimits(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.APIKeys = map[string]string{"premium-key": "premium"}
+	})
+
+	count := func(target, apiKey string) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if apiKey != "" {
+			req.Header.Set(APIKeyHeader, apiKey)
+		}
+		rec := httptest.NewRecorder()
+		handler.GetLeaderboard(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+
+		var entries []models.LeaderboardEntry
+		if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return len(entries)
+	}
+
+	// Free-tier clients (no key or an unknown one) are clamped to 1000
+	if got := count("/leaderboard?limit=5000", ""); got != 1000 {
+		t.Errorf("Expected a free client clamped to 1000 entries, got %d", got)
+	}
+	if got := count("/leaderboard?limit=5000", "stolen-key"); got != 1000 {
+		t.Errorf("Expected an unknown key clamped to 1000 entries, got %d", got)
+	}
+
+	if got := count("/leaderboard?limit=5000", "premium-key"); got != 5000 {
+		t.Errorf("Expected a premium client to get 5000 entries, got %d", got)
+	}
+	if got := count("/leaderboard", "premium-key"); got != 100 {
+		t.Errorf("Expected the default limit of 100, got %d", got)
+	}
+}
+
+func TestGetLeaderboard_LimitAll(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.AdminToken = "secret"
+		c.APIKeys = map[string]string{"unlimited-key": "unlimited"}
+		c.ClientTiers = []services.ClientTier{
+			{Name: services.FreeTier, DefaultLimit: 100, MaxLimit: 1000},
+			{Name: "unlimited", DefaultLimit: 100},
+		}
+	})
+
+	seeds := make([]models.UserSeed, 1500)
+	for i := range seeds {
+		seeds[i] = models.UserSeed{ID: i + 1, Username: "player_" + strconv.Itoa(i), Rating: 1000 + i}
+	}
+	if err := handler.leaderboardService.ReplaceAll(seeds); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	get := func(target string, header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.GetLeaderboard(rec, req)
+		return rec
+	}
+	count := func(rec *httptest.ResponseRecorder) int {
+		t.Helper()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var entries []models.LeaderboardEntry
+		if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return len(entries)
+	}
+
+	// Free clients asking for everything get their tier's maximum
+	if got := count(get("/leaderboard?limit=all", "", "")); got != 1000 {
+		t.Errorf("Expected a free client clamped to 1000 entries, got %d", got)
+	}
+
+	// Admins and unlimited tiers get the whole leaderboard
+	if got := count(get("/leaderboard?limit=all", "Authorization", "Bearer secret")); got != 1500 {
+		t.Errorf("Expected an admin to get all 1500 entries, got %d", got)
+	}
+	if got := count(get("/leaderboard?limit=-1", APIKeyHeader, "unlimited-key")); got != 1500 {
+		t.Errorf("Expected an unlimited client to get all 1500 entries, got %d", got)
+	}
+
+	// Only -1 means all; other non-positive limits are still rejected
+	for _, limit := range []string{"0", "-2"} {
+		if rec := get("/leaderboard?limit="+limit, "", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for limit=%s, got %d", limit, rec.Code)
+		}
+	}
+}
+
+// =============================================================================
+// DELTA SYNC TESTS
+// =============================================================================
+
+func TestGetLeaderboardDelta_FullThenIncremental(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	get := func(target string) services.LeaderboardDelta {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.GetLeaderboardDelta(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+
+		var delta services.LeaderboardDelta
+		if err := json.NewDecoder(rec.Body).Decode(&delta); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return delta
+	}
+
+	first := get("/leaderboard/delta?limit=10")
+	if !first.Full || len(first.Changed) != 10 || first.Version == 0 {
+		t.Fatalf("Expected a full first response with a version, got %+v", first)
+	}
+
+	next := get("/leaderboard/delta?limit=10&since=" + strconv.FormatUint(first.Version, 10))
+	if next.Full || len(next.Changed) != 0 || len(next.Removed) != 0 {
+		t.Errorf("Expected no changes without updates, got %+v", next)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.GetLeaderboardDelta(rec, httptest.NewRequest(http.MethodGet, "/leaderboard/delta?since=-1", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid version, got %d", rec.Code)
+	}
+}
+
+// =============================================================================
+// MOVERS TESTS
+// =============================================================================
+
+func TestGetMovers_Validation(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	rec := httptest.NewRecorder()
+	handler.GetMovers(rec, httptest.NewRequest(http.MethodGet, "/leaderboard/movers?window=3", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var movers services.Movers
+	if err := json.NewDecoder(rec.Body).Decode(&movers); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if movers.Climbers == nil || movers.Decliners == nil {
+		t.Errorf("Expected empty lists rather than null, got %+v", movers)
+	}
+
+	for _, target := range []string{
+		"/leaderboard/movers?window=0",
+		"/leaderboard/movers?limit=101",
+		"/leaderboard/movers?limit=abc",
+	} {
+		rec := httptest.NewRecorder()
+		handler.GetMovers(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", target, rec.Code)
+		}
+	}
+}
+
+// =============================================================================
+// STRING ID TESTS
+// =============================================================================
+
+func TestStringIDs_LargeIDRoundTrips(t *testing.T) {
+	const bigID = 1<<60 + 1 // not representable as a float64
+
+	for _, stringIDs := range []bool{false, true} {
+		handler := newTestHandler(t, func(c *services.Config) {
+			c.StringIDs = stringIDs
+		})
+		err := handler.leaderboardService.ReplaceAll([]models.UserSeed{
+			{ID: bigID, Username: "big", Rating: 4000},
+		})
+		if err != nil {
+			t.Fatalf("ReplaceAll failed: %v", err)
+		}
+
+		target := "/users/" + strconv.Itoa(bigID)
+		rec := httptest.NewRecorder()
+		handler.UserRoutes(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+
+		if !stringIDs {
+			if _, ok := body["id"].(float64); !ok {
+				t.Errorf("Expected a numeric id by default, got %#v", body["id"])
+			}
+			continue
+		}
+
+		id, ok := body["id"].(string)
+		if !ok {
+			t.Fatalf("Expected a string id, got %#v", body["id"])
+		}
+		if parsed, err := strconv.Atoi(id); err != nil || parsed != bigID {
+			t.Errorf("Expected id %d to round-trip, got %q", bigID, id)
+		}
+		if body["rating"] != float64(4000) {
+			t.Errorf("Expected other numbers untouched, got rating %#v", body["rating"])
+		}
+	}
+}
+
+func TestStringIDs_QuotesOnlyUserIDFields(t *testing.T) {
+	const bigID = 1<<60 + 1
+
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.StringIDs = true
+	})
+	err := handler.leaderboardService.ReplaceAll([]models.UserSeed{
+		{ID: bigID, Username: "big", Rating: 4000},
+		{ID: 2, Username: "small", Rating: 3000},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	get := func(handle http.HandlerFunc, req *http.Request) string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handle(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", req.URL, rec.Code, rec.Body.String())
+		}
+		return rec.Body.String()
+	}
+	wantIn := func(body string, want ...string) {
+		t.Helper()
+		for _, w := range want {
+			if !strings.Contains(body, w) {
+				t.Errorf("Expected %s in %s", w, body)
+			}
+		}
+	}
+
+	big := strconv.Itoa(bigID)
+	wantIn(get(handler.GetLeaderboardDelta, httptest.NewRequest(http.MethodGet, "/leaderboard/delta", nil)),
+		`"id":"`+big+`"`, `"id":"2"`, `"rank":1`, `"version":`)
+	wantIn(get(handler.GetRankByUsername, httptest.NewRequest(http.MethodGet, "/rank?username=big", nil)),
+		`"id":"`+big+`"`, `"rating":4000`)
+	wantIn(get(handler.GetUsersByNames, httptest.NewRequest(http.MethodPost, "/users/by-names", strings.NewReader(`["big"]`))),
+		`"data":{"big":[{"id":"`+big+`"`, `"count":1`)
+	wantIn(get(handler.UserRoutes, httptest.NewRequest(http.MethodGet, "/users/"+big+"/rank-history", nil)),
+		`"user_id":"`+big+`"`)
+	wantIn(get(handler.DebugRatings, httptest.NewRequest(http.MethodGet, "/debug/ratings?min=3990&max=4000", nil)),
+		`"min":3990`, `"users":[{"id":"`+big+`"`)
+
+	// Entries without user IDs are untouched, and MessagePack keeps
+	// integer IDs
+	leaderboard := get(handler.GetLeaderboard, httptest.NewRequest(http.MethodGet, "/leaderboard", nil))
+	wantIn(leaderboard, `"rank":1,"username":"big","rating":4000`)
+
+	req := httptest.NewRequest(http.MethodGet, "/rank?username=big", nil)
+	req.Header.Set("Accept", msgpack.ContentType)
+	rec := httptest.NewRecorder()
+	handler.GetRankByUsername(rec, req)
+	decoded, err := msgpack.Unmarshal(rec.Body.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to decode msgpack body: %v", err)
+	}
+	if id := decoded.(map[string]interface{})["id"]; id != int64(bigID) && id != uint64(bigID) {
+		t.Errorf("Expected integer ID %d over MessagePack, got %#v", bigID, id)
+	}
+}
+
+// =============================================================================
+// LEADERBOARD ENVELOPE TESTS
+// =============================================================================
+
+func TestGetLeaderboard_EnvelopeHasMore(t *testing.T) {
+	handler := newTestHandler(t, nil)
+	if err := handler.leaderboardService.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "alice", Rating: 4900},
+		{ID: 2, Username: "bob", Rating: 4800},
+		{ID: 3, Username: "carol", Rating: 4700},
+	}); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		wantLen  int
+		wantMore bool
+	}{
+		{"/leaderboard?limit=10&envelope=true", 3, false},
+		{"/leaderboard?limit=2&envelope=true", 2, true},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.GetLeaderboard(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", tt.path, rec.Code, rec.Body.String())
+		}
+
+		var resp struct {
+			Data    []models.LeaderboardEntry `json:"data"`
+			Count   int                       `json:"count"`
+			HasMore bool                      `json:"has_more"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", tt.path, err)
+		}
+		if len(resp.Data) != tt.wantLen || resp.Count != tt.wantLen || resp.HasMore != tt.wantMore {
+			t.Errorf("%s: expected %d entries with has_more=%v, got %+v", tt.path, tt.wantLen, tt.wantMore, resp)
+		}
+	}
+
+	// Without the parameter the response stays a bare array
+	rec := httptest.NewRecorder()
+	handler.GetLeaderboard(rec, httptest.NewRequest(http.MethodGet, "/leaderboard?limit=2", nil))
+	if !strings.HasPrefix(rec.Body.String(), "[") {
+		t.Errorf("Expected a bare array by default, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.GetLeaderboard(rec, httptest.NewRequest(http.MethodGet, "/leaderboard?envelope=maybe", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid envelope flag, got %d", rec.Code)
+	}
+}
+
+// =============================================================================
+// MALFORMED QUERY TESTS
+// =============================================================================
+
+func TestSearch_RejectsInvalidUTF8(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	endpoints := map[string]http.HandlerFunc{
+		"/search":         handler.Search,
+		"/search/summary": handler.SearchSummary,
+		"/suggest":        handler.Suggest,
+	}
+	for path, serve := range endpoints {
+		// %ff%fe decodes to bytes that are not valid UTF-8
+		rec := httptest.NewRecorder()
+		serve(rec, httptest.NewRequest(http.MethodGet, path+"?query=pl%ff%feayer", nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", path, rec.Code, rec.Body.String())
+		}
+		if !strings.Contains(rec.Body.String(), "UTF-8") {
+			t.Errorf("%s: expected a UTF-8 error message, got %q", path, rec.Body.String())
+		}
+	}
+
+	// Valid multi-byte queries are still accepted
+	rec := httptest.NewRecorder()
+	handler.Search(rec, httptest.NewRequest(http.MethodGet, "/search?query=%C3%A9", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 for a valid UTF-8 query, got %d", rec.Code)
+	}
+}
+
+// =============================================================================
+// SEARCH VERSION TESTS
+// =============================================================================
+
+func TestSearch_EmptyResults(t *testing.T) {
+	for _, noContent := range []bool{false, true} {
+		handler := newTestHandler(t, func(c *services.Config) {
+			c.EmptySearchNoContent = noContent
+		})
+
+		rec := httptest.NewRecorder()
+		handler.Search(rec, httptest.NewRequest(http.MethodGet, "/search?query=zzzzzz", nil))
+
+		if noContent {
+			if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+				t.Errorf("Expected an empty 204, got %d: %q", rec.Code, rec.Body.String())
+			}
+			continue
+		}
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			Data  []models.LeaderboardEntry `json:"data"`
+			Count int                       `json:"count"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if resp.Data == nil || len(resp.Data) != 0 || resp.Count != 0 {
+			t.Errorf("Expected data: [] and count 0, got %+v", resp)
+		}
+	}
+
+	// Searches with matches are unaffected
+	handler := newTestHandler(t, func(c *services.Config) { c.EmptySearchNoContent = true })
+	seed := models.UserSeed{ID: 99999999, Username: "zzzzzz", Rating: 1500}
+	if err := handler.leaderboardService.AddUsers([]models.UserSeed{seed}); err != nil {
+		t.Fatalf("AddUsers failed: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	handler.Search(rec, httptest.NewRequest(http.MethodGet, "/search?query=zzzzzz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 for a search with matches, got %d", rec.Code)
+	}
+}
+
+func TestSearch_ReportsSnapshotVersion(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	search := func() uint64 {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.Search(rec, httptest.NewRequest(http.MethodGet, "/search?query=player", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+
+		var resp struct {
+			Version uint64 `json:"version"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp.Version
+	}
+
+	first := search()
+	if want := handler.leaderboardService.GetSnapshot().Version; first == 0 || first != want {
+		t.Fatalf("Expected version %d, got %d", want, first)
+	}
+
+	// AddUsers publishes a new snapshot before returning
+	seed := models.UserSeed{ID: 99999999, Username: "player_new", Rating: 1500}
+	if err := handler.leaderboardService.AddUsers([]models.UserSeed{seed}); err != nil {
+		t.Fatalf("AddUsers failed: %v", err)
+	}
+
+	next := search()
+	if want := handler.leaderboardService.GetSnapshot().Version; next <= first || next != want {
+		t.Errorf("Expected version %d after an update (was %d), got %d", want, first, next)
+	}
+}
+
+// =============================================================================
+// PROFILE CONTEXT TESTS
+// =============================================================================
+
+func TestGetProfileContext(t *testing.T) {
+	handler := newTestHandler(t, nil)
+	if err := handler.leaderboardService.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "alice", Rating: 4900},
+		{ID: 2, Username: "bob", Rating: 4800},
+		{ID: 3, Username: "carol", Rating: 4700},
+		{ID: 4, Username: "dave", Rating: 2000},
+	}); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.UserRoutes(rec, httptest.NewRequest(http.MethodGet, "/users/2/context?radius=1&rank_base=0", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var context services.ProfileContext
+	if err := json.NewDecoder(rec.Body).Decode(&context); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if context.Username != "bob" || context.Rank != 1 || context.Percentile != 50 || context.Tier != "Diamond" {
+		t.Errorf("Expected bob at 0-based rank 1, 50th percentile, Diamond; got %+v", context)
+	}
+	if len(context.Above) != 1 || context.Above[0].Username != "alice" || context.Above[0].Rank != 0 {
+		t.Errorf("Expected alice above at rank 0, got %+v", context.Above)
+	}
+	if len(context.Below) != 1 || context.Below[0].Username != "carol" || context.Below[0].Rank != 2 {
+		t.Errorf("Expected carol below at rank 2, got %+v", context.Below)
+	}
+
+	// Without bob, carol takes his 0-based rank 1
+	rec = httptest.NewRecorder()
+	handler.UserRoutes(rec, httptest.NewRequest(http.MethodGet, "/users/2/context?radius=1&rank_base=0&exclude_self=true", nil))
+	context = services.ProfileContext{}
+	if err := json.NewDecoder(rec.Body).Decode(&context); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if context.Rank != 1 || len(context.Below) != 1 || context.Below[0].Rank != 1 {
+		t.Errorf("Expected carol ranked 1 with bob excluded, got %+v", context)
+	}
+
+	for path, want := range map[string]int{
+		"/users/99/context":                  http.StatusNotFound,
+		"/users/2/context?radius=0":          http.StatusBadRequest,
+		"/users/2/context?radius=500":        http.StatusBadRequest,
+		"/users/2/context?exclude_self=nope": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		handler.UserRoutes(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: expected %d, got %d", path, want, rec.Code)
+		}
+	}
+}
+
+// =============================================================================
+// PERCENTILE TESTS
+// =============================================================================
+
+func TestGetPercentiles(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"user_ids": [1, 2, 99999999]}`)
+	handler.GetPercentiles(rec, httptest.NewRequest(http.MethodPost, "/percentiles", body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Data  map[int]float64 `json:"data"`
+		Count int             `json:"count"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Count != 2 || len(resp.Data) != 2 {
+		t.Fatalf("Expected users 1 and 2 only, got %+v", resp)
+	}
+	for _, userID := range []int{1, 2} {
+		if want, _ := handler.leaderboardService.GetPercentile(userID); resp.Data[userID] != want {
+			t.Errorf("User %d: expected %v, got %v", userID, want, resp.Data[userID])
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handler.GetPercentiles(rec, httptest.NewRequest(http.MethodGet, "/percentiles", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET, got %d", rec.Code)
+	}
+}
+
+// =============================================================================
+// LATENCY STATS TESTS
+// =============================================================================
+
+func TestGetLatencyStats_RecordsRoutes(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/leaderboard", handler.GetLeaderboard)
+	mux.HandleFunc("/search", handler.Search)
+	server := handler.RecordLatency(mux)
+
+	for i := 0; i < 20; i++ {
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/leaderboard?limit=10", nil))
+	}
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/search?query=a", nil))
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unrouted", nil))
+
+	rec := httptest.NewRecorder()
+	handler.GetLatencyStats(rec, httptest.NewRequest(http.MethodGet, "/stats/latency", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	type routeStats struct {
+		Count int     `json:"count"`
+		P50   float64 `json:"p50_ms"`
+		P95   float64 `json:"p95_ms"`
+		P99   float64 `json:"p99_ms"`
+	}
+	var resp struct {
+		Routes map[string]routeStats `json:"routes"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(resp.Routes) != 2 {
+		t.Errorf("Expected only the two routed paths, got %v", resp.Routes)
+	}
+	for route, wantCount := range map[string]int{"/leaderboard": 20, "/search": 1} {
+		stats := resp.Routes[route]
+		if stats.Count != wantCount {
+			t.Errorf("%s: expected %d samples, got %d", route, wantCount, stats.Count)
+		}
+		if stats.P50 <= 0 || stats.P50 > stats.P95 || stats.P95 > stats.P99 {
+			t.Errorf("%s: expected ordered non-zero percentiles, got %+v", route, stats)
+		}
+	}
+}
+
+func TestLatencyTracker_BoundedWindow(t *testing.T) {
+	tracker := newLatencyTracker()
+	now := time.Now()
+
+	tracker.record("/old", now.Add(-2*latencyWindow), time.Millisecond)
+	for i := 0; i < maxLatencySamples+100; i++ {
+		tracker.record("/busy", now, time.Duration(i+1)*time.Microsecond)
+	}
+
+	stats := tracker.stats(now)
+	if _, ok := stats["/old"]; ok {
+		t.Error("Expected samples older than the window to be left out")
+	}
+	busy := stats["/busy"].(map[string]interface{})
+	if busy["count"] != maxLatencySamples {
+		t.Errorf("Expected %d retained samples, got %v", maxLatencySamples, busy["count"])
+	}
+}
+
+// =============================================================================
+// USERNAME MASKING TESTS
+// =============================================================================
+
+func TestMaskUsernames_SearchMatchesRealNames(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.MaskUsernames = true
+	})
+	err := handler.leaderboardService.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "rahul_kumar", Rating: 4500},
+		{ID: 2, Username: "priya", Rating: 4000},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	// "kumar" only appears past the revealed prefix, so a match proves
+	// search used the real name
+	rec := httptest.NewRecorder()
+	handler.Search(rec, httptest.NewRequest(http.MethodGet, "/search?query=kumar", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Data []models.LeaderboardEntry `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Data) != 1 || resp.Data[0].Username != "ra***" || resp.Data[0].Rank != 1 {
+		t.Errorf("Expected one masked match for rahul_kumar, got %+v", resp.Data)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.GetLeaderboard(rec, httptest.NewRequest(http.MethodGet, "/leaderboard?limit=10", nil))
+	var leaderboard []models.LeaderboardEntry
+	if err := json.NewDecoder(rec.Body).Decode(&leaderboard); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(leaderboard) != 2 || leaderboard[0].Username != "ra***" || leaderboard[1].Username != "pr***" {
+		t.Errorf("Expected masked leaderboard usernames, got %+v", leaderboard)
+	}
+
+	// The masking is output only: the service still holds the real names
+	if _, _, err := handler.leaderboardService.GetRankByUsername("rahul_kumar"); err != nil {
+		t.Errorf("Expected rahul_kumar to still be found by name: %v", err)
+	}
+}
+
+// =============================================================================
+// USERNAME AVAILABILITY TESTS
+// =============================================================================
+
+func TestUsernameAvailable(t *testing.T) {
+	for _, unique := range []bool{false, true} {
+		handler := newTestHandler(t, func(c *services.Config) {
+			c.UniqueUsernames = unique
+			c.SeedUsers = []models.UserSeed{{ID: 1, Username: "rahul", Rating: 4000}}
+		})
+
+		check := func(username string, wantAvailable, wantTaken bool) {
+			t.Helper()
+			rec := httptest.NewRecorder()
+			handler.UserRoutes(rec, httptest.NewRequest(http.MethodGet, "/users/available?username="+username, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+
+			var resp struct {
+				Available bool `json:"available"`
+				Taken     bool `json:"taken"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Available != wantAvailable || resp.Taken != wantTaken {
+				t.Errorf("unique=%v, %s: expected available=%v taken=%v, got %+v",
+					unique, username, wantAvailable, wantTaken, resp)
+			}
+		}
+
+		check("rahul", !unique, true)
+		check("priya", true, false)
+	}
+
+	handler := newTestHandler(t, nil)
+	rec := httptest.NewRecorder()
+	handler.UserRoutes(rec, httptest.NewRequest(http.MethodGet, "/users/available", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a username, got %d", rec.Code)
+	}
+}
+
+// =============================================================================
+// NEAR RANK TESTS
+// =============================================================================
+
+func TestGetUsersNearRank(t *testing.T) {
+	handler := newTestHandler(t, nil)
+	if err := handler.leaderboardService.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "alice", Rating: 4900},
+		{ID: 2, Username: "bob", Rating: 4800},
+		{ID: 3, Username: "carol", Rating: 4800},
+		{ID: 4, Username: "dave", Rating: 4700},
+		{ID: 5, Username: "erin", Rating: 4600},
+	}); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	// rank_base=0: rank 1 is the 1-based rank 2, the bob/carol tie
+	rec := httptest.NewRecorder()
+	handler.GetUsersNearRank(rec, httptest.NewRequest(http.MethodGet, "/leaderboard/near?rank=1&radius=1&rank_base=0", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Rank      int                       `json:"rank"`
+		Data      []models.LeaderboardEntry `json:"data"`
+		Count     int                       `json:"count"`
+		Truncated bool                      `json:"truncated"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Rank != 1 || resp.Count != 4 || resp.Truncated {
+		t.Fatalf("Expected 4 users around rank 1, got %+v", resp)
+	}
+	wantRanks := []int{0, 1, 1, 2}
+	for i, entry := range resp.Data {
+		if entry.Rank != wantRanks[i] {
+			t.Errorf("Expected rank %d at %d, got %+v", wantRanks[i], i, entry)
+		}
+	}
+
+	for _, query := range []string{"", "rank=abc", "rank=0", "rank=-1&rank_base=0", "rank=3&radius=0", "rank=3&radius=100000"} {
+		rec := httptest.NewRecorder()
+		handler.GetUsersNearRank(rec, httptest.NewRequest(http.MethodGet, "/leaderboard/near?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %d", query, rec.Code)
+		}
+	}
+}
+
+// =============================================================================
+// RANK RANGES TESTS
+// =============================================================================
+
+func TestGetRankRanges_MultipleRanges(t *testing.T) {
+	handler := newTestHandler(t, nil)
+	if err := handler.leaderboardService.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "alice", Rating: 4900},
+		{ID: 2, Username: "bob", Rating: 4800},
+		{ID: 3, Username: "carol", Rating: 4800},
+		{ID: 4, Username: "dave", Rating: 4700},
+		{ID: 5, Username: "erin", Rating: 4600},
+		{ID: 6, Username: "frank", Rating: 4500},
+	}); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	body := `{"ranges": [{"from": 1, "to": 1}, {"from": 3, "to": 4}, {"from": 2, "to": 2}]}`
+	rec := httptest.NewRecorder()
+	handler.GetRankRanges(rec, httptest.NewRequest(http.MethodPost, "/leaderboard/ranges", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Data      []services.RankRangeEntries `json:"data"`
+		Truncated bool                        `json:"truncated"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Truncated || len(resp.Data) != 3 {
+		t.Fatalf("Expected 3 untruncated ranges, got %+v", resp)
+	}
+
+	// Dense ranks: alice 1, bob and carol 2, dave 3, erin 4, frank 5
+	want := [][]string{{"alice"}, {"dave", "erin"}, {"bob", "carol"}}
+	for i, result := range resp.Data {
+		got := make([]string, len(result.Entries))
+		for j, entry := range result.Entries {
+			got[j] = entry.Username
+			if entry.Rank < result.From || entry.Rank > result.To {
+				t.Errorf("Range %+v: %s has rank %d", result.RankRange, entry.Username, entry.Rank)
+			}
+		}
+		if !slices.Equal(got, want[i]) || result.Count != len(want[i]) {
+			t.Errorf("Range %+v: expected %v, got %v", result.RankRange, want[i], got)
+		}
+	}
+
+	// rank_base=0 shifts both the ranges and the returned ranks
+	rec = httptest.NewRecorder()
+	handler.GetRankRanges(rec, httptest.NewRequest(http.MethodPost, "/leaderboard/ranges?rank_base=0",
+		strings.NewReader(`{"ranges": [{"from": 0, "to": 0}]}`)))
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Data) != 1 || resp.Data[0].From != 0 || resp.Data[0].Count != 1 || resp.Data[0].Entries[0].Rank != 0 {
+		t.Errorf("Expected alice at rank 0, got %+v", resp.Data)
+	}
+
+	for _, body := range []string{
+		`{}`,
+		`{"ranges": [{"from": 0, "to": 5}]}`,
+		`{"ranges": [{"from": 5, "to": 4}]}`,
+		`not json`,
+		`{"ranges": [` + strings.Repeat(`{"from": 1, "to": 1},`, MaxRankRangesPerRequest) + `{"from": 1, "to": 1}]}`,
+	} {
+		rec := httptest.NewRecorder()
+		handler.GetRankRanges(rec, httptest.NewRequest(http.MethodPost, "/leaderboard/ranges", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %.40s, got %d", body, rec.Code)
+		}
+	}
+}
+
+// =============================================================================
+// WARMUP TESTS
+// =============================================================================
+
+func TestRequireReady_WarmingThenReady(t *testing.T) {
+	serve := func(handler *Handler, path string) *httptest.ResponseRecorder {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/leaderboard", handler.GetLeaderboard)
+		mux.HandleFunc("/health", handler.HealthCheck)
+
+		rec := httptest.NewRecorder()
+		handler.RequireReady(mux).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	health := func(rec *httptest.ResponseRecorder) bool {
+		t.Helper()
+		var resp struct {
+			Ready bool `json:"ready"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode health: %v", err)
+		}
+		return resp.Ready
+	}
+
+	// A service that has not published its first snapshot yet
+	warming := NewHandler(new(services.LeaderboardService))
+	if rec := serve(warming, "/leaderboard"); rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 503 with Retry-After while warming, got %d", rec.Code)
+	}
+	if rec := serve(warming, "/health"); rec.Code != http.StatusOK || health(rec) {
+		t.Errorf("Expected /health to answer, not ready, while warming; got %d", rec.Code)
+	}
+
+	handler := newTestHandler(t, func(c *services.Config) { c.AsyncInit = true })
+	deadline := time.Now().Add(5 * time.Second)
+	for !handler.leaderboardService.Ready() {
+		if time.Now().After(deadline) {
+			t.Fatal("Service never became ready")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if rec := serve(handler, "/leaderboard"); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 once ready, got %d", rec.Code)
+	}
+	if rec := serve(handler, "/health"); !health(rec) {
+		t.Error("Expected /health to report ready")
+	}
+}
+
+// =============================================================================
+// USERNAME LENGTH TESTS
+// =============================================================================
+
+func TestGetUsernameLengths(t *testing.T) {
+	handler := newTestHandler(t, nil)
+	if err := handler.leaderboardService.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "ab", Rating: 4000},
+		{ID: 2, Username: "cd", Rating: 4000},
+		{ID: 3, Username: "héllo", Rating: 4000},
+	}); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.GetUsernameLengths(rec, httptest.NewRequest(http.MethodGet, "/stats/username-lengths", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var stats services.UsernameLengthStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// Lengths are in runes, so "héllo" is 5 long
+	want := []services.UsernameLengthCount{{Length: 2, Count: 2}, {Length: 5, Count: 1}}
+	if !slices.Equal(stats.Histogram, want) || stats.Users != 3 {
+		t.Errorf("Expected %v over 3 users, got %+v", want, stats)
+	}
+	if stats.EstimatedGrams != 1+1+10 {
+		t.Errorf("Expected 12 estimated grams, got %d", stats.EstimatedGrams)
+	}
+}
+
+func TestGetIndexByLength(t *testing.T) {
+	handler := newTestHandler(t, nil)
+	if err := handler.leaderboardService.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "ab", Rating: 4000},
+		{ID: 2, Username: "abc", Rating: 4000},
+	}); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.GetIndexByLength(rec, httptest.NewRequest(http.MethodGet, "/stats/index-by-length", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Data []services.GramLengthStats `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	want := []services.GramLengthStats{
+		{Length: 2, Grams: 2, Postings: 3}, // ab twice, bc
+		{Length: 3, Grams: 1, Postings: 1},
+		{Length: 4},
+		{Length: 5},
+	}
+	if !slices.Equal(resp.Data, want) {
+		t.Errorf("Expected %v, got %v", want, resp.Data)
+	}
+}
+
+// =============================================================================
+// USERS BY NAME TESTS
+// =============================================================================
+
+func TestGetUsersByNames(t *testing.T) {
+	handler := newTestHandler(t, nil)
+	if err := handler.leaderboardService.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "alice", Rating: 4900},
+		{ID: 2, Username: "bob", Rating: 4800},
+		{ID: 3, Username: "alice", Rating: 4700},
+	}); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`["alice", "carol", "bob", "Bob"]`)
+	handler.UserRoutes(rec, httptest.NewRequest(http.MethodPost, "/users/by-names?rank_base=0", body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Data      map[string][]services.RankedUser `json:"data"`
+		Count     int                              `json:"count"`
+		Unmatched []string                         `json:"unmatched"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	wantAlice := []services.RankedUser{
+		{ID: 1, Username: "alice", Rating: 4900, Rank: 0},
+		{ID: 3, Username: "alice", Rating: 4700, Rank: 2},
+	}
+	if !slices.Equal(resp.Data["alice"], wantAlice) {
+		t.Errorf("Expected both alices at 0-based ranks, got %+v", resp.Data["alice"])
+	}
+	if len(resp.Data["bob"]) != 1 || resp.Count != 2 {
+		t.Errorf("Expected alice and bob matched, got %+v", resp)
+	}
+	if !slices.Equal(resp.Unmatched, []string{"carol", "Bob"}) {
+		t.Errorf("Expected carol and Bob unmatched, got %v", resp.Unmatched)
+	}
+
+	for _, tt := range []struct {
+		method, body string
+		want         int
+	}{
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+		{http.MethodPost, `{"names": ["alice"]}`, http.StatusBadRequest},
+		{http.MethodPost, "[" + strings.Repeat(`"x",`, services.MaxFilterUsers) + `"x"]`, http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		handler.UserRoutes(rec, httptest.NewRequest(tt.method, "/users/by-names", strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s %.20s: expected %d, got %d", tt.method, tt.body, tt.want, rec.Code)
+		}
+	}
+}
+
+// =============================================================================
+// TIER LEADERBOARD TESTS
+// =============================================================================
+
+func TestGetTierLeaderboard(t *testing.T) {
+	handler := newTestHandler(t, nil)
+	if err := handler.leaderboardService.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "alice", Rating: 4900},
+		{ID: 2, Username: "bob", Rating: 3000},
+		{ID: 3, Username: "carol", Rating: 2600},
+		{ID: 4, Username: "dave", Rating: 2000},
+	}); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.GetTierLeaderboard(rec, httptest.NewRequest(http.MethodGet, "/leaderboard/tier?name=Gold&limit=10", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Tier  services.Tier             `json:"tier"`
+		Data  []models.LeaderboardEntry `json:"data"`
+		Count int                       `json:"count"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// Only the Gold users, ranked behind alice
+	want := []models.LeaderboardEntry{
+		{Rank: 2, Username: "bob", Rating: 3000},
+		{Rank: 3, Username: "carol", Rating: 2600},
+	}
+	if resp.Tier.Name != "Gold" || !slices.Equal(resp.Data, want) || resp.Count != 2 {
+		t.Errorf("Expected %v in Gold, got %+v", want, resp)
+	}
+
+	for target, code := range map[string]int{
+		"/leaderboard/tier":                    http.StatusBadRequest,
+		"/leaderboard/tier?name=Mithril":       http.StatusBadRequest,
+		"/leaderboard/tier?name=Gold&limit=-5": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		handler.GetTierLeaderboard(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != code {
+			t.Errorf("%s: expected %d, got %d", target, code, rec.Code)
+		}
+	}
+}
+
+// =============================================================================
+// METADATA TESTS
+// =============================================================================
+
+func TestMetadata_IncludeParam(t *testing.T) {
+	handler := newTestHandler(t, nil)
+	if err := handler.leaderboardService.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "alice", Rating: 4900, Metadata: &models.UserMetadata{
+			Country: "IN", Avatar: "https://example.com/alice.png", Joined: "2023-11-20",
+		}},
+		{ID: 2, Username: "bob", Rating: 4800},
+	}); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	get := func(target string) []models.LeaderboardEntry {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		if strings.HasPrefix(target, "/search") {
+			handler.Search(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		} else {
+			handler.GetLeaderboard(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		}
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", target, rec.Code, rec.Body.String())
+		}
+
+		if strings.HasPrefix(target, "/search") {
+			var resp struct {
+				Data []models.LeaderboardEntry `json:"data"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			return resp.Data
+		}
+		var entries []models.LeaderboardEntry
+		if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return entries
+	}
+
+	entries := get("/leaderboard?limit=2&include=country,avatar")
+	want := models.UserMetadata{Country: "IN", Avatar: "https://example.com/alice.png"}
+	if len(entries) != 2 || entries[0].Metadata == nil || *entries[0].Metadata != want {
+		t.Fatalf("Expected alice's country and avatar, got %+v", entries)
+	}
+	if entries[1].Metadata != nil {
+		t.Errorf("Expected bob without metadata, got %+v", entries[1].Metadata)
+	}
+
+	if entries := get("/leaderboard?limit=2"); entries[0].Metadata != nil {
+		t.Errorf("Expected no metadata without include, got %+v", entries[0].Metadata)
+	}
+
+	entries = get("/search?query=alice&include=joined")
+	if len(entries) != 1 || entries[0].Metadata == nil || *entries[0].Metadata != (models.UserMetadata{Joined: "2023-11-20"}) {
+		t.Errorf("Expected alice's join date in search, got %+v", entries)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.GetLeaderboard(rec, httptest.NewRequest(http.MethodGet, "/leaderboard?include=email", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown field, got %d", rec.Code)
+	}
+}
+
+// =============================================================================
+// RANK HISTORY TESTS
+// =============================================================================
+
+func TestGetRankHistory(t *testing.T) {
+	handler := newTestHandler(t, nil)
+
+	rec := httptest.NewRecorder()
+	handler.UserRoutes(rec, httptest.NewRequest(http.MethodGet, "/users/1/rank-history?window=3", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		UserID  int                  `json:"user_id"`
+		History []services.RankPoint `json:"history"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.UserID != 1 || len(resp.History) == 0 || len(resp.History) > 3 {
+		t.Fatalf("Expected 1 to 3 points for user 1, got %+v", resp)
+	}
+	if last := resp.History[len(resp.History)-1]; last.Rank == 0 || last.Version != handler.leaderboardService.GetSnapshot().Version {
+		t.Errorf("Expected the last point to be the current rank, got %+v", last)
+	}
+
+	for target, want := range map[string]int{
+		"/users/99999999/rank-history":   http.StatusNotFound,
+		"/users/1/rank-history?window=0": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		handler.UserRoutes(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != want {
+			t.Errorf("%s: expected %d, got %d", target, want, rec.Code)
+		}
+	}
+}
+
+// =============================================================================
+// ADD USERS TESTS
+// =============================================================================
+
+func TestAddUsers_EvictsAtCap(t *testing.T) {
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.AdminToken = "secret"
+		c.MaxUsers = 5
+	})
+	endpoint := handler.RequireAdmin(handler.AddUsers)
+
+	addUsers := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/users", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		endpoint(rec, req)
+		return rec
+	}
+
+	rec := addUsers(`[{"id": 100001, "username": "vikram", "rating": 5000}]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Added      int `json:"added"`
+		TotalUsers int `json:"total_users"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Added != 1 || resp.TotalUsers != 5 {
+		t.Errorf("Expected 1 added with the population held at 5, got %+v", resp)
+	}
+	if top := handler.leaderboardService.GetLeaderboard(1); top[0].Username != "vikram" {
+		t.Errorf("Expected the new user listed first, got %v", top)
+	}
+
+	if rec := addUsers(`[{"id": 100001, "username": "again", "rating": 4000}]`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a taken ID, got %d", rec.Code)
+	}
+}
+
+// =============================================================================
+// RENAME TESTS
+// =============================================================================
+
+func TestRename_BlocklistAndErrors(t *testing.T) {
+	blocklist, err := services.NewUsernameBlocklist([]string{"badword"})
+	if err != nil {
+		t.Fatalf("NewUsernameBlocklist failed: %v", err)
+	}
+	handler := newTestHandler(t, func(c *services.Config) {
+		c.AdminToken = "secret"
+		c.UsernameBlocklist = blocklist
+	})
+	if err := handler.leaderboardService.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "alice", Rating: 4000},
+	}); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+	endpoint := handler.RequireAdmin(handler.Rename)
+
+	rename := func(query, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/admin/rename"+query, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		endpoint(rec, req)
+		return rec.Code
+	}
+
+	tests := []struct {
+		query, body string
+		want        int
+	}{
+		{"?user_id=1", `{"username": "xBadWordx"}`, http.StatusUnprocessableEntity},
+		{"?user_id=1", `{"username": ""}`, http.StatusBadRequest},
+		{"?user_id=x", `{"username": "alicia"}`, http.StatusBadRequest},
+		{"?user_id=2", `{"username": "alicia"}`, http.StatusNotFound},
+		{"?user_id=1", `{"username": "alicia"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := rename(tt.query, tt.body); got != tt.want {
+			t.Errorf("%s %s: expected %d, got %d", tt.query, tt.body, tt.want, got)
+		}
+	}
+
+	if top := handler.leaderboardService.GetLeaderboard(1); top[0].Username != "alicia" {
+		t.Errorf("Expected the leaderboard to show the new name, got %v", top)
+	}
+}
+
+// =============================================================================
+// RATING LADDER TESTS
+// =============================================================================
+
+func TestGetRatingLadder(t *testing.T) {
+	handler := newTestHandler(t, nil)
+	if err := handler.leaderboardService.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "alice", Rating: 4900},
+		{ID: 2, Username: "bob", Rating: 4900},
+		{ID: 3, Username: "carol", Rating: 4700},
+	}); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.GetRatingLadder(rec, httptest.NewRequest(http.MethodGet, "/leaderboard/ladder?rank_base=0", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Levels []services.RatingLevel `json:"levels"`
+		Count  int                    `json:"count"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	want := []services.RatingLevel{{Rating: 4900, Count: 2, Rank: 0}, {Rating: 4700, Count: 1, Rank: 1}}
+	if !slices.Equal(resp.Levels, want) || resp.Count != 2 {
+		t.Errorf("Expected %v, got %+v", want, resp)
+	}
+}
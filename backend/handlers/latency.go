@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// latencyWindow is how far back /stats/latency looks.
+	latencyWindow = time.Minute
+
+	// maxLatencySamples bounds each route's ring of samples. Under heavier
+	// traffic the window effectively shrinks to the latest samples.
+	maxLatencySamples = 1024
+)
+
+// untimedRoutes are left out of latency tracking: their durations are
+// connection lifetimes rather than response times.
+var untimedRoutes = map[string]bool{
+	"/leaderboard/stream": true,
+	"/leaderboard/ws":     true,
+}
+
+type latencySample struct {
+	at       time.Time
+	duration time.Duration
+}
+
+// latencyRing holds a route's most recent samples, overwriting the oldest.
+type latencyRing struct {
+	samples []latencySample
+	next    int
+}
+
+// latencyTracker keeps a bounded ring of response times per route.
+type latencyTracker struct {
+	mu     sync.Mutex
+	routes map[string]*latencyRing
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{routes: make(map[string]*latencyRing)}
+}
+
+func (t *latencyTracker) record(route string, at time.Time, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ring, ok := t.routes[route]
+	if !ok {
+		ring = &latencyRing{samples: make([]latencySample, 0, maxLatencySamples)}
+		t.routes[route] = ring
+	}
+
+	sample := latencySample{at: at, duration: d}
+	if len(ring.samples) < maxLatencySamples {
+		ring.samples = append(ring.samples, sample)
+		return
+	}
+	ring.samples[ring.next] = sample
+	ring.next = (ring.next + 1) % maxLatencySamples
+}
+
+// stats returns the count and p50/p95/p99 of every route with samples
+// newer than now minus latencyWindow, picking percentiles from the sorted
+// samples as loadtest.LatencyMetrics does.
+func (t *latencyTracker) stats(now time.Time) map[string]interface{} {
+	cutoff := now.Add(-latencyWindow)
+
+	t.mu.Lock()
+	windows := make(map[string][]time.Duration, len(t.routes))
+	for route, ring := range t.routes {
+		for _, sample := range ring.samples {
+			if sample.at.After(cutoff) {
+				windows[route] = append(windows[route], sample.duration)
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	routes := make(map[string]interface{}, len(windows))
+	for route, durations := range windows {
+		slices.Sort(durations)
+		count := len(durations)
+		percentile := func(p float64) float64 {
+			return float64(durations[int(float64(count)*p)]) / float64(time.Millisecond)
+		}
+
+		routes[route] = map[string]interface{}{
+			"count":  count,
+			"p50_ms": percentile(0.50),
+			"p95_ms": percentile(0.95),
+			"p99_ms": percentile(0.99),
+		}
+	}
+	return routes
+}
+
+// RecordLatency wraps mux so every response time is recorded under the
+// route pattern that served it, for /stats/latency.
+func (h *Handler) RecordLatency(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := mux.Handler(r)
+		if route == "" || untimedRoutes[route] {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		mux.ServeHTTP(w, r)
+		h.latency.record(route, start, time.Since(start))
+	})
+}
+
+// GetLatencyStats reports rolling per-route latency percentiles over the
+// last minute, as measured by RecordLatency.
+func (h *Handler) GetLatencyStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	h.writeJSON(w, r, map[string]interface{}{
+		"window_seconds": int(latencyWindow.Seconds()),
+		"routes":         h.latency.stats(time.Now()),
+	})
+}
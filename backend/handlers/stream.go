@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"matiks-backend/services"
+	"matiks-backend/websocket"
+)
+
+const (
+	defaultStreamLimit = 10
+	maxStreamLimit     = 100
+
+	// keyframeInterval is how often the WebSocket stream resends the full
+	// leaderboard, bounding how long a client that lost track stays wrong.
+	keyframeInterval = 30
+)
+
+// StreamLeaderboard pushes the top ?limit= entries as a Server-Sent Event
+// on connect and again whenever a new snapshot is published. Subscribers
+// are capped by Config.MaxSubscribers; beyond that the request gets a 503.
+// The slot is released when the client disconnects or the service stops.
+func (h *Handler) StreamLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit, ok := parsePositiveParam(w, r, "limit", defaultStreamLimit, maxStreamLimit)
+	if !ok {
+		return
+	}
+
+	sub, err := h.leaderboardService.Subscribe()
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrTooManySubscribers), errors.Is(err, services.ErrServiceStopped):
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer sub.Close()
+
+	// Events flow for as long as the client stays, so lift the server's
+	// write timeout. Not every ResponseWriter supports deadlines.
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // keep proxies from batching events
+
+	for {
+		entries := h.leaderboardService.GetLeaderboard(limit)
+		h.leaderboardService.MaskEntries(entries)
+		data, err := json.Marshal(entries)
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(w, "event: leaderboard\ndata: %s\n\n", data); err != nil {
+			return // client went away
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case _, open := <-sub.C:
+			if !open {
+				return
+			}
+		}
+	}
+}
+
+// StreamLeaderboardDeltas pushes the top ?limit= entries over a WebSocket
+// as binary frames (see services.LeaderboardDelta.MarshalBinary): a full
+// keyframe on connect and every keyframeInterval frames, and otherwise
+// only what changed since the connection's previous frame. When that
+// frame's version is no longer retained the delta is a keyframe anyway.
+// Subscribers are capped as for StreamLeaderboard.
+func (h *Handler) StreamLeaderboardDeltas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit, ok := parsePositiveParam(w, r, "limit", defaultStreamLimit, maxStreamLimit)
+	if !ok {
+		return
+	}
+
+	sub, err := h.leaderboardService.Subscribe()
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrTooManySubscribers), errors.Is(err, services.ErrServiceStopped):
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer sub.Close()
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		return // Upgrade has replied
+	}
+	defer conn.Close()
+
+	// Clients only send control frames; reading answers their pings and
+	// notices when they leave
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	var sentVersion uint64 // version of the last frame sent
+	for frames := 0; ; frames++ {
+		since := sentVersion
+		if frames%keyframeInterval == 0 {
+			since = 0
+		}
+
+		delta := h.leaderboardService.LeaderboardDelta(since, limit)
+		for i := range delta.Changed {
+			delta.Changed[i].Username = h.leaderboardService.MaskUsername(delta.Changed[i].Username)
+		}
+		data, err := delta.MarshalBinary()
+		if err != nil {
+			return
+		}
+		if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+			return // client went away
+		}
+		sentVersion = delta.Version
+
+		select {
+		case <-gone:
+			return
+		case _, open := <-sub.C:
+			if !open {
+				return
+			}
+		}
+	}
+}
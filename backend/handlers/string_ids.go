@@ -0,0 +1,134 @@
+package handlers
+
+import "matiks-backend/services"
+
+// Under services.Config.StringIDs, JSON responses write user IDs as
+// strings. Each response type carrying a user ID has a twin below whose ID
+// field shadows the embedded one with a ",string" tag; stringIDs swaps a
+// response for its twin once, before encoding, so encoding/json does the
+// quoting. MessagePack responses are never swapped and keep integer IDs.
+
+type userProfileStringID struct {
+	ID int `json:"id,string"`
+	services.UserProfile
+}
+
+type profileContextStringID struct {
+	ID int `json:"id,string"`
+	services.ProfileContext
+}
+
+type rankedUserStringID struct {
+	ID int `json:"id,string"`
+	services.RankedUser
+}
+
+type deltaEntryStringID struct {
+	ID int `json:"id,string"`
+	services.DeltaEntry
+}
+
+type leaderboardDeltaStringID struct {
+	Changed []deltaEntryStringID `json:"changed"`
+	services.LeaderboardDelta
+}
+
+type moverStringID struct {
+	ID int `json:"id,string"`
+	services.Mover
+}
+
+type moversStringID struct {
+	Climbers  []moverStringID `json:"climbers"`
+	Decliners []moverStringID `json:"decliners"`
+	services.Movers
+}
+
+type usersByNamesStringID struct {
+	Data map[string][]rankedUserStringID `json:"data"`
+	usersByNamesResponse
+}
+
+type rankHistoryStringID struct {
+	UserID int `json:"user_id,string"`
+	rankHistoryResponse
+}
+
+type exclusionStringID struct {
+	UserID int `json:"user_id,string"`
+	exclusionResponse
+}
+
+type renameStringID struct {
+	UserID int `json:"user_id,string"`
+	renameResponse
+}
+
+type debugUserStringID struct {
+	ID int `json:"id,string"`
+	debugUser
+}
+
+type debugBucketStringID struct {
+	Users []debugUserStringID `json:"users"`
+	debugBucket
+}
+
+type debugRatingsStringID struct {
+	Buckets []debugBucketStringID `json:"buckets"`
+	debugRatingsResponse
+}
+
+// stringIDs returns the twin of v with quoted user IDs, or v itself if its
+// type carries none.
+func stringIDs(v interface{}) interface{} {
+	switch v := v.(type) {
+	case services.UserProfile:
+		return userProfileStringID{v.ID, v}
+	case services.ProfileContext:
+		return profileContextStringID{v.ID, v}
+	case services.RankedUser:
+		return rankedUserStringID{v.ID, v}
+	case services.LeaderboardDelta:
+		return leaderboardDeltaStringID{quoteEach(v.Changed, func(e services.DeltaEntry) deltaEntryStringID {
+			return deltaEntryStringID{e.ID, e}
+		}), v}
+	case services.Movers:
+		quote := func(m services.Mover) moverStringID { return moverStringID{m.ID, m} }
+		return moversStringID{quoteEach(v.Climbers, quote), quoteEach(v.Decliners, quote), v}
+	case usersByNamesResponse:
+		data := make(map[string][]rankedUserStringID, len(v.Data))
+		for name, users := range v.Data {
+			data[name] = quoteEach(users, func(u services.RankedUser) rankedUserStringID {
+				return rankedUserStringID{u.ID, u}
+			})
+		}
+		return usersByNamesStringID{data, v}
+	case rankHistoryResponse:
+		return rankHistoryStringID{v.UserID, v}
+	case exclusionResponse:
+		return exclusionStringID{v.UserID, v}
+	case renameResponse:
+		return renameStringID{v.UserID, v}
+	case debugRatingsResponse:
+		return debugRatingsStringID{quoteEach(v.Buckets, func(b debugBucket) debugBucketStringID {
+			return debugBucketStringID{quoteEach(b.Users, func(u debugUser) debugUserStringID {
+				return debugUserStringID{u.ID, u}
+			}), b}
+		}), v}
+	}
+	return v
+}
+
+// quoteEach maps items to their twins, keeping nil as nil so the JSON is
+// unchanged apart from the IDs.
+func quoteEach[T, Q any](items []T, quote func(T) Q) []Q {
+	if items == nil {
+		return nil
+	}
+	quoted := make([]Q, len(items))
+	for i, item := range items {
+		quoted[i] = quote(item)
+	}
+	return quoted
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"matiks-backend/services"
+)
+
+type updateRequest struct {
+	UserID int    `json:"user_id"`
+	Rating int    `json:"rating"`
+	Seq    uint64 `json:"seq,omitempty"`
+}
+
+// IdempotencyKeyHeader lets clients retry an update safely: a repeat with
+// the same key returns the original result instead of applying it twice.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// SubmitUpdate queues an absolute rating change. The update is applied by
+// the snapshot writer, so success is reported as 202 Accepted. An optional
+// "seq" orders racing updates for the same user: older sequences are dropped.
+// Retries carrying the same Idempotency-Key are answered without re-queueing.
+func (h *Handler) SubmitUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req updateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	update := services.RatingUpdate{UserID: req.UserID, NewRating: req.Rating, Seq: req.Seq}
+	h.submitIdempotent(w, r, update)
+}
+
+// SubmitDelta queues a relative rating change, e.g. {"user_id": 42,
+// "delta": -25}. The writer adds the delta to whatever rating the user has
+// when it is applied and clamps the result to the rating range, so
+// concurrent deltas for one user never overwrite each other.
+func (h *Handler) SubmitDelta(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		UserID int `json:"user_id"`
+		Delta  int `json:"delta"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	update := services.RatingUpdate{UserID: req.UserID, Relative: true, Delta: req.Delta}
+	h.submitIdempotent(w, r, update)
+}
+
+// submitIdempotent queues update under the request's Idempotency-Key, if
+// any, and writes the 202 or the matching error status. Keys are essential
+// for deltas: a blind retry of an applied delta would apply it twice.
+func (h *Handler) submitIdempotent(w http.ResponseWriter, r *http.Request, update services.RatingUpdate) {
+	replayed, err := h.leaderboardService.SubmitIdempotent(r.Header.Get(IdempotencyKeyHeader), update)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrIdempotencyKeyReused):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, services.ErrUnknownUser):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrRateLimited):
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	case errors.Is(err, services.ErrUpdateQueueFull), errors.Is(err, services.ErrServiceStopped):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	h.writeEncodedStatus(w, r, http.StatusAccepted, map[string]string{
+		"status": "accepted",
+	})
+}
+
+// SubmitBatch applies a JSON array of updates at once. Every invalid row is
+// reported in a 422; with ?partial=true the valid rows are applied anyway
+// and the invalid ones are listed under "rejected".
+func (h *Handler) SubmitBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	partial, ok := parsePartialParam(w, r)
+	if !ok {
+		return
+	}
+
+	var reqs []updateRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	updates := make([]services.RatingUpdate, len(reqs))
+	for i, req := range reqs {
+		updates[i] = services.RatingUpdate{UserID: req.UserID, NewRating: req.Rating, Seq: req.Seq}
+	}
+
+	errs := h.leaderboardService.ValidateUpdates(updates)
+	if len(errs) > 0 && !partial {
+		h.writeValidationErrors(w, r, errs)
+		return
+	}
+
+	rejected := errs.Rows()
+	valid := updates[:0:0]
+	for i, update := range updates {
+		if !rejected[i] {
+			valid = append(valid, update)
+		}
+	}
+
+	err := h.leaderboardService.ApplyBatch(valid)
+	var lateErrs services.ValidationErrors
+	switch {
+	case err == nil:
+	case errors.As(err, &lateErrs):
+		// The population changed after the up-front check
+		h.writeValidationErrors(w, r, lateErrs)
+		return
+	case errors.Is(err, services.ErrRateLimited):
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	h.writeEncoded(w, r, map[string]interface{}{
+		"applied":  len(valid),
+		"rejected": nonNilErrors(errs),
+	})
+}
+
+// parsePartialParam reads the optional ?partial= flag used by bulk endpoints.
+func parsePartialParam(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	return parseBoolParam(w, r, "partial")
+}
+
+// parseBoolParam reads an optional boolean query parameter, false when
+// absent.
+func parseBoolParam(w http.ResponseWriter, r *http.Request, name string) (bool, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, true
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid %s parameter", name), http.StatusBadRequest)
+		return false, false
+	}
+	return parsed, true
+}
+
+func (h *Handler) writeValidationErrors(w http.ResponseWriter, r *http.Request, errs services.ValidationErrors) {
+	h.writeEncodedStatus(w, r, http.StatusUnprocessableEntity, map[string]interface{}{
+		"errors": errs,
+	})
+}
+
+// nonNilErrors makes an empty list encode as [] rather than null.
+func nonNilErrors(errs services.ValidationErrors) services.ValidationErrors {
+	if errs == nil {
+		return services.ValidationErrors{}
+	}
+	return errs
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"matiks-backend/services"
+)
+
+// UserRoutes serves /users/{id}: the user's profile with their rank and
+// how many users are above and below them, /users/{id}/rank-history,
+// /users/{id}/context, /users/available and /users/by-names.
+func (h *Handler) UserRoutes(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/users/")
+	switch rest {
+	case "available":
+		h.UsernameAvailable(w, r)
+		return
+	case "by-names":
+		h.GetUsersByNames(w, r)
+		return
+	}
+	idStr, sub, _ := strings.Cut(rest, "/")
+
+	userID, err := strconv.Atoi(idStr)
+	if err != nil || userID <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	switch sub {
+	case "":
+		h.GetUserProfile(w, r, userID)
+	case "rank-history":
+		h.GetRankHistory(w, r, userID)
+	case "context":
+		h.GetProfileContext(w, r, userID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) GetUserProfile(w http.ResponseWriter, r *http.Request, userID int) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rankOffset, ok := parseRankBase(w, r)
+	if !ok {
+		return
+	}
+
+	profile, ok := h.leaderboardService.GetUserProfile(userID)
+	if !ok {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	profile.Rank -= rankOffset
+
+	setCacheHeaders(w, h.leaderboardService.Config().LeaderboardCacheTTL)
+
+	h.writeEncoded(w, r, profile)
+}
+
+// MaxProfileContextRadius bounds the neighbours on each side of one
+// /users/{id}/context request.
+const MaxProfileContextRadius = 50
+
+// GetProfileContext returns everything a profile page needs in one call:
+// the profile, percentile, tier and ?radius=K users on either side
+// (default 2), all from one snapshot. ?exclude_self=true ranks the
+// neighbours as if the user were not on the leaderboard.
+func (h *Handler) GetProfileContext(w http.ResponseWriter, r *http.Request, userID int) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	radius, ok := parsePositiveParam(w, r, "radius", 2, MaxProfileContextRadius)
+	if !ok {
+		return
+	}
+
+	excludeSelf, ok := parseBoolParam(w, r, "exclude_self")
+	if !ok {
+		return
+	}
+
+	rankOffset, ok := parseRankBase(w, r)
+	if !ok {
+		return
+	}
+
+	profile, ok := h.leaderboardService.GetProfileContextWithOptions(userID, services.ProfileContextOptions{
+		Radius:      radius,
+		ExcludeSelf: excludeSelf,
+	})
+	if !ok {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	profile.Rank -= rankOffset
+	rebaseRanks(profile.Above, rankOffset)
+	rebaseRanks(profile.Below, rankOffset)
+	h.leaderboardService.MaskEntries(profile.Above)
+	h.leaderboardService.MaskEntries(profile.Below)
+
+	setCacheHeaders(w, h.leaderboardService.Config().LeaderboardCacheTTL)
+
+	h.writeEncoded(w, r, profile)
+}
+
+// GetRankHistory returns the user's rank in each of the last ?window=N
+// retained snapshots (default 10), oldest first, for rank-over-time graphs.
+func (h *Handler) GetRankHistory(w http.ResponseWriter, r *http.Request, userID int) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	window, ok := parsePositiveParam(w, r, "window", 10, 0)
+	if !ok {
+		return
+	}
+
+	rankOffset, ok := parseRankBase(w, r)
+	if !ok {
+		return
+	}
+
+	points, ok := h.leaderboardService.RankHistory(userID, window)
+	if !ok {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	for i := range points {
+		if points[i].Rank > 0 {
+			points[i].Rank -= rankOffset
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	h.writeEncoded(w, r, rankHistoryResponse{UserID: userID, History: points})
+}
+
+type rankHistoryResponse struct {
+	UserID  int                  `json:"user_id"`
+	History []services.RankPoint `json:"history"`
+}
+
+// UsernameAvailable serves /users/available?username=xyz before a signup or
+// rename. available says whether the name may be used (see
+// LeaderboardService.UsernameAvailable); taken whether some user already
+// has exactly that name, which only blocks it with Config.UniqueUsernames.
+func (h *Handler) UsernameAvailable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	username := r.URL.Query().Get("username")
+	if username == "" {
+		http.Error(w, "Missing username parameter", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	h.writeEncoded(w, r, map[string]interface{}{
+		"username":  username,
+		"available": h.leaderboardService.UsernameAvailable(username),
+		"taken":     h.leaderboardService.UsernameTaken(username),
+	})
+}
+
+// GetRankByUsername serves /rank?username=rahul for clients that only know
+// a username. Usernames are not unique; the best-ranked match is returned
+// (see LeaderboardService.GetRankByUsername).
+func (h *Handler) GetRankByUsername(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	username := r.URL.Query().Get("username")
+	if username == "" {
+		http.Error(w, "Missing username parameter", http.StatusBadRequest)
+		return
+	}
+
+	rankOffset, ok := parseRankBase(w, r)
+	if !ok {
+		return
+	}
+
+	user, rank, err := h.leaderboardService.GetRankByUsername(username)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	setCacheHeaders(w, h.leaderboardService.Config().LeaderboardCacheTTL)
+
+	h.writeEncoded(w, r, services.RankedUser{
+		ID:       user.ID,
+		Username: user.Username,
+		Rating:   user.Rating,
+		Rank:     rank - rankOffset,
+	})
+}
+
+// GetUsersByNames returns every user named exactly one of the posted
+// usernames, with their ranks, for importing friends lists keyed by name:
+// POST ["rahul", "priya"]. Each name maps to all users holding it, since
+// names are not unique; names with no user are listed in unmatched.
+func (h *Handler) GetUsersByNames(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rankOffset, ok := parseRankBase(w, r)
+	if !ok {
+		return
+	}
+
+	var names []string
+	if err := json.NewDecoder(r.Body).Decode(&names); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(names) > services.MaxFilterUsers {
+		http.Error(w, fmt.Sprintf("At most %d usernames allowed", services.MaxFilterUsers), http.StatusBadRequest)
+		return
+	}
+
+	matches, unmatched := h.leaderboardService.GetUsersByNames(names)
+	for _, users := range matches {
+		for i := range users {
+			users[i].Rank -= rankOffset
+		}
+	}
+	if unmatched == nil {
+		unmatched = []string{}
+	}
+
+	h.writeEncoded(w, r, usersByNamesResponse{
+		Data:      matches,
+		Count:     len(matches),
+		Unmatched: unmatched,
+	})
+}
+
+type usersByNamesResponse struct {
+	Data      map[string][]services.RankedUser `json:"data"`
+	Count     int                              `json:"count"`
+	Unmatched []string                         `json:"unmatched"`
+}
+
+// GetPercentiles returns the percentile of every posted user ID, for
+// cohort analysis: POST {"user_ids": [3, 8, 10]}. All come from one
+// snapshot; unknown IDs are left out.
+func (h *Handler) GetPercentiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		UserIDs []int `json:"user_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.UserIDs) > services.MaxFilterUsers {
+		http.Error(w, fmt.Sprintf("At most %d user_ids allowed", services.MaxFilterUsers), http.StatusBadRequest)
+		return
+	}
+
+	percentiles := h.leaderboardService.GetPercentiles(req.UserIDs)
+
+	h.writeEncoded(w, r, map[string]interface{}{
+		"data":  percentiles,
+		"count": len(percentiles),
+	})
+}
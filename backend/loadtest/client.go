@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// workerClient is one worker's HTTP client. It retries transient failures
+// and, like a circuit breaker, pauses the worker after BreakerThreshold
+// consecutive failed requests so a struggling server is not hammered
+// further. Retries and pauses are counted in TestResults apart from errors.
+// Not safe for concurrent use; every worker has its own.
+type workerClient struct {
+	client  *http.Client
+	config  LoadTestConfig
+	results *TestResults
+
+	failures int // consecutive failed requests
+}
+
+func newWorkerClient(config LoadTestConfig, results *TestResults) *workerClient {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &workerClient{
+		client:  &http.Client{Timeout: timeout},
+		config:  config,
+		results: results,
+	}
+}
+
+// transientStatus reports whether a response is worth retrying: the server
+// is overloaded or briefly unavailable rather than rejecting the request.
+func transientStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// get fetches url, retrying network errors and transient statuses up to
+// MaxRetries times with a linearly growing RetryBackoff. It returns the
+// final status and the latency of the final attempt; err is set if that
+// attempt failed to get a response.
+func (c *workerClient) get(url string) (status int, latency time.Duration, err error) {
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		resp, reqErr := c.client.Get(url)
+		latency = time.Since(start)
+
+		status, err = 0, reqErr
+		if reqErr == nil {
+			// Drain so the connection is reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			status = resp.StatusCode
+		}
+
+		if attempt >= c.config.MaxRetries || (err == nil && !transientStatus(status)) {
+			break
+		}
+		atomic.AddUint64(&c.results.Retries, 1)
+		time.Sleep(c.config.RetryBackoff * time.Duration(attempt+1))
+	}
+
+	if err == nil && status == http.StatusOK {
+		c.failures = 0
+	} else {
+		c.failures++
+	}
+	return status, latency, err
+}
+
+// breakerOpen reports whether the worker has failed BreakerThreshold times
+// in a row. If so it counts a trip and resets, so the caller should pause.
+func (c *workerClient) breakerOpen() bool {
+	if c.config.BreakerThreshold <= 0 || c.failures < c.config.BreakerThreshold {
+		return false
+	}
+	c.failures = 0
+	atomic.AddUint64(&c.results.BreakerTrips, 1)
+	return true
+}
+
+// pause waits out BreakerCooldown, or until stop is closed.
+func (c *workerClient) pause(stop chan struct{}) {
+	timer := time.NewTimer(c.config.BreakerCooldown)
+	defer timer.Stop()
+
+	select {
+	case <-stop:
+	case <-timer.C:
+	}
+}
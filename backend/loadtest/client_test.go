@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFlakyServer answers every other request with 503.
+func newFlakyServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	var requests atomic.Uint64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1)%2 == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("[]"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestResults() *TestResults {
+	return &TestResults{
+		ReadLatency:   NewLatencyMetrics(),
+		WriteLatency:  NewLatencyMetrics(),
+		SearchLatency: NewLatencyMetrics(),
+	}
+}
+
+// runReadWorker runs one read worker against baseURL for d.
+func runReadWorker(config LoadTestConfig, d time.Duration) *TestResults {
+	results := newTestResults()
+	stop := make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go readWorker(&wg, config, results, stop, make(chan bool, 1), 0, 0, 1)
+
+	time.Sleep(d)
+	close(stop)
+	wg.Wait()
+	return results
+}
+
+func TestReadWorker_RetriesHideTransientErrors(t *testing.T) {
+	server := newFlakyServer(t)
+
+	// Without retries half the requests fail
+	plain := runReadWorker(LoadTestConfig{BaseURL: server.URL}, 100*time.Millisecond)
+	if plain.ReadErrors == 0 || plain.Retries != 0 {
+		t.Fatalf("Expected errors and no retries without retrying, got %d errors, %d retries",
+			plain.ReadErrors, plain.Retries)
+	}
+
+	// A single retry always lands on a success
+	retried := runReadWorker(LoadTestConfig{BaseURL: server.URL, MaxRetries: 1}, 100*time.Millisecond)
+	if retried.ReadErrors != 0 {
+		t.Errorf("Expected retries to absorb every 503, got %d errors", retried.ReadErrors)
+	}
+	if retried.ReadOps == 0 || retried.Retries == 0 {
+		t.Errorf("Expected successful ops after retries, got %d ops, %d retries", retried.ReadOps, retried.Retries)
+	}
+}
+
+func TestWorkerClient_NonTransientNotRetried(t *testing.T) {
+	var requests atomic.Uint64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "bad", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	results := newTestResults()
+	client := newWorkerClient(LoadTestConfig{MaxRetries: 3}, results)
+	if status, _, err := client.get(server.URL); err != nil || status != http.StatusBadRequest {
+		t.Fatalf("Expected a 400, got %d (%v)", status, err)
+	}
+	if requests.Load() != 1 || results.Retries != 0 {
+		t.Errorf("Expected a single attempt, got %d requests and %d retries", requests.Load(), results.Retries)
+	}
+}
+
+func TestWorkerClient_BreakerTripsAfterConsecutiveFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	results := newTestResults()
+	client := newWorkerClient(LoadTestConfig{BreakerThreshold: 3}, results)
+
+	for i := 1; i <= 3; i++ {
+		client.get(server.URL)
+		if open := client.breakerOpen(); open != (i == 3) {
+			t.Errorf("After %d failures: expected breaker open %v, got %v", i, i == 3, open)
+		}
+	}
+	if results.BreakerTrips != 1 {
+		t.Errorf("Expected 1 trip, got %d", results.BreakerTrips)
+	}
+
+	// The count starts over after a trip
+	client.get(server.URL)
+	if client.breakerOpen() {
+		t.Error("Expected the breaker to need another 3 failures")
+	}
+}
@@ -1,16 +1,24 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"math"
 	"net/http"
+	"os"
 	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"matiks-backend/logging"
+	"matiks-backend/models"
+	"matiks-backend/utils"
 )
 
 // LoadTestConfig contains configuration for the load test
@@ -24,6 +32,14 @@ type LoadTestConfig struct {
 	SpikeTest         bool
 	SpikeDuration     time.Duration
 	SpikeMultiplier   int
+
+	// Per-request timeout, and how transient failures are retried (see
+	// workerClient). A BreakerThreshold of zero never pauses workers.
+	Timeout          time.Duration
+	MaxRetries       int
+	RetryBackoff     time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
 }
 
 // LatencyMetrics tracks detailed latency statistics
@@ -105,6 +121,11 @@ type TestResults struct {
 	WriteErrors  uint64
 	SearchErrors uint64
 
+	// Retries of transient failures and circuit breaker pauses. These are
+	// not errors: a request that succeeded on retry counts as an op.
+	Retries      uint64
+	BreakerTrips uint64
+
 	ReadLatency   *LatencyMetrics
 	WriteLatency  *LatencyMetrics
 	SearchLatency *LatencyMetrics
@@ -123,9 +144,27 @@ func main() {
 	spike := flag.Bool("spike", false, "Enable spike test")
 	spikeDuration := flag.Duration("spike-duration", 10*time.Second, "Duration of spike")
 	spikeMultiplier := flag.Int("spike-multiplier", 5, "Spike multiplier")
+	logLevel := flag.String("log-level", "info", "Progress log level: error, warn, info or debug")
+	quiet := flag.Bool("quiet", false, "Only log errors; the final report is still printed")
+	seedUsers := flag.Int("seed-users", 0, "Import this many generated users before the test (needs -admin-token)")
+	seed := flag.Int64("seed", 1, "Random seed for -seed-users; the same seed imports the same users")
+	adminToken := flag.String("admin-token", os.Getenv("ADMIN_TOKEN"), "Admin token for -seed-users (default: $ADMIN_TOKEN)")
+	timeout := flag.Duration("timeout", 10*time.Second, "Per-request timeout")
+	retries := flag.Int("retries", 2, "Retries of network errors and 429/502/503/504 responses")
+	retryBackoff := flag.Duration("retry-backoff", 50*time.Millisecond, "Delay before the first retry, growing linearly")
+	breakerThreshold := flag.Int("breaker-threshold", 10, "Consecutive failures that pause a worker (0 disables)")
+	breakerCooldown := flag.Duration("breaker-cooldown", time.Second, "How long a tripped worker pauses")
 
 	flag.Parse()
 
+	if *quiet {
+		*logLevel = "error"
+	}
+	if err := logging.Setup(*logLevel); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	config := LoadTestConfig{
 		BaseURL:           *baseURL,
 		Duration:          *duration,
@@ -136,35 +175,42 @@ func main() {
 		SpikeTest:         *spike,
 		SpikeDuration:     *spikeDuration,
 		SpikeMultiplier:   *spikeMultiplier,
+		Timeout:           *timeout,
+		MaxRetries:        *retries,
+		RetryBackoff:      *retryBackoff,
+		BreakerThreshold:  *breakerThreshold,
+		BreakerCooldown:   *breakerCooldown,
 	}
 
-	log.Println("╔══════════════════════════════════════════════════════════════╗")
-	log.Println("║       LEADERBOARD LOAD TESTING TOOL                          ║")
-	log.Println("╚══════════════════════════════════════════════════════════════╝")
-	log.Println()
-	log.Printf("Configuration:")
-	log.Printf("  Target URL:            %s", config.BaseURL)
-	log.Printf("  Test Duration:         %v", config.Duration)
-	log.Printf("  Read Concurrency:      %d", config.ReadConcurrency)
-	log.Printf("  Write Concurrency:     %d (simulated)", config.WriteConcurrency)
-	log.Printf("  Search Concurrency:    %d", config.SearchConcurrency)
-	log.Printf("  Ramp-up Time:          %v", config.RampUpTime)
-	log.Printf("  Spike Test:            %v", config.SpikeTest)
+	slog.Info("leaderboard load test",
+		"url", config.BaseURL,
+		"duration", config.Duration,
+		"read_concurrency", config.ReadConcurrency,
+		"write_concurrency", config.WriteConcurrency,
+		"search_concurrency", config.SearchConcurrency,
+		"rampup", config.RampUpTime,
+		"spike", config.SpikeTest)
 	if config.SpikeTest {
-		log.Printf("  Spike Duration:        %v", config.SpikeDuration)
-		log.Printf("  Spike Multiplier:      %dx", config.SpikeMultiplier)
+		slog.Info("spike test configured", "duration", config.SpikeDuration, "multiplier", config.SpikeMultiplier)
 	}
-	log.Println()
 
 	// Check if service is available
-	log.Println("Checking service availability...")
+	slog.Debug("checking service availability")
 	resp, err := http.Get(config.BaseURL + "/health")
 	if err != nil {
-		log.Fatalf("Service not available: %v", err)
+		slog.Error("service not available", "err", err)
+		os.Exit(1)
 	}
 	resp.Body.Close()
-	log.Println("✓ Service is healthy")
-	log.Println()
+	slog.Info("service is healthy")
+
+	if *seedUsers > 0 {
+		if err := importUsers(config.BaseURL, *adminToken, utils.GenerateUsers(*seedUsers, *seed)); err != nil {
+			slog.Error("seeding users failed", "err", err)
+			os.Exit(1)
+		}
+		slog.Info("seeded users", "count", *seedUsers, "seed", *seed)
+	}
 
 	// Run load test
 	results := runLoadTest(config)
@@ -173,6 +219,33 @@ func main() {
 	printResults(results, config)
 }
 
+// importUsers replaces the service's population through POST /admin/import.
+func importUsers(baseURL, adminToken string, users []models.UserSeed) error {
+	body, err := json.Marshal(users)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/admin/import", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("import returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
 func runLoadTest(config LoadTestConfig) *TestResults {
 	results := &TestResults{
 		ReadLatency:   NewLatencyMetrics(),
@@ -187,21 +260,20 @@ func runLoadTest(config LoadTestConfig) *TestResults {
 	startTime := time.Now()
 
 	// Start read workers
-	log.Printf("Starting %d read workers...", config.ReadConcurrency)
+	slog.Debug("starting read workers", "count", config.ReadConcurrency)
 	for i := 0; i < config.ReadConcurrency; i++ {
 		wg.Add(1)
-		go readWorker(&wg, config.BaseURL, results, stop, spike, i, config.RampUpTime, config.ReadConcurrency)
+		go readWorker(&wg, config, results, stop, spike, i, config.RampUpTime, config.ReadConcurrency)
 	}
 
 	// Start search workers
-	log.Printf("Starting %d search workers...", config.SearchConcurrency)
+	slog.Debug("starting search workers", "count", config.SearchConcurrency)
 	for i := 0; i < config.SearchConcurrency; i++ {
 		wg.Add(1)
-		go searchWorker(&wg, config.BaseURL, results, stop, spike, i, config.RampUpTime, config.SearchConcurrency)
+		go searchWorker(&wg, config, results, stop, spike, i, config.RampUpTime, config.SearchConcurrency)
 	}
 
-	log.Println("Load test started!")
-	log.Println()
+	slog.Info("load test started")
 
 	// Progress reporter
 	go func() {
@@ -221,8 +293,10 @@ func runLoadTest(config LoadTestConfig) *TestResults {
 				rps := float64(reads) / elapsed.Seconds()
 				sps := float64(searches) / elapsed.Seconds()
 
-				log.Printf("[%v] Reads: %d (%.0f/s, %d errors) | Searches: %d (%.0f/s, %d errors)",
-					elapsed.Round(time.Second), reads, rps, readErrs, searches, sps, searchErrs)
+				slog.Info("progress",
+					"elapsed", elapsed.Round(time.Second),
+					"reads", reads, "reads_per_sec", math.Round(rps), "read_errors", readErrs,
+					"searches", searches, "searches_per_sec", math.Round(sps), "search_errors", searchErrs)
 			}
 		}
 	}()
@@ -232,21 +306,20 @@ func runLoadTest(config LoadTestConfig) *TestResults {
 		normalDuration := config.Duration - config.SpikeDuration
 		time.Sleep(normalDuration)
 
-		log.Println()
-		log.Printf("🔥 INITIATING SPIKE TEST (%dx traffic for %v)...", config.SpikeMultiplier, config.SpikeDuration)
+		slog.Info("initiating spike test", "multiplier", config.SpikeMultiplier, "duration", config.SpikeDuration)
 		spike <- true
 
 		// Start additional spike workers
 		spikeWorkers := (config.ReadConcurrency + config.SearchConcurrency) * (config.SpikeMultiplier - 1)
-		log.Printf("Spawning %d additional workers...", spikeWorkers)
+		slog.Debug("spawning spike workers", "count", spikeWorkers)
 
 		for i := 0; i < spikeWorkers/2; i++ {
 			wg.Add(1)
-			go readWorker(&wg, config.BaseURL, results, stop, spike, i+10000, 0, 1)
+			go readWorker(&wg, config, results, stop, spike, i+10000, 0, 1)
 		}
 		for i := 0; i < spikeWorkers/2; i++ {
 			wg.Add(1)
-			go searchWorker(&wg, config.BaseURL, results, stop, spike, i+10000, 0, 1)
+			go searchWorker(&wg, config, results, stop, spike, i+10000, 0, 1)
 		}
 
 		time.Sleep(config.SpikeDuration)
@@ -255,19 +328,17 @@ func runLoadTest(config LoadTestConfig) *TestResults {
 	}
 
 	// Stop all workers
-	log.Println()
-	log.Println("Stopping workers...")
+	slog.Debug("stopping workers")
 	close(stop)
 	wg.Wait()
 
 	results.Duration = time.Since(startTime)
-	log.Println("Load test completed!")
-	log.Println()
+	slog.Info("load test completed", "duration", results.Duration.Round(time.Millisecond))
 
 	return results
 }
 
-func readWorker(wg *sync.WaitGroup, baseURL string, results *TestResults, stop chan struct{}, spike chan bool, id int, rampUp time.Duration, totalWorkers int) {
+func readWorker(wg *sync.WaitGroup, config LoadTestConfig, results *TestResults, stop chan struct{}, spike chan bool, id int, rampUp time.Duration, totalWorkers int) {
 	defer wg.Done()
 
 	// Stagger start time for ramp-up
@@ -276,7 +347,7 @@ func readWorker(wg *sync.WaitGroup, baseURL string, results *TestResults, stop c
 		time.Sleep(delay)
 	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := newWorkerClient(config, results)
 	limits := []int{10, 50, 100}
 
 	for {
@@ -285,22 +356,17 @@ func readWorker(wg *sync.WaitGroup, baseURL string, results *TestResults, stop c
 			return
 		default:
 			limit := limits[id%len(limits)]
-			url := fmt.Sprintf("%s/leaderboard?limit=%d", baseURL, limit)
+			url := fmt.Sprintf("%s/leaderboard?limit=%d", config.BaseURL, limit)
 
-			start := time.Now()
-			resp, err := client.Get(url)
-			latency := time.Since(start)
-
-			if err != nil {
-				atomic.AddUint64(&results.ReadErrors, 1)
+			status, latency, err := client.get(url)
+			if err == nil && status == http.StatusOK {
+				atomic.AddUint64(&results.ReadOps, 1)
+				results.ReadLatency.Record(latency)
 			} else {
-				resp.Body.Close()
-				if resp.StatusCode == http.StatusOK {
-					atomic.AddUint64(&results.ReadOps, 1)
-					results.ReadLatency.Record(latency)
-				} else {
-					atomic.AddUint64(&results.ReadErrors, 1)
-				}
+				atomic.AddUint64(&results.ReadErrors, 1)
+			}
+			if client.breakerOpen() {
+				client.pause(stop)
 			}
 
 			// Small delay to avoid overwhelming the system
@@ -309,7 +375,7 @@ func readWorker(wg *sync.WaitGroup, baseURL string, results *TestResults, stop c
 	}
 }
 
-func searchWorker(wg *sync.WaitGroup, baseURL string, results *TestResults, stop chan struct{}, spike chan bool, id int, rampUp time.Duration, totalWorkers int) {
+func searchWorker(wg *sync.WaitGroup, config LoadTestConfig, results *TestResults, stop chan struct{}, spike chan bool, id int, rampUp time.Duration, totalWorkers int) {
 	defer wg.Done()
 
 	// Stagger start time for ramp-up
@@ -318,7 +384,7 @@ func searchWorker(wg *sync.WaitGroup, baseURL string, results *TestResults, stop
 		time.Sleep(delay)
 	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := newWorkerClient(config, results)
 	queries := []string{"user", "rahul", "kumar", "test", "amit", "priya"}
 
 	for {
@@ -327,22 +393,17 @@ func searchWorker(wg *sync.WaitGroup, baseURL string, results *TestResults, stop
 			return
 		default:
 			query := queries[id%len(queries)]
-			url := fmt.Sprintf("%s/search?query=%s", baseURL, query)
+			url := fmt.Sprintf("%s/search?query=%s", config.BaseURL, query)
 
-			start := time.Now()
-			resp, err := client.Get(url)
-			latency := time.Since(start)
-
-			if err != nil {
-				atomic.AddUint64(&results.SearchErrors, 1)
+			status, latency, err := client.get(url)
+			if err == nil && status == http.StatusOK {
+				atomic.AddUint64(&results.SearchOps, 1)
+				results.SearchLatency.Record(latency)
 			} else {
-				resp.Body.Close()
-				if resp.StatusCode == http.StatusOK {
-					atomic.AddUint64(&results.SearchOps, 1)
-					results.SearchLatency.Record(latency)
-				} else {
-					atomic.AddUint64(&results.SearchErrors, 1)
-				}
+				atomic.AddUint64(&results.SearchErrors, 1)
+			}
+			if client.breakerOpen() {
+				client.pause(stop)
 			}
 
 			time.Sleep(5 * time.Millisecond)
@@ -350,60 +411,66 @@ func searchWorker(wg *sync.WaitGroup, baseURL string, results *TestResults, stop
 	}
 }
 
+// printResults writes the final report to stdout. It is the tool's output
+// rather than logging, so it is printed at every log level.
 func printResults(results *TestResults, config LoadTestConfig) {
-	log.Println("╔══════════════════════════════════════════════════════════════╗")
-	log.Println("║                     TEST RESULTS                             ║")
-	log.Println("╚══════════════════════════════════════════════════════════════╝")
-	log.Println()
+	report := log.New(os.Stdout, "", 0)
+
+	report.Println("╔══════════════════════════════════════════════════════════════╗")
+	report.Println("║                     TEST RESULTS                             ║")
+	report.Println("╚══════════════════════════════════════════════════════════════╝")
+	report.Println()
 
 	totalOps := results.ReadOps + results.SearchOps
 	totalErrors := results.ReadErrors + results.SearchErrors
 	errorRate := float64(totalErrors) / float64(totalOps+totalErrors) * 100
 
-	log.Printf("Overall Metrics:")
-	log.Printf("  Duration:              %v", results.Duration.Round(time.Millisecond))
-	log.Printf("  Total Operations:      %d", totalOps)
-	log.Printf("  Total Errors:          %d (%.2f%%)", totalErrors, errorRate)
-	log.Printf("  Overall Throughput:    %.0f ops/sec", float64(totalOps)/results.Duration.Seconds())
-	log.Println()
+	report.Printf("Overall Metrics:")
+	report.Printf("  Duration:              %v", results.Duration.Round(time.Millisecond))
+	report.Printf("  Total Operations:      %d", totalOps)
+	report.Printf("  Total Errors:          %d (%.2f%%)", totalErrors, errorRate)
+	report.Printf("  Retries:               %d", results.Retries)
+	report.Printf("  Breaker Trips:         %d", results.BreakerTrips)
+	report.Printf("  Overall Throughput:    %.0f ops/sec", float64(totalOps)/results.Duration.Seconds())
+	report.Println()
 
-	log.Printf("Read Operations:")
-	log.Printf("  Total:                 %d", results.ReadOps)
-	log.Printf("  Errors:                %d", results.ReadErrors)
-	log.Printf("  Throughput:            %.0f reads/sec", float64(results.ReadOps)/results.Duration.Seconds())
+	report.Printf("Read Operations:")
+	report.Printf("  Total:                 %d", results.ReadOps)
+	report.Printf("  Errors:                %d", results.ReadErrors)
+	report.Printf("  Throughput:            %.0f reads/sec", float64(results.ReadOps)/results.Duration.Seconds())
 
 	if results.ReadOps > 0 {
 		readStats := results.ReadLatency.Calculate()
-		log.Printf("  Latency:")
-		log.Printf("    Min:                 %v", readStats["min"])
-		log.Printf("    Mean:                %v", readStats["mean"])
-		log.Printf("    P50:                 %v", readStats["p50"])
-		log.Printf("    P90:                 %v", readStats["p90"])
-		log.Printf("    P95:                 %v", readStats["p95"])
-		log.Printf("    P99:                 %v", readStats["p99"])
-		log.Printf("    P99.9:               %v", readStats["p999"])
-		log.Printf("    Max:                 %v", readStats["max"])
+		report.Printf("  Latency:")
+		report.Printf("    Min:                 %v", readStats["min"])
+		report.Printf("    Mean:                %v", readStats["mean"])
+		report.Printf("    P50:                 %v", readStats["p50"])
+		report.Printf("    P90:                 %v", readStats["p90"])
+		report.Printf("    P95:                 %v", readStats["p95"])
+		report.Printf("    P99:                 %v", readStats["p99"])
+		report.Printf("    P99.9:               %v", readStats["p999"])
+		report.Printf("    Max:                 %v", readStats["max"])
 	}
-	log.Println()
+	report.Println()
 
-	log.Printf("Search Operations:")
-	log.Printf("  Total:                 %d", results.SearchOps)
-	log.Printf("  Errors:                %d", results.SearchErrors)
-	log.Printf("  Throughput:            %.0f searches/sec", float64(results.SearchOps)/results.Duration.Seconds())
+	report.Printf("Search Operations:")
+	report.Printf("  Total:                 %d", results.SearchOps)
+	report.Printf("  Errors:                %d", results.SearchErrors)
+	report.Printf("  Throughput:            %.0f searches/sec", float64(results.SearchOps)/results.Duration.Seconds())
 
 	if results.SearchOps > 0 {
 		searchStats := results.SearchLatency.Calculate()
-		log.Printf("  Latency:")
-		log.Printf("    Min:                 %v", searchStats["min"])
-		log.Printf("    Mean:                %v", searchStats["mean"])
-		log.Printf("    P50:                 %v", searchStats["p50"])
-		log.Printf("    P90:                 %v", searchStats["p90"])
-		log.Printf("    P95:                 %v", searchStats["p95"])
-		log.Printf("    P99:                 %v", searchStats["p99"])
-		log.Printf("    P99.9:               %v", searchStats["p999"])
-		log.Printf("    Max:                 %v", searchStats["max"])
+		report.Printf("  Latency:")
+		report.Printf("    Min:                 %v", searchStats["min"])
+		report.Printf("    Mean:                %v", searchStats["mean"])
+		report.Printf("    P50:                 %v", searchStats["p50"])
+		report.Printf("    P90:                 %v", searchStats["p90"])
+		report.Printf("    P95:                 %v", searchStats["p95"])
+		report.Printf("    P99:                 %v", searchStats["p99"])
+		report.Printf("    P99.9:               %v", searchStats["p999"])
+		report.Printf("    Max:                 %v", searchStats["max"])
 	}
-	log.Println()
+	report.Println()
 
 	// Get final stats from service
 	client := &http.Client{Timeout: 5 * time.Second}
@@ -412,54 +479,54 @@ func printResults(results *TestResults, config LoadTestConfig) {
 		defer resp.Body.Close()
 		var stats map[string]interface{}
 		if json.NewDecoder(resp.Body).Decode(&stats) == nil {
-			log.Printf("Service Statistics:")
-			log.Printf("  Total Users:           %v", stats["total_users"])
-			log.Printf("  Unique Usernames:      %v", stats["unique_usernames"])
-			log.Printf("  Active Rating Buckets: %v", stats["active_rating_buckets"])
-			log.Println()
+			report.Printf("Service Statistics:")
+			report.Printf("  Total Users:           %v", stats["total_users"])
+			report.Printf("  Unique Usernames:      %v", stats["unique_usernames"])
+			report.Printf("  Active Rating Buckets: %v", stats["active_rating_buckets"])
+			report.Println()
 		}
 	}
 
 	// Performance assessment
-	log.Println("╔══════════════════════════════════════════════════════════════╗")
-	log.Println("║                  PERFORMANCE ASSESSMENT                      ║")
-	log.Println("╚══════════════════════════════════════════════════════════════╝")
-	log.Println()
+	report.Println("╔══════════════════════════════════════════════════════════════╗")
+	report.Println("║                  PERFORMANCE ASSESSMENT                      ║")
+	report.Println("╚══════════════════════════════════════════════════════════════╝")
+	report.Println()
 
 	opsPerSec := float64(totalOps) / results.Duration.Seconds()
 	readLatency := results.ReadLatency.Calculate()
 	p99, _ := readLatency["p99"].(time.Duration)
 
 	if opsPerSec > 10000 {
-		log.Println("✓ EXCELLENT: Throughput > 10K ops/sec")
+		report.Println("✓ EXCELLENT: Throughput > 10K ops/sec")
 	} else if opsPerSec > 5000 {
-		log.Println("✓ GOOD: Throughput > 5K ops/sec")
+		report.Println("✓ GOOD: Throughput > 5K ops/sec")
 	} else if opsPerSec > 1000 {
-		log.Println("⚠ FAIR: Throughput > 1K ops/sec")
+		report.Println("⚠ FAIR: Throughput > 1K ops/sec")
 	} else {
-		log.Println("✗ POOR: Throughput < 1K ops/sec")
+		report.Println("✗ POOR: Throughput < 1K ops/sec")
 	}
 
 	if p99 < 10*time.Millisecond {
-		log.Println("✓ EXCELLENT: P99 latency < 10ms")
+		report.Println("✓ EXCELLENT: P99 latency < 10ms")
 	} else if p99 < 50*time.Millisecond {
-		log.Println("✓ GOOD: P99 latency < 50ms")
+		report.Println("✓ GOOD: P99 latency < 50ms")
 	} else if p99 < 100*time.Millisecond {
-		log.Println("⚠ FAIR: P99 latency < 100ms")
+		report.Println("⚠ FAIR: P99 latency < 100ms")
 	} else {
-		log.Println("✗ POOR: P99 latency > 100ms")
+		report.Println("✗ POOR: P99 latency > 100ms")
 	}
 
 	if errorRate < 0.1 {
-		log.Println("✓ EXCELLENT: Error rate < 0.1%")
+		report.Println("✓ EXCELLENT: Error rate < 0.1%")
 	} else if errorRate < 1.0 {
-		log.Println("✓ GOOD: Error rate < 1%")
+		report.Println("✓ GOOD: Error rate < 1%")
 	} else if errorRate < 5.0 {
-		log.Println("⚠ FAIR: Error rate < 5%")
+		report.Println("⚠ FAIR: Error rate < 5%")
 	} else {
-		log.Println("✗ POOR: Error rate > 5%")
+		report.Println("✗ POOR: Error rate > 5%")
 	}
 
-	log.Println()
-	log.Println("Load test complete!")
+	report.Println()
+	report.Println("Load test complete!")
 }
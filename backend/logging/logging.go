@@ -0,0 +1,45 @@
+// Package logging configures the process-wide slog logger from a level
+// name such as the LOG_LEVEL environment variable.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// ParseLevel accepts error, warn (or warning), info and debug, in any case.
+// An empty name means info.
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "error":
+		return slog.LevelError, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q", name)
+	}
+}
+
+// New returns a text logger writing records at level or above to w.
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+// Setup installs a stderr logger at the named level as the slog default,
+// which also routes the standard log package through it.
+func Setup(name string) error {
+	level, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+
+	slog.SetDefault(New(os.Stderr, level))
+	return nil
+}
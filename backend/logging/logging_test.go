@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		want slog.Level
+	}{
+		{"error", slog.LevelError},
+		{"WARN", slog.LevelWarn},
+		{"warning", slog.LevelWarn},
+		{"Info", slog.LevelInfo},
+		{"", slog.LevelInfo},
+		{"debug", slog.LevelDebug},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.name)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", tt.name, got, err, tt.want)
+		}
+	}
+
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("Expected error for unknown level")
+	}
+}
+
+func TestNew_FiltersBelowLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	logger.Debug("debug message")
+	logger.Info("info message")
+	logger.Error("error message")
+
+	out := buf.String()
+	if strings.Contains(out, "debug message") {
+		t.Error("Debug record should be suppressed at info level")
+	}
+	if !strings.Contains(out, "info message") || !strings.Contains(out, "error message") {
+		t.Errorf("Expected info and error records, got %q", out)
+	}
+
+	buf.Reset()
+	quiet := New(&buf, slog.LevelError)
+	quiet.Info("info message")
+	quiet.Warn("warn message")
+	if buf.Len() != 0 {
+		t.Errorf("Expected nothing below error level, got %q", buf.String())
+	}
+}
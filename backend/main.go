@@ -1,15 +1,26 @@
 package main
 
 import (
+	"bytes"
 	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"matiks-backend/handlers"
+	"matiks-backend/logging"
 	"matiks-backend/services"
 )
 
@@ -17,8 +28,101 @@ const (
 	DefaultPort = "8000"
 )
 
-// CORS middleware
-func corsMiddleware(next http.Handler) http.Handler {
+// corsConfig controls the headers corsMiddleware adds.
+type corsConfig struct {
+	Enabled          bool
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	MaxAge           time.Duration // how long browsers may cache a preflight
+	AllowCredentials bool
+}
+
+// defaultCORSConfig allows every origin the methods and headers the API
+// uses, with credentials.
+func defaultCORSConfig() corsConfig {
+	return corsConfig{
+		Enabled:        true,
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{
+			"Content-Type", "Authorization", "X-Requested-With", "X-User-ID", "Idempotency-Key",
+			"X-Max-Staleness-Ms", "X-Request-ID", "X-API-Key",
+		},
+		MaxAge:           time.Hour,
+		AllowCredentials: true,
+	}
+}
+
+// corsConfigFromEnv overrides the defaults with CORS_ENABLED,
+// CORS_METHODS and CORS_HEADERS (comma-separated), CORS_MAX_AGE (a
+// duration such as 10m) and CORS_CREDENTIALS.
+func corsConfigFromEnv(getenv func(string) string) (corsConfig, error) {
+	config := defaultCORSConfig()
+
+	for name, field := range map[string]*bool{
+		"CORS_ENABLED":     &config.Enabled,
+		"CORS_CREDENTIALS": &config.AllowCredentials,
+	} {
+		if value := getenv(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return corsConfig{}, fmt.Errorf("invalid %s %q", name, value)
+			}
+			*field = parsed
+		}
+	}
+
+	if value := getenv("CORS_METHODS"); value != "" {
+		methods, err := parseList(value)
+		if err != nil {
+			return corsConfig{}, fmt.Errorf("invalid CORS_METHODS: %w", err)
+		}
+		for i, method := range methods {
+			methods[i] = strings.ToUpper(method)
+		}
+		config.AllowedMethods = methods
+	}
+	if value := getenv("CORS_HEADERS"); value != "" {
+		headers, err := parseList(value)
+		if err != nil {
+			return corsConfig{}, fmt.Errorf("invalid CORS_HEADERS: %w", err)
+		}
+		config.AllowedHeaders = headers
+	}
+
+	if value := getenv("CORS_MAX_AGE"); value != "" {
+		maxAge, err := time.ParseDuration(value)
+		if err != nil || maxAge < 0 {
+			return corsConfig{}, fmt.Errorf("invalid CORS_MAX_AGE %q", value)
+		}
+		config.MaxAge = maxAge
+	}
+
+	return config, nil
+}
+
+// parseList splits a comma-separated list, rejecting empty entries.
+func parseList(value string) ([]string, error) {
+	items := strings.Split(value, ",")
+	for i, item := range items {
+		items[i] = strings.TrimSpace(item)
+		if items[i] == "" {
+			return nil, fmt.Errorf("empty entry in %q", value)
+		}
+	}
+	return items, nil
+}
+
+// CORS middleware. When config.Enabled is false it is a pass-through that
+// adds no headers and leaves preflight requests to the router.
+func corsMiddleware(config corsConfig, next http.Handler) http.Handler {
+	if !config.Enabled {
+		return next
+	}
+
+	methods := strings.Join(config.AllowedMethods, ", ")
+	headers := strings.Join(config.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge.Seconds()))
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		origin := r.Header.Get("Origin")
 		if origin == "" {
@@ -26,10 +130,12 @@ func corsMiddleware(next http.Handler) http.Handler {
 		}
 
 		w.Header().Set("Access-Control-Allow-Origin", origin)
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Access-Control-Max-Age", "3600")
+		w.Header().Set("Access-Control-Allow-Methods", methods)
+		w.Header().Set("Access-Control-Allow-Headers", headers)
+		if config.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		w.Header().Set("Access-Control-Max-Age", maxAge)
 
 		// Handle preflight requests
 		if r.Method == "OPTIONS" {
@@ -49,23 +155,60 @@ func loggingMiddleware(next http.Handler) http.Handler {
 		// Call the next handler
 		next.ServeHTTP(w, r)
 
-		log.Printf("%s %s %s", r.Method, r.RequestURI, time.Since(start))
+		slog.Debug("request", "method", r.Method, "uri", r.RequestURI, "duration", time.Since(start))
 	})
 }
 
-// Recovery middleware for panic handling
+// RequestIDHeader carries the request ID assigned by the proxy in front of
+// the service, echoed in error responses so they can be matched to logs.
+const RequestIDHeader = "X-Request-ID"
+
+// Recovery middleware for panic handling: logs the panic with its stack
+// and answers with a JSON 500.
 func recoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
-			if err := recover(); err != nil {
-				log.Printf("Panic recovered: %v", err)
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			err := recover()
+			if err == nil {
+				return
 			}
+			if err == http.ErrAbortHandler {
+				// Deliberate abort: let the server drop the connection
+				panic(err)
+			}
+
+			requestID := r.Header.Get(RequestIDHeader)
+			slog.Error("panic recovered",
+				"method", r.Method,
+				"uri", r.RequestURI,
+				"request_id", requestID,
+				"panic", err,
+				"stack", string(debug.Stack()))
+
+			body := map[string]string{"error": "Internal Server Error"}
+			if requestID != "" {
+				body["request_id"] = requestID
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(body)
 		}()
 		next.ServeHTTP(w, r)
 	})
 }
 
+// withMiddleware wraps the router in the server's middleware. Recovery sits
+// inside gzip, so a panic's JSON error is compressed like any other body
+// rather than written after the gzip stream has been closed, and inside
+// logging, so the 500 is logged.
+func withMiddleware(router http.Handler, sampleRate float64, cors corsConfig) http.Handler {
+	handler := bodySamplingMiddleware(sampleRate, router)
+	handler = corsMiddleware(cors, handler)
+	handler = recoveryMiddleware(handler)
+	handler = gzipMiddleware(handler)
+	return loggingMiddleware(handler)
+}
+
 // Gzip compression middleware
 type gzipResponseWriter struct {
 	io.Writer
@@ -76,9 +219,27 @@ func (w gzipResponseWriter) Write(b []byte) (int, error) {
 	return w.Writer.Write(b)
 }
 
+// Flush sends everything compressed so far, so streamed responses such as
+// /leaderboard/stream are not held back by the gzip buffer.
+func (w gzipResponseWriter) Flush() {
+	if gz, ok := w.Writer.(*gzip.Writer); ok {
+		gz.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying connection.
+func (w gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 func gzipMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		// Upgraded connections (WebSockets) are taken over by the handler,
+		// leaving nothing for the gzip writer to close
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") || r.Header.Get("Upgrade") != "" {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -93,57 +254,373 @@ func gzipMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// serverTimeouts are the public server's timeouts. ReadHeader bounds how
+// long a client may take to send its request headers, so clients trickling
+// headers (slowloris) cannot hold connections open indefinitely.
+type serverTimeouts struct {
+	Read       time.Duration
+	ReadHeader time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+}
+
+func defaultServerTimeouts() serverTimeouts {
+	return serverTimeouts{
+		Read:       10 * time.Second,
+		ReadHeader: 5 * time.Second,
+		Write:      10 * time.Second,
+		Idle:       60 * time.Second,
+	}
+}
+
+// serverTimeoutsFromEnv overrides the defaults with READ_TIMEOUT,
+// READ_HEADER_TIMEOUT, WRITE_TIMEOUT and IDLE_TIMEOUT, each a positive
+// duration such as 30s. The header timeout may not exceed the read timeout,
+// which covers the headers too.
+func serverTimeoutsFromEnv(getenv func(string) string) (serverTimeouts, error) {
+	timeouts := defaultServerTimeouts()
+
+	for _, setting := range []struct {
+		name  string
+		field *time.Duration
+	}{
+		{"READ_TIMEOUT", &timeouts.Read},
+		{"READ_HEADER_TIMEOUT", &timeouts.ReadHeader},
+		{"WRITE_TIMEOUT", &timeouts.Write},
+		{"IDLE_TIMEOUT", &timeouts.Idle},
+	} {
+		value := getenv(setting.name)
+		if value == "" {
+			continue
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return serverTimeouts{}, fmt.Errorf("invalid %s %q, want a positive duration", setting.name, value)
+		}
+		*setting.field = timeout
+	}
+
+	if timeouts.ReadHeader > timeouts.Read {
+		return serverTimeouts{}, fmt.Errorf("READ_HEADER_TIMEOUT %v exceeds READ_TIMEOUT %v", timeouts.ReadHeader, timeouts.Read)
+	}
+	return timeouts, nil
+}
+
+// newServer returns the public server with timeouts applied.
+func newServer(addr string, handler http.Handler, timeouts serverTimeouts) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       timeouts.Read,
+		ReadHeaderTimeout: timeouts.ReadHeader,
+		WriteTimeout:      timeouts.Write,
+		IdleTimeout:       timeouts.Idle,
+	}
+}
+
+// MaxSampledBody bounds how much of a request body bodySamplingMiddleware
+// logs.
+const MaxSampledBody = 4096
+
+// bodySamplingMiddleware logs the first MaxSampledBody bytes of a random
+// rate (0 to 1) fraction of write request bodies, for diagnosing bad
+// writes without logging every body. The sampled bytes are put back in
+// front of the rest so the handler still reads the whole body. A rate of
+// 0 or less returns next unwrapped.
+func bodySamplingMiddleware(rate float64, next http.Handler) http.Handler {
+	if rate <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions ||
+			r.Body == nil || rand.Float64() >= rate {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// One byte past the limit tells a truncated body from a full one
+		sample, err := io.ReadAll(io.LimitReader(r.Body, MaxSampledBody+1))
+		truncated := len(sample) > MaxSampledBody
+		slog.Info("sampled request body",
+			"method", r.Method,
+			"uri", r.RequestURI,
+			"request_id", r.Header.Get(RequestIDHeader),
+			"body", string(sample[:min(len(sample), MaxSampledBody)]),
+			"truncated", truncated)
+
+		// The server closes the original body, so the re-wrap need not
+		if err == nil {
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(sample), r.Body))
+		} else {
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(sample), errReader{err}))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// errReader fails every read with err, so a body that failed mid-sample
+// fails the handler's read the same way.
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
 func main() {
+	// LOG_LEVEL is error, warn, info (default) or debug. Per-request and
+	// startup detail is only logged at debug.
+	if err := logging.Setup(os.Getenv("LOG_LEVEL")); err != nil {
+		slog.Error("invalid LOG_LEVEL", "err", err)
+		os.Exit(1)
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = DefaultPort
 	}
 	serverAddr := ":" + port
 
-	log.Println("Initializing leaderboard service...")
+	slog.Info("initializing leaderboard service")
 	startTime := time.Now()
 
-	leaderboardService := services.NewLeaderboardService()
+	config := services.DefaultConfig()
+	config.AdminToken = os.Getenv("ADMIN_TOKEN")
+	config.DebugAddr = os.Getenv("DEBUG_ADDR")
+	config.LagAlertWebhook = os.Getenv("LAG_ALERT_WEBHOOK")
+	config.StringIDs = os.Getenv("STRING_IDS") == "true"
+	config.MaskUsernames = os.Getenv("MASK_USERNAMES") == "true"
+	config.SeedFile = os.Getenv("SEED_FILE")
+	config.AsyncInit = os.Getenv("ASYNC_INIT") == "true"
+
+	caseFolding, err := services.ParseCaseFolding(os.Getenv("CASE_FOLDING"))
+	if err != nil {
+		slog.Error("invalid CASE_FOLDING", "err", err)
+		os.Exit(1)
+	}
+	config.CaseFolding = caseFolding
+
+	if path := os.Getenv("USERNAME_BLOCKLIST"); path != "" {
+		blocklist, err := services.LoadUsernameBlocklist(path)
+		if err != nil {
+			slog.Error("invalid USERNAME_BLOCKLIST", "path", path, "err", err)
+			os.Exit(1)
+		}
+		config.UsernameBlocklist = blocklist
+	}
+
+	if path := os.Getenv("API_KEYS"); path != "" {
+		apiKeys, err := services.LoadAPIKeys(path)
+		if err != nil {
+			slog.Error("invalid API_KEYS", "path", path, "err", err)
+			os.Exit(1)
+		}
+		config.APIKeys = apiKeys
+	}
+
+	leaderboardService, err := services.NewLeaderboardServiceChecked(config)
+	if err != nil {
+		slog.Error("failed to initialize users", "err", err)
+		os.Exit(1)
+	}
 
 	elapsed := time.Since(startTime)
-	log.Printf("Leaderboard service initialized in %v", elapsed)
+	slog.Info("leaderboard service initialized", "elapsed", elapsed)
 
 	stats := leaderboardService.GetStats()
-	log.Printf("Stats: %+v", stats)
+	slog.Debug("initial stats", "stats", stats)
 
 	handler := handlers.NewHandler(leaderboardService)
 
+	basePath := normalizeBasePath(os.Getenv("BASE_PATH"))
+
+	// BODY_SAMPLE_RATE logs that fraction (0 to 1) of write request bodies
+	sampleRate := 0.0
+	if value := os.Getenv("BODY_SAMPLE_RATE"); value != "" {
+		sampleRate, err = strconv.ParseFloat(value, 64)
+		if err != nil || sampleRate < 0 || sampleRate > 1 {
+			slog.Error("invalid BODY_SAMPLE_RATE, want a number from 0 to 1", "value", value)
+			os.Exit(1)
+		}
+	}
+
+	cors, err := corsConfigFromEnv(os.Getenv)
+	if err != nil {
+		slog.Error("invalid CORS configuration", "err", err)
+		os.Exit(1)
+	}
+
+	handlerWithMiddleware := withMiddleware(newRouter(handler, basePath), sampleRate, cors)
+
+	slog.Info("starting server", "port", port, "base_path", basePath)
+	logEndpoint("GET /leaderboard?limit=N", "Get top N users (default: 100)")
+	logEndpoint("POST /leaderboard/filter", "Leaderboard entries for {user_ids}, with global ranks")
+	logEndpoint("GET /leaderboard/stream?limit=N", "Server-Sent Events with the top N on every snapshot")
+	logEndpoint("GET /leaderboard/ws?limit=N", "WebSocket of binary top N deltas with periodic keyframes")
+	logEndpoint("POST /leaderboard/multi", "Top N of several boards {boards, limit}")
+	logEndpoint("GET /leaderboard/delta?since=V&limit=N", "Top N entries changed since snapshot version V")
+	logEndpoint("GET /leaderboard/movers?window=N&limit=K", "Biggest rank gains and losses over the last N snapshots")
+	logEndpoint("GET /leaderboard/ladder", "Every occupied rating level with its count and rank")
+	logEndpoint("GET /leaderboard/near?rank=R&radius=N", "Users ranked within N of rank R")
+	logEndpoint("POST /leaderboard/ranges", "Users in several rank windows {ranges: [{from, to}]}")
+	logEndpoint("GET /leaderboard/tier?name=TIER&limit=N", "Top N users of one rating tier, with global ranks")
+	logEndpoint("GET /users/{id}", "A user's rating, rank and users above/below")
+	logEndpoint("GET /users/{id}/rank-history?window=N", "A user's rank in each of the last N snapshots")
+	logEndpoint("GET /users/available?username=NAME", "Whether a username may be used")
+	logEndpoint("POST /users/by-names", "Every user named exactly one of a JSON array of usernames")
+	logEndpoint("GET /rank?username=NAME", "Rank of the best-ranked user with that username")
+	logEndpoint("GET /would-be-top?rating=N", "Whether a rating would beat the current #1, and by how much")
+	logEndpoint("POST /percentiles", "Percentile of each of {user_ids}")
+	logEndpoint("GET /search?query=xyz", "Search users by username")
+	logEndpoint("GET /search/summary?query=xyz", "Rank buckets of the users matching a query")
+	logEndpoint("GET /suggest?query=xyz&k=N", "Closest usernames for a misspelled query")
+	logEndpoint("POST /update", "Queue a rating change {user_id, rating}")
+	logEndpoint("POST /update/batch", "Apply an array of rating changes at once")
+	logEndpoint("POST /update/delta", "Queue a relative rating change {user_id, delta}")
+	logEndpoint("GET /health", "Health check")
+	logEndpoint("GET /stats", "Service statistics")
+	logEndpoint("GET /stats/tiers", "User count per rating tier")
+	logEndpoint("GET /stats/count?min=N&max=N", "User count with a rating in [min, max]")
+	logEndpoint("GET /stats/latency", "p50/p95/p99 response times per route over the last minute")
+	logEndpoint("GET /stats/username-lengths", "Username length histogram and estimated index grams")
+	logEndpoint("GET /stats/index-by-length", "Search index grams and postings per gram length")
+	if config.AdminToken != "" {
+		logEndpoint("GET /admin/selfbench?op=search&n=N", "Run an internal latency benchmark")
+		logEndpoint("POST /admin/import[?partial=true]", "Replace all users from a JSON array")
+		logEndpoint("POST /admin/users", "Add users, evicting the coldest beyond MaxUsers")
+		logEndpoint("POST /admin/rename?user_id=N", "Change a user's username, subject to the blocklist")
+		logEndpoint("POST|DELETE /admin/exclude?user_id=N", "Hide or unhide a user in listings")
+		logEndpoint("GET /admin/config", "Show the effective configuration")
+		logEndpoint("POST|DELETE /admin/simulator/pause", "Pause or resume the update simulator")
+		logEndpoint("POST /admin/rebuild", "Publish a fresh snapshot now")
+	}
+	if cors.Enabled {
+		slog.Debug("CORS enabled for all origins", "methods", cors.AllowedMethods)
+	} else {
+		slog.Debug("CORS disabled")
+	}
+
+	timeouts, err := serverTimeoutsFromEnv(os.Getenv)
+	if err != nil {
+		slog.Error("invalid server timeouts", "err", err)
+		os.Exit(1)
+	}
+
+	server := newServer(serverAddr, handlerWithMiddleware, timeouts)
+	// Shutdown waits for active requests, so end open streams first
+	server.RegisterOnShutdown(leaderboardService.CloseSubscriptions)
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("server failed to start", "err", err)
+			os.Exit(1)
+		}
+	}()
+
+	// Profiling lives on its own listener so it is never exposed on the
+	// public port. No write timeout: CPU profiles stream for many seconds.
+	var debugServer *http.Server
+	if config.DebugAddr != "" {
+		debugServer = &http.Server{
+			Addr:              config.DebugAddr,
+			Handler:           handler.DebugMux(),
+			ReadTimeout:       timeouts.Read,
+			ReadHeaderTimeout: timeouts.ReadHeader,
+		}
+
+		go func() {
+			if err := debugServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("debug server failed", "err", err)
+			}
+		}()
+		slog.Info("debug endpoints enabled", "addr", config.DebugAddr, "paths", "/debug/pprof/, /debug/gc, /debug/ratings")
+	}
+
+	// Wait for a termination signal, then stop accepting requests before
+	// flushing queued rating updates into a final snapshot.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	slog.Info("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("server shutdown failed", "err", err)
+	}
+	if debugServer != nil {
+		debugServer.Close()
+	}
+
+	leaderboardService.Stop()
+	slog.Info("leaderboard service stopped")
+}
+
+// newRouter registers every route and mounts them under basePath (e.g.
+// "/api/v1"), stripping it before the routes see the request, so handlers
+// that parse their own path need not know it. Paths outside basePath are
+// a 404. An empty basePath serves the routes at the root.
+func newRouter(handler *handlers.Handler, basePath string) http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/leaderboard", handler.GetLeaderboard)
+	mux.HandleFunc("/leaderboard/filter", handler.FilterLeaderboard)
+	mux.HandleFunc("/leaderboard/stream", handler.StreamLeaderboard)
+	mux.HandleFunc("/leaderboard/ws", handler.StreamLeaderboardDeltas)
+	mux.HandleFunc("/leaderboard/multi", handler.MultiLeaderboard)
+	mux.HandleFunc("/leaderboard/delta", handler.GetLeaderboardDelta)
+	mux.HandleFunc("/leaderboard/movers", handler.GetMovers)
+	mux.HandleFunc("/leaderboard/ladder", handler.GetRatingLadder)
+	mux.HandleFunc("/leaderboard/near", handler.GetUsersNearRank)
+	mux.HandleFunc("/leaderboard/ranges", handler.GetRankRanges)
+	mux.HandleFunc("/leaderboard/tier", handler.GetTierLeaderboard)
+	mux.HandleFunc("/users/", handler.UserRoutes)
+	mux.HandleFunc("/rank", handler.GetRankByUsername)
+	mux.HandleFunc("/would-be-top", handler.WouldBeTop)
+	mux.HandleFunc("/percentiles", handler.GetPercentiles)
 	mux.HandleFunc("/search", handler.Search)
+	mux.HandleFunc("/search/summary", handler.SearchSummary)
+	mux.HandleFunc("/suggest", handler.Suggest)
+	mux.HandleFunc("/update", handler.SubmitUpdate)
+	mux.HandleFunc("/update/batch", handler.SubmitBatch)
+	mux.HandleFunc("/update/delta", handler.SubmitDelta)
 
 	mux.HandleFunc("/health", handler.HealthCheck)
 	mux.HandleFunc("/stats", handler.GetStats)
+	mux.HandleFunc("/stats/tiers", handler.GetTierDistribution)
+	mux.HandleFunc("/stats/count", handler.GetRatingCount)
+	mux.HandleFunc("/stats/latency", handler.GetLatencyStats)
+	mux.HandleFunc("/stats/username-lengths", handler.GetUsernameLengths)
+	mux.HandleFunc("/stats/index-by-length", handler.GetIndexByLength)
 
-	var handlerWithMiddleware http.Handler = mux
-	handlerWithMiddleware = corsMiddleware(handlerWithMiddleware)
-	handlerWithMiddleware = gzipMiddleware(handlerWithMiddleware)
-	handlerWithMiddleware = loggingMiddleware(handlerWithMiddleware)
-	handlerWithMiddleware = recoveryMiddleware(handlerWithMiddleware)
+	mux.HandleFunc("/admin/selfbench", handler.RequireAdmin(handler.SelfBench))
+	mux.HandleFunc("/admin/import", handler.RequireAdmin(handler.Import))
+	mux.HandleFunc("/admin/users", handler.RequireAdmin(handler.AddUsers))
+	mux.HandleFunc("/admin/rename", handler.RequireAdmin(handler.Rename))
+	mux.HandleFunc("/admin/exclude", handler.RequireAdmin(handler.Exclude))
+	mux.HandleFunc("/admin/config", handler.RequireAdmin(handler.Config))
+	mux.HandleFunc("/admin/simulator/pause", handler.RequireAdmin(handler.PauseSimulator))
+	mux.HandleFunc("/admin/rebuild", handler.RequireAdmin(handler.Rebuild))
 
-	log.Printf("Starting server on port %s", port)
-	log.Println("Available endpoints:")
-	log.Println("  GET /leaderboard?limit=N  - Get top N users (default: 100)")
-	log.Println("  GET /search?query=xyz     - Search users by username")
-	log.Println("  GET /health               - Health check")
-	log.Println("  GET /stats                - Service statistics")
-	log.Println("CORS enabled for all origins")
-
-	server := &http.Server{
-		Addr:         serverAddr,
-		Handler:      handlerWithMiddleware,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  60 * time.Second,
+	routes := handler.RequireReady(handler.RecordLatency(mux))
+	if basePath == "" {
+		return routes
 	}
 
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	root := http.NewServeMux()
+	root.Handle(basePath+"/", http.StripPrefix(basePath, routes))
+	return root
+}
+
+// normalizeBasePath turns a configured prefix such as "api/v1/" into the
+// "/api/v1" form newRouter expects; "" and "/" mean the root.
+func normalizeBasePath(basePath string) string {
+	basePath = strings.Trim(strings.TrimSpace(basePath), "/")
+	if basePath == "" {
+		return ""
 	}
+	return "/" + basePath
+}
+
+func logEndpoint(route, description string) {
+	slog.Debug("endpoint", "route", route, "description", description)
 }
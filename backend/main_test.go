@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"matiks-backend/handlers"
+	"matiks-backend/logging"
+	"matiks-backend/services"
+)
+
+func TestRecoveryMiddleware_JSONErrorAndStack(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&logs, slog.LevelError))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	handler := recoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/leaderboard", nil)
+	req.Header.Set(RequestIDHeader, "req-123")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Expected a JSON response, got Content-Type %q", got)
+	}
+
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body["error"] == "" || body["request_id"] != "req-123" {
+		t.Errorf("Expected an error with request_id req-123, got %v", body)
+	}
+
+	logged := logs.String()
+	for _, want := range []string{"panic recovered", "panic=boom", "request_id=req-123", "stack=", "TestRecoveryMiddleware_JSONErrorAndStack"} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("Expected log to contain %q, got:\n%s", want, logged)
+		}
+	}
+}
+
+func TestMiddleware_PanicWithGzipIsCompressedJSON(t *testing.T) {
+	previous := slog.Default()
+	slog.SetDefault(logging.New(io.Discard, slog.LevelError))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	handler := withMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), 0, defaultCORSConfig())
+
+	req := httptest.NewRequest(http.MethodGet, "/leaderboard", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set(RequestIDHeader, "req-123")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Expected a gzip response, got Content-Encoding %q", got)
+	}
+
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("Failed to open gzip body: %v", err)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(gz).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body["error"] == "" || body["request_id"] != "req-123" {
+		t.Errorf("Expected an error with request_id req-123, got %v", body)
+	}
+	if rest, err := io.ReadAll(gz); err != nil || len(bytes.TrimSpace(rest)) != 0 {
+		t.Errorf("Expected only the JSON error in a complete gzip stream, got %q (%v)", rest, err)
+	}
+}
+
+func TestBodySamplingMiddleware_HandlerStillReadsBody(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&logs, slog.LevelInfo))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	var received []byte
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		if received, err = io.ReadAll(r.Body); err != nil {
+			t.Errorf("Reading the body failed: %v", err)
+		}
+	})
+
+	small := `{"user_id": 1, "rating": 4200}`
+	large := strings.Repeat("x", MaxSampledBody+100)
+
+	for _, rate := range []float64{0, 1} {
+		for _, body := range []string{small, large} {
+			logs.Reset()
+			req := httptest.NewRequest(http.MethodPost, "/update", strings.NewReader(body))
+			bodySamplingMiddleware(rate, echo).ServeHTTP(httptest.NewRecorder(), req)
+
+			if string(received) != body {
+				t.Errorf("Rate %v: expected the handler to read all %d bytes, got %d", rate, len(body), len(received))
+			}
+			if sampled := strings.Contains(logs.String(), "sampled request body"); sampled != (rate == 1) {
+				t.Errorf("Rate %v: expected sampled %v, got logs:\n%s", rate, rate == 1, logs.String())
+			}
+		}
+	}
+
+	// The logged sample is bounded and marked as cut short
+	if logged := logs.String(); !strings.Contains(logged, "truncated=true") || strings.Contains(logged, large) {
+		t.Errorf("Expected a truncated sample of the large body, got:\n%s", logged)
+	}
+
+	// Reads are never sampled
+	logs.Reset()
+	bodySamplingMiddleware(1, echo).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/leaderboard", nil))
+	if logs.Len() != 0 {
+		t.Errorf("Expected no sample for a GET, got:\n%s", logs.String())
+	}
+}
+
+func TestCORSMiddleware_Disabled(t *testing.T) {
+	config, err := corsConfigFromEnv(envMap{"CORS_ENABLED": "false"}.get)
+	if err != nil {
+		t.Fatalf("corsConfigFromEnv failed: %v", err)
+	}
+
+	var reached bool
+	handler := corsMiddleware(config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+
+	req := httptest.NewRequest(http.MethodOptions, "/leaderboard", nil)
+	req.Header.Set("Origin", "https://example.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	for name := range rec.Header() {
+		if strings.HasPrefix(name, "Access-Control-") {
+			t.Errorf("Expected no CORS headers, got %s", name)
+		}
+	}
+	if !reached || rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected the preflight passed through to the handler, got %d", rec.Code)
+	}
+}
+
+func TestCORSMiddleware_RestrictedMethods(t *testing.T) {
+	config, err := corsConfigFromEnv(envMap{
+		"CORS_METHODS":     "get, post",
+		"CORS_HEADERS":     "Content-Type",
+		"CORS_MAX_AGE":     "10m",
+		"CORS_CREDENTIALS": "false",
+	}.get)
+	if err != nil {
+		t.Fatalf("corsConfigFromEnv failed: %v", err)
+	}
+
+	handler := corsMiddleware(config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodOptions, "/update", nil)
+	req.Header.Set("Origin", "https://example.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Expected a 204 preflight, got %d", rec.Code)
+	}
+	for name, want := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://example.com",
+		"Access-Control-Allow-Methods":     "GET, POST",
+		"Access-Control-Allow-Headers":     "Content-Type",
+		"Access-Control-Max-Age":           "600",
+		"Access-Control-Allow-Credentials": "",
+	} {
+		if got := rec.Header().Get(name); got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestCORSConfigFromEnv_Invalid(t *testing.T) {
+	for _, env := range []envMap{
+		{"CORS_ENABLED": "maybe"},
+		{"CORS_METHODS": "GET,,POST"},
+		{"CORS_MAX_AGE": "-1s"},
+		{"CORS_MAX_AGE": "3600"},
+	} {
+		if _, err := corsConfigFromEnv(env.get); err == nil {
+			t.Errorf("Expected %v to be rejected", env)
+		}
+	}
+
+	// Without any variables the defaults apply
+	config, err := corsConfigFromEnv(envMap{}.get)
+	if err != nil || !config.Enabled || config.MaxAge != time.Hour || !config.AllowCredentials {
+		t.Errorf("Expected the defaults, got %+v (%v)", config, err)
+	}
+}
+
+func TestNewServer_TimeoutsFromEnv(t *testing.T) {
+	timeouts, err := serverTimeoutsFromEnv(envMap{
+		"READ_TIMEOUT":        "30s",
+		"READ_HEADER_TIMEOUT": "2s",
+		"IDLE_TIMEOUT":        "2m",
+	}.get)
+	if err != nil {
+		t.Fatalf("serverTimeoutsFromEnv failed: %v", err)
+	}
+
+	server := newServer(":0", http.NotFoundHandler(), timeouts)
+	if server.ReadTimeout != 30*time.Second || server.ReadHeaderTimeout != 2*time.Second ||
+		server.IdleTimeout != 2*time.Minute {
+		t.Errorf("Expected the configured timeouts, got read %v, header %v, idle %v",
+			server.ReadTimeout, server.ReadHeaderTimeout, server.IdleTimeout)
+	}
+	// Unset variables keep their defaults
+	if server.WriteTimeout != 10*time.Second {
+		t.Errorf("Expected the default 10s write timeout, got %v", server.WriteTimeout)
+	}
+
+	for _, env := range []envMap{
+		{"READ_TIMEOUT": "soon"},
+		{"WRITE_TIMEOUT": "0s"},
+		{"IDLE_TIMEOUT": "-1m"},
+		{"READ_TIMEOUT": "10s", "READ_HEADER_TIMEOUT": "20s"},
+	} {
+		if _, err := serverTimeoutsFromEnv(env.get); err == nil {
+			t.Errorf("Expected %v to be rejected", env)
+		}
+	}
+}
+
+// envMap stands in for os.Getenv.
+type envMap map[string]string
+
+func (e envMap) get(name string) string { return e[name] }
+
+func TestNewRouter_BasePath(t *testing.T) {
+	config := services.DefaultConfig()
+	config.DisableSimulator = true
+	service := services.NewLeaderboardServiceWithConfig(config)
+	t.Cleanup(service.Stop)
+	handler := handlers.NewHandler(service)
+
+	tests := []struct {
+		basePath string
+		target   string
+		want     int
+	}{
+		{"", "/health", http.StatusOK},
+		{"", "/users/1", http.StatusOK},
+		{"/api/v1", "/api/v1/health", http.StatusOK},
+		{"/api/v1", "/api/v1/users/1", http.StatusOK}, // parses its own path
+		{"/api/v1", "/api/v1/leaderboard?limit=5", http.StatusOK},
+		{"/api/v1", "/health", http.StatusNotFound},
+		{"/api/v1", "/users/1", http.StatusNotFound},
+		{"/api/v1", "/api/v2/health", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		newRouter(handler, tt.basePath).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rec.Code != tt.want {
+			t.Errorf("base path %q, %s: expected %d, got %d", tt.basePath, tt.target, tt.want, rec.Code)
+		}
+	}
+}
+
+func TestNormalizeBasePath(t *testing.T) {
+	for input, want := range map[string]string{
+		"":         "",
+		"/":        "",
+		"/api/v1":  "/api/v1",
+		"api/v1/":  "/api/v1",
+		" /v1/ ":   "/v1",
+		"/api/v1/": "/api/v1",
+	} {
+		if got := normalizeBasePath(input); got != want {
+			t.Errorf("normalizeBasePath(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
@@ -3,10 +3,35 @@ package models
 type User struct {
 	ID       int    `json:"id"`
 	Username string `json:"username"`
+
+	// Metadata is set when the user is added and never modified
+	// afterwards, so it may be shared. Nil means none.
+	Metadata *UserMetadata `json:"metadata,omitempty"`
+}
+
+// UserMetadata is optional profile detail that clients may ask to see
+// alongside a user's leaderboard and search entries.
+type UserMetadata struct {
+	Country string `json:"country,omitempty"` // e.g. "IN"
+	Avatar  string `json:"avatar,omitempty"`  // image URL
+	Joined  string `json:"joined,omitempty"`  // join date, "2006-01-02"
 }
 
 type LeaderboardEntry struct {
 	Rank     int    `json:"rank"`
 	Username string `json:"username"`
 	Rating   int    `json:"rating"`
+	IsSelf   bool   `json:"is_self,omitempty"` // the requesting user's own entry
+
+	// Metadata holds the metadata fields the request asked to include
+	Metadata *UserMetadata `json:"metadata,omitempty"`
+}
+
+// UserSeed describes a user and their starting rating for bulk loading.
+type UserSeed struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Rating   int    `json:"rating"`
+
+	Metadata *UserMetadata `json:"metadata,omitempty"`
 }
@@ -0,0 +1,567 @@
+// Package msgpack implements the subset of MessagePack
+// (https://github.com/msgpack/msgpack/blob/master/spec.md) needed to encode
+// API responses. Structs are encoded as maps with the fields encoding/json
+// would write, keyed by their `json` tag names, so msgpack and JSON responses
+// have the same shape.
+package msgpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+const ContentType = "application/x-msgpack"
+
+// Marshal returns the MessagePack encoding of v.
+func Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type Encoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the MessagePack encoding of v to the underlying writer.
+func (e *Encoder) Encode(v interface{}) error {
+	e.buf = e.buf[:0]
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return err
+	}
+	_, err := e.w.Write(e.buf)
+	return err
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (e *Encoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf = append(e.buf, 0xc0)
+		return nil
+	}
+
+	if v.Type() == timeType {
+		e.encodeString(v.Interface().(time.Time).Format(time.RFC3339Nano))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		e.buf = append(e.buf, 0xcb)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v.Float()))
+	case reflect.String:
+		e.encodeString(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.encodeBytes(v.Bytes())
+			return nil
+		}
+		return e.encodeArray(v)
+	case reflect.Array:
+		return e.encodeArray(v)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		e.encodeLength(v.Len(), 0x80, 0xde, 0xdf)
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := e.encode(iter.Key()); err != nil {
+				return err
+			}
+			if err := e.encode(iter.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		return e.encodeStruct(v)
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		return e.encode(v.Elem())
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func (e *Encoder) encodeInt(n int64) {
+	switch {
+	case n >= 0:
+		e.encodeUint(uint64(n))
+	case n >= -32:
+		e.buf = append(e.buf, byte(n))
+	case n >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		e.buf = append(e.buf, 0xd1)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n >= math.MinInt32:
+		e.buf = append(e.buf, 0xd2)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(n))
+	}
+}
+
+func (e *Encoder) encodeUint(n uint64) {
+	switch {
+	case n <= 0x7f:
+		e.buf = append(e.buf, byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xcd)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = append(e.buf, 0xce)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, 0xcf)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, n)
+	}
+}
+
+func (e *Encoder) encodeString(s string) {
+	n := len(s)
+	switch {
+	case n <= 31:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xda)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdb)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *Encoder) encodeBytes(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xc5)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xc6)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+// encodeLength writes an array or map header: fix is the fixarray/fixmap
+// prefix, op16/op32 the 16- and 32-bit length opcodes.
+func (e *Encoder) encodeLength(n int, fix, op16, op32 byte) {
+	switch {
+	case n <= 15:
+		e.buf = append(e.buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, op16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, op32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+func (e *Encoder) encodeArray(v reflect.Value) error {
+	e.encodeLength(v.Len(), 0x90, 0xdc, 0xdd)
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type field struct {
+	name      string
+	index     []int // reflect field index path, through embedded structs
+	tagged    bool  // named by its json tag
+	omitEmpty bool
+}
+
+// structFields lists the fields encoding/json would encode for t, in the
+// same order: the fields of embedded structs without a json name are
+// promoted into t, a field hides deeper ones of the same name, and of
+// several at the same depth only a single tagged one is kept.
+func structFields(t reflect.Type) []field {
+	var all []field
+	collectFields(t, nil, map[reflect.Type]bool{t: true}, &all)
+
+	byName := make(map[string][]field, len(all))
+	for _, f := range all {
+		byName[f.name] = append(byName[f.name], f)
+	}
+
+	fields := make([]field, 0, len(all))
+	for _, f := range all {
+		if dominant(byName[f.name]) == len(f.index) && f.dominates(byName[f.name]) {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func collectFields(t reflect.Type, index []int, visited map[reflect.Type]bool, fields *[]field) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		// Exported fields of unexported embedded structs are still promoted
+		if !sf.IsExported() && !(sf.Anonymous && ft.Kind() == reflect.Struct) {
+			continue
+		}
+
+		f := field{name: sf.Name, index: append(slices.Clone(index), i)}
+		if tag, ok := sf.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			parts := strings.Split(tag, ",")
+			if parts[0] != "" {
+				f.name, f.tagged = parts[0], true
+			}
+			f.omitEmpty = slices.Contains(parts[1:], "omitempty")
+		}
+
+		if sf.Anonymous && !f.tagged && ft.Kind() == reflect.Struct {
+			if !visited[ft] {
+				visited[ft] = true
+				collectFields(ft, f.index, visited, fields)
+				delete(visited, ft)
+			}
+			continue
+		}
+		*fields = append(*fields, f)
+	}
+}
+
+// dominant returns the shallowest depth among fields sharing a name.
+func dominant(fields []field) int {
+	depth := len(fields[0].index)
+	for _, f := range fields[1:] {
+		depth = min(depth, len(f.index))
+	}
+	return depth
+}
+
+// dominates reports whether f, at the shallowest depth of its name, is the
+// one encoding/json keeps: the only field there, or the only tagged one.
+func (f field) dominates(same []field) bool {
+	rivals, taggedRivals := 0, 0
+	for _, other := range same {
+		if len(other.index) != len(f.index) || slices.Equal(other.index, f.index) {
+			continue
+		}
+		rivals++
+		if other.tagged {
+			taggedRivals++
+		}
+	}
+	return rivals == 0 || (f.tagged && taggedRivals == 0)
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex that reports false instead of
+// panicking when the path goes through a nil embedded pointer, whose
+// fields encoding/json leaves out.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// isEmpty is encoding/json's omitempty test: false, 0, nil pointers and
+// interfaces, and empty arrays, maps, slices and strings. Structs are
+// never empty.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+func (e *Encoder) encodeStruct(v reflect.Value) error {
+	fields := structFields(v.Type())
+
+	present := fields[:0:0]
+	values := make([]reflect.Value, 0, len(fields))
+	for _, f := range fields {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || (f.omitEmpty && isEmpty(fv)) {
+			continue
+		}
+		present = append(present, f)
+		values = append(values, fv)
+	}
+
+	e.encodeLength(len(present), 0x80, 0xde, 0xdf)
+	for i, f := range present {
+		e.encodeString(f.name)
+		if err := e.encode(values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var errShortBuffer = errors.New("msgpack: unexpected end of data")
+
+// Unmarshal decodes MessagePack data into generic Go values: maps become
+// map[string]interface{} (or map[interface{}]interface{} for non-string
+// keys), arrays []interface{}, integers int64 or uint64, floats float64.
+func Unmarshal(data []byte) (interface{}, error) {
+	d := decoder{data: data}
+	v, err := d.decode()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("msgpack: %d trailing bytes", len(d.data)-d.pos)
+	}
+	return v, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if d.pos+n > len(d.data) {
+		return nil, errShortBuffer
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) uint(n int) (uint64, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+func (d *decoder) decode() (interface{}, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	op := b[0]
+
+	switch {
+	case op <= 0x7f:
+		return int64(op), nil
+	case op >= 0xe0:
+		return int64(int8(op)), nil
+	case op&0xe0 == 0xa0:
+		return d.str(int(op & 0x1f))
+	case op&0xf0 == 0x90:
+		return d.array(int(op & 0x0f))
+	case op&0xf0 == 0x80:
+		return d.mapping(int(op & 0x0f))
+	}
+
+	switch op {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.uint(1 << (op - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if n <= math.MaxInt64 {
+			return int64(n), nil
+		}
+		return n, nil
+	case 0xd0:
+		n, err := d.uint(1)
+		return int64(int8(n)), err
+	case 0xd1:
+		n, err := d.uint(2)
+		return int64(int16(n)), err
+	case 0xd2:
+		n, err := d.uint(4)
+		return int64(int32(n)), err
+	case 0xd3:
+		n, err := d.uint(8)
+		return int64(n), err
+	case 0xca:
+		n, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (op - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (op - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		raw, err := d.next(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), raw...), nil
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (op - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (op - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapping(int(n))
+	}
+
+	return nil, fmt.Errorf("msgpack: unsupported type byte 0x%02x", op)
+}
+
+func (d *decoder) str(n int) (interface{}, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (d *decoder) array(n int) (interface{}, error) {
+	if n > len(d.data)-d.pos {
+		return nil, errShortBuffer
+	}
+	arr := make([]interface{}, n)
+	for i := range arr {
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = v
+	}
+	return arr, nil
+}
+
+func (d *decoder) mapping(n int) (interface{}, error) {
+	if n > len(d.data)-d.pos {
+		return nil, errShortBuffer
+	}
+	strMap := make(map[string]interface{}, n)
+	var anyMap map[interface{}]interface{}
+
+	for i := 0; i < n; i++ {
+		k, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+
+		switch key := k.(type) {
+		case []interface{}, map[string]interface{}, map[interface{}]interface{}:
+			return nil, errors.New("msgpack: unsupported map key type")
+		case []byte:
+			k = string(key)
+		}
+
+		if key, ok := k.(string); ok && anyMap == nil {
+			strMap[key] = v
+			continue
+		}
+
+		if anyMap == nil {
+			anyMap = make(map[interface{}]interface{}, n)
+			for sk, sv := range strMap {
+				anyMap[sk] = sv
+			}
+		}
+		anyMap[k] = v
+	}
+
+	if anyMap != nil {
+		return anyMap, nil
+	}
+	return strMap, nil
+}
@@ -0,0 +1,206 @@
+package msgpack
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"matiks-backend/models"
+	"matiks-backend/services"
+)
+
+func TestRoundTrip_Scalars(t *testing.T) {
+	tests := []struct {
+		name  string
+		input interface{}
+		want  interface{}
+	}{
+		{"nil", nil, nil},
+		{"true", true, true},
+		{"false", false, false},
+		{"positive fixint", 7, int64(7)},
+		{"negative fixint", -5, int64(-5)},
+		{"uint8", 200, int64(200)},
+		{"uint16", 60000, int64(60000)},
+		{"uint32", 4000000000, int64(4000000000)},
+		{"int8", -100, int64(-100)},
+		{"int16", -30000, int64(-30000)},
+		{"int32", -2000000000, int64(-2000000000)},
+		{"int64", int64(math.MinInt64), int64(math.MinInt64)},
+		{"uint64", uint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{"float", 3.25, 3.25},
+		{"fixstr", "rahul", "rahul"},
+		{"str8", strings.Repeat("a", 100), strings.Repeat("a", 100)},
+		{"str16", strings.Repeat("b", 1000), strings.Repeat("b", 1000)},
+		{"bytes", []byte{1, 2, 3}, []byte{1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.input)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+
+			got, err := Unmarshal(data)
+			if err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Round trip: got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundTrip_StructUsesJSONTags(t *testing.T) {
+	type entry struct {
+		Rank     int    `json:"rank"`
+		Username string `json:"username"`
+		Hidden   string `json:"-"`
+		Optional string `json:"optional,omitempty"`
+		Plain    int
+	}
+
+	input := map[string]interface{}{
+		"data":  []entry{{Rank: 1, Username: "amit", Hidden: "x", Plain: 2}},
+		"count": 1,
+	}
+
+	data, err := Marshal(input)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"data": []interface{}{
+			map[string]interface{}{"rank": int64(1), "username": "amit", "Plain": int64(2)},
+		},
+		"count": int64(1),
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Round trip: got %#v, want %#v", got, want)
+	}
+}
+
+// sameAsJSON checks that v decodes from MessagePack to the same value as
+// from encoding/json, once msgpack's integers are put through JSON too.
+func sameAsJSON(t *testing.T, v interface{}) {
+	t.Helper()
+
+	data, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	decoded, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	var got, want interface{}
+	viaJSON, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatalf("json.Marshal of decoded msgpack failed: %v", err)
+	}
+	if err := json.Unmarshal(viaJSON, &got); err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	if err := json.Unmarshal(encoded, &want); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MessagePack body %s differs from JSON body %s", viaJSON, encoded)
+	}
+}
+
+func TestRoundTrip_EmbeddedStructsMatchJSON(t *testing.T) {
+	type base struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	type extra struct {
+		Note string `json:"note,omitempty"`
+	}
+	type named struct {
+		Level int `json:"level"`
+	}
+	type response struct {
+		base                        // unexported, still promoted
+		*extra                      // nil: its fields are left out
+		named  `json:"named"`       // tagged: kept nested
+		Name   string               `json:"name"` // hides base.Name
+		Tags   []string             `json:"tags,omitempty"`
+		Inner  struct{}             `json:"inner,omitempty"` // structs are never empty
+		Meta   *models.UserMetadata `json:"meta,omitempty"`
+	}
+
+	sameAsJSON(t, response{base: base{ID: 7, Name: "hidden"}, named: named{Level: 2}, Name: "rahul", Tags: []string{}})
+	sameAsJSON(t, response{extra: &extra{Note: "promoted"}})
+}
+
+func TestRoundTrip_ResponseTypesMatchJSON(t *testing.T) {
+	sameAsJSON(t, services.ProfileContext{
+		UserProfile: services.UserProfile{ID: 3, Username: "rahul", Rating: 4700, Rank: 1, UsersBelow: 2},
+		Percentile:  99.5,
+		Tier:        "Diamond",
+		Version:     12,
+		Below:       []models.LeaderboardEntry{{Rank: 2, Username: "priya", Rating: 4600}},
+	})
+	sameAsJSON(t, []services.RankRangeEntries{{
+		RankRange: services.RankRange{From: 1, To: 10},
+		Entries:   []models.LeaderboardEntry{{Rank: 1, Username: "rahul", Rating: 4700}},
+		Count:     1,
+	}})
+}
+
+func TestRoundTrip_LargeArray(t *testing.T) {
+	input := make([]int, 70000)
+	for i := range input {
+		input[i] = i
+	}
+
+	data, err := Marshal(input)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	arr := got.([]interface{})
+	if len(arr) != len(input) || arr[69999] != int64(69999) {
+		t.Errorf("Large array did not round trip (len %d)", len(arr))
+	}
+}
+
+func TestUnmarshal_Truncated(t *testing.T) {
+	data, _ := Marshal(map[string]interface{}{"username": "rahul_kumar"})
+
+	for i := 0; i < len(data); i++ {
+		if _, err := Unmarshal(data[:i]); err == nil {
+			t.Errorf("Expected error for data truncated to %d bytes", i)
+		}
+	}
+}
+
+func TestMarshal_UnsupportedType(t *testing.T) {
+	if _, err := Marshal(make(chan int)); err == nil {
+		t.Error("Expected error for channel")
+	}
+}
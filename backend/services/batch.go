@@ -0,0 +1,50 @@
+package services
+
+// ApplyBatch applies updates together on the writer goroutine and publishes
+// a snapshot before returning, so every update in the batch becomes visible
+// at once. If any row is invalid the whole batch is rejected with
+// ValidationErrors; callers wanting partial application should drop the rows
+// reported by ValidateUpdates first. Every row counts against its user's
+// rate limit, as a SubmitUpdate would: if any user would go over it, the
+// whole batch fails with ErrRateLimited and no tokens are taken.
+func (s *LeaderboardService) ApplyBatch(updates []RatingUpdate) error {
+	if s.stopped.Load() {
+		return ErrServiceStopped
+	}
+
+	var errs ValidationErrors
+	limited := false
+	err := s.runOnWriter(func() {
+		// Validated here rather than up front so a concurrent ReplaceAll
+		// cannot remove a user between the check and the apply. Only known
+		// users reach the limiter.
+		if errs = s.validateUpdates(updates); len(errs) > 0 {
+			return
+		}
+		if s.updateLimiter != nil {
+			userIDs := make([]int, len(updates))
+			for i, update := range updates {
+				userIDs[i] = update.UserID
+			}
+			if limited = !s.updateLimiter.allowAll(userIDs, s.clock()); limited {
+				return
+			}
+		}
+
+		for _, update := range updates {
+			s.applyUpdate(update)
+		}
+		s.rebuildSnapshot()
+	})
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	if limited {
+		s.rateLimitedUpdates.Add(1)
+		return ErrRateLimited
+	}
+	return nil
+}
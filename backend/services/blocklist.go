@@ -0,0 +1,100 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ErrUsernameBlocked is returned by ValidateUsername for a username matching
+// Config.UsernameBlocklist.
+var ErrUsernameBlocked = errors.New("username is not allowed")
+
+// UsernameBlocklist rejects offensive usernames. Rules are
+// case-insensitive: a plain rule matches anywhere in the username, and a
+// rule written "re:<pattern>" is a regular expression.
+type UsernameBlocklist struct {
+	substrings []string
+	patterns   []*regexp.Regexp
+}
+
+// NewUsernameBlocklist compiles rules as described on UsernameBlocklist.
+func NewUsernameBlocklist(rules []string) (*UsernameBlocklist, error) {
+	b := &UsernameBlocklist{}
+	for _, rule := range rules {
+		if pattern, ok := strings.CutPrefix(rule, "re:"); ok {
+			re, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				return nil, fmt.Errorf("blocklist rule %q: %w", rule, err)
+			}
+			b.patterns = append(b.patterns, re)
+			continue
+		}
+		b.substrings = append(b.substrings, strings.ToLower(rule))
+	}
+	return b, nil
+}
+
+// ParseUsernameBlocklist reads one rule per line. Blank lines and lines
+// starting with # are skipped; surrounding whitespace is trimmed.
+func ParseUsernameBlocklist(r io.Reader) (*UsernameBlocklist, error) {
+	var rules []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rules = append(rules, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewUsernameBlocklist(rules)
+}
+
+// LoadUsernameBlocklist parses the blocklist file at path.
+func LoadUsernameBlocklist(path string) (*UsernameBlocklist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseUsernameBlocklist(f)
+}
+
+// Blocks reports whether username matches any rule. A nil blocklist
+// blocks nothing.
+func (b *UsernameBlocklist) Blocks(username string) bool {
+	if b == nil {
+		return false
+	}
+
+	lower := strings.ToLower(username)
+	for _, substring := range b.substrings {
+		if strings.Contains(lower, substring) {
+			return true
+		}
+	}
+	for _, re := range b.patterns {
+		if re.MatchString(username) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len is the number of rules.
+func (b *UsernameBlocklist) Len() int {
+	if b == nil {
+		return 0
+	}
+	return len(b.substrings) + len(b.patterns)
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/cases"
+)
+
+// CaseFolding is how usernames and queries are made case-insensitive. The
+// same folding is applied when indexing and when querying, so they always
+// agree.
+type CaseFolding string
+
+const (
+	// CaseFoldingSimple lowercases rune by rune (strings.ToLower). It is
+	// the default.
+	CaseFoldingSimple CaseFolding = "simple"
+
+	// CaseFoldingFull applies Unicode full case folding, which also unifies
+	// forms lowercasing leaves apart, e.g. "ß" and "ss", or the long s "ſ"
+	// and "s". It is slower and changes which names collide.
+	CaseFoldingFull CaseFolding = "full"
+)
+
+// ParseCaseFolding validates a case folding name. An empty value means
+// CaseFoldingSimple.
+func ParseCaseFolding(value string) (CaseFolding, error) {
+	switch folding := CaseFolding(strings.ToLower(value)); folding {
+	case "":
+		return CaseFoldingSimple, nil
+	case CaseFoldingSimple, CaseFoldingFull:
+		return folding, nil
+	default:
+		return "", fmt.Errorf("unknown case folding %q", value)
+	}
+}
+
+// fold returns the case-insensitive form of text.
+func (f CaseFolding) fold(text string) string {
+	if f == CaseFoldingFull {
+		// A Caser keeps state, so it can't be shared between goroutines
+		return cases.Fold().String(text)
+	}
+	return strings.ToLower(text)
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ClientTier bounds the leaderboard limits an API client may request.
+type ClientTier struct {
+	Name         string `json:"name"`
+	DefaultLimit int    `json:"default_limit"` // used when no limit is given
+	MaxLimit     int    `json:"max_limit"`     // larger limits are clamped; 0 means none
+}
+
+// FreeTier is the tier of clients without a known API key.
+const FreeTier = "free"
+
+// DefaultClientTiers are a free tier and a premium tier with a ten times
+// larger maximum.
+func DefaultClientTiers() []ClientTier {
+	return []ClientTier{
+		{Name: FreeTier, DefaultLimit: 100, MaxLimit: 1000},
+		{Name: "premium", DefaultLimit: 100, MaxLimit: 10000},
+	}
+}
+
+func (s *LeaderboardService) clientTiers() []ClientTier {
+	if s.config.ClientTiers == nil {
+		return DefaultClientTiers()
+	}
+	return s.config.ClientTiers
+}
+
+// ClientTierFor returns the tier Config.APIKeys assigns to apiKey. Unknown
+// and empty keys, and keys naming a tier that isn't configured, get
+// FreeTier; if that isn't configured either, an unlimited tier with the
+// historical default limit of 100.
+func (s *LeaderboardService) ClientTierFor(apiKey string) ClientTier {
+	name, ok := s.config.APIKeys[apiKey]
+	if !ok || apiKey == "" {
+		name = FreeTier
+	}
+
+	var free *ClientTier
+	tiers := s.clientTiers()
+	for i := range tiers {
+		if tiers[i].Name == name {
+			return tiers[i]
+		}
+		if tiers[i].Name == FreeTier {
+			free = &tiers[i]
+		}
+	}
+
+	if free != nil {
+		return *free
+	}
+	return ClientTier{Name: FreeTier, DefaultLimit: 100}
+}
+
+// ClampLimit applies the tier to a requested limit: zero means the tier's
+// default, and anything above MaxLimit is reduced to it.
+func (t ClientTier) ClampLimit(limit int) int {
+	if limit == 0 {
+		limit = t.DefaultLimit
+	}
+	if t.MaxLimit > 0 && limit > t.MaxLimit {
+		limit = t.MaxLimit
+	}
+	return limit
+}
+
+// ParseAPIKeys reads Config.APIKeys from lines of "<key> <tier>". Blank
+// lines and lines starting with # are skipped; a key may appear only once.
+func ParseAPIKeys(r io.Reader) (map[string]string, error) {
+	keys := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: want \"<key> <tier>\"", line)
+		}
+		if _, ok := keys[fields[0]]; ok {
+			return nil, fmt.Errorf("line %d: duplicate API key", line)
+		}
+		keys[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// LoadAPIKeys parses the API key file at path.
+func LoadAPIKeys(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseAPIKeys(f)
+}
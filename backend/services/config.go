@@ -0,0 +1,348 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"matiks-backend/models"
+	"matiks-backend/snapshot"
+)
+
+// Config holds the tunable behaviour of a LeaderboardService.
+// The zero value is usable and behaves like DefaultConfig for every
+// field whose zero value is documented as the default.
+type Config struct {
+	// DefaultSearchOrder is applied when a search request doesn't ask for
+	// a specific ordering. Empty means SearchOrderRank.
+	DefaultSearchOrder SearchOrder
+
+	// Tiers are the named rating bands used for tier reporting, lowest
+	// first and non-overlapping (see ValidateTiers). Nil means DefaultTiers.
+	Tiers []Tier
+
+	// ClientTiers are the leaderboard limit tiers for API clients, and
+	// APIKeys maps each known API key to a tier name. Clients without a
+	// known key get FreeTier. Nil ClientTiers means DefaultClientTiers.
+	ClientTiers []ClientTier
+	APIKeys     map[string]string
+
+	// UsernameBlocklist rejects matching usernames at ingest (see
+	// ValidateUsername). Nil allows every non-empty username.
+	UsernameBlocklist *UsernameBlocklist
+
+	// IndexSingleChars builds an extra char -> user IDs index so that
+	// 1-character searches use a posting list instead of scanning every
+	// user. It roughly adds one posting per distinct character per user,
+	// so it is off by default.
+	IndexSingleChars bool
+
+	// CaseFolding makes usernames and search queries case-insensitive, the
+	// same way at index and query time. Empty means CaseFoldingSimple.
+	CaseFolding CaseFolding
+
+	// CompactSearchIndex indexes only trigrams instead of every 2- to
+	// 5-gram, for memory-constrained deployments. Queries of 3+ characters
+	// intersect trigram posting lists (every candidate is still verified);
+	// 2-character queries fall back to a linear scan, and suggestions are
+	// pre-filtered on shared trigrams instead of bigrams.
+	CompactSearchIndex bool
+
+	// SearchIndexRebuildInterval, if set, rebuilds the search indexes from
+	// the current users that often (see RebuildSearchIndex), reclaiming
+	// postings left behind by removed or renamed users. Zero disables it.
+	SearchIndexRebuildInterval time.Duration
+
+	// MaxSearchCandidates bounds the work of very broad searches: when the
+	// two most selective posting lists of a query still share more users,
+	// intersection stops and only that many candidates are verified, with
+	// the result reported as truncated. Zero means no limit.
+	MaxSearchCandidates int
+
+	// EmptySearchNoContent answers searches that match nobody with
+	// 204 No Content instead of 200 and an empty data array, for clients
+	// that treat the two differently. Truncated searches still get a 200,
+	// since more users might have matched.
+	EmptySearchNoContent bool
+
+	// SeedUsers is the initial population. Nil means the users in SeedFile
+	// or, without one, InitialUsers generated users, whose usernames
+	// deliberately collide.
+	SeedUsers []models.UserSeed
+
+	// SeedFile is a CSV of "id,username,rating" rows (see ParseSeedCSV)
+	// loaded as the initial population when SeedUsers is nil. Malformed
+	// rows and users failing ValidateUsers make construction fail.
+	SeedFile string
+
+	// AsyncInit indexes the initial population and builds the first
+	// snapshot in the background, so the constructor returns after loading
+	// and validating the seeds rather than seconds later for very large
+	// populations. Until Ready, readers see an empty leaderboard. The
+	// constructor still waits up to InitGracePeriod, so small populations
+	// are ready on return.
+	AsyncInit       bool
+	InitGracePeriod time.Duration
+
+	// beforeAsyncLoad, when set by tests, runs on the writer goroutine
+	// before an asynchronous load, so they can observe the service while it
+	// is still warming up.
+	beforeAsyncLoad func()
+
+	// UniqueUsernames makes a username in use (exact, case sensitive)
+	// unavailable to anyone else: AddUsers rejects taken names, imports
+	// reject names repeated within them, and construction fails if the
+	// initial population repeats a name, so it needs SeedUsers. Off by
+	// default.
+	UniqueUsernames bool
+
+	// MaxUsers caps the population. Adding users beyond it (AddUsers)
+	// first evicts the coldest: lowest rated, then least recently updated.
+	// Evictions are counted under "evictions" in GetStats. Zero means no
+	// cap.
+	MaxUsers int
+
+	// DisableSimulator turns off the random rating update generator.
+	DisableSimulator bool
+
+	// Deterministic runs the service without background goroutines, for
+	// tests: there is no simulator, writer ticker, lag monitor or index
+	// rebuild, and AsyncInit is ignored. Every accepted update is applied
+	// and published before SubmitUpdate returns, so the next read sees it
+	// without waiting. Pair it with Clock and RandSeed for reproducible
+	// timestamps and generated users.
+	Deterministic bool
+
+	// Clock replaces time.Now for rating change timestamps, rate limits
+	// and idempotency keys. Nil means time.Now.
+	Clock func() time.Time
+
+	// RandSeed seeds the generated initial population and the simulator.
+	// Zero means a seed taken from the current time.
+	RandSeed int64
+
+	// FlushOnStop makes Stop apply every queued update and publish a final
+	// snapshot before returning, so no accepted update is lost.
+	FlushOnStop bool
+
+	// AdminToken is the bearer token required by /admin endpoints.
+	// Empty disables the admin API entirely.
+	AdminToken string
+
+	// DebugAddr is the listen address for the pprof and /debug/gc endpoints,
+	// kept off the public port and behind the admin token. Empty disables
+	// them; it is off by default.
+	DebugAddr string
+
+	// LeaderboardCacheTTL and SearchCacheTTL control how long clients and
+	// CDNs may reuse /leaderboard and /search responses. Zero disables
+	// caching for that endpoint.
+	LeaderboardCacheTTL time.Duration
+	SearchCacheTTL      time.Duration
+
+	// StringIDs writes user IDs in JSON responses as strings ("id": "42"),
+	// for clients that parse JSON numbers as float64 and would lose
+	// precision on IDs above 2^53. Off by default, since it changes the
+	// response shape. MessagePack responses are unaffected.
+	StringIDs bool
+
+	// MaskUsernames obscures usernames in leaderboard listings, search and
+	// suggest responses, keeping the first UsernameReveal characters ("ra***"),
+	// for deployments that must not publish them. Search still matches the
+	// real names, and profiles and /rank lookups are unaffected. Off by
+	// default.
+	MaskUsernames  bool
+	UsernameReveal int
+
+	// Ranker is the rank formula used by every snapshot. Nil means dense
+	// ranking (snapshot.DenseRanker).
+	Ranker snapshot.Ranker
+
+	// TieBreak orders users who share a rating in leaderboard listings.
+	// The recency options use when each user's rating last changed;
+	// snapshot.TieBreakInsertion uses the order users were added in. Empty
+	// means snapshot.TieBreakID.
+	TieBreak snapshot.TieBreak
+
+	// TopNCacheSize is how many leaderboard entries every snapshot
+	// precomputes, so GetLeaderboard(limit <= TopNCacheSize) is a copy
+	// rather than a walk over rating levels. Zero disables it. Unless
+	// AsyncInit is set, the first snapshot is built before the constructor
+	// returns, so the common limits are served from it from the first
+	// request: there is no separate warm-up step.
+	TopNCacheSize int
+
+	// UserUpdateRate caps how many rating updates per second a single user
+	// may receive through SubmitUpdate, with bursts of up to UserUpdateBurst.
+	// Zero disables the limit.
+	UserUpdateRate  float64
+	UserUpdateBurst int
+
+	// IdempotencyTTL is how long an accepted Idempotency-Key is remembered,
+	// keeping at most IdempotencyMaxKeys keys. Zero disables idempotency
+	// keys: every submission is applied.
+	IdempotencyTTL     time.Duration
+	IdempotencyMaxKeys int
+
+	// SnapshotHistory is how many recently published snapshots are kept for
+	// stale reads (GetSnapshotWithin). Zero keeps none, so every read sees
+	// the current snapshot.
+	SnapshotHistory int
+
+	// MinPublishInterval is the least time between two snapshots published
+	// for queued updates, however fast they arrive, so a flood of updates
+	// cannot turn every writer wakeup into a new snapshot and bounds the
+	// allocation (and GC) rate of snapshots. Updates held back are
+	// published as soon as the interval has passed. Explicit rebuilds
+	// (ForceRebuild, imports) are not delayed. Zero publishes after every
+	// drained batch.
+	MinPublishInterval time.Duration
+
+	// MaxSubscribers caps concurrent streaming clients (see Subscribe).
+	// Zero means no limit.
+	MaxSubscribers int
+
+	// LagAlertWebhook receives a JSON LagAlert when the writer starts
+	// lagging (updates queued behind a snapshot older than
+	// LagAlertThreshold) and when it recovers. A transition is only
+	// reported once it has held for LagAlertDebounce. Empty disables the
+	// monitor.
+	LagAlertWebhook   string
+	LagAlertThreshold time.Duration
+	LagAlertDebounce  time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		DefaultSearchOrder: SearchOrderRank,
+		Tiers:              DefaultTiers(),
+		FlushOnStop:        true,
+		// Snapshots are rebuilt every SnapshotInterval (100ms), far below
+		// the one second max-age granularity, so one second is the
+		// freshest TTL we can advertise.
+		LeaderboardCacheTTL: time.Second,
+		SearchCacheTTL:      time.Second,
+		UsernameReveal:      2,
+		TopNCacheSize:       100,
+		UserUpdateRate:      10,
+		UserUpdateBurst:     10,
+		IdempotencyTTL:      10 * time.Minute,
+		IdempotencyMaxKeys:  100000,
+		SnapshotHistory:     10,
+		MinPublishInterval:  25 * time.Millisecond,
+		MaxSubscribers:      1000,
+		LagAlertThreshold:   time.Second,
+		LagAlertDebounce:    500 * time.Millisecond,
+	}
+}
+
+// seed returns RandSeed, or a time-based seed if it is unset.
+func (c Config) seed() int64 {
+	if c.RandSeed != 0 {
+		return c.RandSeed
+	}
+	return time.Now().UnixNano()
+}
+
+// newSnapshotBuilder returns a builder configured with the service's
+// rank formula, tie-break and top-N cache size.
+func (s *LeaderboardService) newSnapshotBuilder() *snapshot.SnapshotBuilder {
+	builder := snapshot.NewSnapshotBuilder()
+	builder.SetRanker(s.config.Ranker)
+	builder.SetTieBreak(s.config.TieBreak)
+	builder.SetTopN(s.config.TopNCacheSize)
+	return builder
+}
+
+// Config returns the configuration the service was constructed with.
+func (s *LeaderboardService) Config() Config {
+	return s.config
+}
+
+// redacted replaces secret values in EffectiveConfig.
+const redacted = "[redacted]"
+
+// EffectiveConfig describes the configuration actually in force: zero
+// values are resolved to the defaults they stand for and the fixed limits
+// are included alongside the tunables. Secrets are redacted; an empty
+// secret stays empty so it is clear the feature is disabled.
+func (s *LeaderboardService) EffectiveConfig() map[string]interface{} {
+	c := s.config
+
+	order := c.DefaultSearchOrder
+	if order == "" {
+		order = SearchOrderRank
+	}
+
+	var ranker snapshot.Ranker = snapshot.DenseRanker{}
+	if c.Ranker != nil {
+		ranker = c.Ranker
+	}
+
+	caseFolding := c.CaseFolding
+	if caseFolding == "" {
+		caseFolding = CaseFoldingSimple
+	}
+
+	tieBreak := c.TieBreak
+	if tieBreak == "" {
+		tieBreak = snapshot.TieBreakID
+	}
+
+	adminToken := ""
+	if c.AdminToken != "" {
+		adminToken = redacted
+	}
+
+	// Webhook URLs commonly embed a token
+	lagAlertWebhook := ""
+	if c.LagAlertWebhook != "" {
+		lagAlertWebhook = redacted
+	}
+
+	return map[string]interface{}{
+		"min_rating":                    MinRating,
+		"max_rating":                    MaxRating,
+		"snapshot_interval":             SnapshotInterval.String(),
+		"min_publish_interval":          c.MinPublishInterval.String(),
+		"update_buffer_size":            UpdateBufferSize,
+		"default_search_order":          order,
+		"tiers":                         s.tiers(),
+		"client_tiers":                  s.clientTiers(),
+		"api_keys":                      len(c.APIKeys),
+		"username_blocklist":            c.UsernameBlocklist.Len(),
+		"case_folding":                  caseFolding,
+		"index_single_chars":            c.IndexSingleChars,
+		"compact_search_index":          c.CompactSearchIndex,
+		"max_search_candidates":         c.MaxSearchCandidates,
+		"empty_search_no_content":       c.EmptySearchNoContent,
+		"search_index_rebuild_interval": c.SearchIndexRebuildInterval.String(),
+		"max_users":                     c.MaxUsers,
+		"seed_users":                    len(c.SeedUsers),
+		"seed_file":                     c.SeedFile,
+		"async_init":                    c.AsyncInit,
+		"init_grace_period":             c.InitGracePeriod.String(),
+		"unique_usernames":              c.UniqueUsernames,
+		"simulator_enabled":             !c.DisableSimulator && !c.Deterministic,
+		"deterministic":                 c.Deterministic,
+		"flush_on_stop":                 c.FlushOnStop,
+		"admin_token":                   adminToken,
+		"debug_addr":                    c.DebugAddr,
+		"leaderboard_cache_ttl":         c.LeaderboardCacheTTL.String(),
+		"search_cache_ttl":              c.SearchCacheTTL.String(),
+		"string_ids":                    c.StringIDs,
+		"mask_usernames":                c.MaskUsernames,
+		"username_reveal":               c.UsernameReveal,
+		"ranker":                        fmt.Sprintf("%T", ranker),
+		"tie_break":                     tieBreak,
+		"top_n_cache_size":              c.TopNCacheSize,
+		"user_update_rate":              c.UserUpdateRate,
+		"user_update_burst":             c.UserUpdateBurst,
+		"idempotency_ttl":               c.IdempotencyTTL.String(),
+		"idempotency_max_keys":          c.IdempotencyMaxKeys,
+		"snapshot_history":              c.SnapshotHistory,
+		"max_subscribers":               c.MaxSubscribers,
+		"lag_alert_webhook":             lagAlertWebhook,
+		"lag_alert_threshold":           c.LagAlertThreshold.String(),
+		"lag_alert_debounce":            c.LagAlertDebounce.String(),
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"slices"
+
+	"matiks-backend/snapshot"
+)
+
+// DeltaEntry is a leaderboard entry identified by user, so clients can
+// patch their copy in place.
+type DeltaEntry struct {
+	ID       int    `json:"id"`
+	Rank     int    `json:"rank"`
+	Username string `json:"username"`
+	Rating   int    `json:"rating"`
+}
+
+// LeaderboardDelta is what changed in the top of the leaderboard between
+// two snapshot versions.
+type LeaderboardDelta struct {
+	Version uint64 `json:"version"` // pass as sinceVersion next time
+
+	// Full means the requested version is no longer retained, so Changed
+	// is the whole current leaderboard and the client should replace its
+	// copy rather than patch it.
+	Full bool `json:"full"`
+
+	Changed []DeltaEntry `json:"changed"` // new entries, or a new rank or rating
+	Removed []int        `json:"removed"` // user IDs no longer in the top limit
+}
+
+// LeaderboardDelta compares the first limit entries of the current snapshot
+// with those of the snapshot numbered sinceVersion. Only versions still
+// retained (the current one plus Config.SnapshotHistory) can be diffed;
+// anything older, including zero, gets a Full response.
+func (s *LeaderboardService) LeaderboardDelta(sinceVersion uint64, limit int) LeaderboardDelta {
+	current := s.GetSnapshot()
+	view := s.viewFor(0)
+	entries := topWithIDs(current, limit, view)
+
+	delta := LeaderboardDelta{
+		Version: current.Version,
+		Changed: []DeltaEntry{},
+		Removed: []int{},
+	}
+
+	since, ok := s.snapshotVersion(sinceVersion)
+	if !ok {
+		delta.Full = true
+		delta.Changed = entries
+		return delta
+	}
+
+	before := make(map[int]DeltaEntry, limit)
+	for _, entry := range topWithIDs(since, limit, view) {
+		before[entry.ID] = entry
+	}
+
+	for _, entry := range entries {
+		if prev, ok := before[entry.ID]; !ok || prev != entry {
+			delta.Changed = append(delta.Changed, entry)
+		}
+		delete(before, entry.ID)
+	}
+	for userID := range before {
+		delta.Removed = append(delta.Removed, userID)
+	}
+	slices.Sort(delta.Removed)
+
+	return delta
+}
+
+// topWithIDs is walkLeaderboard keeping user IDs.
+func topWithIDs(snap *snapshot.LeaderboardSnapshot, limit int, view viewFilter) []DeltaEntry {
+	result := make([]DeltaEntry, 0, limit)
+
+	for rating := MaxRating; rating >= MinRating && len(result) < limit; rating-- {
+		rank := snap.GetRank(rating)
+
+		for _, user := range snap.UsersByRating[rating] {
+			if view.hidden(user.ID) {
+				continue
+			}
+
+			result = append(result, DeltaEntry{
+				ID:       user.ID,
+				Rank:     rank,
+				Username: user.Username,
+				Rating:   rating,
+			})
+			if len(result) == limit {
+				break
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Binary delta frames, for bandwidth-constrained stream clients. Every
+// integer is an unsigned varint:
+//
+//	full (1 or 0), version,
+//	len(changed), then per entry: id, rank, rating, len(username), username,
+//	len(removed), then each removed id.
+//
+// A full frame is a keyframe: the client replaces its copy with Changed.
+// Otherwise it patches entries by ID and drops the Removed ones.
+
+var errShortDelta = errors.New("delta: unexpected end of data")
+
+// MarshalBinary encodes d in the binary delta frame format.
+func (d LeaderboardDelta) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, 16+len(d.Changed)*16+len(d.Removed)*4)
+
+	var full uint64
+	if d.Full {
+		full = 1
+	}
+	buf = binary.AppendUvarint(buf, full)
+	buf = binary.AppendUvarint(buf, d.Version)
+
+	buf = binary.AppendUvarint(buf, uint64(len(d.Changed)))
+	for _, entry := range d.Changed {
+		if entry.ID < 0 || entry.Rank < 0 || entry.Rating < 0 {
+			return nil, fmt.Errorf("delta: negative field in entry %+v", entry)
+		}
+		buf = binary.AppendUvarint(buf, uint64(entry.ID))
+		buf = binary.AppendUvarint(buf, uint64(entry.Rank))
+		buf = binary.AppendUvarint(buf, uint64(entry.Rating))
+		buf = binary.AppendUvarint(buf, uint64(len(entry.Username)))
+		buf = append(buf, entry.Username...)
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(d.Removed)))
+	for _, userID := range d.Removed {
+		if userID < 0 {
+			return nil, fmt.Errorf("delta: negative removed id %d", userID)
+		}
+		buf = binary.AppendUvarint(buf, uint64(userID))
+	}
+
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a frame written by MarshalBinary.
+func (d *LeaderboardDelta) UnmarshalBinary(data []byte) error {
+	pos := 0
+	next := func() (uint64, error) {
+		v, n := binary.Uvarint(data[pos:])
+		if n <= 0 {
+			return 0, errShortDelta
+		}
+		pos += n
+		return v, nil
+	}
+	// Counts are bounded by the bytes left, one per element at least, so
+	// a corrupt frame cannot request a huge allocation
+	nextCount := func() (int, error) {
+		v, err := next()
+		if err != nil {
+			return 0, err
+		}
+		if v > uint64(len(data)-pos) {
+			return 0, errShortDelta
+		}
+		return int(v), nil
+	}
+
+	full, err := next()
+	if err != nil {
+		return err
+	}
+	version, err := next()
+	if err != nil {
+		return err
+	}
+
+	changed, err := nextCount()
+	if err != nil {
+		return err
+	}
+	entries := make([]DeltaEntry, changed)
+	for i := range entries {
+		var fields [3]uint64
+		for j := range fields {
+			if fields[j], err = next(); err != nil {
+				return err
+			}
+		}
+		nameLen, err := nextCount()
+		if err != nil {
+			return err
+		}
+		entries[i] = DeltaEntry{
+			ID:       int(fields[0]),
+			Rank:     int(fields[1]),
+			Rating:   int(fields[2]),
+			Username: string(data[pos : pos+nameLen]),
+		}
+		pos += nameLen
+	}
+
+	removed, err := nextCount()
+	if err != nil {
+		return err
+	}
+	removedIDs := make([]int, removed)
+	for i := range removedIDs {
+		v, err := next()
+		if err != nil {
+			return err
+		}
+		removedIDs[i] = int(v)
+	}
+
+	if pos != len(data) {
+		return fmt.Errorf("delta: %d trailing bytes", len(data)-pos)
+	}
+
+	*d = LeaderboardDelta{Version: version, Full: full == 1, Changed: entries, Removed: removedIDs}
+	return nil
+}
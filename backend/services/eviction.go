@@ -0,0 +1,101 @@
+package services
+
+import (
+	"cmp"
+	"slices"
+
+	"matiks-backend/models"
+)
+
+// AddUsers adds new users to the population and publishes a snapshot
+// including them before returning. With Config.MaxUsers set, the coldest
+// existing users are evicted first to make room (see coldestUsers). The
+// batch is rejected as a whole if any row is invalid, an ID is already
+// taken (or, with Config.UniqueUsernames, a username, even one held by a
+// user about to be evicted), or the batch alone exceeds MaxUsers.
+func (s *LeaderboardService) AddUsers(seeds []models.UserSeed) error {
+	if errs := s.ValidateUsers(seeds); len(errs) > 0 {
+		return errs
+	}
+
+	var err error
+	runErr := s.runOnWriter(func() {
+		// Only the writer mutates users, so it may read them unlocked
+		var taken ValidationErrors
+		for i, seed := range seeds {
+			if _, ok := s.users[seed.ID]; ok {
+				taken.add(i, "id", "user %d already exists", seed.ID)
+			}
+			if s.config.UniqueUsernames && s.usernameTaken(seed.Username) {
+				taken.add(i, "username", "username %q already in use", seed.Username)
+			}
+		}
+		if len(taken) > 0 {
+			err = taken
+			return
+		}
+
+		var evicted []int
+		if limit := s.config.MaxUsers; limit > 0 {
+			evicted = s.coldestUsers(len(s.users) + len(seeds) - limit)
+		}
+
+		s.mu.Lock()
+		for _, userID := range evicted {
+			s.removeUser(userID)
+		}
+		for _, seed := range seeds {
+			s.users[seed.ID] = &models.User{ID: seed.ID, Username: seed.Username, Metadata: seed.Metadata}
+			s.indexUsername(seed.ID, seed.Username)
+			s.writerRatings[seed.ID] = seed.Rating
+			s.recordInsertion(seed.ID)
+		}
+		s.usersGeneration++
+		s.mu.Unlock()
+
+		s.evictions.Add(uint64(len(evicted)))
+		s.rebuildSnapshot()
+	})
+	if runErr != nil {
+		return runErr
+	}
+	return err
+}
+
+// coldestUsers returns the n users to evict first: the lowest rated, then
+// among equal ratings the least recently updated (users never updated
+// since loading count as oldest), then the lowest ID. Writer only.
+func (s *LeaderboardService) coldestUsers(n int) []int {
+	if n <= 0 {
+		return nil
+	}
+
+	userIDs := make([]int, 0, len(s.writerRatings))
+	for userID := range s.writerRatings {
+		userIDs = append(userIDs, userID)
+	}
+	slices.SortFunc(userIDs, func(a, b int) int {
+		if c := cmp.Compare(s.writerRatings[a], s.writerRatings[b]); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(s.writerChanged[a], s.writerChanged[b]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+
+	return userIDs[:min(n, len(userIDs))]
+}
+
+// removeUser drops userID from the population and every index. The
+// writer calls it holding the write lock.
+func (s *LeaderboardService) removeUser(userID int) {
+	if user, ok := s.users[userID]; ok {
+		s.unindexUsername(userID, user.Username)
+	}
+	delete(s.users, userID)
+	delete(s.writerRatings, userID)
+	delete(s.writerSeqs, userID)
+	delete(s.writerChanged, userID)
+	delete(s.writerInserted, userID)
+}
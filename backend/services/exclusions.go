@@ -0,0 +1,77 @@
+package services
+
+import "errors"
+
+var ErrUnknownUser = errors.New("unknown user")
+
+// Excluded ("shadowbanned") users are hidden from leaderboard, search and
+// suggestion listings. They keep their rating and still count towards
+// everyone else's rank, so hiding a user leaves a gap rather than moving
+// others up. A viewer always sees their own entry (see viewFilter).
+//
+// The set is copy-on-write behind an atomic.Value so readers never lock;
+// changes are rare admin actions serialized by excludedMu.
+
+// Exclude hides userID from listings.
+func (s *LeaderboardService) Exclude(userID int) error {
+	s.mu.RLock()
+	_, ok := s.users[userID]
+	s.mu.RUnlock()
+	if !ok {
+		return ErrUnknownUser
+	}
+
+	s.updateExcluded(func(excluded map[int]bool) { excluded[userID] = true })
+	return nil
+}
+
+// Unexclude makes userID visible again. It is a no-op for visible users.
+func (s *LeaderboardService) Unexclude(userID int) {
+	s.updateExcluded(func(excluded map[int]bool) { delete(excluded, userID) })
+}
+
+// IsExcluded reports whether userID is hidden from listings.
+func (s *LeaderboardService) IsExcluded(userID int) bool {
+	return s.excludedUsers()[userID]
+}
+
+// excludedUsers returns the current set. It must not be modified.
+func (s *LeaderboardService) excludedUsers() map[int]bool {
+	excluded, _ := s.excluded.Load().(map[int]bool)
+	return excluded
+}
+
+func (s *LeaderboardService) updateExcluded(change func(map[int]bool)) {
+	s.excludedMu.Lock()
+	defer s.excludedMu.Unlock()
+
+	current := s.excludedUsers()
+	next := make(map[int]bool, len(current)+1)
+	for userID := range current {
+		next[userID] = true
+	}
+	change(next)
+
+	s.excluded.Store(next)
+}
+
+// viewFilter decides which users a listing shows to a viewer: excluded
+// users are hidden, except the viewer's own entry, which is marked IsSelf.
+// A zero viewerID is an anonymous viewer.
+type viewFilter struct {
+	excluded map[int]bool
+	viewerID int
+	include  []string // metadata fields copied onto entries
+}
+
+func (s *LeaderboardService) viewFor(viewerID int) viewFilter {
+	return viewFilter{excluded: s.excludedUsers(), viewerID: viewerID}
+}
+
+func (v viewFilter) hidden(userID int) bool {
+	return v.excluded[userID] && userID != v.viewerID
+}
+
+func (v viewFilter) isSelf(userID int) bool {
+	return v.viewerID != 0 && userID == v.viewerID
+}
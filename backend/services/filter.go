@@ -0,0 +1,61 @@
+package services
+
+import (
+	"cmp"
+	"slices"
+
+	"matiks-backend/models"
+)
+
+// MaxFilterUsers bounds how many IDs one GetLeaderboardForUsers call accepts.
+const MaxFilterUsers = 1000
+
+// GetLeaderboardForUsers returns the leaderboard restricted to ids, such as
+// a friends list. Ranks stay global; entries are in leaderboard order (rank,
+// then user ID, as in GetLeaderboard). Unknown, excluded and repeated IDs
+// are skipped.
+func (s *LeaderboardService) GetLeaderboardForUsers(ids []int) []models.LeaderboardEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snap := s.GetSnapshot()
+	view := s.viewFor(0)
+
+	type ranked struct {
+		id    int
+		entry models.LeaderboardEntry
+	}
+
+	found := make([]ranked, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		user, ok := s.users[id]
+		if !ok || seen[id] || view.hidden(id) {
+			continue
+		}
+		seen[id] = true
+
+		rating := snap.GetUserRating(id)
+		found = append(found, ranked{
+			id: id,
+			entry: models.LeaderboardEntry{
+				Rank:     snap.GetRank(rating),
+				Username: user.Username,
+				Rating:   rating,
+			},
+		})
+	}
+
+	slices.SortFunc(found, func(a, b ranked) int {
+		if c := cmp.Compare(a.entry.Rank, b.entry.Rank); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.id, b.id)
+	})
+
+	results := make([]models.LeaderboardEntry, len(found))
+	for i, r := range found {
+		results[i] = r.entry
+	}
+	return results
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"time"
+
+	"matiks-backend/snapshot"
+)
+
+// publish numbers snap, makes it the current snapshot, keeps it in the
+// history ring (see Config.SnapshotHistory) and wakes subscribers. Only the
+// writer goroutine, or a constructor before it starts, may call it.
+func (s *LeaderboardService) publish(snap *snapshot.LeaderboardSnapshot) {
+	s.lastVersion++
+	snap.Version = s.lastVersion
+	s.currentSnapshot.Store(snap)
+
+	if n := s.config.SnapshotHistory; n > 0 {
+		prev, _ := s.history.Load().([]*snapshot.LeaderboardSnapshot)
+		kept := prev[max(0, len(prev)+1-n):]
+
+		// Copy-on-write: readers may still be scanning prev
+		next := make([]*snapshot.LeaderboardSnapshot, 0, len(kept)+1)
+		next = append(next, kept...)
+		s.history.Store(append(next, snap))
+	}
+
+	s.subscribers.notify()
+}
+
+// resetHistory forgets retained snapshots, e.g. once they describe a
+// population that has been replaced.
+func (s *LeaderboardService) resetHistory() {
+	s.history.Store([]*snapshot.LeaderboardSnapshot(nil))
+}
+
+// GetSnapshotWithin returns the oldest retained snapshot generated no more
+// than maxStaleness ago, for clients that accept stale reads: many such
+// requests then share one snapshot (and identical responses) instead of
+// tracking every rebuild. Without a qualifying older snapshot, or with
+// history disabled, it returns the current one.
+func (s *LeaderboardService) GetSnapshotWithin(maxStaleness time.Duration) *snapshot.LeaderboardSnapshot {
+	history, _ := s.history.Load().([]*snapshot.LeaderboardSnapshot)
+
+	now := time.Now()
+	for _, snap := range history { // oldest first
+		if now.Sub(snap.GeneratedAt) <= maxStaleness {
+			return snap
+		}
+	}
+	return s.GetSnapshot()
+}
+
+// snapshotVersion returns the current or a retained snapshot by Version.
+func (s *LeaderboardService) snapshotVersion(version uint64) (*snapshot.LeaderboardSnapshot, bool) {
+	if current := s.GetSnapshot(); current.Version == version {
+		return current, true
+	}
+
+	history, _ := s.history.Load().([]*snapshot.LeaderboardSnapshot)
+	for _, snap := range history {
+		if snap.Version == version {
+			return snap, true
+		}
+	}
+	return nil, false
+}
+
+// recentSnapshots returns up to the last n published snapshots, oldest
+// first and ending with the current one. With history disabled that is
+// just the current snapshot.
+func (s *LeaderboardService) recentSnapshots(n int) []*snapshot.LeaderboardSnapshot {
+	current := s.GetSnapshot()
+
+	history, _ := s.history.Load().([]*snapshot.LeaderboardSnapshot)
+	if len(history) == 0 || history[len(history)-1] != current {
+		history = []*snapshot.LeaderboardSnapshot{current}
+	}
+	return history[max(0, len(history)-max(n, 1)):]
+}
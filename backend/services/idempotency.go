@@ -0,0 +1,116 @@
+package services
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different update")
+
+// idempotencyCache remembers recently accepted idempotency keys for a fixed
+// TTL, bounded to maxKeys. Keys expire in insertion order, so a FIFO list is
+// enough to evict both expired and excess entries.
+type idempotencyCache struct {
+	ttl     time.Duration
+	maxKeys int
+
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	order   *list.List // of string keys, oldest first
+}
+
+type idempotencyEntry struct {
+	fingerprint RatingUpdate
+	done        chan struct{} // closed once the first attempt finishes
+	err         error
+	expires     time.Time
+	element     *list.Element
+}
+
+func newIdempotencyCache(ttl time.Duration, maxKeys int) *idempotencyCache {
+	return &idempotencyCache{
+		ttl:     ttl,
+		maxKeys: maxKeys,
+		entries: make(map[string]*idempotencyEntry),
+		order:   list.New(),
+	}
+}
+
+// do runs submit once per key. A repeat of a successful key returns the
+// original result without running submit again (replayed is true); a
+// repeat that arrives while the first attempt is still running waits for
+// it. Failed attempts are forgotten so the client can retry them.
+func (c *idempotencyCache) do(key string, update RatingUpdate, now time.Time, submit func() error) (replayed bool, err error) {
+	c.mu.Lock()
+	c.evict(now)
+
+	for {
+		entry, ok := c.entries[key]
+		if !ok {
+			break
+		}
+		c.mu.Unlock()
+		if entry.fingerprint != update {
+			return false, ErrIdempotencyKeyReused
+		}
+		<-entry.done
+		if entry.err == nil {
+			return true, nil
+		}
+		// The first attempt failed and released the key; try afresh
+		c.mu.Lock()
+	}
+
+	entry := &idempotencyEntry{
+		fingerprint: update,
+		done:        make(chan struct{}),
+		expires:     now.Add(c.ttl),
+	}
+	entry.element = c.order.PushBack(key)
+	c.entries[key] = entry
+	c.mu.Unlock()
+
+	entry.err = submit()
+
+	if entry.err != nil {
+		c.mu.Lock()
+		if c.entries[key] == entry {
+			c.order.Remove(entry.element)
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+	}
+	close(entry.done)
+
+	return false, entry.err
+}
+
+// evict drops expired keys and, beyond maxKeys, the oldest ones.
+// Requires c.mu.
+func (c *idempotencyCache) evict(now time.Time) {
+	for front := c.order.Front(); front != nil; front = c.order.Front() {
+		key := front.Value.(string)
+		if len(c.entries) < c.maxKeys && now.Before(c.entries[key].expires) {
+			return
+		}
+		c.order.Remove(front)
+		delete(c.entries, key)
+	}
+}
+
+// SubmitIdempotent is SubmitSequencedUpdate guarded by a client-chosen
+// idempotency key: retrying with the same key and update returns the
+// original success without queueing the update again. A key reused for a
+// different update fails with ErrIdempotencyKeyReused. With
+// Config.IdempotencyTTL zero the key is ignored.
+func (s *LeaderboardService) SubmitIdempotent(key string, update RatingUpdate) (replayed bool, err error) {
+	if s.idempotency == nil || key == "" {
+		return false, s.submit(update)
+	}
+
+	return s.idempotency.do(key, update, s.clock(), func() error {
+		return s.submit(update)
+	})
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// lagCheckInterval is how often the lag monitor samples snapshot age.
+const lagCheckInterval = SnapshotInterval
+
+// Lag alert events posted to Config.LagAlertWebhook.
+const (
+	LagAlertLagging   = "writer_lagging"
+	LagAlertRecovered = "writer_recovered"
+)
+
+// LagAlert is the JSON body posted to Config.LagAlertWebhook when the
+// writer starts or stops lagging.
+type LagAlert struct {
+	Event         string    `json:"event"`
+	SnapshotAgeMs int64     `json:"snapshot_age_ms"`
+	QueuedUpdates int       `json:"queued_updates"`
+	ThresholdMs   int64     `json:"threshold_ms"`
+	Time          time.Time `json:"time"`
+}
+
+// lagMonitor tracks whether the writer is lagging. It belongs to the
+// monitor goroutine.
+type lagMonitor struct {
+	lagging bool
+
+	// changingSince is when samples started disagreeing with lagging,
+	// zero while they agree. The state flips once they have disagreed
+	// for Config.LagAlertDebounce.
+	changingSince time.Time
+
+	client *http.Client
+}
+
+// monitorLag samples writer lag until Stop, posting a LagAlert on every
+// debounced transition. The writer counts as lagging while updates are
+// queued and the current snapshot is older than Config.LagAlertThreshold;
+// an idle service's snapshot ages without lagging.
+func (s *LeaderboardService) monitorLag() {
+	monitor := &lagMonitor{client: &http.Client{Timeout: 5 * time.Second}}
+
+	ticker := time.NewTicker(lagCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case now := <-ticker.C:
+			if alert, ok := s.checkLag(monitor, now); ok {
+				s.postLagAlert(monitor.client, alert)
+			}
+		}
+	}
+}
+
+// checkLag takes one sample and returns the alert to send, if the sample
+// completes a transition.
+func (s *LeaderboardService) checkLag(monitor *lagMonitor, now time.Time) (LagAlert, bool) {
+	age := now.Sub(s.GetSnapshot().GeneratedAt)
+	queued := len(s.updateChan)
+	lagging := queued > 0 && age > s.config.LagAlertThreshold
+
+	if lagging == monitor.lagging {
+		monitor.changingSince = time.Time{}
+		return LagAlert{}, false
+	}
+	if monitor.changingSince.IsZero() {
+		monitor.changingSince = now
+	}
+	if now.Sub(monitor.changingSince) < s.config.LagAlertDebounce {
+		return LagAlert{}, false
+	}
+
+	monitor.lagging = lagging
+	monitor.changingSince = time.Time{}
+
+	event := LagAlertRecovered
+	if lagging {
+		event = LagAlertLagging
+	}
+	return LagAlert{
+		Event:         event,
+		SnapshotAgeMs: age.Milliseconds(),
+		QueuedUpdates: queued,
+		ThresholdMs:   s.config.LagAlertThreshold.Milliseconds(),
+		Time:          now,
+	}, true
+}
+
+// postLagAlert delivers alert once; a failed delivery is logged, not retried.
+func (s *LeaderboardService) postLagAlert(client *http.Client, alert LagAlert) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		slog.Error("encoding lag alert failed", "err", err)
+		return
+	}
+
+	resp, err := client.Post(s.config.LagAlertWebhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		slog.Warn("lag alert webhook failed", "event", alert.Event, "err", err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		slog.Warn("lag alert webhook rejected", "event", alert.Event, "status", resp.StatusCode)
+	}
+}
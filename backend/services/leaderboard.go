@@ -1,10 +1,16 @@
 package services
 
 import (
-	"math/rand"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"matiks-backend/models"
 	"matiks-backend/snapshot"
@@ -20,88 +26,572 @@ const (
 	UpdateBufferSize = 10000
 )
 
+var (
+	ErrUpdateQueueFull = errors.New("update queue is full")
+	ErrServiceStopped  = errors.New("leaderboard service is stopped")
+)
+
 type RatingUpdate struct {
 	UserID    int
 	NewRating int
+
+	// Seq optionally orders updates for the same user. When non-zero, the
+	// writer drops an update whose Seq is not newer than the last sequenced
+	// update it applied for that user. Zero means unsequenced: always applied.
+	// Relative updates commute, so Seq is ignored for them.
+	Seq uint64
+
+	// Relative updates add Delta to the user's current rating, clamped to
+	// [MinRating, MaxRating], instead of setting NewRating.
+	Relative bool
+	Delta    int
+
+	// With is applied in the same writer step as this update, so no
+	// snapshot can hold one without the other (see SubmitMatchResult). If
+	// either user is unknown when they are applied, both are dropped.
+	With *RatingUpdate
 }
 
+// MaxRatingDelta is the largest change a relative update may request;
+// anything larger would always clamp.
+const MaxRatingDelta = MaxRating - MinRating
+
 type LeaderboardService struct {
+	config Config
+
+	// mu guards users and the search indexes so readers never observe
+	// a half-replaced user set. Only the writer goroutine mutates them, and
+	// only while holding the write lock; it may read them without locking.
+	mu sync.RWMutex
+
 	users map[int]*models.User
 
+	// usersGeneration counts replacements of users, so work prepared from
+	// an older user set can tell it is stale
+	usersGeneration uint64
+
 	// N-GRAM SEARCH INDEX
 	// Maps n-gram to list of user IDs containing that gram in their username.
 	// Used for scalable substring search.
 	searchIndex map[string][]int
 
+	// SINGLE-CHARACTER INDEX (optional, see Config.IndexSingleChars)
+	// Maps a lowercased byte to the user IDs whose username contains it,
+	// so 1-char queries avoid the linear scan.
+	charIndex map[byte][]int
+
+	// USERNAME INDEX
+	// Maps a lowercased username to the IDs of every user with that name,
+	// for exact-name lookups (see GetRankByUsername).
+	usernameIndex map[string][]int
+
 	currentSnapshot atomic.Value // *snapshot.LeaderboardSnapshot
+	lastVersion     uint64       // Version of the last published snapshot (writer only)
+
+	// Recently published snapshots, oldest first, for stale reads
+	// (see Config.SnapshotHistory and history.go)
+	history atomic.Value // []*snapshot.LeaderboardSnapshot, copy-on-write
 
 	// All rating updates are sent to this buffered channel.
 	// The writer goroutine consumes them asynchronously.
 	updateChan chan RatingUpdate
 
-	writerRatings map[int]int // userID -> rating (writer's working copy)
+	// Work that must run on the writer goroutine (see runOnWriter)
+	commands chan func()
+
+	writerRatings map[int]int    // userID -> rating (writer's working copy)
+	writerSeqs    map[int]uint64 // userID -> last applied RatingUpdate.Seq
+	writerChanged map[int]int64  // userID -> Unix nanos of last rating change
+
+	// Insertion order, for snapshot.TieBreakInsertion (see recordInsertion)
+	writerInserted map[int]uint64 // userID -> insertion sequence
+	lastInserted   uint64         // sequence given to the latest user
+
+	rebuildBuilder *snapshot.SnapshotBuilder // reused by rebuildSnapshot
+
+	staleUpdates  atomic.Uint64 // sequenced updates dropped as out of order
+	unknownUsers  atomic.Uint64 // updates dropped for users not in the population
+	broadSearches atomic.Uint64 // searches capped at MaxSearchCandidates
+	evictions     atomic.Uint64 // users evicted to stay within MaxUsers
+
+	// Updates coalesced per rebuild (see writer_metrics.go)
+	writerStats writerMetrics
+
+	// Shutdown coordination: stopChan is closed by Stop, writerDone is
+	// closed by the writer once its final snapshot is published. stopMu
+	// makes enqueue's stopped check and send one step with respect to Stop.
+	stopChan   chan struct{}
+	writerDone chan struct{}
+	stopOnce   sync.Once
+	stopMu     sync.RWMutex
+	stopped    atomic.Bool
+
+	// Per-user update throttle (nil when Config.UserUpdateRate is zero)
+	updateLimiter      *userRateLimiter
+	rateLimitedUpdates atomic.Uint64
+
+	// Recently accepted Idempotency-Key values (nil when disabled)
+	idempotency *idempotencyCache
+
+	// Users hidden from listings (see exclusions.go)
+	excluded   atomic.Value // map[int]bool, copy-on-write
+	excludedMu sync.Mutex
+
+	// Streaming clients notified on every snapshot publish
+	subscribers subscriberSet
 
-	// Random source for update simulator (used only by simulator goroutine)
-	rng *rand.Rand
+	// Guards SelfBenchmark so only one run can be in flight
+	selfBenchRunning atomic.Bool
+
+	// Test hook called for every search candidate verified
+	verifyHook func()
+
+	// Replaces time.Now for rating change timestamps and rate limits
+	// (Config.Clock, or a test hook)
+	now func() time.Time
+
+	// Serialises writer work run inline when there is no writer goroutine
+	inlineMu sync.Mutex
+
+	// Random source for the generated initial population and the
+	// simulator, seeded from Config.RandSeed
+	rng *utils.Rand
+
+	// Set by PauseSimulator; checked by the simulator before each update
+	simulatorPaused atomic.Bool
 }
 
 func NewLeaderboardService() *LeaderboardService {
+	return NewLeaderboardServiceWithConfig(DefaultConfig())
+}
+
+// NewLeaderboardServiceWithConfig is NewLeaderboardServiceChecked for
+// configurations known to be valid. It panics on invalid tiers or an invalid
+// initial population, which takes Config.SeedUsers or
+// Config.UniqueUsernames.
+func NewLeaderboardServiceWithConfig(config Config) *LeaderboardService {
+	service, err := NewLeaderboardServiceChecked(config)
+	if err != nil {
+		panic(err)
+	}
+	return service
+}
+
+// NewLeaderboardServiceChecked builds and starts a service, failing if
+// Config.Tiers is invalid (see ValidateTiers) or its initial population
+// (Config.SeedUsers or the generated users) is, e.g. shares usernames under
+// Config.UniqueUsernames.
+func NewLeaderboardServiceChecked(config Config) (*LeaderboardService, error) {
+	if err := ValidateTiers(config.Tiers); err != nil {
+		return nil, fmt.Errorf("invalid tiers: %w", err)
+	}
+
 	service := &LeaderboardService{
+		config:        config,
 		users:         make(map[int]*models.User, InitialUsers),
 		searchIndex:   make(map[string][]int),
 		updateChan:    make(chan RatingUpdate, UpdateBufferSize),
+		commands:      make(chan func()),
 		writerRatings: make(map[int]int, InitialUsers),
-		rng:           rand.New(rand.NewSource(time.Now().UnixNano())),
+		stopChan:      make(chan struct{}),
+		writerDone:    make(chan struct{}),
+		rng:           utils.NewRand(config.seed()),
+		now:           config.Clock,
+	}
+	service.writerStats.start = time.Now()
+
+	if config.UserUpdateRate > 0 {
+		service.updateLimiter = newUserRateLimiter(config.UserUpdateRate, config.UserUpdateBurst)
+	}
+	if config.IdempotencyTTL > 0 {
+		service.idempotency = newIdempotencyCache(config.IdempotencyTTL, max(config.IdempotencyMaxKeys, 1))
 	}
 
-	service.initializeUsers()
+	seeds, err := service.initialSeeds()
+	if err != nil {
+		return nil, err
+	}
 
-	go service.snapshotWriter()  // Single writer: consumes updates, builds snapshots
-	go service.updateSimulator() // Simulator: generates random rating updates
+	if config.Deterministic {
+		// No goroutines: updates are applied and published inline
+		service.commands = nil
+		service.loadUsers(seeds)
+		return service, nil
+	}
 
-	return service
+	if config.AsyncInit {
+		service.startAsync(seeds)
+	} else {
+		service.loadUsers(seeds)
+		go service.snapshotWriter() // Single writer: consumes updates, builds snapshots
+	}
+	if !config.DisableSimulator {
+		go service.updateSimulator() // Simulator: generates random rating updates
+	}
+	if config.LagAlertWebhook != "" {
+		go service.monitorLag() // Posts to the webhook when the writer falls behind
+	}
+	if config.SearchIndexRebuildInterval > 0 {
+		go service.rebuildSearchIndexes() // Periodically rebuilds the search indexes
+	}
+
+	return service, nil
+}
+
+// Stop shuts down the writer and simulator. With Config.FlushOnStop, every
+// update already accepted by SubmitUpdate is applied and published in a
+// final snapshot before Stop returns. Stop is safe to call more than once.
+func (s *LeaderboardService) Stop() {
+	if s.stopChan == nil {
+		return // not started by a constructor, nothing to stop
+	}
+
+	s.stopOnce.Do(func() {
+		// Once this lock is held no enqueue is mid-send, so every update it
+		// accepted is queued before the writer sees stopChan and flushes
+		s.stopMu.Lock()
+		s.stopped.Store(true)
+		s.stopMu.Unlock()
+		close(s.stopChan)
+		if s.config.Deterministic {
+			s.subscribers.closeAll()
+			close(s.writerDone)
+		}
+	})
+	<-s.writerDone
+}
+
+// SubmitUpdate enqueues an absolute rating change for the writer.
+// It never blocks: a full queue is reported as ErrUpdateQueueFull, and
+// updates beyond the per-user rate limit as ErrRateLimited. With the rate
+// limit on, updates for unknown users fail with ErrUnknownUser; otherwise
+// the writer drops them.
+func (s *LeaderboardService) SubmitUpdate(userID, newRating int) error {
+	return s.submit(RatingUpdate{UserID: userID, NewRating: newRating})
+}
+
+// SubmitDelta enqueues a relative rating change: the writer adds delta to
+// the user's rating at the time it is applied, clamped to the rating range.
+func (s *LeaderboardService) SubmitDelta(userID, delta int) error {
+	return s.submit(RatingUpdate{UserID: userID, Relative: true, Delta: delta})
+}
+
+// SubmitSequencedUpdate is SubmitUpdate with a caller-assigned sequence
+// number. Producers that may race on the same user stamp increasing seq
+// values so only the newest update wins, regardless of arrival order.
+func (s *LeaderboardService) SubmitSequencedUpdate(userID, newRating int, seq uint64) error {
+	return s.submit(RatingUpdate{UserID: userID, NewRating: newRating, Seq: seq})
+}
+
+func (s *LeaderboardService) submit(update RatingUpdate) error {
+	if err := update.validate(); err != nil {
+		return err
+	}
+	if s.stopped.Load() {
+		return ErrServiceStopped
+	}
+	if s.updateLimiter != nil {
+		// Only known users get a bucket, so made-up IDs cannot grow it
+		if !s.userExists(update.UserID) {
+			return ErrUnknownUser
+		}
+		if !s.updateLimiter.allow(update.UserID, s.clock()) {
+			s.rateLimitedUpdates.Add(1)
+			return ErrRateLimited
+		}
+	}
+
+	return s.enqueue(update)
+}
+
+// userExists reports whether userID is in the population.
+func (s *LeaderboardService) userExists(userID int) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.users[userID]
+	return ok
+}
+
+// enqueue hands an accepted update to the writer without blocking. In
+// deterministic mode it is applied and published before enqueue returns.
+// An update it returns nil for is never lost to a concurrent Stop.
+func (s *LeaderboardService) enqueue(update RatingUpdate) error {
+	if s.config.Deterministic {
+		return s.runOnWriter(func() {
+			s.applyUpdate(update)
+			s.rebuildSnapshot()
+		})
+	}
+
+	s.stopMu.RLock()
+	defer s.stopMu.RUnlock()
+	if s.stopped.Load() {
+		return ErrServiceStopped
+	}
+
+	select {
+	case s.updateChan <- update:
+		return nil
+	default:
+		return ErrUpdateQueueFull
+	}
+}
+
+// clock returns the current time from Config.Clock, if set.
+func (s *LeaderboardService) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
 }
 
-func (s *LeaderboardService) initializeUsers() {
-	builder := snapshot.NewSnapshotBuilder()
+// initialSeeds returns the validated initial population: Config.SeedUsers,
+// else Config.SeedFile, else InitialUsers generated users (capped by
+// MaxUsers).
+func (s *LeaderboardService) initialSeeds() ([]models.UserSeed, error) {
+	seeds, seeded := s.config.SeedUsers, s.config.SeedUsers != nil
+	if !seeded && s.config.SeedFile != "" {
+		loaded, err := LoadSeedCSV(s.config.SeedFile)
+		if err != nil {
+			return nil, fmt.Errorf("seed file %s: %w", s.config.SeedFile, err)
+		}
+		seeds, seeded = loaded, true
+	}
+	if !seeded {
+		count := InitialUsers
+		if s.config.MaxUsers > 0 {
+			count = min(count, s.config.MaxUsers)
+		}
+		seeds = s.rng.Users(count)
+	}
+
+	// Reported by name first: row errors would bury a collision among
+	// thousands of generated users
+	if s.config.UniqueUsernames {
+		if err := duplicateUsernamesError(seeds); err != nil {
+			return nil, err
+		}
+	}
+	if seeded {
+		if errs := s.ValidateUsers(seeds); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid seed users: %w", errs)
+		}
+	}
+	return seeds, nil
+}
 
-	for userID := 1; userID <= InitialUsers; userID++ {
-		username := utils.GenerateRandomUsername(userID)
-		rating := utils.GenerateRandomRating(MinRating, MaxRating)
+// loadUsers indexes seeds and publishes the first snapshot. It runs before
+// the writer starts, but with Config.AsyncInit readers may already be
+// about, hence the lock.
+func (s *LeaderboardService) loadUsers(seeds []models.UserSeed) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
+	builder := s.newSnapshotBuilder()
+	for _, seed := range seeds {
 		user := &models.User{
-			ID:       userID,
-			Username: username,
+			ID:       seed.ID,
+			Username: seed.Username,
+			Metadata: seed.Metadata,
 		}
-		s.users[userID] = user
+		s.users[seed.ID] = user
 
-		s.indexUsername(userID, username)
+		s.indexUsername(seed.ID, seed.Username)
 
 		// Initialize writer's working copy
-		s.writerRatings[userID] = rating
+		s.writerRatings[seed.ID] = seed.Rating
 
-		builder.AddUser(userID, username, rating)
+		builder.AddUser(seed.ID, seed.Username, seed.Rating)
+		builder.SetInserted(seed.ID, s.recordInsertion(seed.ID))
+		if seed.Metadata != nil {
+			builder.SetMetadata(seed.ID, seed.Metadata)
+		}
 	}
 
 	firstSnapshot := builder.Build()
-	s.currentSnapshot.Store(firstSnapshot)
+	s.publish(firstSnapshot)
+}
+
+// emptySnapshot stands in for the current snapshot until the first one is
+// published, so early readers see no users rather than a nil snapshot.
+var emptySnapshot = snapshot.NewSnapshotBuilder().Build()
+
+// Ready reports whether the first snapshot has been published. Until then,
+// with Config.AsyncInit, readers see an empty leaderboard.
+func (s *LeaderboardService) Ready() bool {
+	return s.currentSnapshot.Load() != nil
 }
 
 // This is the ONLY way readers access leaderboard data.
 func (s *LeaderboardService) GetSnapshot() *snapshot.LeaderboardSnapshot {
-	return s.currentSnapshot.Load().(*snapshot.LeaderboardSnapshot)
+	if snap, ok := s.currentSnapshot.Load().(*snapshot.LeaderboardSnapshot); ok {
+		return snap
+	}
+	return emptySnapshot
+}
+
+// ForEachUser calls fn for every user in the current snapshot, highest
+// rating first and by ID within a rating, until fn returns false. The
+// snapshot is immutable, so the walk needs no lock and sees one consistent
+// population even while updates are applied. Excluded users are included.
+func (s *LeaderboardService) ForEachUser(fn func(user models.User, rating int) bool) {
+	snap := s.GetSnapshot()
+
+	for rating := MaxRating; rating >= 0; rating-- {
+		for _, user := range snap.UsersByRating[rating] {
+			if !fn(models.User{ID: user.ID, Username: user.Username, Metadata: snap.Metadata[user.ID]}, rating) {
+				return
+			}
+		}
+	}
 }
 
 func (s *LeaderboardService) GetLeaderboard(limit int) []models.LeaderboardEntry {
+	return s.GetLeaderboardForViewer(limit, 0)
+}
+
+// GetLeaderboardForViewer is GetLeaderboard as seen by viewerID: their own
+// entry is marked IsSelf and shown even if they are excluded. Zero means
+// an anonymous viewer.
+func (s *LeaderboardService) GetLeaderboardForViewer(limit, viewerID int) []models.LeaderboardEntry {
+	return s.GetLeaderboardWithOptions(LeaderboardOptions{Limit: limit, ViewerID: viewerID})
+}
+
+// GetLeaderboardWithin is GetLeaderboardForViewer served from
+// GetSnapshotWithin(maxStaleness), for clients that tolerate stale reads.
+func (s *LeaderboardService) GetLeaderboardWithin(limit, viewerID int, maxStaleness time.Duration) []models.LeaderboardEntry {
+	return s.GetLeaderboardWithOptions(LeaderboardOptions{Limit: limit, ViewerID: viewerID, MaxStaleness: maxStaleness})
+}
+
+// LeaderboardOptions selects what GetLeaderboardWithOptions returns. The
+// zero value is GetLeaderboard's default top 100 from the current snapshot.
+type LeaderboardOptions struct {
+	// Limit is the maximum number of entries. LimitAll means every entry;
+	// zero or any other negative value means 100.
+	Limit int
+
+	// ViewerID marks and always shows that user's entry, as in
+	// GetLeaderboardForViewer. Zero means an anonymous viewer.
+	ViewerID int
+
+	// MinRating stops the walk at this rating: only users rated at least
+	// MinRating are returned, with their global ranks.
+	MinRating int
+
+	// MaxStaleness serves GetSnapshotWithin(MaxStaleness) instead of the
+	// current snapshot. Zero means the current snapshot.
+	MaxStaleness time.Duration
+
+	// Include lists the metadata fields (see ParseMetadataFields) copied
+	// onto each entry. Nil includes none.
+	Include []string
+
+	// Offset skips that many visible entries before the first one
+	// returned, for paging through the leaderboard Limit entries at a time.
+	Offset int
+}
+
+// LimitAll as LeaderboardOptions.Limit asks for the whole leaderboard.
+const LimitAll = -1
+
+// DefaultLeaderboardLimit is the number of entries returned when
+// LeaderboardOptions.Limit is zero.
+const DefaultLeaderboardLimit = 100
+
+func (s *LeaderboardService) GetLeaderboardWithOptions(opts LeaderboardOptions) []models.LeaderboardEntry {
+	return s.leaderboard(s.snapshotFor(opts), opts)
+}
+
+// GetLeaderboardPage is GetLeaderboardWithOptions that also returns the
+// total number of entries visible to the viewer, counted from the same
+// snapshot, so callers paging by Offset know where the last page starts.
+func (s *LeaderboardService) GetLeaderboardPage(opts LeaderboardOptions) (entries []models.LeaderboardEntry, total int) {
+	snap := s.snapshotFor(opts)
+	view := s.viewFor(opts.ViewerID)
+
+	total = snap.CountInRange(opts.MinRating, MaxRating)
+	for id := range view.excluded {
+		if rating, ok := snap.UserRatings[id]; ok && rating >= opts.MinRating && view.hidden(id) {
+			total--
+		}
+	}
+	return s.leaderboard(snap, opts), total
+}
+
+func (s *LeaderboardService) snapshotFor(opts LeaderboardOptions) *snapshot.LeaderboardSnapshot {
+	if opts.MaxStaleness > 0 {
+		return s.GetSnapshotWithin(opts.MaxStaleness)
+	}
+	return s.GetSnapshot()
+}
+
+func (s *LeaderboardService) leaderboard(snap *snapshot.LeaderboardSnapshot, opts LeaderboardOptions) []models.LeaderboardEntry {
+	limit := opts.Limit
+	switch {
+	case limit == LimitAll:
+		limit = snap.TotalUsers()
+	case limit <= 0:
+		limit = DefaultLeaderboardLimit
+	}
+	offset := max(opts.Offset, 0)
+	if offset >= snap.TotalUsers() {
+		return []models.LeaderboardEntry{}
+	}
+	// Nobody lies past the population, which also keeps offset+limit from
+	// overflowing for huge offsets
+	limit = min(limit, snap.TotalUsers()-offset)
+	view := s.viewFor(opts.ViewerID)
+	view.include = opts.Include
+
+	// Common case: served from the entries precomputed at build time,
+	// which carry no user IDs to filter, mark or attach metadata by. Top
+	// is in rating order, so the rating floor just cuts it short.
+	if offset < len(snap.Top) && limit <= len(snap.Top)-offset && len(view.excluded) == 0 && opts.ViewerID == 0 && len(opts.Include) == 0 {
+		top := snap.Top[offset : offset+limit]
+		if end := slices.IndexFunc(top, func(e models.LeaderboardEntry) bool { return e.Rating < opts.MinRating }); end >= 0 {
+			top = top[:end]
+		}
+		return slices.Clone(top)
+	}
+
+	entries := walkLeaderboard(snap, offset+limit, opts.MinRating, view)
+	return entries[min(offset, len(entries)):]
+}
+
+// GetLeaderboardWithMore is GetLeaderboardWithOptions that also reports
+// whether more entries exist beyond the ones returned, e.g. hasMore is false
+// when the limit exceeds the (visible) population.
+func (s *LeaderboardService) GetLeaderboardWithMore(opts LeaderboardOptions) (entries []models.LeaderboardEntry, hasMore bool) {
+	if opts.Limit == LimitAll {
+		return s.GetLeaderboardWithOptions(opts), false
+	}
+
+	limit := opts.Limit
 	if limit <= 0 {
-		limit = 100 // Default limit
+		limit = DefaultLeaderboardLimit
 	}
 
-	snap := s.GetSnapshot()
+	// One extra entry tells whether the list goes on
+	opts.Limit = limit + 1
+	entries = s.GetLeaderboardWithOptions(opts)
+	if len(entries) <= limit {
+		return entries, false
+	}
+	return entries[:limit], true
+}
+
+// walkLeaderboard builds the first limit entries visible in view by walking
+// rating levels from the top down to minRating.
+func walkLeaderboard(snap *snapshot.LeaderboardSnapshot, limit, minRating int, view viewFilter) []models.LeaderboardEntry {
+	return walkRatings(snap, limit, minRating, MaxRating, view)
+}
 
-	result := make([]models.LeaderboardEntry, 0, limit)
+// walkRatings is walkLeaderboard starting from maxRating rather than the
+// top, so only the levels in [minRating, maxRating] are visited.
+func walkRatings(snap *snapshot.LeaderboardSnapshot, limit, minRating, maxRating int, view viewFilter) []models.LeaderboardEntry {
+	result := make([]models.LeaderboardEntry, 0, min(limit, snap.CountInRange(minRating, maxRating)))
 
-	for rating := MaxRating; rating >= MinRating; rating-- {
+	for rating := min(MaxRating, maxRating); rating >= max(MinRating, minRating); rating-- {
 		users := snap.UsersByRating[rating]
 		if len(users) == 0 {
 			continue
@@ -110,10 +600,16 @@ func (s *LeaderboardService) GetLeaderboard(limit int) []models.LeaderboardEntry
 		rank := snap.GetRank(rating)
 
 		for _, userSum := range users {
+			if view.hidden(userSum.ID) {
+				continue
+			}
+
 			result = append(result, models.LeaderboardEntry{
 				Rank:     rank,
 				Username: userSum.Username,
 				Rating:   userSum.Rating,
+				IsSelf:   view.isSelf(userSum.ID),
+				Metadata: view.metadata(snap, userSum.ID),
 			})
 
 			if len(result) >= limit {
@@ -125,32 +621,115 @@ func (s *LeaderboardService) GetLeaderboard(limit int) []models.LeaderboardEntry
 	return result
 }
 
+// Search returns users whose username contains query, ordered by the
+// service's configured default search order.
 func (s *LeaderboardService) Search(query string) []models.LeaderboardEntry {
+	return s.SearchWithOrder(query, s.config.DefaultSearchOrder)
+}
+
+// SearchWithOrder is Search with an explicit result ordering.
+// An empty order means SearchOrderRank.
+func (s *LeaderboardService) SearchWithOrder(query string, order SearchOrder) []models.LeaderboardEntry {
+	results, _ := s.SearchContext(context.Background(), query, order)
+	return results
+}
+
+// SearchContext is SearchWithOrder bounded by ctx. If ctx is done before
+// every candidate has been verified, the matches found so far are returned
+// (still ordered) with truncated set to true.
+func (s *LeaderboardService) SearchContext(ctx context.Context, query string, order SearchOrder) (results []models.LeaderboardEntry, truncated bool) {
+	return s.SearchForViewer(ctx, query, order, 0)
+}
+
+// SearchForViewer is SearchContext as seen by viewerID: if their username
+// matches, their entry is marked IsSelf and returned even if they are
+// excluded. Zero means an anonymous viewer.
+func (s *LeaderboardService) SearchForViewer(ctx context.Context, query string, order SearchOrder, viewerID int) (results []models.LeaderboardEntry, truncated bool) {
+	results, truncated, _ = s.SearchWithVersion(ctx, query, order, viewerID)
+	return results, truncated
+}
+
+// SearchWithVersion is SearchForViewer that also returns the Version of
+// the snapshot the ranks and ratings were read from, so clients caching
+// results know when they go stale.
+func (s *LeaderboardService) SearchWithVersion(ctx context.Context, query string, order SearchOrder, viewerID int) (results []models.LeaderboardEntry, truncated bool, version uint64) {
+	return s.SearchWithMetadata(ctx, query, order, viewerID, nil)
+}
+
+// SearchWithMetadata is SearchWithVersion with the metadata fields in
+// include (see ParseMetadataFields) copied onto each result.
+func (s *LeaderboardService) SearchWithMetadata(ctx context.Context, query string, order SearchOrder, viewerID int, include []string) (results []models.LeaderboardEntry, truncated bool, version uint64) {
 	if query == "" {
-		return []models.LeaderboardEntry{}
+		return []models.LeaderboardEntry{}, false, s.GetSnapshot().Version
 	}
 
-	query = strings.ToLower(query)
+	query = s.config.CaseFolding.fold(query)
+
+	view := s.viewFor(viewerID)
+	view.include = include
+	results, truncated, version = s.searchMatches(ctx, query, view)
+	sortSearchResults(results, query, order, s.config.CaseFolding)
+
+	return results, truncated, version
+}
+
+// deadlineCheckInterval is how many candidates are verified between checks
+// of the search context, keeping the check off the per-candidate hot path.
+const deadlineCheckInterval = 64
+
+// searchMatches returns users visible in view matching the lowercased query,
+// unordered, and the version of the snapshot they were read from.
+// truncated reports that ctx ended before all candidates were checked.
+func (s *LeaderboardService) searchMatches(ctx context.Context, query string, view viewFilter) (results []models.LeaderboardEntry, truncated bool, version uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
 	snap := s.GetSnapshot()
+	results, truncated = s.matchSnapshot(ctx, query, snap, view)
+	return results, truncated, snap.Version
+}
 
-	queryGrams := generateNGrams(query)
+// matchSnapshot does the work of searchMatches against snap. Requires s.mu.
+func (s *LeaderboardService) matchSnapshot(ctx context.Context, query string, snap *snapshot.LeaderboardSnapshot, view viewFilter) ([]models.LeaderboardEntry, bool) {
+
+	queryGrams := s.gramsOf(query)
 	if len(queryGrams) == 0 {
+		if len(query) == 1 && s.charIndex != nil {
+			return s.singleCharSearch(ctx, query[0], snap, view)
+		}
 		// Query too short or no valid grams, fallback to linear scan
-		return s.linearScanSearch(query, snap)
+		return s.linearScanSearch(ctx, query, snap, view)
 	}
 
-	candidateIDs := s.intersectPostingLists(queryGrams)
+	candidateIDs, tooBroad := s.intersectPostingLists(longestGrams(queryGrams))
+	if tooBroad {
+		s.broadSearches.Add(1)
+	}
 
 	results := make([]models.LeaderboardEntry, 0, len(candidateIDs))
 
-	// Verify candidates and build results
+	// Verify candidates and build results. A query too broad to narrow
+	// down only has its first MaxSearchCandidates candidates verified and
+	// is reported as truncated, like one that ran out of time.
+	checked := 0
 	for userID := range candidateIDs {
+		if checked%deadlineCheckInterval == 0 && ctx.Err() != nil {
+			return results, true
+		}
+		if tooBroad && checked >= s.config.MaxSearchCandidates {
+			return results, true
+		}
+		checked++
+
+		if s.verifyHook != nil {
+			s.verifyHook()
+		}
+
 		user := s.users[userID]
-		lowerUsername := strings.ToLower(user.Username)
+		lowerUsername := s.config.CaseFolding.fold(user.Username)
 
 		// Filter false positives
-		if !strings.Contains(lowerUsername, query) {
+		if !strings.Contains(lowerUsername, query) || view.hidden(userID) {
 			continue
 		}
 
@@ -161,20 +740,58 @@ func (s *LeaderboardService) Search(query string) []models.LeaderboardEntry {
 			Rank:     rank,
 			Username: user.Username,
 			Rating:   rating,
+			IsSelf:   view.isSelf(userID),
+			Metadata: view.metadata(snap, userID),
 		})
 	}
 
-	return results
+	return results, false
 }
 
 func (s *LeaderboardService) GetStats() map[string]interface{} {
 	snap := s.GetSnapshot()
 
 	return map[string]interface{}{
-		"total_users":     snap.TotalUsers(),
-		"snapshot_age_ms": time.Since(snap.GeneratedAt).Milliseconds(),
-		"min_rating":      MinRating,
-		"max_rating":      MaxRating,
+		"total_users":          snap.TotalUsers(),
+		"snapshot_age_ms":      time.Since(snap.GeneratedAt).Milliseconds(),
+		"snapshot_version":     snap.Version,
+		"min_rating":           MinRating,
+		"max_rating":           MaxRating,
+		"rate_limited_updates": s.rateLimitedUpdates.Load(),
+		"stale_updates":        s.staleUpdates.Load(),
+		"unknown_user_updates": s.unknownUsers.Load(),
+		"broad_searches":       s.broadSearches.Load(),
+		"evictions":            s.evictions.Load(),
+		"subscribers":          s.subscribers.count(),
+		"simulator_paused":     s.simulatorPaused.Load(),
+		"search_index":         s.searchIndexStats(),
+		"writer":               s.writerStats.stats(time.Now()),
+	}
+}
+
+// searchIndexStats sizes the n-gram index. estimated_bytes counts gram
+// keys, slice headers and postings but not map bucket overhead, so it is
+// for comparing index modes rather than an exact heap figure.
+func (s *LeaderboardService) searchIndexStats() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	mode := "full"
+	if s.config.CompactSearchIndex {
+		mode = "compact"
+	}
+
+	postings, bytes := 0, 0
+	for gram, userIDs := range s.searchIndex {
+		postings += len(userIDs)
+		bytes += len(gram) + 16 + 24 + 8*cap(userIDs) // string and slice headers
+	}
+
+	return map[string]interface{}{
+		"mode":            mode,
+		"grams":           len(s.searchIndex),
+		"postings":        postings,
+		"estimated_bytes": bytes,
 	}
 }
 
@@ -184,63 +801,271 @@ func (s *LeaderboardService) snapshotWriter() {
 
 	pendingUpdates := false
 
+	// Publishing is held to one snapshot per MinPublishInterval; a batch
+	// held back is published when deferred fires
+	var lastPublish time.Time
+	var deferred <-chan time.Time
+	publish := func() {
+		if !pendingUpdates {
+			return
+		}
+		if wait := s.config.MinPublishInterval - time.Since(lastPublish); wait > 0 {
+			if deferred == nil {
+				deferred = time.After(wait)
+			}
+			return
+		}
+		s.rebuildSnapshot()
+		pendingUpdates = false
+		lastPublish = time.Now()
+	}
+
 	for {
 		select {
 		case update := <-s.updateChan:
-			s.writerRatings[update.UserID] = update.NewRating
+			s.applyUpdate(update)
 			pendingUpdates = true
 
+		case cmd := <-s.commands:
+			cmd()
+
 		case <-ticker.C:
-			if pendingUpdates {
-				s.rebuildSnapshot()
-				pendingUpdates = false
+			publish()
+
+		case <-deferred:
+			deferred = nil
+			publish()
+
+		case <-s.stopChan:
+			if s.config.FlushOnStop {
+				s.flushPendingUpdates()
 			}
+			s.subscribers.closeAll()
+			close(s.writerDone)
+			return
 		}
 
 		drained := false
 		for !drained {
 			select {
 			case update := <-s.updateChan:
-				s.writerRatings[update.UserID] = update.NewRating
+				s.applyUpdate(update)
 				pendingUpdates = true
 			default:
 				drained = true
 			}
 		}
 
-		// If we drained updates, build snapshot immediately (don't wait for ticker)
-		if pendingUpdates {
+		// If we drained updates, build snapshot immediately (don't wait for
+		// ticker) unless one was published too recently
+		publish()
+	}
+}
+
+// validate checks an update's rating, or its delta for relative updates.
+func (u RatingUpdate) validate() error {
+	if u.Relative {
+		if u.Delta < -MaxRatingDelta || u.Delta > MaxRatingDelta {
+			return fmt.Errorf("delta %d out of range [%d, %d]", u.Delta, -MaxRatingDelta, MaxRatingDelta)
+		}
+		return nil
+	}
+
+	if u.NewRating < MinRating || u.NewRating > MaxRating {
+		return fmt.Errorf("rating %d out of range [%d, %d]", u.NewRating, MinRating, MaxRating)
+	}
+	return nil
+}
+
+// applyUpdate records an update in the writer's working copy. Updates for
+// users that do not exist (never did, or removed by ReplaceAll or eviction
+// while queued) are dropped and counted, as are sequenced updates older
+// than one already applied.
+func (s *LeaderboardService) applyUpdate(update RatingUpdate) {
+	if update.With != nil {
+		s.applyPaired(update)
+		return
+	}
+
+	s.writerStats.recordUpdate()
+
+	if _, ok := s.users[update.UserID]; !ok {
+		s.unknownUsers.Add(1)
+		return
+	}
+
+	if update.Relative {
+		rating := s.writerRatings[update.UserID] + update.Delta
+		s.setWriterRating(update.UserID, min(max(rating, MinRating), MaxRating))
+		return
+	}
+
+	if update.Seq != 0 {
+		if update.Seq <= s.writerSeqs[update.UserID] {
+			s.staleUpdates.Add(1)
+			return
+		}
+		if s.writerSeqs == nil {
+			s.writerSeqs = make(map[int]uint64)
+		}
+		s.writerSeqs[update.UserID] = update.Seq
+	}
+
+	s.setWriterRating(update.UserID, update.NewRating)
+}
+
+// setWriterRating stores a user's rating in the writer's working copy and,
+// if it changed, when, for recency tie-breaks (Config.TieBreak).
+func (s *LeaderboardService) setWriterRating(userID, rating int) {
+	if s.writerRatings[userID] == rating {
+		return
+	}
+	s.writerRatings[userID] = rating
+
+	if s.writerChanged == nil {
+		s.writerChanged = make(map[int]int64)
+	}
+	s.writerChanged[userID] = s.clock().UnixNano()
+}
+
+// recordInsertion gives userID the next insertion sequence and returns it.
+// Callers hold the write lock or own the service.
+func (s *LeaderboardService) recordInsertion(userID int) uint64 {
+	if s.writerInserted == nil {
+		s.writerInserted = make(map[int]uint64)
+	}
+	s.lastInserted++
+	s.writerInserted[userID] = s.lastInserted
+	return s.lastInserted
+}
+
+// runOnWriter executes fn on the writer goroutine, serialised with update
+// application and rebuilds, and waits for it to finish. Services that were
+// not started by a constructor have no writer, so fn runs inline.
+func (s *LeaderboardService) runOnWriter(fn func()) error {
+	if s.commands == nil {
+		s.inlineMu.Lock()
+		defer s.inlineMu.Unlock()
+		fn()
+		return nil
+	}
+
+	done := make(chan struct{})
+	cmd := func() {
+		fn()
+		close(done)
+	}
+
+	select {
+	case s.commands <- cmd:
+		<-done
+		return nil
+	case <-s.writerDone:
+		return ErrServiceStopped
+	}
+}
+
+// ForceRebuild publishes a fresh snapshot now instead of waiting for the
+// next tick. It runs on the writer like every other rebuild, so concurrent
+// calls queue behind each other and behind ticker rebuilds rather than
+// building in parallel.
+func (s *LeaderboardService) ForceRebuild() error {
+	if s.stopped.Load() {
+		return ErrServiceStopped
+	}
+	return s.runOnWriter(s.rebuildSnapshot)
+}
+
+// flushPendingUpdates applies everything still queued and publishes a
+// final snapshot. Only called by the writer during shutdown.
+func (s *LeaderboardService) flushPendingUpdates() {
+	for {
+		select {
+		case update := <-s.updateChan:
+			s.applyUpdate(update)
+		default:
 			s.rebuildSnapshot()
-			pendingUpdates = false
+			return
 		}
 	}
 }
 
 func (s *LeaderboardService) rebuildSnapshot() {
-	builder := snapshot.NewSnapshotBuilder()
+	started := s.writerStats.beginRebuild()
+
+	// The builder's maps are private to the writer and copied by Build, so
+	// they are reused across rebuilds rather than reallocated every tick.
+	// Published snapshots are never recycled: readers, the history ring and
+	// streams hold them with no point at which they are known released.
+	if s.rebuildBuilder == nil {
+		s.rebuildBuilder = s.newSnapshotBuilder()
+	}
+	builder := s.rebuildBuilder
+	builder.Reset()
 
 	for userID, rating := range s.writerRatings {
-		user := s.users[userID]
+		// writerRatings and users change together, but a rating without a
+		// user must never take the writer down
+		user, ok := s.users[userID]
+		if !ok {
+			continue
+		}
 		builder.AddUser(userID, user.Username, rating)
+		if user.Metadata != nil {
+			builder.SetMetadata(userID, user.Metadata)
+		}
+	}
+	for userID, changed := range s.writerChanged {
+		builder.SetUpdatedAt(userID, changed)
+	}
+	// Every user has a sequence, so only pay for copying them when used
+	if s.config.TieBreak == snapshot.TieBreakInsertion {
+		for userID, seq := range s.writerInserted {
+			builder.SetInserted(userID, seq)
+		}
 	}
 
 	newSnapshot := builder.Build()
 
 	// Atomically publish the new snapshot
 	// Readers will see either old or new, never partial
-	s.currentSnapshot.Store(newSnapshot)
+	s.publish(newSnapshot)
+	s.writerStats.recordRebuild(newSnapshot.GeneratedAt)
+	s.writerStats.finishRebuild(started)
+}
+
+// PauseSimulator stops the update simulator from generating rating changes,
+// e.g. to freeze the leaderboard during a demo. Submitted updates still
+// apply. An update the simulator had already queued may still land.
+func (s *LeaderboardService) PauseSimulator() {
+	s.simulatorPaused.Store(true)
+}
+
+// ResumeSimulator undoes PauseSimulator.
+func (s *LeaderboardService) ResumeSimulator() {
+	s.simulatorPaused.Store(false)
+}
+
+// SimulatorPaused reports whether PauseSimulator is in effect.
+func (s *LeaderboardService) SimulatorPaused() bool {
+	return s.simulatorPaused.Load()
 }
 
 func (s *LeaderboardService) updateSimulator() {
 	for {
 		sleepMs := 50 + s.rng.Intn(51)
-		time.Sleep(time.Duration(sleepMs) * time.Millisecond)
+
+		select {
+		case <-s.stopChan:
+			return
+		case <-time.After(time.Duration(sleepMs) * time.Millisecond):
+		}
 
 		numUpdates := 5 + s.rng.Intn(11) // 5-15 users
 
-		for i := 0; i < numUpdates; i++ {
+		for i := 0; i < numUpdates && !s.simulatorPaused.Load(); i++ {
 			userID := 1 + s.rng.Intn(InitialUsers)
-			newRating := utils.GenerateRandomRating(MinRating, MaxRating)
+			newRating := s.rng.Rating(MinRating, MaxRating)
 
 			select {
 			case s.updateChan <- RatingUpdate{
@@ -255,7 +1080,8 @@ func (s *LeaderboardService) updateSimulator() {
 }
 
 func (s *LeaderboardService) indexUsername(userID int, username string) {
-	grams := generateNGrams(strings.ToLower(username))
+	lowerUsername := s.config.CaseFolding.fold(username)
+	grams := s.gramsOf(lowerUsername)
 	seen := make(map[string]bool)
 
 	for _, gram := range grams {
@@ -264,20 +1090,123 @@ func (s *LeaderboardService) indexUsername(userID int, username string) {
 			seen[gram] = true
 		}
 	}
+
+	if s.usernameIndex == nil {
+		s.usernameIndex = make(map[string][]int)
+	}
+	s.usernameIndex[lowerUsername] = append(s.usernameIndex[lowerUsername], userID)
+
+	if s.config.IndexSingleChars {
+		s.indexChars(userID, lowerUsername)
+	}
+}
+
+// unindexUsername undoes indexUsername. Posting lists are edited in
+// place, so the caller must hold the write lock.
+func (s *LeaderboardService) unindexUsername(userID int, username string) {
+	lowerUsername := s.config.CaseFolding.fold(username)
+
+	remove := func(postingList []int) []int {
+		if i := slices.Index(postingList, userID); i >= 0 {
+			return slices.Delete(postingList, i, i+1)
+		}
+		return postingList
+	}
+
+	for _, gram := range s.gramsOf(lowerUsername) {
+		if postingList := remove(s.searchIndex[gram]); len(postingList) > 0 {
+			s.searchIndex[gram] = postingList
+		} else {
+			delete(s.searchIndex, gram)
+		}
+	}
+
+	if postingList := remove(s.usernameIndex[lowerUsername]); len(postingList) > 0 {
+		s.usernameIndex[lowerUsername] = postingList
+	} else {
+		delete(s.usernameIndex, lowerUsername)
+	}
+
+	for i := 0; i < len(lowerUsername) && s.charIndex != nil; i++ {
+		c := lowerUsername[i]
+		if postingList := remove(s.charIndex[c]); len(postingList) > 0 {
+			s.charIndex[c] = postingList
+		} else {
+			delete(s.charIndex, c)
+		}
+	}
+}
+
+// indexChars adds userID to the posting list of every distinct byte in
+// the lowercased username.
+func (s *LeaderboardService) indexChars(userID int, lowerUsername string) {
+	if s.charIndex == nil {
+		s.charIndex = make(map[byte][]int)
+	}
+
+	var seen [256]bool
+	for i := 0; i < len(lowerUsername); i++ {
+		c := lowerUsername[i]
+		if !seen[c] {
+			s.charIndex[c] = append(s.charIndex[c], userID)
+			seen[c] = true
+		}
+	}
+}
+
+// Gram lengths, in runes, indexed by default and in compact mode
+// (Config.CompactSearchIndex).
+const (
+	minGramLength     = 2
+	maxGramLength     = 5
+	compactGramLength = 3
+)
+
+// gramLengths returns the n-gram lengths the search index holds.
+func (s *LeaderboardService) gramLengths() (minN, maxN int) {
+	if s.config.CompactSearchIndex {
+		return compactGramLength, compactGramLength
+	}
+	return minGramLength, maxGramLength
+}
+
+// gramsOf returns the n-grams of s that the search index holds.
+func (s *LeaderboardService) gramsOf(str string) []string {
+	minN, maxN := s.gramLengths()
+	return generateNGramsRange(str, minN, maxN)
 }
 
 func generateNGrams(s string) []string {
-	if len(s) < 2 {
+	return generateNGramsRange(s, minGramLength, maxGramLength)
+}
+
+// generateNGramsRange returns the distinct substrings of s with minN to
+// maxN runes, shortest first. Grams are cut on rune boundaries, so each is
+// valid UTF-8; invalid bytes in s are replaced by U+FFFD first. Equal
+// grams taken at different offsets are returned once.
+func generateNGramsRange(s string, minN, maxN int) []string {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, string(utf8.RuneError))
+	}
+
+	// Byte offset of every rune, then the end of s
+	offsets := make([]int, 0, len(s)+1)
+	for i := range s {
+		offsets = append(offsets, i)
+	}
+	runes := len(offsets)
+	offsets = append(offsets, len(s))
+
+	if runes < minN {
 		return []string{}
 	}
 
 	grams := make([]string, 0)
 	seen := make(map[string]bool)
 
-	// Generate n-grams of length 2 to 5
-	for n := 2; n <= 5 && n <= len(s); n++ {
-		for i := 0; i <= len(s)-n; i++ {
-			gram := s[i : i+n]
+	for n := minN; n <= maxN && n <= runes; n++ {
+		for i := 0; i+n <= runes; i++ {
+			gram := s[offsets[i]:offsets[i+n]]
 			if !seen[gram] {
 				grams = append(grams, gram)
 				seen[gram] = true
@@ -288,64 +1217,119 @@ func generateNGrams(s string) []string {
 	return grams
 }
 
-func (s *LeaderboardService) intersectPostingLists(grams []string) map[int]bool {
-	if len(grams) == 0 {
-		return make(map[int]bool)
+// longestGrams keeps only the longest grams of a query. Every shorter gram
+// is a substring of one of them, so a username containing all the longest
+// grams contains the rest too: intersecting these alone yields the same
+// candidates with fewer, shorter posting lists.
+func longestGrams(grams []string) []string {
+	longest := 0
+	for _, gram := range grams {
+		longest = max(longest, utf8.RuneCountInString(gram))
 	}
 
-	// Find shortest posting list to start with (optimization)
-	shortestIdx := 0
-	shortestLen := len(s.searchIndex[grams[0]])
-
-	for i, gram := range grams {
-		listLen := len(s.searchIndex[gram])
-		if listLen < shortestLen {
-			shortestLen = listLen
-			shortestIdx = i
+	result := make([]string, 0, len(grams))
+	for _, gram := range grams {
+		if utf8.RuneCountInString(gram) == longest {
+			result = append(result, gram)
 		}
 	}
+	return result
+}
+
+// intersectPostingLists returns the users present in every gram's posting
+// list. Lists are intersected shortest first; if the two most selective
+// lists still leave more than Config.MaxSearchCandidates users, it stops
+// there and reports tooBroad, returning that unfinished candidate set.
+func (s *LeaderboardService) intersectPostingLists(grams []string) (candidates map[int]bool, tooBroad bool) {
+	if len(grams) == 0 {
+		return make(map[int]bool), false
+	}
+
+	// Shortest first keeps the candidate set small from the start
+	slices.SortFunc(grams, func(a, b string) int {
+		return cmp.Compare(len(s.searchIndex[a]), len(s.searchIndex[b]))
+	})
 
-	candidates := make(map[int]bool)
-	for _, userID := range s.searchIndex[grams[shortestIdx]] {
+	candidates = make(map[int]bool, len(s.searchIndex[grams[0]]))
+	for _, userID := range s.searchIndex[grams[0]] {
 		candidates[userID] = true
 	}
 
-	// Intersect with remaining lists
+	limit := s.config.MaxSearchCandidates
 	for i, gram := range grams {
-		if i == shortestIdx {
-			continue
-		}
+		if i > 0 {
+			postingList := s.searchIndex[gram]
+			if len(postingList) == 0 {
+				return make(map[int]bool), false
+			}
 
-		postingList := s.searchIndex[gram]
-		if len(postingList) == 0 {
-			return make(map[int]bool)
-		}
+			postingSet := make(map[int]bool, len(postingList))
+			for _, userID := range postingList {
+				postingSet[userID] = true
+			}
 
-		postingSet := make(map[int]bool)
-		for _, userID := range postingList {
-			postingSet[userID] = true
-		}
+			for userID := range candidates {
+				if !postingSet[userID] {
+					delete(candidates, userID)
+				}
+			}
 
-		for userID := range candidates {
-			if !postingSet[userID] {
-				delete(candidates, userID)
+			if len(candidates) == 0 {
+				return candidates, false
 			}
 		}
 
-		if len(candidates) == 0 {
-			return candidates
+		// Checked once the two shortest lists (or the only one) are in
+		if i == min(1, len(grams)-1) && limit > 0 && len(candidates) > limit {
+			return candidates, true
+		}
+	}
+
+	return candidates, false
+}
+
+// singleCharSearch answers a 1-char query straight from the char index.
+// Every posting is an exact match, so no verification is needed.
+func (s *LeaderboardService) singleCharSearch(ctx context.Context, c byte, snap *snapshot.LeaderboardSnapshot, view viewFilter) ([]models.LeaderboardEntry, bool) {
+	postingList := s.charIndex[c]
+	results := make([]models.LeaderboardEntry, 0, len(postingList))
+
+	for i, userID := range postingList {
+		if i%deadlineCheckInterval == 0 && ctx.Err() != nil {
+			return results, true
 		}
+		if view.hidden(userID) {
+			continue
+		}
+
+		user := s.users[userID]
+		rating := snap.GetUserRating(userID)
+		rank := snap.GetRank(rating)
+
+		results = append(results, models.LeaderboardEntry{
+			Rank:     rank,
+			Username: user.Username,
+			Rating:   rating,
+			IsSelf:   view.isSelf(userID),
+			Metadata: view.metadata(snap, userID),
+		})
 	}
 
-	return candidates
+	return results, false
 }
 
-func (s *LeaderboardService) linearScanSearch(query string, snap *snapshot.LeaderboardSnapshot) []models.LeaderboardEntry {
+func (s *LeaderboardService) linearScanSearch(ctx context.Context, query string, snap *snapshot.LeaderboardSnapshot, view viewFilter) ([]models.LeaderboardEntry, bool) {
 	results := make([]models.LeaderboardEntry, 0)
 
+	checked := 0
 	for userID, user := range s.users {
-		lowerUsername := strings.ToLower(user.Username)
-		if strings.Contains(lowerUsername, query) {
+		if checked%deadlineCheckInterval == 0 && ctx.Err() != nil {
+			return results, true
+		}
+		checked++
+
+		lowerUsername := s.config.CaseFolding.fold(user.Username)
+		if strings.Contains(lowerUsername, query) && !view.hidden(userID) {
 			rating := snap.GetUserRating(userID)
 			rank := snap.GetRank(rating)
 
@@ -353,9 +1337,11 @@ func (s *LeaderboardService) linearScanSearch(query string, snap *snapshot.Leade
 				Rank:     rank,
 				Username: user.Username,
 				Rating:   rating,
+				IsSelf:   view.isSelf(userID),
+				Metadata: view.metadata(snap, userID),
 			})
 		}
 	}
 
-	return results
+	return results, false
 }
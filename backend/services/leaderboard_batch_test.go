@@ -0,0 +1,46 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplyBatch(t *testing.T) {
+	service := createTestService()
+
+	err := service.ApplyBatch([]RatingUpdate{
+		{UserID: 9, NewRating: 5000},
+		{UserID: 10, NewRating: 4999},
+	})
+	if err != nil {
+		t.Fatalf("ApplyBatch failed: %v", err)
+	}
+
+	snap := service.GetSnapshot()
+	if snap.GetRank(snap.GetUserRating(9)) != 1 || snap.GetRank(snap.GetUserRating(10)) != 2 {
+		t.Errorf("Expected both batch updates in the same snapshot, got ratings %d and %d",
+			snap.GetUserRating(9), snap.GetUserRating(10))
+	}
+}
+
+func TestApplyBatch_RejectsWholeBatchOnInvalidRows(t *testing.T) {
+	service := createTestService()
+
+	err := service.ApplyBatch([]RatingUpdate{
+		{UserID: 1, NewRating: 1000},
+		{UserID: 999, NewRating: 1000},
+		{UserID: 2, NewRating: MinRating - 1},
+	})
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+	if len(errs) != 2 || errs[0].Index != 1 || errs[1].Index != 2 {
+		t.Errorf("Expected errors for rows 1 and 2, got %v", errs)
+	}
+
+	if got := service.GetSnapshot().GetUserRating(1); got != 4500 {
+		t.Errorf("Valid row must not be applied when the batch is rejected, got rating %d", got)
+	}
+}
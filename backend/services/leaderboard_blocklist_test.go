@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"matiks-backend/models"
+)
+
+func newBlocklistService(t *testing.T) *LeaderboardService {
+	t.Helper()
+
+	blocklist, err := ParseUsernameBlocklist(strings.NewReader(`
+# offensive words
+badword
+  Rude
+
+re:^admin\d*$
+`))
+	if err != nil {
+		t.Fatalf("ParseUsernameBlocklist failed: %v", err)
+	}
+	if blocklist.Len() != 3 {
+		t.Fatalf("Expected 3 rules, got %d", blocklist.Len())
+	}
+
+	service := createTestService()
+	service.config.UsernameBlocklist = blocklist
+	return service
+}
+
+func TestValidateUsername_Blocklist(t *testing.T) {
+	service := newBlocklistService(t)
+
+	for _, username := range []string{"badword", "xxBadWordxx", "BADWORD", "rude_player", "RuDe", "admin", "Admin42"} {
+		if err := service.ValidateUsername(username); !errors.Is(err, ErrUsernameBlocked) {
+			t.Errorf("ValidateUsername(%q) = %v, expected ErrUsernameBlocked", username, err)
+		}
+	}
+
+	for _, username := range []string{"rahul", "bad_word", "administrator", "my_admin"} {
+		if err := service.ValidateUsername(username); err != nil {
+			t.Errorf("ValidateUsername(%q) = %v, expected it to be allowed", username, err)
+		}
+	}
+
+	if err := service.ValidateUsername(""); err == nil {
+		t.Error("Expected an empty username to be rejected")
+	}
+}
+
+func TestReplaceAll_RejectsBlockedUsernames(t *testing.T) {
+	service := newBlocklistService(t)
+
+	err := service.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "zara", Rating: 3000},
+		{ID: 2, Username: "SoRudeOfYou", Rating: 3500},
+		{ID: 3, Username: "", Rating: MaxRating + 1},
+	})
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+	want := []ValidationError{
+		{Index: 1, Field: "username"},
+		{Index: 2, Field: "username"},
+		{Index: 2, Field: "rating"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for i, w := range want {
+		if errs[i].Index != w.Index || errs[i].Field != w.Field {
+			t.Errorf("Error %d: expected row %d field %q, got %+v", i, w.Index, w.Field, errs[i])
+		}
+	}
+
+	if got := service.GetSnapshot().TotalUsers(); got != 10 {
+		t.Errorf("Expected the population to be unchanged, got %d users", got)
+	}
+}
+
+func TestNewUsernameBlocklist_InvalidPattern(t *testing.T) {
+	if _, err := NewUsernameBlocklist([]string{"re:("}); err == nil {
+		t.Error("Expected an error for an invalid regular expression")
+	}
+}
+
+func TestUpdateUsername(t *testing.T) {
+	service := newBlocklistService(t)
+
+	for _, username := range []string{"BadWord99", "admin7", ""} {
+		if err := service.UpdateUsername(3, username); err == nil {
+			t.Errorf("Expected renaming to %q to be rejected", username)
+		}
+	}
+	if err := service.UpdateUsername(999, "newcomer"); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("Expected ErrUnknownUser, got %v", err)
+	}
+	if got := service.Search("rahul"); len(got) == 0 || got[0].Username != "rahul" {
+		t.Fatalf("Expected rejected renames to leave rahul in place, got %v", got)
+	}
+
+	if err := service.UpdateUsername(3, "zorawar"); err != nil {
+		t.Fatalf("UpdateUsername failed: %v", err)
+	}
+	if got := service.Search("zorawar"); len(got) != 1 || got[0].Rank != 1 {
+		t.Errorf("Expected the new name at rank 1, got %v", got)
+	}
+	for _, entry := range service.Search("rahul") {
+		if entry.Username == "rahul" {
+			t.Errorf("Expected the old name gone from search, got %v", entry)
+		}
+	}
+
+	// Under unique usernames another user's name is taken, but a user's
+	// own name is not
+	service.config.UniqueUsernames = true
+	if err := service.UpdateUsername(3, "priya"); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("Expected ErrUsernameTaken, got %v", err)
+	}
+	if err := service.UpdateUsername(3, "zorawar"); err != nil {
+		t.Errorf("Expected keeping the current name to succeed, got %v", err)
+	}
+}
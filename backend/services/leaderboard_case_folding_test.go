@@ -0,0 +1,91 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"matiks-backend/models"
+)
+
+func TestCaseFolding_IndexAndQueryAgree(t *testing.T) {
+	// Lowercasing leaves "ß" and "ss" apart; full folding unifies them
+	tests := []struct {
+		folding   CaseFolding
+		wantMatch bool
+	}{
+		{"", false}, // the default, CaseFoldingSimple
+		{CaseFoldingSimple, false},
+		{CaseFoldingFull, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.folding), func(t *testing.T) {
+			service := createTestServiceWithConfig(Config{CaseFolding: tt.folding})
+			service.users[11] = &models.User{ID: 11, Username: "Straße"}
+			service.writerRatings[11] = 4000
+			service.indexUsername(11, "Straße")
+			service.rebuildSnapshot()
+
+			for _, query := range []string{"STRASSE", "strasse"} {
+				results := service.Search(query)
+				if found := len(results) == 1 && results[0].Username == "Straße"; found != tt.wantMatch {
+					t.Errorf("Search(%q): expected match=%v, got %v", query, tt.wantMatch, results)
+				}
+			}
+
+			_, _, err := service.GetRankByUsername("STRASSE")
+			if (err == nil) != tt.wantMatch {
+				t.Errorf("GetRankByUsername: expected match=%v, got err %v", tt.wantMatch, err)
+			}
+
+			// Ordinary case differences match under either folding
+			if results := service.Search("STRAß"); len(results) != 1 {
+				t.Errorf("Expected STRAß to match under %q folding, got %v", tt.folding, results)
+			}
+		})
+	}
+}
+
+func TestCaseFolding_SortsLikeItMatches(t *testing.T) {
+	// Lowercased, "straße" sorts after "strasz"; folded, "strasse" before
+	tests := []struct {
+		folding CaseFolding
+		want    []string
+	}{
+		{CaseFoldingSimple, []string{"strasz", "Straße"}},
+		{CaseFoldingFull, []string{"Straße", "strasz"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.folding), func(t *testing.T) {
+			service := createTestServiceWithConfig(Config{CaseFolding: tt.folding})
+			for id, username := range map[int]string{11: "Straße", 12: "strasz"} {
+				service.users[id] = &models.User{ID: id, Username: username}
+				service.writerRatings[id] = 4000
+				service.indexUsername(id, username)
+			}
+			service.rebuildSnapshot()
+
+			for _, order := range []SearchOrder{SearchOrderAlpha, "username:asc"} {
+				var got []string
+				for _, entry := range service.SearchWithOrder("stra", order) {
+					got = append(got, entry.Username)
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("%s order: expected %v, got %v", order, tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCaseFolding(t *testing.T) {
+	for value, want := range map[string]CaseFolding{"": CaseFoldingSimple, "simple": CaseFoldingSimple, "FULL": CaseFoldingFull} {
+		if got, err := ParseCaseFolding(value); err != nil || got != want {
+			t.Errorf("ParseCaseFolding(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParseCaseFolding("turkish"); err == nil {
+		t.Error("Expected an error for an unknown folding")
+	}
+}
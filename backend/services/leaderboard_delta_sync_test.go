@@ -0,0 +1,103 @@
+package services
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestLeaderboardDelta_OnlyAffectedEntries(t *testing.T) {
+	service := createTestServiceWithConfig(Config{SnapshotHistory: 10})
+	service.rebuildSnapshot()
+	base := service.GetSnapshot().Version
+
+	// A rating change that moves nobody else
+	service.applyUpdate(RatingUpdate{UserID: 10, NewRating: 3850}) // priyanka, still last
+	service.rebuildSnapshot()
+
+	delta := service.LeaderboardDelta(base, 10)
+	if delta.Full {
+		t.Fatal("Expected an incremental delta for a retained version")
+	}
+	if delta.Version != service.GetSnapshot().Version {
+		t.Errorf("Expected version %d, got %d", service.GetSnapshot().Version, delta.Version)
+	}
+	want := []DeltaEntry{{ID: 10, Rank: 10, Username: "priyanka", Rating: 3850}}
+	if !slices.Equal(delta.Changed, want) || len(delta.Removed) != 0 {
+		t.Errorf("Expected only priyanka's new rating, got %+v", delta)
+	}
+
+	// rahul drops out of the top 3, moving priya and amit up and neha in
+	service.applyUpdate(RatingUpdate{UserID: 3, NewRating: 4000})
+	service.rebuildSnapshot()
+
+	delta = service.LeaderboardDelta(base, 3)
+	want = []DeltaEntry{
+		{ID: 5, Rank: 1, Username: "priya", Rating: 4600},
+		{ID: 1, Rank: 2, Username: "amit", Rating: 4500},
+		{ID: 7, Rank: 3, Username: "neha", Rating: 4400},
+	}
+	if !slices.Equal(delta.Changed, want) {
+		t.Errorf("Expected changed %+v, got %+v", want, delta.Changed)
+	}
+	if !slices.Equal(delta.Removed, []int{3}) {
+		t.Errorf("Expected rahul (3) removed, got %v", delta.Removed)
+	}
+
+	// Nothing changed since the current version
+	if delta := service.LeaderboardDelta(delta.Version, 10); len(delta.Changed) != 0 || len(delta.Removed) != 0 || delta.Full {
+		t.Errorf("Expected an empty delta, got %+v", delta)
+	}
+}
+
+func TestLeaderboardDelta_FullWhenVersionTooOld(t *testing.T) {
+	service := createTestServiceWithConfig(Config{SnapshotHistory: 2})
+	service.rebuildSnapshot()
+	old := service.GetSnapshot().Version
+
+	for i := 0; i < 3; i++ {
+		service.applyUpdate(RatingUpdate{UserID: 1, NewRating: 2000 + i})
+		service.rebuildSnapshot()
+	}
+
+	delta := service.LeaderboardDelta(old, 5)
+	if !delta.Full {
+		t.Fatal("Expected a full response for a version outside the history")
+	}
+	if len(delta.Changed) != 5 || delta.Changed[0].Username != "rahul" {
+		t.Errorf("Expected the full top 5, got %+v", delta.Changed)
+	}
+}
+
+func TestLeaderboardDelta_BinaryRoundTrip(t *testing.T) {
+	delta := LeaderboardDelta{
+		Version: 300,
+		Changed: []DeltaEntry{
+			{ID: 7, Rank: 1, Username: "neha", Rating: 4999},
+			{ID: 1000000, Rank: 150, Username: "", Rating: 0},
+		},
+		Removed: []int{3, 9},
+	}
+
+	data, err := delta.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	var got LeaderboardDelta
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, delta) {
+		t.Errorf("Round trip: got %+v, want %+v", got, delta)
+	}
+
+	// Truncation anywhere is an error rather than a panic
+	for i := 0; i < len(data); i++ {
+		if err := new(LeaderboardDelta).UnmarshalBinary(data[:i]); err == nil {
+			t.Errorf("Expected an error for %d of %d bytes", i, len(data))
+		}
+	}
+	if err := new(LeaderboardDelta).UnmarshalBinary(append(data, 0)); err == nil {
+		t.Error("Expected an error for trailing bytes")
+	}
+}
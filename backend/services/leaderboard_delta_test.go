@@ -0,0 +1,73 @@
+package services
+
+import "testing"
+
+func TestDeltaUpdates_CumulativeAndClamped(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	config.UserUpdateRate = 0
+	service := NewLeaderboardServiceWithConfig(config)
+
+	if err := service.SubmitUpdate(7, 3000); err != nil {
+		t.Fatalf("SubmitUpdate failed: %v", err)
+	}
+	for _, delta := range []int{100, -40, 15} {
+		if err := service.SubmitDelta(7, delta); err != nil {
+			t.Fatalf("SubmitDelta failed: %v", err)
+		}
+	}
+
+	// Deltas apply to the rating at the time, so an absolute update in
+	// between is built upon rather than overwritten
+	if err := service.SubmitUpdate(8, 4990); err != nil {
+		t.Fatalf("SubmitUpdate failed: %v", err)
+	}
+	for _, delta := range []int{30, -5} {
+		if err := service.SubmitDelta(8, delta); err != nil {
+			t.Fatalf("SubmitDelta failed: %v", err)
+		}
+	}
+
+	service.Stop()
+
+	if got := service.GetSnapshot().GetUserRating(7); got != 3075 {
+		t.Errorf("Expected cumulative rating 3075, got %d", got)
+	}
+
+	// 4990 + 30 clamps to 5000 before the -5 applies
+	if got := service.GetSnapshot().GetUserRating(8); got != MaxRating-5 {
+		t.Errorf("Expected clamped rating %d, got %d", MaxRating-5, got)
+	}
+}
+
+func TestDeltaUpdates_ClampAtMinimum(t *testing.T) {
+	service := createTestService()
+
+	service.applyUpdate(RatingUpdate{UserID: 9, Relative: true, Delta: -MaxRatingDelta})
+	service.applyUpdate(RatingUpdate{UserID: 9, Relative: true, Delta: 50})
+
+	if got := service.writerRatings[9]; got != MinRating+50 {
+		t.Errorf("Expected rating %d after clamping, got %d", MinRating+50, got)
+	}
+}
+
+func TestDeltaUpdates_IgnoreSequence(t *testing.T) {
+	service := createTestService()
+
+	service.applyUpdate(RatingUpdate{UserID: 1, NewRating: 2000, Seq: 5})
+	service.applyUpdate(RatingUpdate{UserID: 1, Relative: true, Delta: 10, Seq: 1})
+
+	if got := service.writerRatings[1]; got != 2010 {
+		t.Errorf("Expected delta to apply regardless of seq, got rating %d", got)
+	}
+}
+
+func TestDeltaUpdates_RejectsOutOfRangeDelta(t *testing.T) {
+	service := createTestService()
+
+	for _, delta := range []int{MaxRatingDelta + 1, -MaxRatingDelta - 1} {
+		if err := service.SubmitDelta(1, delta); err == nil {
+			t.Errorf("Expected delta %d to be rejected", delta)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"matiks-backend/models"
+)
+
+// deterministicSeeds are three users rated 4000, 4200 and 4400.
+func deterministicSeeds() []models.UserSeed {
+	return []models.UserSeed{
+		{ID: 1, Username: "amit", Rating: 4000},
+		{ID: 2, Username: "priya", Rating: 4200},
+		{ID: 3, Username: "rahul", Rating: 4400},
+	}
+}
+
+func newDeterministicService(t *testing.T, config Config) *LeaderboardService {
+	t.Helper()
+	config.Deterministic = true
+	service := NewLeaderboardServiceWithConfig(config)
+	t.Cleanup(service.Stop)
+	return service
+}
+
+func TestDeterministic_UpdatesVisibleOnReturn(t *testing.T) {
+	service := newDeterministicService(t, Config{SeedUsers: deterministicSeeds()})
+	version := service.GetSnapshot().Version
+
+	if err := service.SubmitUpdate(1, 4800); err != nil {
+		t.Fatalf("SubmitUpdate failed: %v", err)
+	}
+	snap := service.GetSnapshot()
+	if snap.GetUserRating(1) != 4800 || snap.GetRank(4800) != 1 {
+		t.Errorf("Expected user 1 at 4800 and rank 1, got %d and rank %d",
+			snap.GetUserRating(1), snap.GetRank(4800))
+	}
+	if snap.Version != version+1 {
+		t.Errorf("Expected one new snapshot, got version %d after %d", snap.Version, version)
+	}
+
+	if err := service.SubmitMatchResult(2, 3, 4750, 4650); err != nil {
+		t.Fatalf("SubmitMatchResult failed: %v", err)
+	}
+	snap = service.GetSnapshot()
+	if snap.GetUserRating(2) != 4750 || snap.GetUserRating(3) != 4650 {
+		t.Errorf("Expected the match applied, got %d and %d", snap.GetUserRating(2), snap.GetUserRating(3))
+	}
+
+	// Nothing runs in the background to change ratings
+	if service.GetSnapshot().Version != snap.Version {
+		t.Error("Expected no snapshots beyond those for submitted updates")
+	}
+}
+
+func TestDeterministic_ClockDrivesRateLimit(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	service := newDeterministicService(t, Config{
+		SeedUsers:       deterministicSeeds(),
+		Clock:           func() time.Time { return now },
+		UserUpdateRate:  1,
+		UserUpdateBurst: 1,
+	})
+
+	if err := service.SubmitUpdate(1, 4000); err != nil {
+		t.Fatalf("First update failed: %v", err)
+	}
+	if err := service.SubmitUpdate(1, 4100); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Expected ErrRateLimited while the clock stands still, got %v", err)
+	}
+
+	now = now.Add(time.Second)
+	if err := service.SubmitUpdate(1, 4100); err != nil {
+		t.Errorf("Expected the update allowed a second later, got %v", err)
+	}
+}
+
+func TestDeterministic_SeedReproducesUsers(t *testing.T) {
+	first := newDeterministicService(t, Config{RandSeed: 42, MaxUsers: 50})
+	second := newDeterministicService(t, Config{RandSeed: 42, MaxUsers: 50})
+
+	a, b := first.GetLeaderboard(50), second.GetLeaderboard(50)
+	if len(a) != 50 || len(b) != 50 {
+		t.Fatalf("Expected 50 users each, got %d and %d", len(a), len(b))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("Entry %d differs: %+v vs %+v", i, a[i], b[i])
+		}
+	}
+}
+
+func TestDeterministic_StopClosesSubscribers(t *testing.T) {
+	service := NewLeaderboardServiceWithConfig(Config{SeedUsers: deterministicSeeds(), Deterministic: true})
+	sub, err := service.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	service.Stop()
+	if _, open := <-sub.C; open {
+		t.Error("Expected the subscription closed by Stop")
+	}
+	if err := service.SubmitUpdate(1, 4000); !errors.Is(err, ErrServiceStopped) {
+		t.Errorf("Expected ErrServiceStopped, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"matiks-backend/models"
+)
+
+func TestAddUsers_EvictsColdestBeyondCap(t *testing.T) {
+	service := createTestServiceWithConfig(Config{MaxUsers: 10}) // already full
+
+	// amit_sharma (8) and deepak (9) tie at 3900; deepak was updated more
+	// recently, so amit_sharma is colder
+	clock := time.Unix(1000, 0)
+	service.now = func() time.Time { clock = clock.Add(time.Second); return clock }
+	service.applyUpdate(RatingUpdate{UserID: 8, NewRating: 3900})
+	service.applyUpdate(RatingUpdate{UserID: 9, NewRating: 3850})
+	service.applyUpdate(RatingUpdate{UserID: 9, NewRating: 3900})
+
+	err := service.AddUsers([]models.UserSeed{
+		{ID: 11, Username: "vikram", Rating: 4900},
+		{ID: 12, Username: "sunita", Rating: 4800},
+	})
+	if err != nil {
+		t.Fatalf("AddUsers failed: %v", err)
+	}
+
+	// priyanka (10, 3800) is the lowest, then amit_sharma
+	for _, userID := range []int{10, 8} {
+		if _, ok := service.GetSnapshot().UserRatings[userID]; ok {
+			t.Errorf("Expected user %d evicted", userID)
+		}
+		if _, ok := service.users[userID]; ok {
+			t.Errorf("Expected user %d removed from users", userID)
+		}
+	}
+	if got := service.GetSnapshot().TotalUsers(); got != 10 {
+		t.Errorf("Expected the population held at 10, got %d", got)
+	}
+	if got := service.GetStats()["evictions"]; got != uint64(2) {
+		t.Errorf("Expected 2 evictions in stats, got %v", got)
+	}
+
+	top := service.GetLeaderboard(3)
+	if top[0].Username != "vikram" || top[1].Username != "sunita" || top[2].Username != "rahul" {
+		t.Errorf("Expected the new and top players to remain, got %v", top)
+	}
+
+	// Evicted users are gone from every index
+	if results := service.Search("priyanka"); len(results) != 0 {
+		t.Errorf("Expected no search results for an evicted user, got %v", results)
+	}
+	if results := service.Search("priya"); len(results) != 1 {
+		t.Errorf("Expected only priya left matching, got %v", results)
+	}
+	if _, _, err := service.GetRankByUsername("amit_sharma"); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("Expected amit_sharma unknown after eviction, got %v", err)
+	}
+	for gram, userIDs := range service.searchIndex {
+		if slices.Contains(userIDs, 8) || slices.Contains(userIDs, 10) {
+			t.Errorf("Gram %q still lists an evicted user: %v", gram, userIDs)
+		}
+	}
+}
+
+func TestAddUsers_Rejected(t *testing.T) {
+	service := createTestServiceWithConfig(Config{MaxUsers: 2})
+
+	var errs ValidationErrors
+	err := service.AddUsers([]models.UserSeed{
+		{ID: 3, Username: "rahul_again", Rating: 4000}, // ID taken
+	})
+	if !errors.As(err, &errs) || errs[0].Field != "id" {
+		t.Errorf("Expected a validation error for a taken ID, got %v", err)
+	}
+
+	err = service.AddUsers([]models.UserSeed{
+		{ID: 11, Username: "a", Rating: 4000},
+		{ID: 12, Username: "b", Rating: 4000},
+		{ID: 13, Username: "c", Rating: 4000},
+	})
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Index != 2 {
+		t.Errorf("Expected the row beyond the cap reported, got %v", err)
+	}
+
+	if got := service.GetStats()["evictions"]; got != uint64(0) {
+		t.Errorf("Expected rejected batches to evict nobody, got %v", got)
+	}
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestExclude_HidesUserFromListings(t *testing.T) {
+	service := createTestService()
+
+	if err := service.Exclude(3); err != nil { // rahul, rank 1
+		t.Fatalf("Exclude failed: %v", err)
+	}
+
+	for _, entry := range service.Search("rahul") {
+		if entry.Username == "rahul" {
+			t.Error("Excluded user should not appear in search")
+		}
+	}
+
+	top := service.GetLeaderboard(3)
+	if len(top) != 3 || top[0].Username != "priya" {
+		t.Fatalf("Expected priya first with rahul hidden, got %v", top)
+	}
+	if top[0].Rank != 2 {
+		t.Errorf("Hiding a user must not change others' ranks, got priya at %d", top[0].Rank)
+	}
+
+	if err := service.Exclude(999); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("Expected ErrUnknownUser, got %v", err)
+	}
+
+	service.Unexclude(3)
+	if results := service.Search("rahul"); len(results) != 3 {
+		t.Errorf("Expected rahul visible again, got %v", results)
+	}
+}
+
+func TestSearchForViewer_IncludesSelfDespiteExclusion(t *testing.T) {
+	service := createTestService()
+	if err := service.Exclude(3); err != nil {
+		t.Fatalf("Exclude failed: %v", err)
+	}
+
+	results, _ := service.SearchForViewer(context.Background(), "rahul", SearchOrderRank, 3)
+	if len(results) != 3 || results[0].Username != "rahul" || !results[0].IsSelf {
+		t.Fatalf("Expected rahul first and marked as self, got %v", results)
+	}
+	for _, entry := range results[1:] {
+		if entry.IsSelf {
+			t.Errorf("Only the viewer's entry should be marked, got %v", entry)
+		}
+	}
+
+	// Another viewer still doesn't see the excluded user
+	results, _ = service.SearchForViewer(context.Background(), "rahul", SearchOrderRank, 5)
+	if len(results) != 2 {
+		t.Errorf("Expected 2 results for another viewer, got %v", results)
+	}
+
+	top := service.GetLeaderboardForViewer(2, 3)
+	if len(top) != 2 || top[0].Username != "rahul" || !top[0].IsSelf {
+		t.Errorf("Expected rahul in own leaderboard view, got %v", top)
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"matiks-backend/snapshot"
+)
+
+// publishAged publishes a one-user snapshot generated age ago.
+func publishAged(service *LeaderboardService, rating int, age time.Duration) *snapshot.LeaderboardSnapshot {
+	builder := snapshot.NewSnapshotBuilder()
+	builder.AddUser(1, "amit", rating)
+	snap := builder.Build()
+	snap.GeneratedAt = time.Now().Add(-age)
+	service.publish(snap)
+	return snap
+}
+
+func TestGetSnapshotWithin_ServesOldestWithinWindow(t *testing.T) {
+	service := createTestService()
+	service.config.SnapshotHistory = 3
+
+	publishAged(service, 1000, 5*time.Second) // evicted by the ring
+	tooOld := publishAged(service, 2000, 2*time.Second)
+	withinWindow := publishAged(service, 3000, 400*time.Millisecond)
+	current := publishAged(service, 4000, 0)
+
+	if got := service.GetSnapshotWithin(time.Second); got != withinWindow {
+		t.Errorf("Expected the 400ms old snapshot, got rating %d", got.GetUserRating(1))
+	}
+	if got := service.GetSnapshotWithin(3 * time.Second); got != tooOld {
+		t.Errorf("Expected the 2s old snapshot, got rating %d", got.GetUserRating(1))
+	}
+	if got := service.GetSnapshotWithin(time.Minute); got != tooOld {
+		t.Errorf("Expected the evicted snapshot to be gone, got rating %d", got.GetUserRating(1))
+	}
+
+	// Nothing older qualifies: fall back to the current snapshot
+	if got := service.GetSnapshotWithin(0); got != current {
+		t.Errorf("Expected the current snapshot, got rating %d", got.GetUserRating(1))
+	}
+
+	leaderboard := service.GetLeaderboardWithin(1, 0, time.Second)
+	if len(leaderboard) != 1 || leaderboard[0].Rating != 3000 {
+		t.Errorf("Expected leaderboard from the 400ms old snapshot, got %+v", leaderboard)
+	}
+}
+
+func TestGetSnapshotWithin_DisabledHistory(t *testing.T) {
+	service := createTestService()
+
+	publishAged(service, 2000, time.Second)
+	current := publishAged(service, 3000, 0)
+
+	if got := service.GetSnapshotWithin(time.Minute); got != current {
+		t.Errorf("Expected the current snapshot without history, got rating %d", got.GetUserRating(1))
+	}
+}
+
+func TestRankHistory_Progression(t *testing.T) {
+	service := createTestServiceWithConfig(Config{SnapshotHistory: 10})
+	service.rebuildSnapshot() // deepak (9) at 3900: rank 9
+
+	for _, rating := range []int{4350, 4650, 4800} { // ranks 5, 2, 1
+		service.applyUpdate(RatingUpdate{UserID: 9, NewRating: rating})
+		service.rebuildSnapshot()
+	}
+
+	points, ok := service.RankHistory(9, 3)
+	if !ok {
+		t.Fatal("Expected deepak to have a rank history")
+	}
+	var ranks []int
+	for _, point := range points {
+		ranks = append(ranks, point.Rank)
+	}
+	if !slices.Equal(ranks, []int{5, 2, 1}) {
+		t.Errorf("Expected ranks [5 2 1] over the last 3 snapshots, got %v", ranks)
+	}
+	if last := points[len(points)-1]; last.Version != service.GetSnapshot().Version || last.Rating != 4800 {
+		t.Errorf("Expected the last point to be the current snapshot, got %+v", last)
+	}
+
+	// A longer window stops at what is retained
+	points, _ = service.RankHistory(9, 50)
+	if len(points) != 4 || points[0].Rank != 9 {
+		t.Errorf("Expected 4 retained points starting at rank 9, got %+v", points)
+	}
+
+	// A user missing from older snapshots has empty points there
+	builder := service.newSnapshotBuilder()
+	for userID, rating := range service.GetSnapshot().UserRatings {
+		builder.AddUser(userID, service.users[userID].Username, rating)
+	}
+	builder.AddUser(11, "newcomer", 5000)
+	service.publish(builder.Build())
+
+	points, ok = service.RankHistory(11, 2)
+	if !ok || len(points) != 2 || points[0].Rank != 0 || points[1].Rank != 1 {
+		t.Errorf("Expected no rank, then rank 1 for the newcomer, got %+v", points)
+	}
+
+	if _, ok := service.RankHistory(99, 10); ok {
+		t.Error("Expected no history for an unknown user")
+	}
+}
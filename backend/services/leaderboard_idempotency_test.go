@@ -0,0 +1,123 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSubmitIdempotent_AppliesOnce(t *testing.T) {
+	service := &LeaderboardService{
+		updateChan:  make(chan RatingUpdate, 10),
+		idempotency: newIdempotencyCache(time.Minute, 100),
+	}
+	update := RatingUpdate{UserID: 1, NewRating: 3000}
+
+	replayed, err := service.SubmitIdempotent("key-1", update)
+	if err != nil || replayed {
+		t.Fatalf("First submission: replayed=%v err=%v", replayed, err)
+	}
+
+	replayed, err = service.SubmitIdempotent("key-1", update)
+	if err != nil || !replayed {
+		t.Fatalf("Retry: expected replay, got replayed=%v err=%v", replayed, err)
+	}
+
+	if queued := len(service.updateChan); queued != 1 {
+		t.Errorf("Expected the update queued once, got %d", queued)
+	}
+
+	if _, err := service.SubmitIdempotent("key-1", RatingUpdate{UserID: 1, NewRating: 3100}); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("Expected ErrIdempotencyKeyReused for a different update, got %v", err)
+	}
+
+	// Without a key every submission is applied
+	service.SubmitIdempotent("", update)
+	service.SubmitIdempotent("", update)
+	if queued := len(service.updateChan); queued != 3 {
+		t.Errorf("Expected unkeyed submissions to be queued, got %d", queued)
+	}
+}
+
+func TestSubmitIdempotent_FailureReleasesKey(t *testing.T) {
+	service := &LeaderboardService{
+		updateChan:  make(chan RatingUpdate), // unbuffered: always full
+		idempotency: newIdempotencyCache(time.Minute, 100),
+	}
+	update := RatingUpdate{UserID: 1, NewRating: 3000}
+
+	if _, err := service.SubmitIdempotent("key-1", update); !errors.Is(err, ErrUpdateQueueFull) {
+		t.Fatalf("Expected ErrUpdateQueueFull, got %v", err)
+	}
+
+	service.updateChan = make(chan RatingUpdate, 1)
+	replayed, err := service.SubmitIdempotent("key-1", update)
+	if err != nil || replayed {
+		t.Errorf("Expected retry after failure to be applied, got replayed=%v err=%v", replayed, err)
+	}
+}
+
+func TestIdempotencyCache_Eviction(t *testing.T) {
+	cache := newIdempotencyCache(time.Minute, 2)
+	start := time.Now()
+	submit := func() error { return nil }
+
+	cache.do("a", RatingUpdate{UserID: 1}, start, submit)
+	cache.do("b", RatingUpdate{UserID: 2}, start, submit)
+	cache.do("c", RatingUpdate{UserID: 3}, start, submit) // evicts "a"
+
+	if replayed, _ := cache.do("a", RatingUpdate{UserID: 1}, start, submit); replayed {
+		t.Error("Expected oldest key evicted beyond maxKeys")
+	}
+
+	later := start.Add(2 * time.Minute)
+	if replayed, _ := cache.do("c", RatingUpdate{UserID: 3}, later, submit); replayed {
+		t.Error("Expected key to expire after the TTL")
+	}
+}
+
+func TestIdempotencyCache_RetryAfterFailureKeepsCallersClock(t *testing.T) {
+	cache := newIdempotencyCache(time.Minute, 100)
+	start := time.Unix(1700000000, 0) // a caller clock far from time.Now
+	update := RatingUpdate{UserID: 1}
+
+	release := make(chan struct{})
+	first := make(chan error)
+	go func() {
+		_, err := cache.do("k", update, start, func() error {
+			<-release
+			return ErrUpdateQueueFull
+		})
+		first <- err
+	}()
+
+	// Wait for the first attempt to hold the key, then retry behind it
+	for {
+		cache.mu.Lock()
+		_, held := cache.entries["k"]
+		cache.mu.Unlock()
+		if held {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	retried := make(chan error)
+	go func() {
+		_, err := cache.do("k", update, start, func() error { return nil })
+		retried <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	if err := <-first; !errors.Is(err, ErrUpdateQueueFull) {
+		t.Fatalf("Expected the first attempt to fail, got %v", err)
+	}
+	if err := <-retried; err != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", err)
+	}
+
+	// The retry's key expires a TTL after the caller's clock
+	if replayed, _ := cache.do("k", update, start.Add(2*time.Minute), func() error { return nil }); replayed {
+		t.Error("Expected the retried key to expire by the caller's clock")
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"matiks-backend/snapshot"
+)
+
+func TestLagMonitor_AlertsOncePerTransition(t *testing.T) {
+	alerts := make(chan LagAlert, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert LagAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Errorf("Failed to decode alert: %v", err)
+		}
+		alerts <- alert
+	}))
+	defer webhook.Close()
+
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	config.UserUpdateRate = 0
+	config.LagAlertWebhook = webhook.URL
+	config.LagAlertThreshold = 200 * time.Millisecond
+	config.LagAlertDebounce = 100 * time.Millisecond
+	service := NewLeaderboardServiceWithConfig(config)
+	defer service.Stop()
+
+	// Stall the writer with an update queued behind it
+	release := make(chan struct{})
+	go service.runOnWriter(func() { <-release })
+	time.Sleep(10 * time.Millisecond)
+	if err := service.SubmitUpdate(1, 2500); err != nil {
+		t.Fatalf("SubmitUpdate failed: %v", err)
+	}
+
+	expectAlert := func(event string) {
+		t.Helper()
+		select {
+		case alert := <-alerts:
+			if alert.Event != event {
+				t.Fatalf("Expected %s alert, got %+v", event, alert)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("No %s alert received", event)
+		}
+	}
+	expectNoAlert := func(wait time.Duration) {
+		t.Helper()
+		select {
+		case alert := <-alerts:
+			t.Fatalf("Unexpected extra alert %+v", alert)
+		case <-time.After(wait):
+		}
+	}
+
+	expectAlert(LagAlertLagging)
+	expectNoAlert(500 * time.Millisecond) // still lagging: no repeat
+
+	close(release)
+	expectAlert(LagAlertRecovered)
+	expectNoAlert(500 * time.Millisecond)
+}
+
+func TestCheckLag_Debounces(t *testing.T) {
+	service := &LeaderboardService{
+		config: Config{
+			LagAlertThreshold: 100 * time.Millisecond,
+			LagAlertDebounce:  300 * time.Millisecond,
+		},
+		updateChan: make(chan RatingUpdate, 1),
+	}
+	snap := snapshot.NewSnapshotBuilder().Build()
+	service.publish(snap)
+	service.updateChan <- RatingUpdate{UserID: 1, NewRating: 2000}
+
+	monitor := &lagMonitor{}
+	start := snap.GeneratedAt.Add(time.Second)
+
+	// Lagging, but not yet for the debounce period
+	for _, offset := range []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond} {
+		if alert, ok := service.checkLag(monitor, start.Add(offset)); ok {
+			t.Fatalf("Alert %+v before the debounce period", alert)
+		}
+	}
+	if alert, ok := service.checkLag(monitor, start.Add(300*time.Millisecond)); !ok || alert.Event != LagAlertLagging {
+		t.Fatalf("Expected a lagging alert after the debounce period, got %+v (%v)", alert, ok)
+	}
+
+	// A brief recovery that does not last is not reported
+	<-service.updateChan
+	if _, ok := service.checkLag(monitor, start.Add(400*time.Millisecond)); ok {
+		t.Fatal("Recovery reported before the debounce period")
+	}
+	service.updateChan <- RatingUpdate{UserID: 1, NewRating: 2000}
+	if _, ok := service.checkLag(monitor, start.Add(800*time.Millisecond)); ok {
+		t.Fatal("Flapping back to lagging produced an alert")
+	}
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"testing"
+
+	"matiks-backend/models"
+)
+
+func TestMaskUsername(t *testing.T) {
+	tests := []struct {
+		name   string
+		reveal int
+		want   string
+	}{
+		{"rahul", 2, "ra***"},
+		{"rahul", 0, "***"},
+		{"ra", 2, "r***"}, // never the whole name
+		{"a", 2, "***"},
+		{"", 2, "***"},
+		{"Ünal", 2, "Ün***"}, // characters, not bytes
+	}
+
+	for _, tt := range tests {
+		service := createTestServiceWithConfig(Config{MaskUsernames: true, UsernameReveal: tt.reveal})
+		if got := service.MaskUsername(tt.name); got != tt.want {
+			t.Errorf("MaskUsername(%q) with reveal %d = %q, want %q", tt.name, tt.reveal, got, tt.want)
+		}
+	}
+
+	if got := createTestService().MaskUsername("rahul"); got != "rahul" {
+		t.Errorf("Expected no masking by default, got %q", got)
+	}
+}
+
+func TestMaskEntries_KeepsViewersOwnEntry(t *testing.T) {
+	service := createTestServiceWithConfig(Config{MaskUsernames: true, UsernameReveal: 2})
+
+	entries := []models.LeaderboardEntry{
+		{Rank: 1, Username: "rahul"},
+		{Rank: 2, Username: "priya", IsSelf: true},
+	}
+	service.MaskEntries(entries)
+
+	if entries[0].Username != "ra***" || entries[1].Username != "priya" {
+		t.Errorf("Expected only the other user masked, got %+v", entries)
+	}
+}
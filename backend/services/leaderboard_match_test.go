@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"matiks-backend/models"
+)
+
+func TestSubmitMatchResult_BothRatingsInSameSnapshot(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	config.UserUpdateRate = 0
+	service := NewLeaderboardServiceWithConfig(config)
+	defer service.Stop()
+
+	// Each round's ratings are unique to it, so any snapshot holding one
+	// player's new rating must hold the other's too
+	for round := 0; round < 20; round++ {
+		winnerRating, loserRating := 3000+round, 2000+round
+		if err := service.SubmitMatchResult(1, 2, winnerRating, loserRating); err != nil {
+			t.Fatalf("SubmitMatchResult failed: %v", err)
+		}
+
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			snap := service.GetSnapshot()
+			winner, loser := snap.GetUserRating(1), snap.GetUserRating(2)
+			if (winner == winnerRating) != (loser == loserRating) {
+				t.Fatalf("Snapshot %d holds only half of round %d: %d and %d", snap.Version, round, winner, loser)
+			}
+			if winner == winnerRating {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Round %d was never published", round)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestSubmitMatchResult_Rejections(t *testing.T) {
+	service := createTestService()
+	service.updateChan = make(chan RatingUpdate, 10)
+
+	tests := []struct {
+		name                      string
+		winnerID, loserID         int
+		winnerRating, loserRating int
+		want                      error
+	}{
+		{"unknown winner", 999, 2, 4000, 3900, ErrUnknownUser},
+		{"unknown loser", 1, 999, 4000, 3900, ErrUnknownUser},
+		{"same user", 1, 1, 4000, 3900, ErrSameUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.SubmitMatchResult(tt.winnerID, tt.loserID, tt.winnerRating, tt.loserRating)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+	if err := service.SubmitMatchResult(1, 2, MaxRating+1, 3900); err == nil {
+		t.Error("Expected an out-of-range rating to be rejected")
+	}
+	if len(service.updateChan) != 0 {
+		t.Errorf("Expected nothing queued, got %d updates", len(service.updateChan))
+	}
+}
+
+func TestSubmitMatchResult_ThrottledLoserCostsWinnerNothing(t *testing.T) {
+	config := DefaultConfig()
+	config.Deterministic = true
+	config.Clock = func() time.Time { return time.Unix(1700000000, 0) }
+	config.UserUpdateRate = 0.01
+	config.UserUpdateBurst = 1
+	config.SeedUsers = []models.UserSeed{
+		{ID: 1, Username: "alice", Rating: 3000},
+		{ID: 2, Username: "bob", Rating: 2000},
+	}
+	service := NewLeaderboardServiceWithConfig(config)
+	defer service.Stop()
+
+	// The loser spends their only token
+	if err := service.SubmitUpdate(2, 2100); err != nil {
+		t.Fatalf("SubmitUpdate failed: %v", err)
+	}
+	if err := service.SubmitMatchResult(1, 2, 3100, 1900); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Expected ErrRateLimited with the loser throttled, got %v", err)
+	}
+
+	// The rejected match took nothing from the winner
+	if err := service.SubmitUpdate(1, 3200); err != nil {
+		t.Errorf("Expected the winner's token untouched, got %v", err)
+	}
+	if got := service.GetSnapshot().GetUserRating(2); got != 2100 {
+		t.Errorf("Expected the loser's rating unchanged by the match, got %d", got)
+	}
+}
+
+func TestApplyUpdate_PairedDroppedTogether(t *testing.T) {
+	service := createTestService()
+
+	// The loser left between submission and application
+	loser := RatingUpdate{UserID: 999, NewRating: 3000}
+	service.applyUpdate(RatingUpdate{UserID: 1, NewRating: 4999, With: &loser})
+	service.rebuildSnapshot()
+
+	if got := service.GetSnapshot().GetUserRating(1); got != 4500 {
+		t.Errorf("Expected the winner's rating unchanged at 4500, got %d", got)
+	}
+	if got := service.unknownUsers.Load(); got != 2 {
+		t.Errorf("Expected both updates counted as dropped, got %d", got)
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"matiks-backend/models"
+)
+
+func TestMetadata_IncludedWhenRequested(t *testing.T) {
+	service := createTestServiceWithConfig(Config{TopNCacheSize: 100})
+	err := service.AddUsers([]models.UserSeed{
+		{ID: 20, Username: "zara", Rating: 4900, Metadata: &models.UserMetadata{
+			Country: "IN", Avatar: "https://example.com/zara.png", Joined: "2024-03-01",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("AddUsers failed: %v", err)
+	}
+	service.rebuildSnapshot()
+
+	// Without include, nothing is attached, even from the top-N cache
+	if entries := service.GetLeaderboard(3); entries[0].Username != "zara" || entries[0].Metadata != nil {
+		t.Errorf("Expected zara first without metadata, got %+v", entries[0])
+	}
+
+	entries := service.GetLeaderboardWithOptions(LeaderboardOptions{Limit: 3, Include: []string{"country", "avatar"}})
+	want := models.UserMetadata{Country: "IN", Avatar: "https://example.com/zara.png"}
+	if entries[0].Metadata == nil || *entries[0].Metadata != want {
+		t.Errorf("Expected only country and avatar, got %+v", entries[0].Metadata)
+	}
+	if entries[1].Metadata != nil {
+		t.Errorf("Expected no metadata for a user without any, got %+v", entries[1].Metadata)
+	}
+
+	results, _, _ := service.SearchWithMetadata(context.Background(), "zara", SearchOrderRank, 0, []string{"joined"})
+	if len(results) != 1 || results[0].Metadata == nil || *results[0].Metadata != (models.UserMetadata{Joined: "2024-03-01"}) {
+		t.Errorf("Expected zara's join date in search results, got %+v", results)
+	}
+}
+
+func TestMetadata_SurvivesReplaceAll(t *testing.T) {
+	service := createTestService()
+	err := service.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "amit", Rating: 4500, Metadata: &models.UserMetadata{Country: "NP"}},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	// Rating updates rebuild the snapshot from the writer's users
+	service.applyUpdate(RatingUpdate{UserID: 1, NewRating: 4600})
+	service.rebuildSnapshot()
+
+	entries := service.GetLeaderboardWithOptions(LeaderboardOptions{Limit: 1, Include: []string{"country"}})
+	if len(entries) != 1 || entries[0].Rating != 4600 || entries[0].Metadata == nil || entries[0].Metadata.Country != "NP" {
+		t.Errorf("Expected amit at 4600 from NP, got %+v", entries)
+	}
+}
+
+func TestMetadata_Validation(t *testing.T) {
+	errs := ValidateSeeds([]models.UserSeed{
+		{ID: 1, Username: "ok", Rating: 4000, Metadata: &models.UserMetadata{Joined: "2024-03-01"}},
+		{ID: 2, Username: "bad_date", Rating: 4000, Metadata: &models.UserMetadata{Joined: "March 2024"}},
+	})
+	if len(errs) != 1 || errs[0].Index != 1 || errs[0].Field != "metadata" {
+		t.Errorf("Expected one metadata error on row 1, got %v", errs)
+	}
+
+	if _, err := ParseMetadataFields("country, avatar,"); err != nil {
+		t.Errorf("Expected known fields to parse, got %v", err)
+	}
+	if _, err := ParseMetadataFields("country,email"); err == nil {
+		t.Error("Expected an unknown field to be rejected")
+	}
+}
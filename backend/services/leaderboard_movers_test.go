@@ -0,0 +1,70 @@
+package services
+
+import "testing"
+
+func TestTopMovers_RaisedUserClimbs(t *testing.T) {
+	service := createTestServiceWithConfig(Config{SnapshotHistory: 10})
+	service.rebuildSnapshot()
+
+	// deepak goes from 9th to 1st, pushing everyone above him down one
+	service.applyUpdate(RatingUpdate{UserID: 9, NewRating: 4800})
+	service.rebuildSnapshot()
+
+	movers := service.TopMovers(5, 3)
+	if movers.Window != 1 {
+		t.Errorf("Expected the window clamped to 1 retained snapshot, got %d", movers.Window)
+	}
+	if movers.Version != service.GetSnapshot().Version || movers.SinceVersion != movers.Version-1 {
+		t.Errorf("Expected versions %d..%d, got %d..%d",
+			service.GetSnapshot().Version-1, service.GetSnapshot().Version, movers.SinceVersion, movers.Version)
+	}
+
+	if len(movers.Climbers) != 1 {
+		t.Fatalf("Expected only deepak to climb, got %+v", movers.Climbers)
+	}
+	top := movers.Climbers[0]
+	if top.ID != 9 || top.Username != "deepak" || top.PreviousRank != 9 || top.Rank != 1 || top.Change != 8 {
+		t.Errorf("Expected deepak 9 -> 1 (+8), got %+v", top)
+	}
+
+	if len(movers.Decliners) != 3 {
+		t.Fatalf("Expected the limit of 3 decliners, got %+v", movers.Decliners)
+	}
+	for _, mover := range movers.Decliners {
+		if mover.Change != -1 {
+			t.Errorf("Expected decliners to drop one place, got %+v", mover)
+		}
+	}
+	if movers.Decliners[0].ID != 3 {
+		t.Errorf("Expected ties broken by current rank (rahul first), got %+v", movers.Decliners[0])
+	}
+}
+
+func TestTopMovers_SkipsUsersWithoutBothRanks(t *testing.T) {
+	service := createTestServiceWithConfig(Config{SnapshotHistory: 10})
+	service.rebuildSnapshot()
+
+	// Nothing retained to compare with yet
+	if movers := service.TopMovers(1, 10); movers.Window != 0 || len(movers.Climbers)+len(movers.Decliners) != 0 {
+		t.Errorf("Expected no movers from a single snapshot, got %+v", movers)
+	}
+
+	// rahul leaves, everyone else below him moves up
+	builder := service.newSnapshotBuilder()
+	for userID, rating := range service.GetSnapshot().UserRatings {
+		if userID != 3 {
+			builder.AddUser(userID, service.users[userID].Username, rating)
+		}
+	}
+	service.publish(builder.Build())
+
+	movers := service.TopMovers(1, 10)
+	for _, mover := range append(movers.Climbers, movers.Decliners...) {
+		if mover.ID == 3 {
+			t.Errorf("Expected a departed user not to be listed, got %+v", mover)
+		}
+	}
+	if len(movers.Climbers) != 9 || len(movers.Decliners) != 0 {
+		t.Errorf("Expected the 9 remaining users to climb one place, got %+v", movers)
+	}
+}
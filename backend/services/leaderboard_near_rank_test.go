@@ -0,0 +1,133 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"matiks-backend/models"
+)
+
+func TestGetUsersNearRank_ClustersAroundTarget(t *testing.T) {
+	service := createTestService()
+	service.rebuildSnapshot()
+
+	entries, truncated := service.GetUsersNearRank(5, 1)
+	if truncated || len(entries) != 3 {
+		t.Fatalf("Expected ranks 4 to 6, got %+v (truncated %v)", entries, truncated)
+	}
+	for i, want := range []string{"neha", "amit_kumar", "rahul_sharma"} {
+		if entries[i].Rank != 4+i || entries[i].Username != want {
+			t.Errorf("Expected %s at rank %d, got %+v", want, 4+i, entries[i])
+		}
+	}
+}
+
+func TestGetUsersNearRank_Edges(t *testing.T) {
+	service := createTestService()
+	service.rebuildSnapshot()
+
+	// The window is clipped at rank 1 rather than shifted
+	entries, _ := service.GetUsersNearRank(1, 2)
+	if len(entries) != 3 || entries[0].Username != "rahul" || entries[2].Rank != 3 {
+		t.Errorf("Expected ranks 1 to 3, got %+v", entries)
+	}
+
+	entries, _ = service.GetUsersNearRank(10, 5)
+	if len(entries) != 6 || entries[len(entries)-1].Username != "priyanka" {
+		t.Errorf("Expected ranks 5 to 10, got %+v", entries)
+	}
+
+	if entries, _ = service.GetUsersNearRank(50, 5); len(entries) != 0 {
+		t.Errorf("Expected no one past the last rank, got %+v", entries)
+	}
+}
+
+func TestGetUsersNearRank_TieGroupCapped(t *testing.T) {
+	service := createTestService()
+
+	// Rank 2 becomes a tie group bigger than the cap
+	seeds := make([]models.UserSeed, MaxNearRankUsers)
+	for i := range seeds {
+		seeds[i] = models.UserSeed{ID: 1000 + i, Username: fmt.Sprintf("tied_%d", i), Rating: 4600}
+	}
+	if err := service.AddUsers(seeds); err != nil {
+		t.Fatalf("AddUsers failed: %v", err)
+	}
+	service.rebuildSnapshot()
+
+	entries, truncated := service.GetUsersNearRank(2, 1)
+	if !truncated || len(entries) != MaxNearRankUsers {
+		t.Fatalf("Expected %d users and truncation, got %d (truncated %v)", MaxNearRankUsers, len(entries), truncated)
+	}
+	for _, entry := range entries {
+		if entry.Rank != 2 {
+			t.Fatalf("Expected only the target's tie group to be kept, got %+v", entry)
+		}
+	}
+	if entries[0].Username != "priya" {
+		t.Errorf("Expected the tie group in listing order, got %+v first", entries[0])
+	}
+}
+
+func TestGetRankRanges_DisjointRanges(t *testing.T) {
+	service := createTestService()
+	service.rebuildSnapshot()
+
+	results, truncated := service.GetRankRanges([]RankRange{{1, 2}, {5, 6}, {9, 20}, {30, 40}})
+	if truncated || len(results) != 4 {
+		t.Fatalf("Expected 4 untruncated ranges, got %+v (truncated %v)", results, truncated)
+	}
+
+	want := [][]string{
+		{"rahul", "priya"},
+		{"amit_kumar", "rahul_sharma"},
+		{"deepak", "priyanka"},
+		{}, // past the last rank
+	}
+	for i, result := range results {
+		if result.Count != len(want[i]) || len(result.Entries) != len(want[i]) {
+			t.Fatalf("Range %+v: expected %v, got %+v", result.RankRange, want[i], result.Entries)
+		}
+		for j, entry := range result.Entries {
+			if entry.Username != want[i][j] || entry.Rank < result.From || entry.Rank > result.To {
+				t.Errorf("Range %+v: expected %s within the range, got %+v", result.RankRange, want[i][j], entry)
+			}
+		}
+	}
+}
+
+func TestGetRankRanges_TotalCapped(t *testing.T) {
+	service := createTestService()
+
+	// Rank 2 becomes a tie group bigger than the cap
+	seeds := make([]models.UserSeed, MaxRankRangeEntries)
+	for i := range seeds {
+		seeds[i] = models.UserSeed{ID: 1000 + i, Username: fmt.Sprintf("tied_%d", i), Rating: 4600}
+	}
+	if err := service.AddUsers(seeds); err != nil {
+		t.Fatalf("AddUsers failed: %v", err)
+	}
+	service.rebuildSnapshot()
+
+	results, truncated := service.GetRankRanges([]RankRange{{1, 2}, {3, 3}, {50, 60}})
+	if !truncated {
+		t.Fatal("Expected the request to be truncated")
+	}
+
+	total := 0
+	for _, result := range results {
+		total += result.Count
+	}
+	if total != MaxRankRangeEntries {
+		t.Errorf("Expected %d entries in total, got %d", MaxRankRangeEntries, total)
+	}
+	if !results[0].Truncated || results[0].Count != MaxRankRangeEntries {
+		t.Errorf("Expected the first range to use up the cap, got %d (truncated %v)", results[0].Count, results[0].Truncated)
+	}
+	if !results[1].Truncated || results[1].Count != 0 {
+		t.Errorf("Expected rank 3 cut off entirely, got %+v", results[1])
+	}
+	if results[2].Truncated {
+		t.Error("Expected an empty range not to count as truncated")
+	}
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"fmt"
 	"matiks-backend/snapshot"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -98,6 +99,34 @@ func BenchmarkSnapshotRebuildTiming(b *testing.B) {
 	})
 }
 
+// BenchmarkRebuildSnapshot measures one writer cycle at the simulator's
+// load: about 10 updates applied, then a rebuild. gc-pause-ns/op is the
+// stop-the-world pause time the cycle's garbage adds.
+func BenchmarkRebuildSnapshot(b *testing.B) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	config.SnapshotHistory = 0
+	service := NewLeaderboardServiceWithConfig(config)
+	service.Stop() // drive the writer's work from this goroutine instead
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 10; j++ {
+			service.applyUpdate(RatingUpdate{UserID: 1 + (i*10+j)%InitialUsers, NewRating: MinRating + (i*7+j)%(MaxRating-MinRating)})
+		}
+		service.rebuildSnapshot()
+	}
+
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(b.N), "gc-pause-ns/op")
+}
+
 // BenchmarkLatencyDistribution measures P50, P95, P99 latencies
 func BenchmarkLatencyDistribution(b *testing.B) {
 	service := NewLeaderboardService()
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"matiks-backend/models"
+)
+
+func TestUsersBetween_CountsSumToTotal(t *testing.T) {
+	service := createTestService()
+
+	// Tie amit_kumar and neha with amit at 4500
+	service.applyUpdate(RatingUpdate{UserID: 2, NewRating: 4500})
+	service.applyUpdate(RatingUpdate{UserID: 7, NewRating: 4500})
+	service.rebuildSnapshot()
+
+	snap := service.GetSnapshot()
+	for userID, rating := range snap.UserRatings {
+		above, below, ok := service.UsersBetween(userID)
+		if !ok {
+			t.Fatalf("UsersBetween(%d) not found", userID)
+		}
+		if same := snap.RatingCount[rating]; above+below+same != snap.TotalUsers() {
+			t.Errorf("User %d: above %d + below %d + same %d != total %d",
+				userID, above, below, same, snap.TotalUsers())
+		}
+	}
+
+	// rahul 4700 and priya 4600 are above the three tied at 4500
+	if above, below, _ := service.UsersBetween(1); above != 2 || below != 5 {
+		t.Errorf("Expected 2 above and 5 below amit, got %d and %d", above, below)
+	}
+
+	if _, _, ok := service.UsersBetween(999); ok {
+		t.Error("Expected unknown user to report ok=false")
+	}
+}
+
+func TestGetUserProfile(t *testing.T) {
+	service := createTestService()
+
+	profile, ok := service.GetUserProfile(7)
+	if !ok {
+		t.Fatal("Expected profile for neha")
+	}
+
+	want := UserProfile{ID: 7, Username: "neha", Rating: 4400, Rank: 4, UsersAbove: 3, UsersBelow: 6}
+	if profile != want {
+		t.Errorf("Expected %+v, got %+v", want, profile)
+	}
+
+	if _, ok := service.GetUserProfile(999); ok {
+		t.Error("Expected no profile for an unknown user")
+	}
+}
+
+func TestGetPercentiles_MatchesGetPercentile(t *testing.T) {
+	service := createTestService()
+
+	// Tie amit_kumar with amit so a shared rating is covered
+	service.applyUpdate(RatingUpdate{UserID: 2, NewRating: 4500})
+	service.rebuildSnapshot()
+
+	userIDs := []int{1, 2, 3, 10, 999}
+	got := service.GetPercentiles(userIDs)
+	if len(got) != 4 {
+		t.Fatalf("Expected 4 percentiles (unknown ID omitted), got %v", got)
+	}
+	if _, ok := got[999]; ok {
+		t.Error("Expected unknown user 999 to be omitted")
+	}
+
+	for _, userID := range userIDs {
+		want, ok := service.GetPercentile(userID)
+		if got[userID] != want {
+			t.Errorf("User %d: bulk %v, individual %v (ok=%v)", userID, got[userID], want, ok)
+		}
+	}
+
+	// 10 users: rahul has 9 below, priyanka none, amit 6 (excluding the tie)
+	for userID, want := range map[int]float64{3: 90, 10: 0, 1: 60} {
+		if got[userID] != want {
+			t.Errorf("User %d: expected percentile %v, got %v", userID, want, got[userID])
+		}
+	}
+}
+
+func TestGetRankByUsername_CollidingNames(t *testing.T) {
+	service := createTestServiceWithConfig(Config{})
+
+	// Two more "rahul"s, both rated above the original (ID 3, 4700)
+	for _, user := range []*models.User{{ID: 12, Username: "RAHUL"}, {ID: 11, Username: "Rahul"}} {
+		service.users[user.ID] = user
+		service.writerRatings[user.ID] = 4800
+		service.indexUsername(user.ID, user.Username)
+	}
+	service.rebuildSnapshot()
+
+	user, rank, err := service.GetRankByUsername("rahul")
+	if err != nil {
+		t.Fatalf("GetRankByUsername failed: %v", err)
+	}
+	if user.ID != 11 || user.Username != "Rahul" || user.Rating != 4800 || rank != 1 {
+		t.Errorf("Expected the lower ID of the two tied leaders (11, rank 1), got %+v rank %d", user, rank)
+	}
+
+	// Hidden users are skipped; the next best match is the original
+	service.Exclude(11)
+	service.Exclude(12)
+	user, rank, err = service.GetRankByUsername("rahul")
+	if err != nil || user.ID != 3 || rank != 2 {
+		t.Errorf("Expected rahul (3) at rank 2, got %+v rank %d err %v", user, rank, err)
+	}
+
+	// Exact names only: "rahul_kumar" is not a match for "rahul_k"
+	if _, _, err := service.GetRankByUsername("rahul_k"); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("Expected ErrUnknownUser for a partial name, got %v", err)
+	}
+}
+
+func TestUsernameAvailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		unique   bool
+		username string
+		want     bool
+	}{
+		{"shared names allowed", false, "rahul", true},
+		{"unique taken", true, "rahul", false},
+		{"unique differs in case", true, "Rahul", true},
+		{"unique free", true, "rahul_new", true},
+		{"empty", false, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := createTestServiceWithConfig(Config{UniqueUsernames: tt.unique})
+			if got := service.UsernameAvailable(tt.username); got != tt.want {
+				t.Errorf("UsernameAvailable(%q) = %v, want %v", tt.username, got, tt.want)
+			}
+		})
+	}
+
+	service := createTestService()
+	if !service.UsernameTaken("rahul") || service.UsernameTaken("Rahul") || service.UsernameTaken("rahu") {
+		t.Error("Expected only the exact name rahul to be taken")
+	}
+}
+
+func TestGetUsersByNames(t *testing.T) {
+	service := createTestService()
+	if err := service.AddUsers([]models.UserSeed{
+		{ID: 11, Username: "rahul", Rating: 4800},
+		{ID: 12, Username: "priya", Rating: 2000},
+	}); err != nil {
+		t.Fatalf("AddUsers failed: %v", err)
+	}
+	service.rebuildSnapshot()
+	if err := service.Exclude(12); err != nil {
+		t.Fatalf("Exclude failed: %v", err)
+	}
+
+	matches, unmatched := service.GetUsersByNames([]string{"rahul", "nobody", "Neha", "neha", "rahul", "rahu"})
+
+	// Both rahuls, the better-rated new one first
+	want := []RankedUser{
+		{ID: 11, Username: "rahul", Rating: 4800, Rank: 1},
+		{ID: 3, Username: "rahul", Rating: 4700, Rank: 2},
+	}
+	if !slices.Equal(matches["rahul"], want) {
+		t.Errorf("Expected %+v, got %+v", want, matches["rahul"])
+	}
+	if len(matches["neha"]) != 1 || matches["neha"][0].ID != 7 {
+		t.Errorf("Expected neha found, got %+v", matches["neha"])
+	}
+
+	// Matching is exact: no case folding and no prefixes
+	if !slices.Equal(unmatched, []string{"nobody", "Neha", "rahu"}) {
+		t.Errorf("Expected nobody, Neha and rahu unmatched, got %v", unmatched)
+	}
+	if len(matches) != 2 {
+		t.Errorf("Expected 2 matched names, got %d", len(matches))
+	}
+
+	// The excluded priya is skipped
+	if matches, _ := service.GetUsersByNames([]string{"priya"}); len(matches["priya"]) != 1 || matches["priya"][0].ID != 5 {
+		t.Errorf("Expected only the listed priya, got %+v", matches["priya"])
+	}
+}
+
+func TestGetProfileContext_Consistent(t *testing.T) {
+	service := createTestService()
+
+	context, ok := service.GetProfileContext(7, 2)
+	if !ok {
+		t.Fatal("Expected a profile context for neha")
+	}
+
+	wantProfile := UserProfile{ID: 7, Username: "neha", Rating: 4400, Rank: 4, UsersAbove: 3, UsersBelow: 6}
+	if context.UserProfile != wantProfile {
+		t.Errorf("Expected %+v, got %+v", wantProfile, context.UserProfile)
+	}
+	if context.Percentile != 60 || context.Tier != "Diamond" || context.Version != service.GetSnapshot().Version {
+		t.Errorf("Expected the 60th percentile, Diamond and the current version, got %+v", context)
+	}
+
+	if len(context.Above) != 2 || context.Above[0].Username != "priya" || context.Above[1].Username != "amit" {
+		t.Errorf("Expected priya then amit above, got %+v", context.Above)
+	}
+	if len(context.Below) != 2 || context.Below[0].Username != "amit_kumar" || context.Below[1].Username != "rahul_sharma" {
+		t.Errorf("Expected amit_kumar then rahul_sharma below, got %+v", context.Below)
+	}
+
+	// The neighbours' ranks bracket the user's
+	for _, entry := range context.Above {
+		if entry.Rank > context.Rank {
+			t.Errorf("Neighbour above ranked %d, below the user's %d", entry.Rank, context.Rank)
+		}
+	}
+	for _, entry := range context.Below {
+		if entry.Rank < context.Rank {
+			t.Errorf("Neighbour below ranked %d, above the user's %d", entry.Rank, context.Rank)
+		}
+	}
+
+	if _, ok := service.GetProfileContext(999, 2); ok {
+		t.Error("Expected no context for an unknown user")
+	}
+}
+
+func TestGetProfileContext_TiesEdgesAndExclusions(t *testing.T) {
+	service := createTestService()
+
+	// deepak ties neha at 4400 and is listed after her (higher ID)
+	service.applyUpdate(RatingUpdate{UserID: 9, NewRating: 4400})
+	service.rebuildSnapshot()
+	if err := service.Exclude(1); err != nil {
+		t.Fatalf("Exclude failed: %v", err)
+	}
+
+	context, _ := service.GetProfileContext(7, 2)
+	if len(context.Above) != 2 || context.Above[0].Username != "rahul" || context.Above[1].Username != "priya" {
+		t.Errorf("Expected excluded amit skipped above, got %+v", context.Above)
+	}
+	if len(context.Below) != 2 || context.Below[0].Username != "deepak" || context.Below[0].Rank != context.Rank {
+		t.Errorf("Expected the tied deepak first below at the same rank, got %+v", context.Below)
+	}
+
+	// The leader has no one above; the last user no one below
+	if top, _ := service.GetProfileContext(3, 2); len(top.Above) != 0 || len(top.Below) != 2 {
+		t.Errorf("Expected nothing above rahul, got %+v", top)
+	}
+	if last, _ := service.GetProfileContext(10, 3); len(last.Below) != 0 || len(last.Above) != 3 {
+		t.Errorf("Expected nothing below priyanka, got %+v", last)
+	}
+}
+
+func TestGetProfileContext_ExcludeSelf(t *testing.T) {
+	service := createTestService()
+
+	// neha is alone at 4400, so without her everyone below moves up a rank
+	opts := ProfileContextOptions{Radius: 2, ExcludeSelf: true}
+	context, _ := service.GetProfileContextWithOptions(7, opts)
+	if context.Rank != 4 {
+		t.Errorf("Expected neha's own rank unchanged at 4, got %d", context.Rank)
+	}
+	if context.Above[0].Rank != 2 || context.Above[1].Rank != 3 {
+		t.Errorf("Expected the users above unchanged at 2 and 3, got %+v", context.Above)
+	}
+	if context.Below[0].Rank != 4 || context.Below[1].Rank != 5 {
+		t.Errorf("Expected amit_kumar and rahul_sharma to move up to 4 and 5, got %+v", context.Below)
+	}
+
+	// With deepak tied at 4400 the rating level stays, and so do the ranks
+	service.applyUpdate(RatingUpdate{UserID: 9, NewRating: 4400})
+	service.rebuildSnapshot()
+
+	context, _ = service.GetProfileContextWithOptions(7, opts)
+	included, _ := service.GetProfileContext(7, 2)
+	for i := range context.Below {
+		if context.Below[i] != included.Below[i] {
+			t.Errorf("Expected tied exclusion to keep ranks, got %+v, want %+v", context.Below[i], included.Below[i])
+		}
+	}
+	if context.Below[0].Username != "deepak" || context.Below[0].Rank != 4 {
+		t.Errorf("Expected deepak still ranked 4, got %+v", context.Below[0])
+	}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSubmitUpdate_ThrottlesRapidUpdatesPerUser(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	config.UserUpdateRate = 1
+	config.UserUpdateBurst = 3
+	service := NewLeaderboardServiceWithConfig(config)
+	t.Cleanup(service.Stop)
+
+	accepted, throttled := 0, 0
+	for i := 0; i < 20; i++ {
+		err := service.SubmitUpdate(1, 1000+i)
+		switch {
+		case err == nil:
+			accepted++
+		case errors.Is(err, ErrRateLimited):
+			throttled++
+		default:
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	// One token may refill if the loop straddles a second boundary
+	if accepted < 3 || accepted > 4 {
+		t.Errorf("Expected the burst of 3 updates to be accepted, got %d", accepted)
+	}
+	if throttled == 0 {
+		t.Fatal("Expected rapid updates to be throttled")
+	}
+
+	// Other users have their own bucket
+	if err := service.SubmitUpdate(2, 2000); err != nil {
+		t.Errorf("Expected update for another user to be accepted, got %v", err)
+	}
+
+	if got := service.GetStats()["rate_limited_updates"]; got != uint64(throttled) {
+		t.Errorf("Expected rate_limited_updates = %d, got %v", throttled, got)
+	}
+}
+
+func TestUserRateLimiter_Refills(t *testing.T) {
+	limiter := newUserRateLimiter(2, 1)
+	start := time.Now()
+
+	if !limiter.allow(7, start) {
+		t.Fatal("Expected first update to be allowed")
+	}
+	if limiter.allow(7, start.Add(100*time.Millisecond)) {
+		t.Error("Expected second update within 500ms to be throttled")
+	}
+	if !limiter.allow(7, start.Add(600*time.Millisecond)) {
+		t.Error("Expected a token to refill after 500ms")
+	}
+
+	// Idle time never banks more than the burst
+	later := start.Add(time.Hour)
+	if !limiter.allow(7, later) {
+		t.Error("Expected update after idle period to be allowed")
+	}
+	if limiter.allow(7, later) {
+		t.Error("Expected burst of 1 to cap banked tokens")
+	}
+}
+
+func TestSubmitUpdate_UnknownUsersGetNoBucket(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	service := NewLeaderboardServiceWithConfig(config)
+	t.Cleanup(service.Stop)
+
+	for id := InitialUsers + 1; id <= InitialUsers+1000; id++ {
+		if err := service.SubmitUpdate(id, 3000); !errors.Is(err, ErrUnknownUser) {
+			t.Fatalf("Expected ErrUnknownUser for user %d, got %v", id, err)
+		}
+	}
+	if n := service.updateLimiter.size(); n != 0 {
+		t.Errorf("Expected no buckets for unknown users, got %d", n)
+	}
+}
+
+func TestUserRateLimiter_SweepsIdleBuckets(t *testing.T) {
+	limiter := newUserRateLimiter(10, 10) // refills in one second
+	start := time.Now()
+
+	// A steady stream of new users: only those seen within about two
+	// refill periods are kept
+	for i := 0; i < 10000; i++ {
+		limiter.allow(i, start.Add(time.Duration(i)*10*time.Millisecond))
+	}
+	if n := limiter.size(); n > 200 {
+		t.Errorf("Expected at most 200 buckets, got %d", n)
+	}
+
+	// A throttled bucket is kept until it has refilled
+	limiter = newUserRateLimiter(1, 1)
+	limiter.allow(1, start)
+	limiter.allow(2, start.Add(500*time.Millisecond))
+	if limiter.allow(1, start.Add(900*time.Millisecond)) {
+		t.Error("Expected user 1 to still be throttled")
+	}
+	limiter.allow(3, start.Add(2*time.Second))
+	if n := limiter.size(); n != 1 {
+		t.Errorf("Expected only the newest bucket after a refill period, got %d", n)
+	}
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"matiks-backend/models"
+	"matiks-backend/snapshot"
+)
+
+func TestCountInRange(t *testing.T) {
+	service := &LeaderboardService{users: make(map[int]*models.User)}
+
+	builder := snapshot.NewSnapshotBuilder()
+	for id, rating := range []int{100, 2999, 3000, 3000, 3500, 4000, 4001, 5000} {
+		builder.AddUser(id+1, "u", rating)
+	}
+	service.currentSnapshot.Store(builder.Build())
+
+	tests := []struct {
+		min, max int
+		want     int
+	}{
+		{3000, 4000, 4},
+		{100, 5000, 8},
+		{3001, 3999, 1},
+		{3000, 3000, 2},
+		{4002, 4999, 0},
+		{5000, 5000, 1},
+		{100, 100, 1},
+	}
+
+	for _, tt := range tests {
+		if got := service.CountInRange(tt.min, tt.max); got != tt.want {
+			t.Errorf("CountInRange(%d, %d) = %d, expected %d", tt.min, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestGetRatingLadder(t *testing.T) {
+	service := &LeaderboardService{users: make(map[int]*models.User)}
+
+	builder := snapshot.NewSnapshotBuilder()
+	for id, rating := range []int{100, 2999, 3000, 3000, 3500, 4000, 4000, 4000, 5000} {
+		builder.AddUser(id+1, "u", rating)
+	}
+	service.currentSnapshot.Store(builder.Build())
+
+	want := []RatingLevel{
+		{Rating: 5000, Count: 1, Rank: 1},
+		{Rating: 4000, Count: 3, Rank: 2},
+		{Rating: 3500, Count: 1, Rank: 3},
+		{Rating: 3000, Count: 2, Rank: 4},
+		{Rating: 2999, Count: 1, Rank: 5},
+		{Rating: 100, Count: 1, Rank: 6},
+	}
+	ladder := service.GetRatingLadder()
+	if !slices.Equal(ladder, want) {
+		t.Fatalf("Expected ladder %v, got %v", want, ladder)
+	}
+
+	snap := service.GetSnapshot()
+	for _, level := range ladder {
+		if rank := snap.GetRank(level.Rating); rank != level.Rank {
+			t.Errorf("Level %d: ladder rank %d, GetRank %d", level.Rating, level.Rank, rank)
+		}
+	}
+}
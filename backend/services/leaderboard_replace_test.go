@@ -0,0 +1,220 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"matiks-backend/models"
+	"matiks-backend/utils"
+)
+
+func TestReplaceAll(t *testing.T) {
+	service := createTestService()
+
+	err := service.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "zara", Rating: 3000},
+		{ID: 2, Username: "zoe", Rating: 3500},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	if total := service.GetSnapshot().TotalUsers(); total != 2 {
+		t.Errorf("Expected 2 users after ReplaceAll, got %d", total)
+	}
+
+	if results := service.Search("amit"); len(results) != 0 {
+		t.Errorf("Old users should no longer be searchable, got %v", results)
+	}
+
+	results := service.Search("zo")
+	if len(results) != 1 || results[0].Username != "zoe" || results[0].Rank != 1 {
+		t.Errorf("Expected zoe at rank 1, got %v", results)
+	}
+}
+
+func TestReplaceAll_RejectsInvalidSeeds(t *testing.T) {
+	service := createTestService()
+
+	tests := []struct {
+		name  string
+		seeds []models.UserSeed
+	}{
+		{"duplicate id", []models.UserSeed{{ID: 1, Username: "a", Rating: 1000}, {ID: 1, Username: "b", Rating: 1000}}},
+		{"empty username", []models.UserSeed{{ID: 1, Username: "", Rating: 1000}}},
+		{"rating too high", []models.UserSeed{{ID: 1, Username: "a", Rating: MaxRating + 1}}},
+		{"invalid id", []models.UserSeed{{ID: 0, Username: "a", Rating: 1000}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := service.ReplaceAll(tt.seeds); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+
+	// The original population must be untouched
+	if total := service.GetSnapshot().TotalUsers(); total != 10 {
+		t.Errorf("Expected original 10 users to remain, got %d", total)
+	}
+}
+
+func TestReplaceAll_ConcurrentReadersSeeWholeSets(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	service := NewLeaderboardServiceWithConfig(config)
+	defer service.Stop()
+
+	// Both sets reuse IDs 1..n so a mismatched index/snapshot pair would
+	// surface as the wrong rating on a search result.
+	makeSet := func(prefix string, n, rating int) []models.UserSeed {
+		seeds := make([]models.UserSeed, n)
+		for i := range seeds {
+			seeds[i] = models.UserSeed{ID: i + 1, Username: fmt.Sprintf("%s_%d", prefix, i), Rating: rating}
+		}
+		return seeds
+	}
+	setA := makeSet("alpha", 300, 1000)
+	setB := makeSet("bravo", 500, 2000)
+
+	if err := service.ReplaceAll(setA); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var inconsistencies int32
+	stop := make(chan struct{})
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				total := service.GetSnapshot().TotalUsers()
+				if total != len(setA) && total != len(setB) {
+					atomic.AddInt32(&inconsistencies, 1)
+				}
+
+				// Each search must come entirely from one set
+				alpha := service.Search("alpha")
+				if len(alpha) != 0 && len(alpha) != len(setA) {
+					atomic.AddInt32(&inconsistencies, 1)
+				}
+				for _, entry := range alpha {
+					if entry.Rating != 1000 {
+						atomic.AddInt32(&inconsistencies, 1)
+					}
+				}
+
+				bravo := service.Search("bravo")
+				if len(bravo) != 0 && len(bravo) != len(setB) {
+					atomic.AddInt32(&inconsistencies, 1)
+				}
+				for _, entry := range bravo {
+					if entry.Rating != 2000 {
+						atomic.AddInt32(&inconsistencies, 1)
+					}
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 20; i++ {
+		next := setB
+		if i%2 == 1 {
+			next = setA
+		}
+		if err := service.ReplaceAll(next); err != nil {
+			t.Fatalf("ReplaceAll failed: %v", err)
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+
+	if inconsistencies > 0 {
+		t.Errorf("Readers observed %d inconsistent states", inconsistencies)
+	}
+}
+
+func TestValidateSeeds_ReportsEveryProblem(t *testing.T) {
+	errs := ValidateSeeds([]models.UserSeed{
+		{ID: 1, Username: "ok", Rating: 1000},
+		{ID: 0, Username: "", Rating: 1000},
+		{ID: 2, Username: "fine", Rating: MaxRating + 1},
+		{ID: 1, Username: "dup", Rating: 1000},
+	})
+
+	want := []ValidationError{
+		{Index: 1, Field: "id"},
+		{Index: 1, Field: "username"},
+		{Index: 2, Field: "rating"},
+		{Index: 3, Field: "id"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for i, w := range want {
+		if errs[i].Index != w.Index || errs[i].Field != w.Field || errs[i].Message == "" {
+			t.Errorf("Error %d: expected row %d field %q, got %+v", i, w.Index, w.Field, errs[i])
+		}
+	}
+}
+
+func TestResolveDuplicateIDs(t *testing.T) {
+	seeds := []models.UserSeed{
+		{ID: 1, Username: "first", Rating: 3000},
+		{ID: 2, Username: "solo", Rating: 2000},
+		{ID: 1, Username: "second", Rating: 4000},
+		{ID: 1, Username: "third", Rating: 3500},
+	}
+
+	tests := []struct {
+		policy      DuplicatePolicy
+		wantKeep    []int
+		wantDropped int
+	}{
+		{DuplicateReject, []int{0, 1, 2, 3}, 0},
+		{DuplicateKeepLast, []int{1, 3}, 2},
+		{DuplicateKeepHighest, []int{1, 2}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			keep, dropped := ResolveDuplicateIDs(seeds, tt.policy)
+			if fmt.Sprint(keep) != fmt.Sprint(tt.wantKeep) || dropped != tt.wantDropped {
+				t.Errorf("Expected keep %v dropped %d, got %v dropped %d", tt.wantKeep, tt.wantDropped, keep, dropped)
+			}
+		})
+	}
+
+	if _, err := ParseDuplicatePolicy("keep-first"); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+}
+
+func TestGenerateUsers_ImportsCleanly(t *testing.T) {
+	if utils.MinRating != MinRating || utils.MaxRating != MaxRating {
+		t.Fatalf("utils rating bounds [%d, %d] differ from the service's [%d, %d]",
+			utils.MinRating, utils.MaxRating, MinRating, MaxRating)
+	}
+
+	service := createTestService()
+	seeds := utils.GenerateUsers(1000, 7)
+	if err := service.ReplaceAll(seeds); err != nil {
+		t.Fatalf("ReplaceAll rejected generated users: %v", err)
+	}
+
+	if got := service.GetSnapshot().GetUserRating(500); got != seeds[499].Rating {
+		t.Errorf("Expected user 500 rated %d, got %d", seeds[499].Rating, got)
+	}
+}
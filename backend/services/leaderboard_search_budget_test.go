@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"matiks-backend/models"
+)
+
+// createBudgetTestService returns a service with n users all matching "player"
+func createBudgetTestService(t *testing.T, n int) *LeaderboardService {
+	t.Helper()
+
+	service := createTestService()
+
+	seeds := make([]models.UserSeed, n)
+	for i := range seeds {
+		seeds[i] = models.UserSeed{ID: i + 1, Username: fmt.Sprintf("player_%d", i), Rating: MinRating + i%1000}
+	}
+	if err := service.ReplaceAll(seeds); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	return service
+}
+
+func TestSearchContext_TruncatesOnDeadline(t *testing.T) {
+	service := createBudgetTestService(t, 1000)
+
+	// Slow verification down to ~100ms for the full candidate set
+	service.verifyHook = func() { time.Sleep(100 * time.Microsecond) }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	results, truncated := service.SearchContext(ctx, "player", SearchOrderRank)
+
+	if !truncated {
+		t.Fatal("Expected truncated=true when the budget runs out")
+	}
+
+	if len(results) == 0 || len(results) >= 1000 {
+		t.Errorf("Expected partial results, got %d of 1000", len(results))
+	}
+
+	for i, result := range results {
+		if !strings.Contains(result.Username, "player") {
+			t.Errorf("Partial result %q is not a verified match", result.Username)
+		}
+		if i > 0 && results[i-1].Rank > result.Rank {
+			t.Errorf("Partial results not ordered by rank at position %d", i)
+		}
+	}
+}
+
+func TestSearchContext_CompletesWithinBudget(t *testing.T) {
+	service := createBudgetTestService(t, 1000)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	results, truncated := service.SearchContext(ctx, "player", SearchOrderRank)
+
+	if truncated {
+		t.Error("Expected complete results within a generous budget")
+	}
+	if len(results) != 1000 {
+		t.Errorf("Expected 1000 results, got %d", len(results))
+	}
+}
+
+func TestSearchContext_LinearScanHonorsDeadline(t *testing.T) {
+	service := createBudgetTestService(t, 1000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// 1-char queries take the linear scan path
+	results, truncated := service.SearchContext(ctx, "p", SearchOrderRank)
+
+	if !truncated || len(results) != 0 {
+		t.Errorf("Expected an immediately truncated empty result, got %d results (truncated=%v)", len(results), truncated)
+	}
+}
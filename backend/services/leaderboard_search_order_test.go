@@ -0,0 +1,154 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"matiks-backend/models"
+)
+
+// =============================================================================
+// SEARCH ORDERING TESTS
+// =============================================================================
+
+func TestSearch_DefaultOrderRank(t *testing.T) {
+	service := createTestService()
+
+	results := service.Search("amit")
+
+	expected := []string{"amit", "amit_kumar", "amit_sharma"}
+	assertUsernames(t, results, expected)
+}
+
+func TestSearch_DefaultOrderRelevance(t *testing.T) {
+	service := createTestService()
+	service.config.DefaultSearchOrder = SearchOrderRelevance
+
+	results := service.Search("rahul")
+
+	// Exact match first, then prefix matches by length, then rank
+	expected := []string{"rahul", "rahul_kumar", "rahul_sharma"}
+	assertUsernames(t, results, expected)
+
+	results = service.Search("kumar")
+
+	// The match starts earlier in amit_kumar than in rahul_kumar
+	expected = []string{"amit_kumar", "rahul_kumar"}
+	assertUsernames(t, results, expected)
+}
+
+func TestSearch_DefaultOrderAlpha(t *testing.T) {
+	service := createTestService()
+	service.config.DefaultSearchOrder = SearchOrderAlpha
+
+	results := service.Search("priya")
+
+	expected := []string{"priya", "priyanka"}
+	assertUsernames(t, results, expected)
+
+	results = service.Search("sharma")
+
+	expected = []string{"amit_sharma", "rahul_sharma"}
+	assertUsernames(t, results, expected)
+}
+
+func TestSearchWithOrder_OverridesDefault(t *testing.T) {
+	service := createTestService()
+	service.config.DefaultSearchOrder = SearchOrderAlpha
+
+	results := service.SearchWithOrder("sharma", SearchOrderRank)
+
+	// rahul_sharma (4200) outranks amit_sharma (4000)
+	expected := []string{"rahul_sharma", "amit_sharma"}
+	assertUsernames(t, results, expected)
+}
+
+func TestParseSearchOrder(t *testing.T) {
+	for _, value := range []string{"rank", "relevance", "alpha", "ALPHA"} {
+		if _, err := ParseSearchOrder(value); err != nil {
+			t.Errorf("ParseSearchOrder(%q) returned error: %v", value, err)
+		}
+	}
+
+	if _, err := ParseSearchOrder("random"); err == nil {
+		t.Error("Expected error for unknown search order")
+	}
+}
+
+func TestSearchWithOrder_FieldDirection(t *testing.T) {
+	service := createTestService()
+
+	// "ar" matches amit_kumar 4300, rahul_sharma 4200, rahul_kumar 4100
+	// and amit_sharma 4000
+	byRating := []string{"amit_kumar", "rahul_sharma", "rahul_kumar", "amit_sharma"}
+	byUsername := []string{"amit_kumar", "amit_sharma", "rahul_kumar", "rahul_sharma"}
+
+	tests := []struct {
+		sort     string
+		expected []string
+	}{
+		{"rating:desc", byRating},
+		{"rating:asc", reversed(byRating)},
+		{"rank:asc", byRating},
+		{"rank:desc", reversed(byRating)},
+		{"username:asc", byUsername},
+		{"USERNAME:DESC", reversed(byUsername)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			order, err := ParseSearchOrder(tt.sort)
+			if err != nil {
+				t.Fatalf("ParseSearchOrder(%q) returned error: %v", tt.sort, err)
+			}
+			assertUsernames(t, service.SearchWithOrder("ar", order), tt.expected)
+		})
+	}
+}
+
+func TestSearchWithOrder_SecondarySortKey(t *testing.T) {
+	service := createTestService()
+
+	// Tie amit_sharma with amit_kumar at 4300
+	service.applyUpdate(RatingUpdate{UserID: 8, NewRating: 4300})
+	service.rebuildSnapshot()
+
+	order, err := ParseSearchOrder("rating:desc,username:desc")
+	if err != nil {
+		t.Fatalf("ParseSearchOrder returned error: %v", err)
+	}
+
+	results := service.SearchWithOrder("amit", order)
+
+	expected := []string{"amit", "amit_sharma", "amit_kumar"}
+	assertUsernames(t, results, expected)
+}
+
+func TestParseSearchOrder_InvalidFieldDirection(t *testing.T) {
+	for _, value := range []string{"score:desc", "rating:up", "rating", "rating:desc,rating:asc", "rating:desc,"} {
+		if _, err := ParseSearchOrder(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func reversed(usernames []string) []string {
+	out := slices.Clone(usernames)
+	slices.Reverse(out)
+	return out
+}
+
+// assertUsernames checks that results list exactly the expected usernames in order
+func assertUsernames(t *testing.T, results []models.LeaderboardEntry, expected []string) {
+	t.Helper()
+
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d: %v", len(expected), len(results), results)
+	}
+
+	for i, username := range expected {
+		if results[i].Username != username {
+			t.Errorf("Position %d: got %q, want %q", i, results[i].Username, username)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"matiks-backend/models"
+)
+
+func TestRebuildSearchIndex_DropsStaleIDs(t *testing.T) {
+	service := createTestServiceWithConfig(Config{IndexSingleChars: true})
+
+	// Churn behind the index's back: two users leave, one is renamed and
+	// one joins, leaving stale postings for the old names
+	delete(service.users, 2)
+	delete(service.users, 4)
+	service.users[3].Username = "vikram"
+	service.users[11] = &models.User{ID: 11, Username: "zoe"}
+	service.indexUsername(11, "zoe")
+
+	if err := service.RebuildSearchIndex(); err != nil {
+		t.Fatalf("RebuildSearchIndex failed: %v", err)
+	}
+
+	for gram, userIDs := range service.searchIndex {
+		for _, userID := range userIDs {
+			user, ok := service.users[userID]
+			if !ok {
+				t.Errorf("Gram %q lists removed user %d", gram, userID)
+			} else if !strings.Contains(strings.ToLower(user.Username), gram) {
+				t.Errorf("Gram %q lists user %d (%s) who no longer matches", gram, userID, user.Username)
+			}
+		}
+	}
+	for c, userIDs := range service.charIndex {
+		for _, userID := range userIDs {
+			if user, ok := service.users[userID]; !ok || !strings.ContainsRune(strings.ToLower(user.Username), rune(c)) {
+				t.Errorf("Char %q lists stale user %d", c, userID)
+			}
+		}
+	}
+	for name, userIDs := range service.usernameIndex {
+		for _, userID := range userIDs {
+			if user, ok := service.users[userID]; !ok || strings.ToLower(user.Username) != name {
+				t.Errorf("Username %q lists stale user %d", name, userID)
+			}
+		}
+	}
+
+	// Every current user is still findable
+	for userID, user := range service.users {
+		if !slices.Contains(service.usernameIndex[strings.ToLower(user.Username)], userID) {
+			t.Errorf("User %d (%s) missing from the username index", userID, user.Username)
+		}
+	}
+	if !slices.Contains(service.searchIndex["vik"], 3) || slices.Contains(service.searchIndex["rah"], 3) {
+		t.Errorf("Expected user 3 indexed under the new name only")
+	}
+}
+
+func TestRebuildSearchIndex_ConcurrentSearches(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	service := NewLeaderboardServiceWithConfig(config)
+	defer service.Stop()
+
+	want := len(service.Search("rahul"))
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if got := len(service.Search("rahul")); got != want {
+					t.Errorf("Search saw %d matches mid-rebuild, want %d", got, want)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := service.RebuildSearchIndex(); err != nil {
+			t.Fatalf("RebuildSearchIndex failed: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
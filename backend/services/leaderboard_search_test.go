@@ -1,9 +1,13 @@
 package services
 
 import (
+	"context"
+	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"matiks-backend/models"
 	"matiks-backend/snapshot"
@@ -49,6 +53,11 @@ func TestGenerateNGrams(t *testing.T) {
 			input:    "aaa",
 			expected: []string{"aa", "aaa"},
 		},
+		{
+			name:     "multibyte runes",
+			input:    "çağ",
+			expected: []string{"ça", "ağ", "çağ"},
+		},
 		{
 			name:     "long string (6+ chars)",
 			input:    "priyanka",
@@ -80,6 +89,33 @@ func TestGenerateNGrams(t *testing.T) {
 	}
 }
 
+func FuzzGenerateNGrams(f *testing.F) {
+	for _, seed := range []string{"rahul", "çağrı", "ıİß", "日本語ユーザー", "aéaéaé", "ab\xffcd", "\xe6\x97"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		valid := strings.ToValidUTF8(input, string(utf8.RuneError))
+
+		seen := make(map[string]bool)
+		for _, gram := range generateNGrams(input) {
+			if !utf8.ValidString(gram) {
+				t.Fatalf("Gram %q of %q is not valid UTF-8", gram, input)
+			}
+			if n := utf8.RuneCountInString(gram); n < minGramLength || n > maxGramLength {
+				t.Fatalf("Gram %q of %q has %d runes", gram, input, n)
+			}
+			if !strings.Contains(valid, gram) {
+				t.Fatalf("Gram %q is not a substring of %q", gram, input)
+			}
+			if seen[gram] {
+				t.Fatalf("Duplicate gram %q of %q", gram, input)
+			}
+			seen[gram] = true
+		}
+	})
+}
+
 func TestGenerateNGrams_NoduplicateGrams(t *testing.T) {
 	input := "aaaaaa"
 	grams := generateNGrams(input)
@@ -366,6 +402,25 @@ func TestSearch_LiveRanks(t *testing.T) {
 // POSTING LIST INTERSECTION TESTS
 // =============================================================================
 
+func TestLongestGrams(t *testing.T) {
+	got := longestGrams(generateNGrams("rahul_k"))
+	want := []string{"rahul", "ahul_", "hul_k"}
+
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, got)
+			break
+		}
+	}
+
+	if got := longestGrams(generateNGrams("amit")); len(got) != 1 || got[0] != "amit" {
+		t.Errorf("Expected a short query to be its own only gram, got %v", got)
+	}
+}
+
 func TestIntersectPostingLists_SingleGram(t *testing.T) {
 	service := &LeaderboardService{
 		searchIndex: map[string][]int{
@@ -373,7 +428,7 @@ func TestIntersectPostingLists_SingleGram(t *testing.T) {
 		},
 	}
 
-	candidates := service.intersectPostingLists([]string{"ab"})
+	candidates, _ := service.intersectPostingLists([]string{"ab"})
 
 	if len(candidates) != 3 {
 		t.Errorf("Expected 3 candidates, got %d", len(candidates))
@@ -396,7 +451,7 @@ func TestIntersectPostingLists_MultipleGrams(t *testing.T) {
 	}
 
 	// Intersection of all three: only 3 and 4 appear in all
-	candidates := service.intersectPostingLists([]string{"ab", "bc", "cd"})
+	candidates, _ := service.intersectPostingLists([]string{"ab", "bc", "cd"})
 
 	if len(candidates) != 2 {
 		t.Errorf("Expected 2 candidates, got %d", len(candidates))
@@ -416,7 +471,7 @@ func TestIntersectPostingLists_EmptyIntersection(t *testing.T) {
 	}
 
 	// No common users
-	candidates := service.intersectPostingLists([]string{"ab", "cd"})
+	candidates, _ := service.intersectPostingLists([]string{"ab", "cd"})
 
 	if len(candidates) != 0 {
 		t.Errorf("Expected 0 candidates, got %d", len(candidates))
@@ -431,7 +486,7 @@ func TestIntersectPostingLists_MissingGram(t *testing.T) {
 	}
 
 	// "xyz" doesn't exist in index
-	candidates := service.intersectPostingLists([]string{"ab", "xyz"})
+	candidates, _ := service.intersectPostingLists([]string{"ab", "xyz"})
 
 	// Should return empty (one gram has no users)
 	if len(candidates) != 0 {
@@ -492,7 +547,7 @@ func BenchmarkIntersectPostingLists(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = service.intersectPostingLists(grams)
+		_, _ = service.intersectPostingLists(grams)
 	}
 }
 
@@ -502,7 +557,13 @@ func BenchmarkIntersectPostingLists(b *testing.B) {
 
 // createTestService creates a minimal service for testing search functionality
 func createTestService() *LeaderboardService {
+	return createTestServiceWithConfig(Config{})
+}
+
+// createTestServiceWithConfig is createTestService indexed under config.
+func createTestServiceWithConfig(config Config) *LeaderboardService {
 	service := &LeaderboardService{
+		config:        config,
 		users:         make(map[int]*models.User),
 		searchIndex:   make(map[string][]int),
 		writerRatings: make(map[int]int),
@@ -555,3 +616,206 @@ func makeRange(start, end int) []int {
 	}
 	return result
 }
+
+// =============================================================================
+// SINGLE-CHARACTER INDEX TESTS
+// =============================================================================
+
+func TestSearch_SingleCharacterIndex(t *testing.T) {
+	linear := createTestService()
+
+	indexed := createTestService()
+	indexed.config.IndexSingleChars = true
+	for id, user := range indexed.users {
+		indexed.indexChars(id, strings.ToLower(user.Username))
+	}
+
+	if indexed.charIndex == nil {
+		t.Fatal("Expected char index to be built when IndexSingleChars is enabled")
+	}
+	if linear.charIndex != nil {
+		t.Fatal("Char index should not be built when IndexSingleChars is disabled")
+	}
+
+	for _, query := range []string{"a", "K", "_", "z"} {
+		want := linear.Search(query)
+		got := indexed.Search(query)
+
+		if len(got) != len(want) {
+			t.Errorf("Query %q: index returned %d results, linear scan %d", query, len(got), len(want))
+			continue
+		}
+
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Query %q position %d: index %+v, linear scan %+v", query, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestSuggest_Misspelling(t *testing.T) {
+	service := createTestService()
+
+	if results := service.Search("rahl"); len(results) != 0 {
+		t.Fatalf("Precondition: expected no search results, got %v", results)
+	}
+
+	suggestions := service.Suggest("rahl", 3)
+	if len(suggestions) == 0 {
+		t.Fatal("Expected suggestions for misspelled query")
+	}
+
+	if suggestions[0].Username != "rahul" || suggestions[0].Distance != 1 {
+		t.Errorf("Expected rahul at distance 1 first, got %+v", suggestions[0])
+	}
+	if suggestions[0].Rank != 1 {
+		t.Errorf("Expected live rank 1 for rahul, got %d", suggestions[0].Rank)
+	}
+
+	if len(suggestions) > 3 {
+		t.Errorf("Expected at most 3 suggestions, got %d", len(suggestions))
+	}
+	for i := 1; i < len(suggestions); i++ {
+		if suggestions[i].Distance < suggestions[i-1].Distance {
+			t.Errorf("Suggestions not ordered by distance: %+v", suggestions)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"rahul", "rahl", 1},
+		{"priya", "priyanka", 3},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSearchRankSummary(t *testing.T) {
+	service := createTestService()
+
+	// 1200 matching users on distinct ratings hold dense ranks 1..1200,
+	// plus non-matching users that must not be counted.
+	seeds := make([]models.UserSeed, 0, 1300)
+	for i := 0; i < 1200; i++ {
+		seeds = append(seeds, models.UserSeed{ID: i + 1, Username: fmt.Sprintf("match_%d", i), Rating: MaxRating - i})
+	}
+	for i := 0; i < 100; i++ {
+		seeds = append(seeds, models.UserSeed{ID: 2000 + i, Username: fmt.Sprintf("other_%d", i), Rating: MaxRating - i})
+	}
+	if err := service.ReplaceAll(seeds); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	got := service.SearchRankSummary("MATCH")
+	want := RankSummary{Query: "MATCH", Total: 1200, Top10: 10, Top100: 90, Top1000: 900, Below: 200}
+	if got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	if empty := service.SearchRankSummary("nobody"); empty.Total != 0 || empty.Top10 != 0 {
+		t.Errorf("Expected empty summary, got %+v", empty)
+	}
+}
+
+func TestCompactSearchIndex_MatchesFullIndex(t *testing.T) {
+	full := createTestService()
+	compact := createTestServiceWithConfig(Config{CompactSearchIndex: true})
+
+	// Every substring of length 2 to 6, plus misses
+	queries := map[string]bool{"zz": true, "xyz": true, "amitx": true, "_sharma_": true}
+	for _, user := range full.users {
+		name := user.Username
+		for n := 2; n <= 6; n++ {
+			for i := 0; i+n <= len(name); i++ {
+				queries[name[i:i+n]] = true
+			}
+		}
+	}
+
+	for query := range queries {
+		want := full.Search(query)
+		got := compact.Search(query)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("Query %q: compact index returned %v, full index %v", query, got, want)
+		}
+	}
+
+	fullStats, compactStats := full.searchIndexStats(), compact.searchIndexStats()
+	if compactStats["mode"] != "compact" || fullStats["mode"] != "full" {
+		t.Errorf("Unexpected modes: full %v, compact %v", fullStats["mode"], compactStats["mode"])
+	}
+	if compactStats["estimated_bytes"].(int) >= fullStats["estimated_bytes"].(int) {
+		t.Errorf("Expected compact index to be smaller: %v vs %v bytes",
+			compactStats["estimated_bytes"], fullStats["estimated_bytes"])
+	}
+}
+
+func TestSearch_GroupByUsername(t *testing.T) {
+	service := createTestService()
+	err := service.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "rahul", Rating: 3000},
+		{ID: 2, Username: "rahul", Rating: 4500},
+		{ID: 3, Username: "rahul_k", Rating: 4000},
+		{ID: 4, Username: "rahul", Rating: 3500},
+		{ID: 5, Username: "Rahul", Rating: 2000},
+		{ID: 6, Username: "rahul_k", Rating: 4500},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	groups := GroupByUsername(service.Search("rahul"))
+
+	// Ranks: 4500 -> 1, 4000 -> 2, 3500 -> 3, 3000 -> 4, 2000 -> 5
+	want := []UsernameGroup{
+		{Username: "rahul", Count: 3, BestRank: 1, BestRating: 4500},
+		{Username: "rahul_k", Count: 2, BestRank: 1, BestRating: 4500},
+		{Username: "Rahul", Count: 1, BestRank: 5, BestRating: 2000},
+	}
+	if !slices.Equal(groups, want) {
+		t.Errorf("Expected groups %+v, got %+v", want, groups)
+	}
+}
+
+func TestIntersectPostingLists_BailsOutOnBroadQuery(t *testing.T) {
+	service := createTestServiceWithConfig(Config{MaxSearchCandidates: 50})
+	for id := 100; id < 300; id++ {
+		username := fmt.Sprintf("common_%d", id)
+		service.users[id] = &models.User{ID: id, Username: username}
+		service.indexUsername(id, username)
+	}
+
+	// Every "common" posting list holds 200 users
+	candidates, tooBroad := service.intersectPostingLists(longestGrams(generateNGrams("common")))
+	if !tooBroad || len(candidates) != 200 {
+		t.Errorf("Expected bailout with 200 candidates, got tooBroad=%v with %d", tooBroad, len(candidates))
+	}
+
+	results, truncated := service.SearchContext(context.Background(), "common", SearchOrderRank)
+	if !truncated || len(results) > 50 {
+		t.Errorf("Expected at most 50 results marked truncated, got %d (truncated=%v)", len(results), truncated)
+	}
+	if got := service.GetStats()["broad_searches"]; got != uint64(1) {
+		t.Errorf("Expected 1 broad search in stats, got %v", got)
+	}
+
+	// "on_19" narrows to common_190..199 after the first two lists
+	if _, tooBroad := service.intersectPostingLists(longestGrams(generateNGrams("common_19"))); tooBroad {
+		t.Error("Expected a specific query not to bail out")
+	}
+	if results, truncated := service.SearchContext(context.Background(), "common_19", SearchOrderRank); truncated || len(results) != 10 {
+		t.Errorf("Expected all 10 matches untruncated, got %d (truncated=%v)", len(results), truncated)
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSeedFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "users.csv")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("Failed to write seed file: %v", err)
+	}
+	return path
+}
+
+func TestSeedFile_LoadsLeaderboard(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	config.SeedFile = writeSeedFile(t, "id,username,rating\n7,neha,4400\n3,rahul,4700\n9, deepak,3900\n")
+
+	service, err := NewLeaderboardServiceChecked(config)
+	if err != nil {
+		t.Fatalf("NewLeaderboardServiceChecked failed: %v", err)
+	}
+	defer service.Stop()
+
+	got := service.GetLeaderboard(10)
+	want := []string{"rahul", "neha", "deepak"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d entries, got %+v", len(want), got)
+	}
+	for i, entry := range got {
+		if entry.Username != want[i] || entry.Rank != i+1 {
+			t.Errorf("Position %d: expected %s at rank %d, got %+v", i, want[i], i+1, entry)
+		}
+	}
+
+	// The search index is built from the seeds too
+	if results := service.Search("deep"); len(results) != 1 || results[0].Username != "deepak" {
+		t.Errorf("Expected deepak to be searchable, got %+v", results)
+	}
+}
+
+func TestParseSeedCSV_MalformedRows(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"too few fields", "1,amit,4500\n2,rahul\n", "line 2"},
+		{"bad id", "1,amit,4500\nx,rahul,4700\n", `line 2: invalid id "x"`},
+		{"bad rating", "1,amit,high\n", `line 1: invalid rating "high"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSeedCSV(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error mentioning %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestSeedFile_RejectsInvalidUsers(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	config.SeedFile = writeSeedFile(t, "1,amit,4500\n2,rahul,9000\n")
+
+	service, err := NewLeaderboardServiceChecked(config)
+	var errs ValidationErrors
+	if service != nil || !errors.As(err, &errs) || errs[0].Index != 1 || errs[0].Field != "rating" {
+		t.Errorf("Expected the out of range rating on row 1 to be reported, got %v", err)
+	}
+
+	config.SeedFile = filepath.Join(t.TempDir(), "missing.csv")
+	if _, err := NewLeaderboardServiceChecked(config); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a missing seed file to fail, got %v", err)
+	}
+}
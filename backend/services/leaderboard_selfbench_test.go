@@ -0,0 +1,50 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSelfBenchmark(t *testing.T) {
+	service := createTestService()
+
+	for _, op := range []string{"rank", "search", "leaderboard"} {
+		result, err := service.SelfBenchmark(op, 200)
+		if err != nil {
+			t.Fatalf("SelfBenchmark(%q) failed: %v", op, err)
+		}
+
+		if result.Iterations != 200 {
+			t.Errorf("%s: expected 200 iterations, got %d", op, result.Iterations)
+		}
+
+		if !(result.MinUs <= result.P50Us && result.P50Us <= result.P99Us && result.P99Us <= result.MaxUs) {
+			t.Errorf("%s: percentiles out of order: %+v", op, result)
+		}
+	}
+}
+
+func TestSelfBenchmark_ConcurrencyGuard(t *testing.T) {
+	service := createTestService()
+	service.selfBenchRunning.Store(true)
+
+	if _, err := service.SelfBenchmark("rank", 10); !errors.Is(err, ErrSelfBenchBusy) {
+		t.Errorf("Expected ErrSelfBenchBusy while a run is in flight, got %v", err)
+	}
+}
+
+func TestSelfBenchmark_InvalidInput(t *testing.T) {
+	service := createTestService()
+
+	if _, err := service.SelfBenchmark("rank", 0); err == nil {
+		t.Error("Expected error for zero iterations")
+	}
+
+	if _, err := service.SelfBenchmark("rank", MaxSelfBenchIterations+1); err == nil {
+		t.Error("Expected error above MaxSelfBenchIterations")
+	}
+
+	if _, err := service.SelfBenchmark("delete", 10); err == nil {
+		t.Error("Expected error for unknown op")
+	}
+}
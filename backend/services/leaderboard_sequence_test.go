@@ -0,0 +1,46 @@
+package services
+
+import "testing"
+
+func TestSequencedUpdates_NewestWins(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	config.UserUpdateRate = 0
+	service := NewLeaderboardServiceWithConfig(config)
+
+	// Arrive out of order: seq 3 is the newest and must win
+	for _, update := range []struct {
+		rating int
+		seq    uint64
+	}{
+		{rating: 3000, seq: 1},
+		{rating: 3300, seq: 3},
+		{rating: 3200, seq: 2},
+		{rating: 3100, seq: 3}, // duplicate sequence is stale too
+	} {
+		if err := service.SubmitSequencedUpdate(7, update.rating, update.seq); err != nil {
+			t.Fatalf("SubmitSequencedUpdate failed: %v", err)
+		}
+	}
+
+	service.Stop()
+
+	if got := service.GetSnapshot().GetUserRating(7); got != 3300 {
+		t.Errorf("Expected newest sequenced rating 3300, got %d", got)
+	}
+
+	if got := service.GetStats()["stale_updates"]; got != uint64(2) {
+		t.Errorf("Expected 2 stale updates, got %v", got)
+	}
+}
+
+func TestSequencedUpdates_UnsequencedAlwaysApplied(t *testing.T) {
+	service := createTestService()
+
+	service.applyUpdate(RatingUpdate{UserID: 1, NewRating: 2000, Seq: 5})
+	service.applyUpdate(RatingUpdate{UserID: 1, NewRating: 2100})
+
+	if got := service.writerRatings[1]; got != 2100 {
+		t.Errorf("Expected unsequenced update to apply, got rating %d", got)
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"matiks-backend/models"
+)
+
+func TestStop_FlushesQueuedUpdates(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	service := NewLeaderboardServiceWithConfig(config)
+
+	updates := map[int]int{
+		1:   MaxRating,
+		42:  MinRating,
+		500: 2500,
+		999: 4321,
+	}
+
+	for userID, rating := range updates {
+		if err := service.SubmitUpdate(userID, rating); err != nil {
+			t.Fatalf("SubmitUpdate(%d, %d) failed: %v", userID, rating, err)
+		}
+	}
+
+	service.Stop()
+
+	snap := service.GetSnapshot()
+	for userID, rating := range updates {
+		if got := snap.GetUserRating(userID); got != rating {
+			t.Errorf("User %d: expected rating %d after Stop, got %d", userID, rating, got)
+		}
+	}
+
+	if err := service.SubmitUpdate(1, 3000); !errors.Is(err, ErrServiceStopped) {
+		t.Errorf("Expected ErrServiceStopped after Stop, got %v", err)
+	}
+
+	// A second Stop must not block or panic
+	service.Stop()
+}
+
+func TestStop_KeepsUpdatesAcceptedDuringStop(t *testing.T) {
+	const writers, perWriter = 8, 250
+
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	for id := 1; id <= writers*perWriter; id++ {
+		config.SeedUsers = append(config.SeedUsers, models.UserSeed{ID: id, Username: "user_" + strconv.Itoa(id), Rating: MinRating})
+	}
+	service := NewLeaderboardServiceWithConfig(config)
+
+	// Each writer submits for its own users while Stop runs; whatever was
+	// acknowledged with nil must be in the final snapshot
+	accepted := make([][]int, writers)
+	var started, wg sync.WaitGroup
+	started.Add(writers)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			started.Done()
+			for i := 1; i <= perWriter; i++ {
+				userID := w*perWriter + i
+				if service.SubmitUpdate(userID, 1000+userID) == nil {
+					accepted[w] = append(accepted[w], userID)
+				}
+			}
+		}(w)
+	}
+	started.Wait()
+	service.Stop()
+	wg.Wait()
+
+	snap := service.GetSnapshot()
+	for _, userIDs := range accepted {
+		for _, userID := range userIDs {
+			if got := snap.GetUserRating(userID); got != 1000+userID {
+				t.Fatalf("User %d: update was accepted but the final snapshot has rating %d", userID, got)
+			}
+		}
+	}
+}
+
+func TestSubmitUpdate_RejectsOutOfRangeRating(t *testing.T) {
+	service := &LeaderboardService{
+		updateChan: make(chan RatingUpdate, 1),
+	}
+
+	if err := service.SubmitUpdate(1, MaxRating+1); err == nil {
+		t.Error("Expected error for rating above MaxRating")
+	}
+
+	if err := service.SubmitUpdate(1, MinRating-1); err == nil {
+		t.Error("Expected error for rating below MinRating")
+	}
+
+	if err := service.SubmitUpdate(1, MinRating); err != nil {
+		t.Errorf("Unexpected error for valid rating: %v", err)
+	}
+
+	if err := service.SubmitUpdate(1, MinRating); !errors.Is(err, ErrUpdateQueueFull) {
+		t.Errorf("Expected ErrUpdateQueueFull, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestPauseSimulator_FreezesRandomUpdates(t *testing.T) {
+	config := DefaultConfig()
+	config.UserUpdateRate = 0
+	service := NewLeaderboardServiceWithConfig(config)
+	defer service.Stop()
+
+	service.PauseSimulator()
+	if !service.SimulatorPaused() {
+		t.Fatal("Expected the simulator to report paused")
+	}
+
+	// Let updates queued before the pause reach a snapshot
+	time.Sleep(3 * SnapshotInterval)
+	before := maps.Clone(service.GetSnapshot().UserRatings)
+
+	newRating := MinRating
+	if before[1] == MinRating {
+		newRating = MaxRating
+	}
+	if err := service.SubmitUpdate(1, newRating); err != nil {
+		t.Fatalf("SubmitUpdate failed: %v", err)
+	}
+
+	time.Sleep(5 * SnapshotInterval)
+	after := service.GetSnapshot().UserRatings
+
+	if after[1] != newRating {
+		t.Errorf("Expected the submitted update to apply while paused, got rating %d", after[1])
+	}
+	for userID, rating := range before {
+		if userID != 1 && after[userID] != rating {
+			t.Errorf("User %d changed from %d to %d while the simulator was paused", userID, rating, after[userID])
+		}
+	}
+
+	service.ResumeSimulator()
+	if service.SimulatorPaused() {
+		t.Error("Expected the simulator to report resumed")
+	}
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"fmt"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -450,3 +452,372 @@ func BenchmarkSearch(b *testing.B) {
 		_ = service.Search("user")
 	}
 }
+
+// TestConfiguredRanker verifies the service applies Config.Ranker to every snapshot.
+func TestConfiguredRanker(t *testing.T) {
+	service := &LeaderboardService{
+		config:        Config{Ranker: snapshot.CompetitionRanker{}},
+		users:         make(map[int]*models.User),
+		writerRatings: make(map[int]int),
+	}
+
+	for id, rating := range map[int]int{1: 5000, 2: 5000, 3: 4000} {
+		service.users[id] = &models.User{ID: id, Username: "user"}
+		service.writerRatings[id] = rating
+	}
+
+	service.rebuildSnapshot()
+
+	result := service.GetLeaderboard(10)
+	expected := []int{1, 1, 3}
+
+	if len(result) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d", len(expected), len(result))
+	}
+	for i, rank := range expected {
+		if result[i].Rank != rank {
+			t.Errorf("Entry %d: expected competition rank %d, got %d", i, rank, result[i].Rank)
+		}
+	}
+}
+
+// TestGetLeaderboard_PrecomputedTopMatchesWalk verifies the top-N cache
+// returns exactly what walking the rating levels would.
+func TestGetLeaderboard_PrecomputedTopMatchesWalk(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	config.TopNCacheSize = 100
+	service := NewLeaderboardServiceWithConfig(config)
+	defer service.Stop()
+
+	snap := service.GetSnapshot()
+	if len(snap.Top) != 100 {
+		t.Fatalf("Expected 100 precomputed entries, got %d", len(snap.Top))
+	}
+
+	for _, limit := range []int{1, 10, 100, 101, 500} {
+		got := service.GetLeaderboard(limit)
+		want := walkLeaderboard(snap, limit, 0, viewFilter{})
+		if !slices.Equal(got, want) {
+			t.Errorf("limit %d: precomputed result differs from walk", limit)
+		}
+	}
+
+	// Callers must not be able to modify the shared snapshot
+	top := service.GetLeaderboard(10)
+	top[0].Username = "mutated"
+	if snap.Top[0].Username == "mutated" {
+		t.Error("GetLeaderboard returned the snapshot's own slice")
+	}
+}
+
+// TestNewLeaderboardService_TopWarmOnReturn verifies the common leaderboard
+// limits are served from the precomputed top as soon as the constructor
+// returns, with no warm-up request needed.
+func TestNewLeaderboardService_TopWarmOnReturn(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	service := NewLeaderboardServiceWithConfig(config)
+	defer service.Stop()
+
+	snap := service.GetSnapshot()
+	if len(snap.Top) < 100 {
+		t.Fatalf("Expected 100 precomputed entries on return, got %d", len(snap.Top))
+	}
+
+	// Only the precomputed entries carry this name; a walk over rating
+	// levels would read the real one from UsersByRating
+	const marker = "from_precomputed_top"
+	snap.Top[0].Username = marker
+
+	for _, limit := range []int{10, 50, 100} {
+		got := service.GetLeaderboard(limit)
+		if len(got) != limit || got[0].Username != marker {
+			t.Errorf("limit %d: not served from the precomputed top", limit)
+		}
+	}
+}
+
+// BenchmarkGetLeaderboard_TopN compares the precomputed top-N against
+// walking rating levels for the default limit.
+func BenchmarkGetLeaderboard_TopN(b *testing.B) {
+	for _, size := range []int{0, 100} {
+		config := DefaultConfig()
+		config.DisableSimulator = true
+		config.TopNCacheSize = size
+		service := NewLeaderboardServiceWithConfig(config)
+
+		b.Run(fmt.Sprintf("TopNCacheSize_%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = service.GetLeaderboard(100)
+			}
+		})
+
+		service.Stop()
+	}
+}
+
+// TestGetLeaderboardForUsers verifies a filtered leaderboard keeps global ranks.
+func TestGetLeaderboardForUsers(t *testing.T) {
+	service := createTestService()
+
+	// amit_sharma (4000), rahul (4700), priyanka (3800), plus an unknown
+	// ID and a repeat
+	entries := service.GetLeaderboardForUsers([]int{8, 3, 999, 10, 3})
+
+	want := []models.LeaderboardEntry{
+		{Rank: 1, Username: "rahul", Rating: 4700},
+		{Rank: 8, Username: "amit_sharma", Rating: 4000},
+		{Rank: 10, Username: "priyanka", Rating: 3800},
+	}
+	if !slices.Equal(entries, want) {
+		t.Errorf("Expected %v, got %v", want, entries)
+	}
+
+	// Ranks must match the full leaderboard
+	full := service.GetLeaderboard(100)
+	for _, entry := range entries {
+		for _, f := range full {
+			if f.Username == entry.Username && f.Rank != entry.Rank {
+				t.Errorf("%s: filtered rank %d, global rank %d", entry.Username, entry.Rank, f.Rank)
+			}
+		}
+	}
+
+	if empty := service.GetLeaderboardForUsers(nil); len(empty) != 0 {
+		t.Errorf("Expected no entries for empty ID list, got %v", empty)
+	}
+}
+
+func TestForEachUser(t *testing.T) {
+	service := createTestService()
+	service.Exclude(9)
+
+	count := 0
+	lastRating := MaxRating + 1
+	service.ForEachUser(func(user models.User, rating int) bool {
+		count++
+		if rating > lastRating {
+			t.Errorf("User %s (%d) walked after a lower rating %d", user.Username, rating, lastRating)
+		}
+		lastRating = rating
+		return true
+	})
+
+	if total := service.GetSnapshot().TotalUsers(); count != total {
+		t.Errorf("Walked %d users, expected TotalUsers %d", count, total)
+	}
+
+	// Returning false stops the walk
+	var first []string
+	service.ForEachUser(func(user models.User, rating int) bool {
+		first = append(first, user.Username)
+		return len(first) < 2
+	})
+	if !slices.Equal(first, []string{"rahul", "priya"}) {
+		t.Errorf("Expected early stop after [rahul priya], got %v", first)
+	}
+}
+
+func TestGetSnapshot_BeforeFirstPublish(t *testing.T) {
+	service := &LeaderboardService{}
+
+	snap := service.GetSnapshot()
+	if snap == nil || snap.TotalUsers() != 0 {
+		t.Fatalf("Expected an empty snapshot before init, got %+v", snap)
+	}
+
+	// Readers built on the snapshot degrade to empty results
+	if got := service.GetLeaderboard(10); len(got) != 0 {
+		t.Errorf("Expected empty leaderboard, got %v", got)
+	}
+	if got := service.Search("amit"); len(got) != 0 {
+		t.Errorf("Expected no search results, got %v", got)
+	}
+	if got := service.GetStats()["total_users"]; got != 0 {
+		t.Errorf("Expected 0 total users, got %v", got)
+	}
+}
+
+func TestGetLeaderboard_MinRating(t *testing.T) {
+	service := createTestService()
+
+	got := service.GetLeaderboardWithOptions(LeaderboardOptions{Limit: 10, MinRating: 4300})
+
+	// rahul 4700, priya 4600, amit 4500, neha 4400, amit_kumar 4300
+	want := []models.LeaderboardEntry{
+		{Rank: 1, Username: "rahul", Rating: 4700},
+		{Rank: 2, Username: "priya", Rating: 4600},
+		{Rank: 3, Username: "amit", Rating: 4500},
+		{Rank: 4, Username: "neha", Rating: 4400},
+		{Rank: 5, Username: "amit_kumar", Rating: 4300},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// A floor above every rating but one leaves just the leader
+	if got := service.GetLeaderboardWithOptions(LeaderboardOptions{Limit: 2, MinRating: 4650}); len(got) != 1 || got[0].Rank != 1 {
+		t.Errorf("Expected only rahul at rank 1, got %v", got)
+	}
+}
+
+func TestGetLeaderboard_MinRatingFromPrecomputedTop(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	service := NewLeaderboardServiceWithConfig(config)
+	defer service.Stop()
+
+	snap := service.GetSnapshot()
+	floor := snap.Top[40].Rating
+
+	got := service.GetLeaderboardWithOptions(LeaderboardOptions{Limit: 80, MinRating: floor})
+	want := walkLeaderboard(snap, 80, floor, viewFilter{})
+	if !slices.Equal(got, want) {
+		t.Errorf("Precomputed top with a rating floor differs from the walk:\n got %v\nwant %v", got, want)
+	}
+	for _, entry := range got {
+		if entry.Rating < floor {
+			t.Errorf("Entry %+v is below the %d floor", entry, floor)
+		}
+	}
+}
+
+func TestGetLeaderboard_RecencyTieBreak(t *testing.T) {
+	tests := []struct {
+		tieBreak snapshot.TieBreak
+		want     []string
+	}{
+		{snapshot.TieBreakID, []string{"amit_kumar", "rahul_kumar", "deepak"}},
+		{snapshot.TieBreakRecentFirst, []string{"rahul_kumar", "amit_kumar", "deepak"}},
+		{snapshot.TieBreakRecentLast, []string{"deepak", "amit_kumar", "rahul_kumar"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.tieBreak), func(t *testing.T) {
+			service := createTestServiceWithConfig(Config{TieBreak: tt.tieBreak})
+			clock := time.Unix(1700000000, 0)
+			service.now = func() time.Time {
+				clock = clock.Add(time.Second)
+				return clock
+			}
+
+			// deepak reaches 4800 first, then amit_kumar, then rahul_kumar
+			for _, userID := range []int{9, 2, 6} {
+				service.applyUpdate(RatingUpdate{UserID: userID, NewRating: 4800})
+			}
+			service.rebuildSnapshot()
+
+			got := service.GetLeaderboard(3)
+			for i, entry := range got {
+				if entry.Rank != 1 || entry.Rating != 4800 {
+					t.Errorf("Expected tied users at rank 1, got %+v", entry)
+				}
+				if i < len(tt.want) && entry.Username != tt.want[i] {
+					t.Errorf("Position %d: expected %s, got %s", i, tt.want[i], entry.Username)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("Expected %d entries, got %d", len(tt.want), len(got))
+			}
+		})
+	}
+}
+
+func TestGetLeaderboard_InsertionTieBreak(t *testing.T) {
+	service := createTestServiceWithConfig(Config{TieBreak: snapshot.TieBreakInsertion})
+
+	// IDs deliberately out of order: insertion, not ID, must decide
+	err := service.ReplaceAll([]models.UserSeed{
+		{ID: 30, Username: "first", Rating: 4800},
+		{ID: 10, Username: "second", Rating: 4800},
+		{ID: 20, Username: "third", Rating: 4800},
+		{ID: 40, Username: "below", Rating: 4000},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceAll: %v", err)
+	}
+	if err := service.AddUsers([]models.UserSeed{{ID: 5, Username: "fourth", Rating: 4800}}); err != nil {
+		t.Fatalf("AddUsers: %v", err)
+	}
+
+	// A rating change and a rebuild must not disturb the order
+	service.applyUpdate(RatingUpdate{UserID: 10, NewRating: 4700})
+	service.applyUpdate(RatingUpdate{UserID: 10, NewRating: 4800})
+	service.rebuildSnapshot()
+
+	want := []string{"first", "second", "third", "fourth"}
+	got := service.GetLeaderboard(len(want))
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d entries, got %d", len(want), len(got))
+	}
+	for i, entry := range got {
+		if entry.Rank != 1 {
+			t.Errorf("Expected tied users at rank 1, got %+v", entry)
+		}
+		if entry.Username != want[i] {
+			t.Errorf("Position %d: expected %s, got %s", i, want[i], entry.Username)
+		}
+	}
+}
+
+func TestGetLeaderboardWithMore(t *testing.T) {
+	service := createTestService()
+
+	tests := []struct {
+		name     string
+		opts     LeaderboardOptions
+		wantLen  int
+		wantMore bool
+	}{
+		{"limit above population", LeaderboardOptions{Limit: 50}, 10, false},
+		{"limit equal to population", LeaderboardOptions{Limit: 10}, 10, false},
+		{"small limit", LeaderboardOptions{Limit: 3}, 3, true},
+		{"rating floor ends the list", LeaderboardOptions{Limit: 5, MinRating: 4300}, 5, false},
+		{"rating floor with more", LeaderboardOptions{Limit: 4, MinRating: 4300}, 4, true},
+		{"all", LeaderboardOptions{Limit: LimitAll}, 10, false},
+		{"all above a rating floor", LeaderboardOptions{Limit: LimitAll, MinRating: 4300}, 5, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, hasMore := service.GetLeaderboardWithMore(tt.opts)
+			if len(entries) != tt.wantLen || hasMore != tt.wantMore {
+				t.Errorf("Expected %d entries with has_more=%v, got %d with %v", tt.wantLen, tt.wantMore, len(entries), hasMore)
+			}
+		})
+	}
+
+	// Hidden users don't count as more
+	service.Exclude(10) // priyanka, last
+	if _, hasMore := service.GetLeaderboardWithMore(LeaderboardOptions{Limit: 9}); hasMore {
+		t.Error("Expected has_more=false when only an excluded user remains")
+	}
+}
+
+func TestGetLeaderboardWithOptions_LimitAll(t *testing.T) {
+	service := createTestService()
+
+	seeds := make([]models.UserSeed, 150)
+	for i := range seeds {
+		seeds[i] = models.UserSeed{ID: 100 + i, Username: fmt.Sprintf("player_%d", i), Rating: 1000 + i}
+	}
+	if err := service.AddUsers(seeds); err != nil {
+		t.Fatalf("AddUsers failed: %v", err)
+	}
+	service.rebuildSnapshot()
+
+	all := service.GetLeaderboardWithOptions(LeaderboardOptions{Limit: LimitAll})
+	if len(all) != 160 {
+		t.Fatalf("Expected all 160 entries, got %d", len(all))
+	}
+	if all[0].Username != "rahul" || all[len(all)-1].Username != "player_0" {
+		t.Errorf("Expected the full leaderboard in order, got %+v ... %+v", all[0], all[len(all)-1])
+	}
+
+	// Other non-positive limits keep meaning the default of 100
+	if got := service.GetLeaderboardWithOptions(LeaderboardOptions{Limit: -5}); len(got) != 100 {
+		t.Errorf("Expected the default 100 entries for limit -5, got %d", len(got))
+	}
+}
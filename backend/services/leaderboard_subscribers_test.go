@@ -0,0 +1,77 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSubscribe_CapsSubscribers(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	config.MaxSubscribers = 2
+	service := NewLeaderboardServiceWithConfig(config)
+	defer service.Stop()
+
+	first, err := service.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	second, err := service.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	if _, err := service.Subscribe(); !errors.Is(err, ErrTooManySubscribers) {
+		t.Fatalf("Expected ErrTooManySubscribers beyond the cap, got %v", err)
+	}
+	if got := service.GetStats()["subscribers"]; got != 2 {
+		t.Errorf("Expected 2 subscribers in stats, got %v", got)
+	}
+
+	// Closing (twice, harmlessly) frees the slot
+	first.Close()
+	first.Close()
+	third, err := service.Subscribe()
+	if err != nil {
+		t.Fatalf("Expected a freed slot after Close, got %v", err)
+	}
+
+	third.Close()
+	second.Close()
+	if got := service.GetStats()["subscribers"]; got != 0 {
+		t.Errorf("Expected 0 subscribers after closing all, got %v", got)
+	}
+}
+
+func TestSubscribe_NotifiedOnPublishAndClosedOnStop(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	config.UserUpdateRate = 0
+	service := NewLeaderboardServiceWithConfig(config)
+
+	sub, err := service.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Close()
+
+	if err := service.SubmitUpdate(1, 4321); err != nil {
+		t.Fatalf("SubmitUpdate failed: %v", err)
+	}
+
+	select {
+	case <-sub.C:
+	case <-time.After(2 * time.Second):
+		t.Fatal("No notification after a snapshot rebuild")
+	}
+
+	service.Stop()
+
+	// Drain a possible pending notification, then expect the close
+	for range sub.C {
+	}
+	if _, err := service.Subscribe(); !errors.Is(err, ErrServiceStopped) {
+		t.Errorf("Expected ErrServiceStopped after Stop, got %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"matiks-backend/models"
+	"matiks-backend/snapshot"
+)
+
+func TestGetTierDistribution(t *testing.T) {
+	service := &LeaderboardService{
+		config: Config{
+			Tiers: []Tier{
+				{Name: "Low", MinRating: 100, MaxRating: 1999},
+				{Name: "Mid", MinRating: 2000, MaxRating: 3999},
+				{Name: "High", MinRating: 4000, MaxRating: 5000},
+			},
+		},
+		users: make(map[int]*models.User),
+	}
+
+	builder := snapshot.NewSnapshotBuilder()
+	builder.AddUser(1, "a", 100)
+	builder.AddUser(2, "b", 1999)
+	builder.AddUser(3, "c", 2000)
+	builder.AddUser(4, "d", 4000)
+	builder.AddUser(5, "e", 4500)
+	builder.AddUser(6, "f", 5000)
+	service.currentSnapshot.Store(builder.Build())
+
+	distribution := service.GetTierDistribution()
+
+	expected := map[string]int{"Low": 2, "Mid": 1, "High": 3}
+	for name, count := range expected {
+		if distribution[name] != count {
+			t.Errorf("Tier %s: expected %d users, got %d", name, count, distribution[name])
+		}
+	}
+
+	total := 0
+	for _, count := range distribution {
+		total += count
+	}
+	if total != service.GetSnapshot().TotalUsers() {
+		t.Errorf("Tier counts sum to %d, expected %d", total, service.GetSnapshot().TotalUsers())
+	}
+}
+
+func TestGetTierDistribution_Unranked(t *testing.T) {
+	service := &LeaderboardService{
+		config: Config{
+			Tiers: []Tier{{Name: "Top", MinRating: 4000, MaxRating: 5000}},
+		},
+	}
+
+	builder := snapshot.NewSnapshotBuilder()
+	builder.AddUser(1, "a", 4200)
+	builder.AddUser(2, "b", 1200)
+	service.currentSnapshot.Store(builder.Build())
+
+	distribution := service.GetTierDistribution()
+
+	if distribution["Top"] != 1 || distribution[UnrankedTier] != 1 {
+		t.Errorf("Expected 1 Top and 1 %s user, got %v", UnrankedTier, distribution)
+	}
+}
+
+func TestValidateTiers(t *testing.T) {
+	if err := ValidateTiers(DefaultTiers()); err != nil {
+		t.Errorf("Expected the default tiers to be valid, got %v", err)
+	}
+	if err := ValidateTiers([]Tier{{Name: "Top", MinRating: 4000, MaxRating: 5000}}); err != nil {
+		t.Errorf("Expected a gap below the only tier to be valid, got %v", err)
+	}
+
+	invalid := map[string][]Tier{
+		"overlapping": {{Name: "Low", MinRating: 100, MaxRating: 2500}, {Name: "High", MinRating: 2000, MaxRating: 5000}},
+		"unsorted":    {{Name: "High", MinRating: 3000, MaxRating: 5000}, {Name: "Low", MinRating: 100, MaxRating: 2999}},
+		"reserved":    {{Name: UnrankedTier, MinRating: 100, MaxRating: 5000}},
+		"duplicate":   {{Name: "Gold", MinRating: 100, MaxRating: 999}, {Name: "Gold", MinRating: 1000, MaxRating: 5000}},
+		"inverted":    {{Name: "Gold", MinRating: 3000, MaxRating: 2000}},
+		"unnamed":     {{MinRating: 100, MaxRating: 5000}},
+	}
+	for name, tiers := range invalid {
+		if err := ValidateTiers(tiers); err == nil {
+			t.Errorf("%s: expected an error for %v", name, tiers)
+		}
+	}
+}
+
+func TestNewLeaderboardServiceChecked_RejectsInvalidTiers(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	config.Tiers = []Tier{{Name: "Low", MinRating: 100, MaxRating: 2500}, {Name: "High", MinRating: 2000, MaxRating: 5000}}
+
+	if service, err := NewLeaderboardServiceChecked(config); err == nil {
+		service.Stop()
+		t.Fatal("Expected overlapping tiers to be rejected")
+	}
+}
+
+func TestGetTierDistribution_DefaultTiersCoverAllUsers(t *testing.T) {
+	service := createTestService()
+
+	distribution := service.GetTierDistribution()
+
+	if _, ok := distribution[UnrankedTier]; ok {
+		t.Errorf("Default tiers should cover every rating, got %v", distribution)
+	}
+
+	total := 0
+	for _, count := range distribution {
+		total += count
+	}
+	if total != service.GetSnapshot().TotalUsers() {
+		t.Errorf("Tier counts sum to %d, expected %d", total, service.GetSnapshot().TotalUsers())
+	}
+}
+
+func TestClientTierFor(t *testing.T) {
+	service := &LeaderboardService{
+		config: Config{
+			APIKeys: map[string]string{"pk-1": "premium", "pk-2": "enterprise"},
+		},
+	}
+
+	tests := []struct {
+		apiKey string
+		want   string
+	}{
+		{"pk-1", "premium"},
+		{"", FreeTier},
+		{"unknown", FreeTier},
+		{"pk-2", FreeTier}, // names a tier that isn't configured
+	}
+
+	for _, tt := range tests {
+		if got := service.ClientTierFor(tt.apiKey); got.Name != tt.want {
+			t.Errorf("ClientTierFor(%q) = %s, expected %s", tt.apiKey, got.Name, tt.want)
+		}
+	}
+
+	free := service.ClientTierFor("")
+	if got := free.ClampLimit(0); got != free.DefaultLimit {
+		t.Errorf("Expected the default limit %d, got %d", free.DefaultLimit, got)
+	}
+	if got := free.ClampLimit(free.MaxLimit + 1); got != free.MaxLimit {
+		t.Errorf("Expected clamping to %d, got %d", free.MaxLimit, got)
+	}
+}
+
+func TestParseAPIKeys(t *testing.T) {
+	keys, err := ParseAPIKeys(strings.NewReader(`
+# partner keys
+pk-1 premium
+  pk-2	free
+`))
+	if err != nil {
+		t.Fatalf("ParseAPIKeys failed: %v", err)
+	}
+	if want := map[string]string{"pk-1": "premium", "pk-2": "free"}; !maps.Equal(keys, want) {
+		t.Errorf("Expected %v, got %v", want, keys)
+	}
+
+	// The error names the line but never echoes the key
+	for _, input := range []string{"pk-1", "pk-1 premium extra", "pk-1 premium\npk-1 free"} {
+		_, err := ParseAPIKeys(strings.NewReader(input))
+		if err == nil || strings.Contains(err.Error(), "pk-1") {
+			t.Errorf("ParseAPIKeys(%q) = %v, expected an error without the key", input, err)
+		}
+	}
+}
+
+func TestGetTierLeaderboard(t *testing.T) {
+	service := createTestService()
+
+	tier, entries, err := service.GetTierLeaderboard("Platinum", 3)
+	if err != nil {
+		t.Fatalf("GetTierLeaderboard failed: %v", err)
+	}
+	if tier.MinRating != 3500 || tier.MaxRating != 4299 {
+		t.Errorf("Expected the Platinum bounds, got %+v", tier)
+	}
+
+	// The best of Platinum, below every Diamond user, keep global ranks
+	want := []models.LeaderboardEntry{
+		{Rank: 6, Username: "rahul_sharma", Rating: 4200},
+		{Rank: 7, Username: "rahul_kumar", Rating: 4100},
+		{Rank: 8, Username: "amit_sharma", Rating: 4000},
+	}
+	if !slices.Equal(entries, want) {
+		t.Errorf("Expected %v, got %v", want, entries)
+	}
+
+	// The whole tier stays within its bounds and skips excluded users
+	if err := service.Exclude(9); err != nil {
+		t.Fatalf("Exclude failed: %v", err)
+	}
+	_, entries, _ = service.GetTierLeaderboard("Platinum", LimitAll)
+	if len(entries) != 4 || entries[3].Username != "priyanka" {
+		t.Errorf("Expected 4 visible Platinum users ending with priyanka, got %v", entries)
+	}
+	for _, entry := range entries {
+		if entry.Rating < tier.MinRating || entry.Rating > tier.MaxRating {
+			t.Errorf("Entry %+v is outside Platinum", entry)
+		}
+	}
+
+	if _, entries, _ := service.GetTierLeaderboard("Gold", 10); len(entries) != 0 {
+		t.Errorf("Expected an empty Gold tier, got %v", entries)
+	}
+	if _, _, err := service.GetTierLeaderboard("diamond", 10); !errors.Is(err, ErrUnknownTier) {
+		t.Errorf("Expected ErrUnknownTier for a differently cased name, got %v", err)
+	}
+}
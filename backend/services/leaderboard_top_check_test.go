@@ -0,0 +1,43 @@
+package services
+
+import (
+	"testing"
+
+	"matiks-backend/models"
+	"matiks-backend/snapshot"
+)
+
+func TestWouldBeTop(t *testing.T) {
+	service := &LeaderboardService{users: make(map[int]*models.User)}
+	service.currentSnapshot.Store(snapshot.NewSnapshotBuilder().Build())
+
+	if check := service.WouldBeTop(100); !check.WouldBeTop || check.TopRating != nil || check.Margin != nil {
+		t.Errorf("Expected any rating to top an empty leaderboard, got %+v", check)
+	}
+
+	builder := snapshot.NewSnapshotBuilder()
+	for id, rating := range []int{3000, 4200, 4200} {
+		builder.AddUser(id+1, "u", rating)
+	}
+	service.currentSnapshot.Store(builder.Build())
+
+	tests := []struct {
+		rating int
+		top    bool
+		margin int
+	}{
+		{4201, true, 1},
+		{4200, false, 0}, // ties for first rather than beating it
+		{4199, false, -1},
+	}
+
+	for _, tt := range tests {
+		check := service.WouldBeTop(tt.rating)
+		if check.TopRating == nil || *check.TopRating != 4200 {
+			t.Fatalf("Rating %d: expected top rating 4200, got %+v", tt.rating, check)
+		}
+		if check.WouldBeTop != tt.top || *check.Margin != tt.margin {
+			t.Errorf("Rating %d: expected top %v by %d, got %v by %d", tt.rating, tt.top, tt.margin, check.WouldBeTop, *check.Margin)
+		}
+	}
+}
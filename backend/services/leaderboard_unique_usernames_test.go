@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"matiks-backend/models"
+)
+
+func TestUniqueUsernames_AddUsers(t *testing.T) {
+	service := createTestServiceWithConfig(Config{UniqueUsernames: true})
+
+	err := service.AddUsers([]models.UserSeed{{ID: 11, Username: "rahul", Rating: 3000}})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "username" {
+		t.Fatalf("Expected a username error for taken rahul, got %v", err)
+	}
+	if _, ok := service.GetSnapshot().UserRatings[11]; ok {
+		t.Error("Expected the rejected user not to be added")
+	}
+
+	if err := service.AddUsers([]models.UserSeed{{ID: 11, Username: "rahul_new", Rating: 3000}}); err != nil {
+		t.Fatalf("Expected a unique name to be accepted, got %v", err)
+	}
+	if !service.UsernameTaken("rahul_new") {
+		t.Error("Expected rahul_new to be taken once added")
+	}
+
+	// Names differing only in case are distinct
+	if err := service.AddUsers([]models.UserSeed{{ID: 12, Username: "Rahul", Rating: 3000}}); err != nil {
+		t.Errorf("Expected Rahul to be accepted next to rahul, got %v", err)
+	}
+}
+
+func TestUniqueUsernames_SharedNamesAllowedByDefault(t *testing.T) {
+	service := createTestServiceWithConfig(Config{})
+
+	if err := service.AddUsers([]models.UserSeed{{ID: 11, Username: "rahul", Rating: 3000}}); err != nil {
+		t.Errorf("Expected a shared name to be accepted without UniqueUsernames, got %v", err)
+	}
+}
+
+func TestUniqueUsernames_ImportRejectsRepeats(t *testing.T) {
+	service := createTestServiceWithConfig(Config{UniqueUsernames: true})
+
+	// Replacing the population may reuse its names, but not repeat one
+	errs := service.ValidateUsers([]models.UserSeed{
+		{ID: 1, Username: "rahul", Rating: 3000},
+		{ID: 2, Username: "priya", Rating: 3000},
+		{ID: 3, Username: "rahul", Rating: 3000},
+	})
+	if len(errs) != 1 || errs[0].Index != 2 || errs[0].Field != "username" {
+		t.Errorf("Expected only row 2 reported, got %v", errs)
+	}
+
+	err := service.ReplaceAll([]models.UserSeed{{ID: 1, Username: "rahul", Rating: 3000}, {ID: 2, Username: "rahul", Rating: 2000}})
+	if err == nil {
+		t.Error("Expected ReplaceAll to reject a repeated username")
+	}
+}
+
+func TestUniqueUsernames_FailsFastOnCollidingSeeds(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	config.UniqueUsernames = true
+	config.SeedUsers = []models.UserSeed{
+		{ID: 1, Username: "rahul", Rating: 3000},
+		{ID: 2, Username: "priya", Rating: 3000},
+		{ID: 3, Username: "rahul", Rating: 2000},
+	}
+
+	service, err := NewLeaderboardServiceChecked(config)
+	if !errors.Is(err, ErrUsernameTaken) || service != nil {
+		t.Fatalf("Expected ErrUsernameTaken, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `"rahul" (2 users)`) || strings.Contains(err.Error(), "priya") {
+		t.Errorf("Expected the error to list only rahul, got %v", err)
+	}
+
+	config.SeedUsers[2].Username = "amit"
+	service, err = NewLeaderboardServiceChecked(config)
+	if err != nil {
+		t.Fatalf("Expected unique seeds to be accepted, got %v", err)
+	}
+	defer service.Stop()
+
+	if got := service.GetSnapshot().TotalUsers(); got != 3 {
+		t.Errorf("Expected the 3 seed users, got %d", got)
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"matiks-backend/models"
+)
+
+func TestUsernameLengths_Histogram(t *testing.T) {
+	service := createTestService()
+
+	stats := service.UsernameLengths()
+
+	want := []UsernameLengthCount{
+		{Length: 4, Count: 2},  // amit, neha
+		{Length: 5, Count: 2},  // rahul, priya
+		{Length: 6, Count: 1},  // deepak
+		{Length: 8, Count: 1},  // priyanka
+		{Length: 10, Count: 1}, // amit_kumar
+		{Length: 11, Count: 2}, // rahul_kumar, amit_sharma
+		{Length: 12, Count: 1}, // rahul_sharma
+	}
+	if !slices.Equal(stats.Histogram, want) {
+		t.Errorf("Expected histogram %v, got %v", want, stats.Histogram)
+	}
+	if stats.Users != 10 || stats.AvgLength != 7.6 {
+		t.Errorf("Expected 10 users averaging 7.6 runes, got %d averaging %v", stats.Users, stats.AvgLength)
+	}
+
+	// 2- to 5-grams: a name of length L has (L-1)+(L-2)+(L-3)+(L-4) of them
+	// once L >= 5, e.g. 6 for length 4 and 38 for length 12
+	if stats.EstimatedGrams != 204 {
+		t.Errorf("Expected 204 estimated grams, got %d", stats.EstimatedGrams)
+	}
+}
+
+func TestUsernameLengths_CompactIndexBoundsPostings(t *testing.T) {
+	service := createTestServiceWithConfig(Config{CompactSearchIndex: true})
+
+	// Trigrams only: L-2 per name
+	stats := service.UsernameLengths()
+	if stats.EstimatedGrams != 56 {
+		t.Errorf("Expected 56 estimated trigrams, got %d", stats.EstimatedGrams)
+	}
+
+	postings := service.searchIndexStats()["postings"].(int)
+	if postings > stats.EstimatedGrams {
+		t.Errorf("Expected the estimate to bound the %d actual postings, got %d", postings, stats.EstimatedGrams)
+	}
+}
+
+func TestGramsPerLength_ShortNames(t *testing.T) {
+	if got := gramsPerLength(1, 2, 5); got != 0 {
+		t.Errorf("Expected no grams for a 1-rune name, got %d", got)
+	}
+	if got := gramsPerLength(2, 2, 5); got != 1 {
+		t.Errorf("Expected one bigram for a 2-rune name, got %d", got)
+	}
+}
+
+func TestIndexByLength(t *testing.T) {
+	service := createTestService()
+	if err := service.ReplaceAll([]models.UserSeed{
+		{ID: 1, Username: "abab", Rating: 4000},
+		{ID: 2, Username: "abc", Rating: 4000},
+	}); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+
+	// abab: ab (once), ba, aba, bab, abab; abc: ab, bc, abc
+	want := []GramLengthStats{
+		{Length: 2, Grams: 3, Postings: 4}, // ab is in both names
+		{Length: 3, Grams: 3, Postings: 3},
+		{Length: 4, Grams: 1, Postings: 1},
+		{Length: 5, Grams: 0, Postings: 0},
+	}
+	got := service.IndexByLength()
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// The breakdown adds up to the index totals
+	grams, postings := 0, 0
+	for _, stats := range got {
+		grams += stats.Grams
+		postings += stats.Postings
+	}
+	totals := service.searchIndexStats()
+	if grams != totals["grams"] || postings != totals["postings"] {
+		t.Errorf("Expected %v grams and %v postings in total, got %d and %d", totals["grams"], totals["postings"], grams, postings)
+	}
+}
+
+func TestIndexByLength_Compact(t *testing.T) {
+	service := createTestServiceWithConfig(Config{CompactSearchIndex: true})
+
+	got := service.IndexByLength()
+	if len(got) != 1 || got[0].Length != 3 || got[0].Postings == 0 {
+		t.Errorf("Expected only trigrams, got %v", got)
+	}
+}
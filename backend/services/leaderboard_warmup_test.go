@@ -0,0 +1,76 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"matiks-backend/models"
+)
+
+func asyncConfig() Config {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	config.UserUpdateRate = 0
+	config.AsyncInit = true
+	config.SeedUsers = []models.UserSeed{
+		{ID: 1, Username: "alice", Rating: 4000},
+		{ID: 2, Username: "bob", Rating: 3000},
+	}
+	return config
+}
+
+func TestAsyncInit_WarmsUpInBackground(t *testing.T) {
+	release := make(chan struct{})
+	config := asyncConfig()
+	config.beforeAsyncLoad = func() { <-release }
+
+	service := NewLeaderboardServiceWithConfig(config)
+	defer service.Stop()
+
+	if service.Ready() || service.GetSnapshot().TotalUsers() != 0 {
+		t.Fatal("Expected an empty, unready service while loading")
+	}
+
+	// Queued behind the load rather than dropped as an unknown user
+	if err := service.SubmitUpdate(2, 4500); err != nil {
+		t.Fatalf("SubmitUpdate failed: %v", err)
+	}
+
+	close(release)
+	deadline := time.Now().Add(2 * time.Second)
+	for service.GetSnapshot().GetUserRating(2) != 4500 {
+		if time.Now().After(deadline) {
+			t.Fatal("Update submitted while warming was never applied")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if !service.Ready() || service.GetSnapshot().TotalUsers() != 2 {
+		t.Errorf("Expected 2 users once ready, got %d", service.GetSnapshot().TotalUsers())
+	}
+	if got := service.GetStats()["unknown_user_updates"].(uint64); got != 0 {
+		t.Errorf("Expected no unknown user updates, got %d", got)
+	}
+}
+
+func TestAsyncInit_GracePeriod(t *testing.T) {
+	config := asyncConfig()
+	config.InitGracePeriod = 5 * time.Second
+
+	service := NewLeaderboardServiceWithConfig(config)
+	defer service.Stop()
+
+	// A small population loads well within the grace period
+	if !service.Ready() {
+		t.Error("Expected the service to be ready on return")
+	}
+}
+
+func TestAsyncInit_InvalidSeedsStillFail(t *testing.T) {
+	config := asyncConfig()
+	config.SeedUsers = append(config.SeedUsers, models.UserSeed{ID: 1, Username: "again", Rating: 100})
+
+	if _, err := NewLeaderboardServiceChecked(config); err == nil {
+		t.Error("Expected a duplicate ID to fail construction")
+	}
+}
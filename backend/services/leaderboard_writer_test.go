@@ -0,0 +1,220 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriterMetrics_CoalescedUpdates(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	config.UserUpdateRate = 0
+	service := NewLeaderboardServiceWithConfig(config)
+	defer service.Stop()
+
+	const updates = 50
+	for i := 0; i < updates; i++ {
+		if err := service.SubmitUpdate(i+1, 2000+i); err != nil {
+			t.Fatalf("SubmitUpdate failed: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for service.writerStats.coalesced.Load() < updates {
+		if time.Now().After(deadline) {
+			t.Fatalf("Only %d of %d updates coalesced", service.writerStats.coalesced.Load(), updates)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats := service.GetStats()["writer"].(map[string]interface{})
+	rebuilds := stats["snapshot_rebuilds"].(uint64)
+	if rebuilds == 0 {
+		t.Fatal("Expected at least one rebuild")
+	}
+
+	// Submitted well within one interval, so normally a single rebuild
+	// holds the whole batch; a tick landing mid-submit splits it
+	if got, want := stats["avg_updates_per_rebuild"].(float64), float64(updates)/float64(rebuilds); got != want {
+		t.Errorf("Expected %.1f updates per rebuild over %d rebuilds, got %.1f", want, rebuilds, got)
+	}
+	if rebuilds == 1 && stats["avg_updates_per_rebuild"].(float64) != updates {
+		t.Errorf("Expected the whole batch in one rebuild, got %v", stats["avg_updates_per_rebuild"])
+	}
+	if stats["rebuilds_per_second"].(float64) <= 0 {
+		t.Errorf("Expected a positive rebuild rate, got %v", stats["rebuilds_per_second"])
+	}
+}
+
+func TestWriterMetrics_RebuildInterval(t *testing.T) {
+	var m writerMetrics
+	start := time.Now()
+
+	m.recordUpdate()
+	m.recordUpdate()
+	m.recordRebuild(start)
+	m.recordUpdate()
+	m.recordRebuild(start.Add(100 * time.Millisecond))
+	m.recordRebuild(start.Add(300 * time.Millisecond))
+
+	stats := m.stats(start.Add(time.Second))
+	if stats["snapshot_rebuilds"] != uint64(3) {
+		t.Errorf("Expected 3 rebuilds, got %v", stats["snapshot_rebuilds"])
+	}
+	if stats["avg_updates_per_rebuild"] != 1.0 {
+		t.Errorf("Expected 1 update per rebuild, got %v", stats["avg_updates_per_rebuild"])
+	}
+	if stats["avg_rebuild_interval_ms"] != 150.0 {
+		t.Errorf("Expected 150ms between rebuilds, got %v", stats["avg_rebuild_interval_ms"])
+	}
+}
+
+func TestWriter_SurvivesUpdatesForUnknownUsers(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	config.UserUpdateRate = 0
+	service := NewLeaderboardServiceWithConfig(config)
+	defer service.Stop()
+
+	const unknownID = InitialUsers + 1000
+	if err := service.SubmitUpdate(unknownID, 3000); err != nil {
+		t.Fatalf("SubmitUpdate failed: %v", err)
+	}
+	if err := service.SubmitUpdate(1, 4999); err != nil {
+		t.Fatalf("SubmitUpdate failed: %v", err)
+	}
+
+	// The known user's update is applied after the unknown one, so seeing
+	// it proves the writer kept going
+	deadline := time.Now().Add(2 * time.Second)
+	for service.GetSnapshot().GetUserRating(1) != 4999 {
+		if time.Now().After(deadline) {
+			t.Fatal("Update after the unknown user's was never applied")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := service.GetStats()["unknown_user_updates"].(uint64); got != 1 {
+		t.Errorf("Expected 1 unknown user update, got %d", got)
+	}
+	if _, ok := service.GetSnapshot().UserRatings[unknownID]; ok {
+		t.Error("Expected the unknown user to stay out of the snapshot")
+	}
+}
+
+func TestRebuildSnapshot_SkipsRatingsWithoutUser(t *testing.T) {
+	service := createTestService()
+
+	// A stray rating must not be dereferenced into a nil user
+	service.writerRatings[999] = 3000
+	service.rebuildSnapshot()
+
+	if _, ok := service.GetSnapshot().UserRatings[999]; ok {
+		t.Error("Expected a rating without a user to be left out")
+	}
+	if got := service.GetSnapshot().TotalUsers(); got != 10 {
+		t.Errorf("Expected 10 users, got %d", got)
+	}
+}
+
+func TestForceRebuild_Serialized(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	config.UserUpdateRate = 0
+	service := NewLeaderboardServiceWithConfig(config)
+	defer service.Stop()
+
+	before := service.writerStats.rebuilds.Load()
+
+	// Forced rebuilds race each other and ticker rebuilds triggered by
+	// a stream of updates
+	const callers, perCaller = 8, 10
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < perCaller; j++ {
+				if err := service.SubmitUpdate(i+1, 2000+j); err != nil {
+					t.Errorf("SubmitUpdate failed: %v", err)
+				}
+				if err := service.ForceRebuild(); err != nil {
+					t.Errorf("ForceRebuild failed: %v", err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	stats := service.GetStats()["writer"].(map[string]interface{})
+	if got := stats["rebuild_overlaps"].(uint64); got != 0 {
+		t.Errorf("Expected rebuilds never to overlap, saw %d overlaps", got)
+	}
+	if got := service.writerStats.rebuilds.Load() - before; got < callers*perCaller {
+		t.Errorf("Expected at least %d rebuilds, got %d", callers*perCaller, got)
+	}
+	if stats["rebuild_time_ms_total"].(float64) <= 0 || stats["avg_rebuild_ms"].(float64) <= 0 {
+		t.Errorf("Expected rebuild time to be measured, got %v", stats)
+	}
+
+	service.Stop()
+	if err := service.ForceRebuild(); err != ErrServiceStopped {
+		t.Errorf("Expected ErrServiceStopped after Stop, got %v", err)
+	}
+}
+
+func TestWriterMetrics_DetectsOverlap(t *testing.T) {
+	var m writerMetrics
+
+	first := m.beginRebuild()
+	second := m.beginRebuild()
+	m.finishRebuild(second)
+	m.finishRebuild(first)
+	m.finishRebuild(m.beginRebuild())
+
+	if got := m.overlaps.Load(); got != 1 {
+		t.Errorf("Expected 1 overlap, got %d", got)
+	}
+}
+
+func TestWriter_MinPublishIntervalCapsPublishRate(t *testing.T) {
+	config := DefaultConfig()
+	config.DisableSimulator = true
+	config.UserUpdateRate = 0
+	config.MinPublishInterval = 50 * time.Millisecond
+	service := NewLeaderboardServiceWithConfig(config)
+	defer service.Stop()
+
+	// Flood single updates so nearly every writer wakeup has a batch
+	const flood = 500 * time.Millisecond
+	startVersion := service.GetSnapshot().Version
+	start := time.Now()
+	lastRating := 0
+	for i := 0; time.Since(start) < flood; i++ {
+		rating := MinRating + i%(MaxRating-MinRating)
+		if service.SubmitUpdate(1, rating) == nil {
+			lastRating = rating
+		}
+		time.Sleep(50 * time.Microsecond)
+	}
+	elapsed := time.Since(start)
+	published := service.GetSnapshot().Version - startVersion
+
+	// At most one publish per interval, plus the one that may open it
+	if limit := uint64(elapsed/config.MinPublishInterval) + 1; published > limit {
+		t.Errorf("Expected at most %d snapshots in %v, got %d", limit, elapsed, published)
+	}
+	if published == 0 {
+		t.Error("Expected snapshots to keep being published during the flood")
+	}
+
+	// The last update is published once the flood stops
+	deadline := time.Now().Add(2 * time.Second)
+	for service.GetSnapshot().GetUserRating(1) != lastRating {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected rating %d to be published, got %d", lastRating, service.GetSnapshot().GetUserRating(1))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
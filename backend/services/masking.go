@@ -0,0 +1,34 @@
+package services
+
+import "matiks-backend/models"
+
+// maskSuffix replaces the hidden part of a masked username. It has a fixed
+// length so masking does not leak how long the name is.
+const maskSuffix = "***"
+
+// MaskUsername returns name as public listings show it: unchanged unless
+// Config.MaskUsernames is set, in which case only the first
+// Config.UsernameReveal characters are kept ("ra***"). At least one
+// character is always hidden, so a short name is never shown whole.
+func (s *LeaderboardService) MaskUsername(name string) string {
+	if !s.config.MaskUsernames {
+		return name
+	}
+
+	runes := []rune(name)
+	reveal := max(min(s.config.UsernameReveal, len(runes)-1), 0)
+	return string(runes[:reveal]) + maskSuffix
+}
+
+// MaskEntries applies MaskUsername to entries in place, except the
+// viewer's own entry (IsSelf), which they may always read.
+func (s *LeaderboardService) MaskEntries(entries []models.LeaderboardEntry) {
+	if !s.config.MaskUsernames {
+		return
+	}
+	for i := range entries {
+		if !entries[i].IsSelf {
+			entries[i].Username = s.MaskUsername(entries[i].Username)
+		}
+	}
+}
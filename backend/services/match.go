@@ -0,0 +1,68 @@
+package services
+
+import "errors"
+
+// ErrSameUser rejects a match result whose winner and loser are the same user.
+var ErrSameUser = errors.New("winner and loser must be different users")
+
+// SubmitMatchResult enqueues a match's two rating changes as one unit: the
+// writer applies both in the same step, so every snapshot shows either both
+// new ratings or neither. Like SubmitUpdate it never blocks. Unknown users
+// are rejected with ErrUnknownUser; if a user is removed while the result
+// is queued, neither rating changes. The per-user rate limit applies to
+// both players.
+func (s *LeaderboardService) SubmitMatchResult(winnerID, loserID, winnerRating, loserRating int) error {
+	if winnerID == loserID {
+		return ErrSameUser
+	}
+
+	winner := RatingUpdate{UserID: winnerID, NewRating: winnerRating}
+	loser := RatingUpdate{UserID: loserID, NewRating: loserRating}
+	for _, update := range []RatingUpdate{winner, loser} {
+		if err := update.validate(); err != nil {
+			return err
+		}
+	}
+	if s.stopped.Load() {
+		return ErrServiceStopped
+	}
+
+	s.mu.RLock()
+	_, winnerKnown := s.users[winnerID]
+	_, loserKnown := s.users[loserID]
+	s.mu.RUnlock()
+	if !winnerKnown || !loserKnown {
+		return ErrUnknownUser
+	}
+
+	if s.updateLimiter != nil {
+		now := s.clock()
+		if !s.updateLimiter.allowAll([]int{winnerID, loserID}, now) {
+			s.rateLimitedUpdates.Add(1)
+			return ErrRateLimited
+		}
+	}
+
+	winner.With = &loser
+	return s.enqueue(winner)
+}
+
+// applyPaired applies update and update.With together, or neither if
+// either user is unknown. Writer only.
+func (s *LeaderboardService) applyPaired(update RatingUpdate) {
+	pair := *update.With
+	update.With = nil
+
+	_, firstKnown := s.users[update.UserID]
+	_, pairKnown := s.users[pair.UserID]
+	if !firstKnown || !pairKnown {
+		// Both updates are dropped
+		s.writerStats.recordUpdate()
+		s.writerStats.recordUpdate()
+		s.unknownUsers.Add(2)
+		return
+	}
+
+	s.applyUpdate(update)
+	s.applyUpdate(pair)
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"matiks-backend/models"
+	"matiks-backend/snapshot"
+)
+
+// MetadataFields names the models.UserMetadata fields a listing can
+// include, as accepted by ParseMetadataFields.
+var MetadataFields = []string{"country", "avatar", "joined"}
+
+// MaxMetadataLength bounds each metadata value, in bytes.
+const MaxMetadataLength = 256
+
+// ParseMetadataFields parses a comma-separated list of MetadataFields,
+// such as "country,avatar". Blank items are skipped.
+func ParseMetadataFields(list string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !slices.Contains(MetadataFields, field) {
+			return nil, fmt.Errorf("unknown metadata field %q (valid: %s)", field, strings.Join(MetadataFields, ", "))
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func validateMetadata(errs *ValidationErrors, index int, metadata *models.UserMetadata) {
+	if metadata == nil {
+		return
+	}
+	values := []string{metadata.Country, metadata.Avatar, metadata.Joined} // in MetadataFields order
+	for i, value := range values {
+		if len(value) > MaxMetadataLength {
+			errs.add(index, "metadata", "%s longer than %d bytes", MetadataFields[i], MaxMetadataLength)
+		}
+	}
+	if metadata.Joined != "" {
+		if _, err := time.Parse(time.DateOnly, metadata.Joined); err != nil {
+			errs.add(index, "metadata", "joined %q is not a YYYY-MM-DD date", metadata.Joined)
+		}
+	}
+}
+
+// selectMetadata returns a copy of metadata holding only the named fields,
+// or nil if none of them are set.
+func selectMetadata(metadata *models.UserMetadata, fields []string) *models.UserMetadata {
+	if metadata == nil {
+		return nil
+	}
+
+	var selected models.UserMetadata
+	for _, field := range fields {
+		switch field {
+		case "country":
+			selected.Country = metadata.Country
+		case "avatar":
+			selected.Avatar = metadata.Avatar
+		case "joined":
+			selected.Joined = metadata.Joined
+		}
+	}
+	if selected == (models.UserMetadata{}) {
+		return nil
+	}
+	return &selected
+}
+
+// metadata returns the metadata of userID in snap that the view includes.
+func (v viewFilter) metadata(snap *snapshot.LeaderboardSnapshot, userID int) *models.UserMetadata {
+	if len(v.include) == 0 {
+		return nil
+	}
+	return selectMetadata(snap.Metadata[userID], v.include)
+}
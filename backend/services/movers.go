@@ -0,0 +1,104 @@
+package services
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Mover is a user whose rank changed over a movers window. Change is
+// positive for a climb (PreviousRank 9, Rank 1: Change 8).
+type Mover struct {
+	ID           int    `json:"id"`
+	Username     string `json:"username"`
+	Rating       int    `json:"rating"`
+	Rank         int    `json:"rank"`
+	PreviousRank int    `json:"previous_rank"`
+	Change       int    `json:"change"`
+}
+
+// Movers are the biggest rank gains and losses between two snapshots.
+type Movers struct {
+	Window       int     `json:"window"` // snapshots actually spanned
+	SinceVersion uint64  `json:"since_version"`
+	Version      uint64  `json:"version"`
+	Climbers     []Mover `json:"climbers"`  // biggest gains first
+	Decliners    []Mover `json:"decliners"` // biggest losses first
+}
+
+// TopMovers compares the current snapshot with the one window snapshots
+// earlier (or the oldest retained, see Config.SnapshotHistory) and returns
+// up to limit users with the largest rank gains and losses. Users who
+// joined or left the population in between have no rank to compare and are
+// not listed; neither are excluded users. It is O(users).
+func (s *LeaderboardService) TopMovers(window, limit int) Movers {
+	snaps := s.recentSnapshots(max(window, 0) + 1)
+	since, current := snaps[0], snaps[len(snaps)-1]
+
+	movers := Movers{
+		Window:       len(snaps) - 1,
+		SinceVersion: since.Version,
+		Version:      current.Version,
+		Climbers:     []Mover{},
+		Decliners:    []Mover{},
+	}
+	if since == current {
+		return movers
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	view := s.viewFor(0)
+	for userID, rating := range current.UserRatings {
+		previous, ok := since.UserRatings[userID]
+		if !ok || view.hidden(userID) {
+			continue
+		}
+
+		rank, previousRank := current.GetRank(rating), since.GetRank(previous)
+		if rank == previousRank {
+			continue
+		}
+
+		mover := Mover{
+			ID:           userID,
+			Rating:       rating,
+			Rank:         rank,
+			PreviousRank: previousRank,
+			Change:       previousRank - rank,
+		}
+		if user, ok := s.users[userID]; ok {
+			mover.Username = user.Username
+		}
+
+		if mover.Change > 0 {
+			movers.Climbers = append(movers.Climbers, mover)
+		} else {
+			movers.Decliners = append(movers.Decliners, mover)
+		}
+	}
+
+	// Biggest moves first, then the better current rank, then user ID
+	byMagnitude := func(a, b Mover) int {
+		if c := cmp.Compare(abs(b.Change), abs(a.Change)); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.Rank, b.Rank); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	}
+	slices.SortFunc(movers.Climbers, byMagnitude)
+	slices.SortFunc(movers.Decliners, byMagnitude)
+	movers.Climbers = movers.Climbers[:min(len(movers.Climbers), limit)]
+	movers.Decliners = movers.Decliners[:min(len(movers.Decliners), limit)]
+
+	return movers
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
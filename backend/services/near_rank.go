@@ -0,0 +1,78 @@
+package services
+
+import (
+	"cmp"
+	"slices"
+
+	"matiks-backend/models"
+	"matiks-backend/snapshot"
+)
+
+// MaxNearRankUsers bounds GetUsersNearRank. Under dense ranking one rank
+// is a whole tie group, so even a small radius can span thousands of users.
+const MaxNearRankUsers = 1000
+
+// GetUsersNearRank returns the visible users ranked within radius of
+// targetRank, best rank first, with their global ranks. Ranks are mapped
+// back to the rating levels holding them, the inverse of GetRank, so it
+// works with any Config.Ranker; ranks nobody holds (past the last user, or
+// skipped by competition ranking) contribute no one. If the window holds
+// more than MaxNearRankUsers, the users ranked nearest targetRank are kept
+// and truncated is true.
+func (s *LeaderboardService) GetUsersNearRank(targetRank, radius int) (entries []models.LeaderboardEntry, truncated bool) {
+	radius = max(radius, 0)
+	low, high := max(targetRank-radius, 1), targetRank+radius
+
+	entries, _ = usersInRanks(s.GetSnapshot(), s.viewFor(0), low, high, -1)
+
+	if len(entries) <= MaxNearRankUsers {
+		return entries, false
+	}
+
+	// Keep the nearest ranks; stable sorts preserve listing order in ties
+	slices.SortStableFunc(entries, func(a, b models.LeaderboardEntry) int {
+		return cmp.Compare(abs(a.Rank-targetRank), abs(b.Rank-targetRank))
+	})
+	entries = entries[:MaxNearRankUsers]
+	slices.SortStableFunc(entries, func(a, b models.LeaderboardEntry) int {
+		return cmp.Compare(a.Rank, b.Rank)
+	})
+	return entries, true
+}
+
+// usersInRanks returns the visible users of snap ranked in [low, high],
+// best rank first, stopping after limit users (limit < 0 means no limit)
+// with truncated set if any were left out.
+func usersInRanks(snap *snapshot.LeaderboardSnapshot, view viewFilter, low, high, limit int) (entries []models.LeaderboardEntry, truncated bool) {
+	entries = []models.LeaderboardEntry{}
+	for rating := MaxRating; rating >= MinRating; rating-- {
+		users := snap.UsersByRating[rating]
+		if len(users) == 0 {
+			continue
+		}
+
+		// Ranks only grow as ratings fall
+		rank := snap.GetRank(rating)
+		if rank < low {
+			continue
+		}
+		if rank > high {
+			break
+		}
+
+		for _, user := range users {
+			if view.hidden(user.ID) {
+				continue
+			}
+			if len(entries) == limit {
+				return entries, true
+			}
+			entries = append(entries, models.LeaderboardEntry{
+				Rank:     rank,
+				Username: user.Username,
+				Rating:   user.Rating,
+			})
+		}
+	}
+	return entries, false
+}
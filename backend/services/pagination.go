@@ -0,0 +1,72 @@
+package services
+
+import "matiks-backend/models"
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 1000
+)
+
+// Page describes one page of a larger, already ordered result set.
+// Pages are 1-based; TotalPages is 0 when there are no results.
+type Page struct {
+	Page         int `json:"page"`
+	PageSize     int `json:"page_size"`
+	TotalResults int `json:"total_results"`
+	TotalPages   int `json:"total_pages"`
+}
+
+// Paginate returns the requested page of results and its metadata. A page
+// past the end yields an empty slice with accurate totals. page and
+// pageSize must be positive.
+func Paginate[T any](results []T, page, pageSize int) ([]T, Page) {
+	meta := Page{
+		Page:         page,
+		PageSize:     pageSize,
+		TotalResults: len(results),
+	}
+	if len(results) > 0 {
+		meta.TotalPages = (len(results)-1)/pageSize + 1
+	}
+
+	// Compared before multiplying: (page-1)*pageSize can overflow
+	if page > meta.TotalPages {
+		return []T{}, meta
+	}
+	start := (page - 1) * pageSize
+
+	end := min(start+pageSize, len(results))
+	return results[start:end], meta
+}
+
+// TieBoundary describes the tie group of a page's last entry. Pages are
+// plain slices of one ordering, so a tie group larger than what is left of
+// a page continues on the next one; nothing is dropped or repeated. Later
+// tells clients how many of the tied users are still to come.
+type TieBoundary struct {
+	Rank    int `json:"rank"`     // rank of the page's last entry
+	TieSize int `json:"tie_size"` // results sharing that rank, on any page
+	Later   int `json:"later"`    // of those, how many are on later pages
+}
+
+// PageTieBoundary returns the TieBoundary of the page of results described
+// by meta (from Paginate). ok is false for an empty page, or a zero meta. Tied entries are
+// counted wherever they sit, so it holds for any result ordering.
+func PageTieBoundary(results []models.LeaderboardEntry, meta Page) (boundary TieBoundary, ok bool) {
+	if meta.Page < 1 || meta.Page > meta.TotalPages {
+		return TieBoundary{}, false
+	}
+	end := min(meta.Page*meta.PageSize, len(results))
+
+	boundary.Rank = results[end-1].Rank
+	for i, entry := range results {
+		if entry.Rank != boundary.Rank {
+			continue
+		}
+		boundary.TieSize++
+		if i >= end {
+			boundary.Later++
+		}
+	}
+	return boundary, true
+}
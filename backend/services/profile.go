@@ -0,0 +1,259 @@
+package services
+
+import (
+	"slices"
+
+	"matiks-backend/models"
+	"matiks-backend/snapshot"
+)
+
+// UserProfile is one user's standing, as shown on their profile page.
+// UsersAbove and UsersBelow are raw user counts, unlike Rank which counts
+// rating levels under dense ranking.
+type UserProfile struct {
+	ID         int    `json:"id"`
+	Username   string `json:"username"`
+	Rating     int    `json:"rating"`
+	Rank       int    `json:"rank"`
+	UsersAbove int    `json:"users_above"`
+	UsersBelow int    `json:"users_below"`
+}
+
+// ProfileContext is everything a profile page shows, read from one
+// snapshot so the fields always agree with each other.
+type ProfileContext struct {
+	UserProfile
+	Percentile float64 `json:"percentile"`
+	Tier       string  `json:"tier"` // UnrankedTier outside every tier
+	Version    uint64  `json:"version"`
+
+	// The users listed immediately before and after this one, both in
+	// leaderboard order, so the nearest neighbours are Above's last and
+	// Below's first entries.
+	Above []models.LeaderboardEntry `json:"above"`
+	Below []models.LeaderboardEntry `json:"below"`
+}
+
+// UsersBetween returns how many users have a strictly higher and strictly
+// lower rating than userID ("pass N players to rank up"), in O(1) from the
+// snapshot's cumulative counts. Users tied with userID are in neither
+// count. ok is false for unknown users.
+func (s *LeaderboardService) UsersBetween(userID int) (above, below int, ok bool) {
+	snap := s.GetSnapshot()
+
+	rating, ok := snap.UserRatings[userID]
+	if !ok {
+		return 0, 0, false
+	}
+
+	above, below = usersAround(snap, rating)
+	return above, below, true
+}
+
+// usersAround counts the users strictly above and below rating.
+func usersAround(snap *snapshot.LeaderboardSnapshot, rating int) (above, below int) {
+	above = snap.CountAbove[rating]
+	return above, snap.TotalUsers() - above - snap.RatingCount[rating]
+}
+
+// GetPercentile returns the percentage of users rated strictly below
+// userID, from 0 (no one below) towards 100. ok is false for unknown users.
+func (s *LeaderboardService) GetPercentile(userID int) (float64, bool) {
+	snap := s.GetSnapshot()
+
+	rating, ok := snap.UserRatings[userID]
+	if !ok {
+		return 0, false
+	}
+	return percentile(snap, rating), true
+}
+
+// GetPercentiles is GetPercentile for many users at once, all read from
+// one snapshot. Unknown IDs are left out of the result.
+func (s *LeaderboardService) GetPercentiles(userIDs []int) map[int]float64 {
+	snap := s.GetSnapshot()
+
+	percentiles := make(map[int]float64, len(userIDs))
+	for _, userID := range userIDs {
+		if rating, ok := snap.UserRatings[userID]; ok {
+			percentiles[userID] = percentile(snap, rating)
+		}
+	}
+	return percentiles
+}
+
+// percentile requires at least one user, as any rating found in snap has.
+func percentile(snap *snapshot.LeaderboardSnapshot, rating int) float64 {
+	_, below := usersAround(snap, rating)
+	return 100 * float64(below) / float64(snap.TotalUsers())
+}
+
+// GetUserProfile returns userID's rating, rank and the users above and below
+// them, all from one snapshot. Excluded users still have a profile; they
+// are only hidden from listings.
+func (s *LeaderboardService) GetUserProfile(userID int) (UserProfile, bool) {
+	s.mu.RLock()
+	user, ok := s.users[userID]
+	s.mu.RUnlock()
+	if !ok {
+		return UserProfile{}, false
+	}
+
+	snap := s.GetSnapshot()
+	rating, ok := snap.UserRatings[userID]
+	if !ok {
+		return UserProfile{}, false
+	}
+
+	above, below := usersAround(snap, rating)
+	return UserProfile{
+		ID:         userID,
+		Username:   user.Username,
+		Rating:     rating,
+		Rank:       snap.GetRank(rating),
+		UsersAbove: above,
+		UsersBelow: below,
+	}, true
+}
+
+// GetRankByUsername finds a user by name, ignoring case (see Config.CaseFolding), and returns them
+// with their rank. Usernames are not unique: when several users share the
+// name, the best-ranked one is returned, and among equally rated ones the
+// lowest ID. Excluded users are never matched. Unknown names return
+// ErrUnknownUser.
+func (s *LeaderboardService) GetRankByUsername(username string) (snapshot.UserSummary, int, error) {
+	snap := s.GetSnapshot()
+	view := s.viewFor(0)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	best := snapshot.UserSummary{}
+	found := false
+	for _, userID := range s.usernameIndex[s.config.CaseFolding.fold(username)] {
+		rating, ok := snap.UserRatings[userID]
+		if !ok || view.hidden(userID) {
+			continue
+		}
+		if found && (rating < best.Rating || (rating == best.Rating && userID > best.ID)) {
+			continue
+		}
+
+		best = snapshot.UserSummary{ID: userID, Username: s.users[userID].Username, Rating: rating}
+		found = true
+	}
+
+	if !found {
+		return snapshot.UserSummary{}, 0, ErrUnknownUser
+	}
+	return best, snap.GetRank(best.Rating), nil
+}
+
+// ProfileContextOptions tunes GetProfileContextWithOptions.
+type ProfileContextOptions struct {
+	// Radius is how many users to list on either side of the user.
+	Radius int
+
+	// ExcludeSelf ranks the neighbours as if the user were not on the
+	// leaderboard ("your rank among others" displays), using
+	// snapshot.ExcludingRating. Only neighbours below the user can move:
+	// under dense ranking they move up one only when the user is alone at
+	// their rating, since a tied user leaves the rating level in place.
+	// The user's own Rank is unaffected, as nobody above them changes.
+	ExcludeSelf bool
+}
+
+// GetProfileContext returns userID's profile, percentile and tier along
+// with up to radius users listed on either side of them, all from one
+// snapshot. Neighbours follow leaderboard order, so tied users count
+// too; excluded users are skipped, but userID's own profile is shown even
+// if they are excluded.
+func (s *LeaderboardService) GetProfileContext(userID, radius int) (ProfileContext, bool) {
+	return s.GetProfileContextWithOptions(userID, ProfileContextOptions{Radius: radius})
+}
+
+// GetProfileContextWithOptions is GetProfileContext with the options above.
+func (s *LeaderboardService) GetProfileContextWithOptions(userID int, opts ProfileContextOptions) (ProfileContext, bool) {
+	s.mu.RLock()
+	user, ok := s.users[userID]
+	s.mu.RUnlock()
+	if !ok {
+		return ProfileContext{}, false
+	}
+
+	snap := s.GetSnapshot()
+	rating, ok := snap.UserRatings[userID]
+	if !ok {
+		return ProfileContext{}, false
+	}
+
+	tier := UnrankedTier
+	if found, ok := s.TierForRating(rating); ok {
+		tier = found.Name
+	}
+
+	above, below := usersAround(snap, rating)
+	result := ProfileContext{
+		UserProfile: UserProfile{
+			ID:         userID,
+			Username:   user.Username,
+			Rating:     rating,
+			Rank:       snap.GetRank(rating),
+			UsersAbove: above,
+			UsersBelow: below,
+		},
+		Percentile: percentile(snap, rating),
+		Tier:       tier,
+		Version:    snap.Version,
+		Above:      []models.LeaderboardEntry{},
+		Below:      []models.LeaderboardEntry{},
+	}
+
+	ranks := snap
+	if opts.ExcludeSelf {
+		ranks = snap.ExcludingRating(rating)
+	}
+
+	radius := opts.Radius
+	view := s.viewFor(userID)
+	bucket := snap.UsersByRating[rating]
+	pos := slices.IndexFunc(bucket, func(u snapshot.UserSummary) bool { return u.ID == userID })
+
+	add := func(list *[]models.LeaderboardEntry, u snapshot.UserSummary) bool {
+		if !view.hidden(u.ID) {
+			*list = append(*list, models.LeaderboardEntry{
+				Rank:     ranks.GetRank(u.Rating),
+				Username: u.Username,
+				Rating:   u.Rating,
+			})
+		}
+		return len(*list) < radius
+	}
+
+	// Walk up from the user, nearest first, then put Above in order
+	more := radius > 0
+	for i := pos - 1; more && i >= 0; i-- {
+		more = add(&result.Above, bucket[i])
+	}
+	for r := rating + 1; more && r <= MaxRating; r++ {
+		users := snap.UsersByRating[r]
+		for i := len(users) - 1; more && i >= 0; i-- {
+			more = add(&result.Above, users[i])
+		}
+	}
+	slices.Reverse(result.Above)
+
+	more = radius > 0
+	for i := pos + 1; more && i < len(bucket); i++ {
+		more = add(&result.Below, bucket[i])
+	}
+	for r := rating - 1; more && r >= MinRating; r-- {
+		for _, u := range snap.UsersByRating[r] {
+			if more = add(&result.Below, u); !more {
+				break
+			}
+		}
+	}
+
+	return result, true
+}
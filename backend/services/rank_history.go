@@ -0,0 +1,33 @@
+package services
+
+import "time"
+
+// RankPoint is a user's standing in one published snapshot. Rank and
+// Rating are zero when the user was not in that snapshot.
+type RankPoint struct {
+	Version     uint64    `json:"version"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Rank        int       `json:"rank,omitempty"`
+	Rating      int       `json:"rating,omitempty"`
+}
+
+// RankHistory returns userID's rank in each of the last window snapshots,
+// oldest first and ending with the current one. Only retained snapshots
+// can be reported (see Config.SnapshotHistory), so fewer than window
+// points may be returned. ok is false if the user is in none of them.
+func (s *LeaderboardService) RankHistory(userID, window int) (points []RankPoint, ok bool) {
+	snaps := s.recentSnapshots(window)
+
+	points = make([]RankPoint, len(snaps))
+	for i, snap := range snaps {
+		points[i] = RankPoint{Version: snap.Version, GeneratedAt: snap.GeneratedAt}
+
+		if rating, found := snap.UserRatings[userID]; found {
+			points[i].Rank = snap.GetRank(rating)
+			points[i].Rating = rating
+			ok = true
+		}
+	}
+
+	return points, ok
+}
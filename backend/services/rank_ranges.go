@@ -0,0 +1,48 @@
+package services
+
+import "matiks-backend/models"
+
+// MaxRankRangeEntries bounds how many users one GetRankRanges call returns
+// across all of its ranges.
+const MaxRankRangeEntries = 1000
+
+// RankRange is an inclusive range of ranks, such as 1 to 10.
+type RankRange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// RankRangeEntries holds the users ranked within one RankRange.
+type RankRangeEntries struct {
+	RankRange
+	Entries   []models.LeaderboardEntry `json:"data"`
+	Count     int                       `json:"count"`
+	Truncated bool                      `json:"truncated"`
+}
+
+// GetRankRanges returns the visible users ranked within each of ranges, in
+// request order, all read from a single snapshot so the windows are
+// consistent with each other. Like GetUsersNearRank, a rank is a whole tie
+// group and ranks nobody holds contribute no one. At most
+// MaxRankRangeEntries users are returned in total: once they are used up,
+// the current and later ranges are cut short and marked truncated.
+func (s *LeaderboardService) GetRankRanges(ranges []RankRange) (results []RankRangeEntries, truncated bool) {
+	snap := s.GetSnapshot()
+	view := s.viewFor(0)
+
+	remaining := MaxRankRangeEntries
+	results = make([]RankRangeEntries, len(ranges))
+	for i, r := range ranges {
+		entries, cut := usersInRanks(snap, view, max(r.From, 1), r.To, remaining)
+		remaining -= len(entries)
+		truncated = truncated || cut
+
+		results[i] = RankRangeEntries{
+			RankRange: r,
+			Entries:   entries,
+			Count:     len(entries),
+			Truncated: cut,
+		}
+	}
+	return results, truncated
+}
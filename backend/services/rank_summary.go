@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+)
+
+// RankSummary buckets the users matching a search by rank. Buckets are
+// exclusive, so they always add up to Total.
+type RankSummary struct {
+	Query   string `json:"query"`
+	Total   int    `json:"total"`
+	Top10   int    `json:"top_10"`   // ranks 1-10
+	Top100  int    `json:"top_100"`  // ranks 11-100
+	Top1000 int    `json:"top_1000"` // ranks 101-1000
+	Below   int    `json:"below"`    // ranks above 1000
+}
+
+// SearchRankSummary reports where the users matching query fall on the
+// leaderboard. It uses the same candidate lookup as Search but aggregates
+// instead of sorting and listing the matches.
+func (s *LeaderboardService) SearchRankSummary(query string) RankSummary {
+	summary := RankSummary{Query: query}
+
+	matches, _, _ := s.searchMatches(context.Background(), s.config.CaseFolding.fold(query), s.viewFor(0))
+	for _, entry := range matches {
+		switch {
+		case entry.Rank <= 10:
+			summary.Top10++
+		case entry.Rank <= 100:
+			summary.Top100++
+		case entry.Rank <= 1000:
+			summary.Top1000++
+		default:
+			summary.Below++
+		}
+	}
+	summary.Total = len(matches)
+
+	return summary
+}
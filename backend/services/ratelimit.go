@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrRateLimited = errors.New("too many rating updates for this user")
+
+// userRateLimiter is a token bucket per user ID. Each user may submit a
+// burst of updates, then rate updates per second on average. It is shared
+// by every caller of SubmitUpdate, independent of HTTP connection.
+//
+// A bucket left idle for a full refill period is full again, no different
+// from a new one, so such buckets are swept once per period to keep the
+// map to the users active recently.
+type userRateLimiter struct {
+	rate   float64       // tokens added per second
+	burst  float64       // bucket capacity
+	refill time.Duration // time for an empty bucket to fill up
+
+	mu        sync.Mutex
+	buckets   map[int]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newUserRateLimiter(rate float64, burst int) *userRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &userRateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		refill:  time.Duration(float64(burst) / rate * float64(time.Second)),
+		buckets: make(map[int]*tokenBucket),
+	}
+}
+
+// allow consumes one token from userID's bucket if available.
+func (l *userRateLimiter) allow(userID int, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+	bucket := l.bucket(userID, now)
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+	return true
+}
+
+// allowAll consumes one token per occurrence of each ID in userIDs, from
+// every bucket or from none: if any bucket lacks the tokens, nothing is
+// taken.
+func (l *userRateLimiter) allowAll(userIDs []int, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+	need := make(map[int]float64, len(userIDs))
+	for _, userID := range userIDs {
+		need[userID]++
+	}
+	for userID, tokens := range need {
+		if l.bucket(userID, now).tokens < tokens {
+			return false
+		}
+	}
+	for userID, tokens := range need {
+		l.buckets[userID].tokens -= tokens
+	}
+	return true
+}
+
+// bucket returns userID's bucket, created full or refilled up to now.
+// Requires l.mu.
+func (l *userRateLimiter) bucket(userID int, now time.Time) *tokenBucket {
+	bucket, ok := l.buckets[userID]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[userID] = bucket
+	}
+
+	elapsed := now.Sub(bucket.last).Seconds()
+	if elapsed > 0 {
+		bucket.tokens = min(l.burst, bucket.tokens+elapsed*l.rate)
+		bucket.last = now
+	}
+	return bucket
+}
+
+// sweep drops buckets idle for a refill period, at most once per period.
+// Requires l.mu.
+func (l *userRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.refill {
+		return
+	}
+	l.lastSweep = now
+
+	for userID, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= l.refill {
+			delete(l.buckets, userID)
+		}
+	}
+}
+
+// size returns how many buckets are held.
+func (l *userRateLimiter) size() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
@@ -0,0 +1,30 @@
+package services
+
+// CountInRange returns how many users in the current snapshot have a
+// rating between min and max inclusive, in O(1).
+func (s *LeaderboardService) CountInRange(min, max int) int {
+	return s.GetSnapshot().CountInRange(min, max)
+}
+
+// RatingLevel is one distinct rating held by at least one user, with the
+// rank GetRank gives it.
+type RatingLevel struct {
+	Rating int `json:"rating"`
+	Count  int `json:"count"`
+	Rank   int `json:"rank"`
+}
+
+// GetRatingLadder lists every occupied rating level of the current
+// snapshot, highest first, for rank-ladder views. Counts include excluded
+// users, as ranks do.
+func (s *LeaderboardService) GetRatingLadder() []RatingLevel {
+	snap := s.GetSnapshot()
+
+	ladder := make([]RatingLevel, 0)
+	for rating := MaxRating; rating >= MinRating; rating-- {
+		if count := snap.RatingCount[rating]; count > 0 {
+			ladder = append(ladder, RatingLevel{Rating: rating, Count: count, Rank: snap.GetRank(rating)})
+		}
+	}
+	return ladder
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"matiks-backend/models"
+)
+
+// ReplaceAll swaps the entire population for users. The new user set,
+// search index and first snapshot are built off to the side, then published
+// together on the writer goroutine, so readers see either the complete old
+// set or the complete new one. Rating updates still queued for the old
+// population are dropped if their user no longer exists.
+func (s *LeaderboardService) ReplaceAll(users []models.UserSeed) error {
+	if errs := s.ValidateUsers(users); len(errs) > 0 {
+		return errs
+	}
+
+	staged := &LeaderboardService{
+		config:        s.config,
+		users:         make(map[int]*models.User, len(users)),
+		searchIndex:   make(map[string][]int),
+		writerRatings: make(map[int]int, len(users)),
+	}
+
+	builder := s.newSnapshotBuilder()
+	for _, seed := range users {
+		staged.users[seed.ID] = &models.User{ID: seed.ID, Username: seed.Username, Metadata: seed.Metadata}
+		staged.indexUsername(seed.ID, seed.Username)
+		staged.writerRatings[seed.ID] = seed.Rating
+		builder.AddUser(seed.ID, seed.Username, seed.Rating)
+		builder.SetInserted(seed.ID, staged.recordInsertion(seed.ID))
+		if seed.Metadata != nil {
+			builder.SetMetadata(seed.ID, seed.Metadata)
+		}
+	}
+	newSnapshot := builder.Build()
+
+	return s.runOnWriter(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.users = staged.users
+		s.usersGeneration++
+		s.searchIndex = staged.searchIndex
+		s.charIndex = staged.charIndex
+		s.usernameIndex = staged.usernameIndex
+		s.writerRatings = staged.writerRatings
+		s.writerSeqs = nil    // sequences belonged to the old population
+		s.writerChanged = nil // as did rating change times
+		s.writerInserted = staged.writerInserted
+		s.lastInserted = staged.lastInserted
+		s.resetHistory() // older snapshots describe the old population
+		s.publish(newSnapshot)
+	})
+}
+
+// DuplicatePolicy decides what an import does with rows sharing a user ID.
+type DuplicatePolicy string
+
+const (
+	// DuplicateReject reports every repeated ID as a validation error.
+	DuplicateReject DuplicatePolicy = "reject"
+
+	// DuplicateKeepLast keeps the last row for each ID.
+	DuplicateKeepLast DuplicatePolicy = "keep-last"
+
+	// DuplicateKeepHighest keeps the row with the highest rating for each
+	// ID, the last such row on a tie.
+	DuplicateKeepHighest DuplicatePolicy = "keep-highest"
+)
+
+// ParseDuplicatePolicy validates a user-supplied policy name. Empty means
+// DuplicateReject.
+func ParseDuplicatePolicy(value string) (DuplicatePolicy, error) {
+	switch policy := DuplicatePolicy(strings.ToLower(value)); policy {
+	case "":
+		return DuplicateReject, nil
+	case DuplicateReject, DuplicateKeepLast, DuplicateKeepHighest:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown duplicate policy %q", value)
+	}
+}
+
+// ResolveDuplicateIDs returns the indexes of the rows to keep under policy,
+// in their original order, and how many rows were dropped as duplicates.
+// DuplicateReject keeps every row so ValidateSeeds can report the repeats.
+func ResolveDuplicateIDs(users []models.UserSeed, policy DuplicatePolicy) (keep []int, dropped int) {
+	chosen := make(map[int]int, len(users)) // user ID -> index of kept row
+
+	for i, seed := range users {
+		prev, seen := chosen[seed.ID]
+		switch {
+		case !seen, policy == DuplicateKeepLast:
+			chosen[seed.ID] = i
+		case policy == DuplicateKeepHighest && seed.Rating >= users[prev].Rating:
+			chosen[seed.ID] = i
+		}
+	}
+
+	keep = make([]int, 0, len(chosen))
+	for i, seed := range users {
+		if policy == DuplicateReject || chosen[seed.ID] == i {
+			keep = append(keep, i)
+		}
+	}
+
+	return keep, len(users) - len(keep)
+}
@@ -0,0 +1,45 @@
+package services
+
+import "matiks-backend/models"
+
+// UsernameGroup summarises the search matches sharing one exact username.
+type UsernameGroup struct {
+	Username   string `json:"username"`
+	Count      int    `json:"count"`
+	BestRank   int    `json:"best_rank"`
+	BestRating int    `json:"best_rating"`
+}
+
+// GroupByUsername collapses results with identical usernames (case
+// sensitive) into one group each. Groups keep the order in which their
+// first member appears, so rank-ordered results give groups ordered by
+// best rank.
+func GroupByUsername(results []models.LeaderboardEntry) []UsernameGroup {
+	groups := make([]UsernameGroup, 0, len(results))
+	index := make(map[string]int, len(results))
+
+	for _, entry := range results {
+		i, ok := index[entry.Username]
+		if !ok {
+			index[entry.Username] = len(groups)
+			groups = append(groups, UsernameGroup{
+				Username:   entry.Username,
+				Count:      1,
+				BestRank:   entry.Rank,
+				BestRating: entry.Rating,
+			})
+			continue
+		}
+
+		group := &groups[i]
+		group.Count++
+		if entry.Rank < group.BestRank {
+			group.BestRank = entry.Rank
+		}
+		if entry.Rating > group.BestRating {
+			group.BestRating = entry.Rating
+		}
+	}
+
+	return groups
+}
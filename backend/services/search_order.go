@@ -0,0 +1,233 @@
+package services
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"matiks-backend/models"
+)
+
+// SearchOrder selects how search results are ordered.
+type SearchOrder string
+
+const (
+	// SearchOrderRank lists the best-ranked matches first.
+	SearchOrderRank SearchOrder = "rank"
+
+	// SearchOrderRelevance lists exact matches first, then prefix matches,
+	// then matches by how early the query appears in the username.
+	SearchOrderRelevance SearchOrder = "relevance"
+
+	// SearchOrderAlpha lists matches alphabetically by username.
+	SearchOrderAlpha SearchOrder = "alpha"
+)
+
+// sortFields are the fields an explicit "field:direction" ordering may
+// name, e.g. "rating:desc,username:asc".
+var sortFields = map[string]bool{"rank": true, "rating": true, "username": true}
+
+// sortKey is one field of an explicit ordering.
+type sortKey struct {
+	field string
+	desc  bool
+}
+
+// ParseSearchOrder validates a user-supplied ordering: one of the named
+// orders, or a comma-separated list of field:direction keys where field is
+// rank, rating or username and direction is asc or desc.
+func ParseSearchOrder(value string) (SearchOrder, error) {
+	order := SearchOrder(strings.ToLower(value))
+	switch order {
+	case SearchOrderRank, SearchOrderRelevance, SearchOrderAlpha:
+		return order, nil
+	}
+
+	if strings.Contains(string(order), ":") {
+		if _, err := order.keys(); err != nil {
+			return "", err
+		}
+		return order, nil
+	}
+	return "", fmt.Errorf("unknown search order %q", value)
+}
+
+// keys parses an explicit field:direction ordering.
+func (o SearchOrder) keys() ([]sortKey, error) {
+	var keys []sortKey
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(string(o), ",") {
+		field, direction, _ := strings.Cut(strings.TrimSpace(part), ":")
+		if !sortFields[field] {
+			return nil, fmt.Errorf("unknown sort field %q", field)
+		}
+		if seen[field] {
+			return nil, fmt.Errorf("sort field %q given twice", field)
+		}
+		seen[field] = true
+
+		switch direction {
+		case "asc":
+			keys = append(keys, sortKey{field: field})
+		case "desc":
+			keys = append(keys, sortKey{field: field, desc: true})
+		default:
+			return nil, fmt.Errorf("unknown sort direction %q for %s", direction, field)
+		}
+	}
+	return keys, nil
+}
+
+// sortSearchResults orders results in place. query must already be folded
+// with fold. Every ordering falls back to rank and then username so output is deterministic.
+func sortSearchResults(results []models.LeaderboardEntry, query string, order SearchOrder, fold CaseFolding) {
+	if strings.Contains(string(order), ":") {
+		if keys, err := order.keys(); err == nil {
+			sortByKeys(results, keys, fold)
+			return
+		}
+	}
+
+	if order != SearchOrderRelevance && order != SearchOrderAlpha {
+		sortByRank(results)
+		return
+	}
+
+	// Derive each entry's sort key once rather than on every comparison
+	keyed := make([]keyedEntry, len(results))
+	for i, entry := range results {
+		keyed[i].entry = entry
+		if order == SearchOrderRelevance {
+			keyed[i].key = relevanceScore(entry.Username, query, fold)
+		} else {
+			keyed[i].folded = fold.fold(entry.Username)
+		}
+	}
+
+	slices.SortFunc(keyed, func(a, b keyedEntry) int {
+		if c := cmp.Compare(a.key[0], b.key[0]); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.key[1], b.key[1]); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.folded, b.folded); c != 0 {
+			return c
+		}
+		return compareByRank(a.entry, b.entry)
+	})
+
+	for i := range keyed {
+		results[i] = keyed[i].entry
+	}
+}
+
+// sortByKeys orders results by an explicit field:direction list, then by
+// rank and username like every other ordering. Usernames compare folded
+// with fold, as they are matched.
+func sortByKeys(results []models.LeaderboardEntry, keys []sortKey, fold CaseFolding) {
+	keyed := make([]keyedEntry, len(results))
+	for i, entry := range results {
+		keyed[i].entry = entry
+		keyed[i].folded = fold.fold(entry.Username)
+	}
+
+	slices.SortFunc(keyed, func(a, b keyedEntry) int {
+		for _, key := range keys {
+			var c int
+			switch key.field {
+			case "rank":
+				c = cmp.Compare(a.entry.Rank, b.entry.Rank)
+			case "rating":
+				c = cmp.Compare(a.entry.Rating, b.entry.Rating)
+			case "username":
+				c = strings.Compare(a.folded, b.folded)
+			}
+			if key.desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return compareByRank(a.entry, b.entry)
+	})
+
+	for i := range keyed {
+		results[i] = keyed[i].entry
+	}
+}
+
+type keyedEntry struct {
+	entry  models.LeaderboardEntry
+	key    [2]int // relevance score, zero for other orders
+	folded string // case-folded username, empty for rank and relevance orders
+}
+
+// sortByRank is a counting sort on Rank. Dense ranks are bounded by the
+// number of distinct rating levels, so this is O(n + levels) and much cheaper
+// than a comparison sort for broad queries; only users tied on rank are
+// compared by username.
+func sortByRank(results []models.LeaderboardEntry) {
+	maxRank, minRank := 0, 0
+	for _, entry := range results {
+		maxRank = max(maxRank, entry.Rank)
+		minRank = min(minRank, entry.Rank)
+	}
+
+	// Competition or custom rankers can produce ranks far larger than the
+	// result set (or negative); only bucket when the array stays proportionate.
+	if minRank < 0 || (maxRank > MaxRating+1 && maxRank > 8*len(results)) {
+		slices.SortFunc(results, compareByRank)
+		return
+	}
+
+	starts := make([]int, maxRank+2)
+	for _, entry := range results {
+		starts[entry.Rank+1]++
+	}
+	for rank := 1; rank < len(starts); rank++ {
+		starts[rank] += starts[rank-1]
+	}
+
+	sorted := make([]models.LeaderboardEntry, len(results))
+	next := append([]int(nil), starts...)
+	for _, entry := range results {
+		sorted[next[entry.Rank]] = entry
+		next[entry.Rank]++
+	}
+
+	for rank := 0; rank <= maxRank; rank++ {
+		if tied := sorted[starts[rank]:starts[rank+1]]; len(tied) > 1 {
+			slices.SortFunc(tied, compareByRank)
+		}
+	}
+
+	copy(results, sorted)
+}
+
+func compareByRank(a, b models.LeaderboardEntry) int {
+	if c := cmp.Compare(a.Rank, b.Rank); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Username, b.Username)
+}
+
+// relevanceScore returns a lower score for a better match. Exact matches
+// score 0; otherwise a match nearer the start of the username wins, and among
+// matches at the same offset the shorter username wins.
+func relevanceScore(username, query string, fold CaseFolding) [2]int {
+	lower := fold.fold(username)
+	if lower == query {
+		return [2]int{0, 0}
+	}
+
+	offset := strings.Index(lower, query)
+	if offset < 0 {
+		offset = len(lower)
+	}
+
+	return [2]int{1 + offset, len(lower) - len(query)}
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"matiks-backend/models"
+)
+
+// rebuildSearchIndexes rebuilds the search indexes every
+// Config.SearchIndexRebuildInterval until Stop.
+func (s *LeaderboardService) rebuildSearchIndexes() {
+	ticker := time.NewTicker(s.config.SearchIndexRebuildInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.RebuildSearchIndex()
+		}
+	}
+}
+
+// RebuildSearchIndex rebuilds the n-gram, username and single-character
+// indexes from the current users and swaps them in, dropping postings for
+// users that no longer exist or were renamed and trimming posting list
+// capacity. Readers see either the old indexes or the new ones, never a
+// mix. The build runs off the writer; if the users are replaced meanwhile,
+// the stale build is discarded since ReplaceAll brought fresh indexes.
+func (s *LeaderboardService) RebuildSearchIndex() error {
+	s.mu.RLock()
+	generation := s.usersGeneration
+	users := make([]models.User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, *user)
+	}
+	grams := len(s.searchIndex)
+	s.mu.RUnlock()
+
+	// Posting lists in ID order, as initially built
+	slices.SortFunc(users, func(a, b models.User) int { return cmp.Compare(a.ID, b.ID) })
+
+	staged := &LeaderboardService{
+		config:      s.config,
+		searchIndex: make(map[string][]int, grams),
+	}
+	for _, user := range users {
+		staged.indexUsername(user.ID, user.Username)
+	}
+
+	return s.runOnWriter(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.usersGeneration != generation {
+			return
+		}
+		s.searchIndex = staged.searchIndex
+		s.charIndex = staged.charIndex
+		s.usernameIndex = staged.usernameIndex
+	})
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"matiks-backend/models"
+)
+
+// ParseSeedCSV reads users as "id,username,rating" rows, for seeding the
+// initial population (see Config.SeedFile). A first row of exactly those
+// column names is skipped as a header. Rows that are not three fields or
+// whose id or rating is not an integer fail with their line number; ranges
+// and usernames are left to ValidateUsers.
+func ParseSeedCSV(r io.Reader) ([]models.UserSeed, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	var seeds []models.UserSeed
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return seeds, nil
+		}
+		if err != nil {
+			return nil, err // *csv.ParseError carries the line
+		}
+		if first && strings.EqualFold(strings.Join(record, ","), "id,username,rating") {
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		id, err := strconv.Atoi(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid id %q", line, record[0])
+		}
+		rating, err := strconv.Atoi(record[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rating %q", line, record[2])
+		}
+
+		seeds = append(seeds, models.UserSeed{ID: id, Username: record[1], Rating: rating})
+	}
+}
+
+// LoadSeedCSV parses the seed file at path.
+func LoadSeedCSV(path string) ([]models.UserSeed, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseSeedCSV(f)
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// MaxSelfBenchIterations bounds a single self-benchmark run.
+	MaxSelfBenchIterations = 100000
+)
+
+var ErrSelfBenchBusy = errors.New("a self-benchmark is already running")
+
+// SelfBenchResult summarises the latency of one self-benchmark run.
+// Latencies are reported in microseconds.
+type SelfBenchResult struct {
+	Op         string  `json:"op"`
+	Iterations int     `json:"iterations"`
+	TotalMs    float64 `json:"total_ms"`
+	MinUs      float64 `json:"min_us"`
+	MeanUs     float64 `json:"mean_us"`
+	P50Us      float64 `json:"p50_us"`
+	P90Us      float64 `json:"p90_us"`
+	P95Us      float64 `json:"p95_us"`
+	P99Us      float64 `json:"p99_us"`
+	MaxUs      float64 `json:"max_us"`
+}
+
+// SelfBenchmark times n sequential calls of op ("rank", "search" or
+// "leaderboard") against the live service, in the same way as
+// BenchmarkLatencyDistribution. Only one run may be in flight at a time.
+func (s *LeaderboardService) SelfBenchmark(op string, n int) (SelfBenchResult, error) {
+	if n <= 0 || n > MaxSelfBenchIterations {
+		return SelfBenchResult{}, fmt.Errorf("iterations must be between 1 and %d", MaxSelfBenchIterations)
+	}
+
+	var fn func(i int)
+	switch op {
+	case "rank":
+		fn = func(i int) {
+			s.GetSnapshot().GetRank(MinRating + i%(MaxRating-MinRating+1))
+		}
+	case "search":
+		queries := []string{"rahul", "kumar", "user", "amit", "priya"}
+		fn = func(i int) {
+			s.Search(queries[i%len(queries)])
+		}
+	case "leaderboard":
+		fn = func(i int) {
+			s.GetLeaderboard(100)
+		}
+	default:
+		return SelfBenchResult{}, fmt.Errorf("unknown op %q", op)
+	}
+
+	if !s.selfBenchRunning.CompareAndSwap(false, true) {
+		return SelfBenchResult{}, ErrSelfBenchBusy
+	}
+	defer s.selfBenchRunning.Store(false)
+
+	latencies := make([]time.Duration, n)
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		opStart := time.Now()
+		fn(i)
+		latencies[i] = time.Since(opStart)
+	}
+	total := time.Since(start)
+
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+
+	var sum time.Duration
+	for _, latency := range latencies {
+		sum += latency
+	}
+
+	micros := func(d time.Duration) float64 {
+		return float64(d.Nanoseconds()) / 1000.0
+	}
+	percentile := func(p float64) float64 {
+		return micros(latencies[int(float64(n-1)*p)])
+	}
+
+	return SelfBenchResult{
+		Op:         op,
+		Iterations: n,
+		TotalMs:    float64(total.Microseconds()) / 1000.0,
+		MinUs:      micros(latencies[0]),
+		MeanUs:     micros(sum / time.Duration(n)),
+		P50Us:      percentile(0.50),
+		P90Us:      percentile(0.90),
+		P95Us:      percentile(0.95),
+		P99Us:      percentile(0.99),
+		MaxUs:      micros(latencies[n-1]),
+	}, nil
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"sync"
+)
+
+var ErrTooManySubscribers = errors.New("too many subscribers")
+
+// Subscription is notified whenever a new snapshot is published. C carries
+// at most one pending notification: a slow reader that misses several
+// publishes sees a single one and should read GetSnapshot for the latest
+// state. C is closed when the service stops.
+type Subscription struct {
+	C <-chan struct{}
+
+	c   chan struct{}
+	set *subscriberSet
+}
+
+// Close unsubscribes and frees the slot. It is safe to call more than once
+// and after the service has stopped.
+func (sub *Subscription) Close() {
+	sub.set.remove(sub)
+}
+
+// subscriberSet tracks live subscriptions. Channels are only closed while
+// holding mu and after removal from subs, so notify never sends on a closed
+// channel. The zero value is ready to use.
+type subscriberSet struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func (set *subscriberSet) add(limit int) (*Subscription, error) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	if set.closed {
+		return nil, ErrServiceStopped
+	}
+	if limit > 0 && len(set.subs) >= limit {
+		return nil, ErrTooManySubscribers
+	}
+
+	c := make(chan struct{}, 1)
+	sub := &Subscription{C: c, c: c, set: set}
+	if set.subs == nil {
+		set.subs = make(map[*Subscription]struct{})
+	}
+	set.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+func (set *subscriberSet) remove(sub *Subscription) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	if _, ok := set.subs[sub]; ok {
+		delete(set.subs, sub)
+		close(sub.c)
+	}
+}
+
+// notify wakes every subscriber without blocking; one already holding a
+// pending notification is left as is.
+func (set *subscriberSet) notify() {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	for sub := range set.subs {
+		select {
+		case sub.c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// closeAll ends every subscription and refuses new ones.
+func (set *subscriberSet) closeAll() {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	for sub := range set.subs {
+		delete(set.subs, sub)
+		close(sub.c)
+	}
+	set.closed = true
+}
+
+func (set *subscriberSet) count() int {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	return len(set.subs)
+}
+
+// CloseSubscriptions ends every open subscription and refuses new ones,
+// letting streaming handlers return ahead of a server shutdown. Stop does
+// the same.
+func (s *LeaderboardService) CloseSubscriptions() {
+	s.subscribers.closeAll()
+}
+
+// Subscribe registers for snapshot publish notifications, for streaming
+// endpoints. At most Config.MaxSubscribers subscriptions may be open at
+// once (zero means no limit); beyond that ErrTooManySubscribers is
+// returned. Callers must Close the subscription when done.
+func (s *LeaderboardService) Subscribe() (*Subscription, error) {
+	return s.subscribers.add(s.config.MaxSubscribers)
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+const (
+	DefaultSuggestions = 5
+	MaxSuggestions     = 50
+
+	// maxSuggestCandidates bounds how many users are scored by edit distance
+	// after the bigram pre-filter.
+	maxSuggestCandidates = 500
+)
+
+// Suggestion is a "did you mean" candidate for a search query.
+type Suggestion struct {
+	Rank     int    `json:"rank"`
+	Username string `json:"username"`
+	Rating   int    `json:"rating"`
+	Distance int    `json:"distance"` // edit distance from the query
+}
+
+// Suggest returns up to k distinct usernames closest to query by edit
+// distance, nearest first. Candidates are users sharing at least one bigram
+// (trigram with a compact index) with the query, found through the n-gram
+// index, so no full scan is needed.
+// Among users sharing a username, the best ranked one is returned.
+func (s *LeaderboardService) Suggest(query string, k int) []Suggestion {
+	query = s.config.CaseFolding.fold(query)
+	n, _ := s.gramLengths()
+	grams := generateNGramsRange(query, n, n)
+	if len(grams) == 0 || k <= 0 {
+		return []Suggestion{}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snap := s.GetSnapshot()
+
+	// Pre-filter: count shared shortest grams per user
+	overlap := make(map[int]int)
+	for _, gram := range grams {
+		for _, userID := range s.searchIndex[gram] {
+			overlap[userID]++
+		}
+	}
+
+	candidates := make([]int, 0, len(overlap))
+	for userID := range overlap {
+		candidates = append(candidates, userID)
+	}
+	slices.SortFunc(candidates, func(a, b int) int {
+		if c := cmp.Compare(overlap[b], overlap[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	if len(candidates) > maxSuggestCandidates {
+		candidates = candidates[:maxSuggestCandidates]
+	}
+
+	view := s.viewFor(0)
+	best := make(map[string]Suggestion, len(candidates))
+	for _, userID := range candidates {
+		if view.hidden(userID) {
+			continue
+		}
+
+		username := s.users[userID].Username
+		rating := snap.GetUserRating(userID)
+		suggestion := Suggestion{
+			Rank:     snap.GetRank(rating),
+			Username: username,
+			Rating:   rating,
+			Distance: levenshtein(s.config.CaseFolding.fold(username), query),
+		}
+
+		if prev, ok := best[username]; !ok || suggestion.Rank < prev.Rank {
+			best[username] = suggestion
+		}
+	}
+
+	suggestions := make([]Suggestion, 0, len(best))
+	for _, suggestion := range best {
+		suggestions = append(suggestions, suggestion)
+	}
+	slices.SortFunc(suggestions, func(a, b Suggestion) int {
+		if c := cmp.Compare(a.Distance, b.Distance); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.Rank, b.Rank); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Username, b.Username)
+	})
+
+	if len(suggestions) > k {
+		suggestions = suggestions[:k]
+	}
+	return suggestions
+}
+
+// levenshtein returns the byte-wise edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"matiks-backend/models"
+)
+
+// Tier is a named, inclusive rating band such as "Gold" (2500-3499).
+type Tier struct {
+	Name      string `json:"name"`
+	MinRating int    `json:"min_rating"`
+	MaxRating int    `json:"max_rating"`
+}
+
+// UnrankedTier is reported for users whose rating falls outside every
+// configured tier, so distributions always sum to the total population.
+const UnrankedTier = "Unranked"
+
+// DefaultTiers covers the full MinRating..MaxRating range.
+func DefaultTiers() []Tier {
+	return []Tier{
+		{Name: "Bronze", MinRating: MinRating, MaxRating: 1499},
+		{Name: "Silver", MinRating: 1500, MaxRating: 2499},
+		{Name: "Gold", MinRating: 2500, MaxRating: 3499},
+		{Name: "Platinum", MinRating: 3500, MaxRating: 4299},
+		{Name: "Diamond", MinRating: 4300, MaxRating: MaxRating},
+	}
+}
+
+// ValidateTiers checks a custom tier list: every tier is named and within
+// its own bounds, tiers are sorted by rating without overlapping (gaps are
+// allowed and reported as UnrankedTier), names are unique, and none is the
+// reserved UnrankedTier.
+func ValidateTiers(tiers []Tier) error {
+	names := make(map[string]bool, len(tiers))
+	for i, tier := range tiers {
+		switch {
+		case tier.Name == "":
+			return fmt.Errorf("tier %d has no name", i)
+		case tier.Name == UnrankedTier:
+			return fmt.Errorf("tier name %q is reserved", UnrankedTier)
+		case names[tier.Name]:
+			return fmt.Errorf("tier %q is listed twice", tier.Name)
+		case tier.MinRating > tier.MaxRating:
+			return fmt.Errorf("tier %q: min_rating %d is above max_rating %d", tier.Name, tier.MinRating, tier.MaxRating)
+		case i > 0 && tier.MinRating <= tiers[i-1].MaxRating:
+			return fmt.Errorf("tier %q (from %d) must start above %q (up to %d)", tier.Name, tier.MinRating, tiers[i-1].Name, tiers[i-1].MaxRating)
+		}
+		names[tier.Name] = true
+	}
+	return nil
+}
+
+func (s *LeaderboardService) tiers() []Tier {
+	if s.config.Tiers == nil {
+		return DefaultTiers()
+	}
+	return s.config.Tiers
+}
+
+// TierForRating returns the first configured tier containing rating.
+func (s *LeaderboardService) TierForRating(rating int) (Tier, bool) {
+	return findTier(s.tiers(), rating)
+}
+
+func findTier(tiers []Tier, rating int) (Tier, bool) {
+	for _, tier := range tiers {
+		if rating >= tier.MinRating && rating <= tier.MaxRating {
+			return tier, true
+		}
+	}
+	return Tier{}, false
+}
+
+// ErrUnknownTier reports a tier name that is not configured.
+var ErrUnknownTier = errors.New("unknown tier")
+
+// TierByName returns the configured tier called name, compared exactly.
+func (s *LeaderboardService) TierByName(name string) (Tier, bool) {
+	for _, tier := range s.tiers() {
+		if tier.Name == name {
+			return tier, true
+		}
+	}
+	return Tier{}, false
+}
+
+// GetTierLeaderboard returns the first limit users of the named tier in
+// leaderboard order, with their global ranks, walking only the rating levels
+// within the tier's bounds. Excluded users are skipped; LimitAll returns the
+// whole tier and other non-positive limits mean 100, as in
+// GetLeaderboardWithOptions. Unknown names return ErrUnknownTier.
+func (s *LeaderboardService) GetTierLeaderboard(name string, limit int) (Tier, []models.LeaderboardEntry, error) {
+	tier, ok := s.TierByName(name)
+	if !ok {
+		return Tier{}, nil, ErrUnknownTier
+	}
+
+	snap := s.GetSnapshot()
+	switch {
+	case limit == LimitAll:
+		limit = snap.TotalUsers()
+	case limit <= 0:
+		limit = 100
+	}
+
+	return tier, walkRatings(snap, limit, tier.MinRating, tier.MaxRating, s.viewFor(0)), nil
+}
+
+// GetTierDistribution counts users per tier from the current snapshot.
+// Every configured tier is present (possibly zero); users outside all
+// tiers are counted under UnrankedTier.
+func (s *LeaderboardService) GetTierDistribution() map[string]int {
+	snap := s.GetSnapshot()
+	tiers := s.tiers()
+
+	distribution := make(map[string]int, len(tiers)+1)
+	for _, tier := range tiers {
+		distribution[tier.Name] = 0
+	}
+
+	for rating, count := range snap.RatingCount {
+		if count == 0 {
+			continue
+		}
+
+		if tier, ok := findTier(tiers, rating); ok {
+			distribution[tier.Name] += count
+		} else {
+			distribution[UnrankedTier] += count
+		}
+	}
+
+	return distribution
+}
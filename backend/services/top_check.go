@@ -0,0 +1,23 @@
+package services
+
+// TopCheck reports whether a rating would take sole first place.
+// TopRating and Margin are nil on an empty leaderboard, where any rating
+// would be the top.
+type TopCheck struct {
+	Rating     int  `json:"rating"`
+	TopRating  *int `json:"top_rating"`
+	WouldBeTop bool `json:"would_be_top"`
+	Margin     *int `json:"margin"` // rating - top_rating; positive when it would be top
+}
+
+// WouldBeTop reports whether rating exceeds the highest rating in the
+// current snapshot, and by how much. Matching it only ties for first.
+func (s *LeaderboardService) WouldBeTop(rating int) TopCheck {
+	top, ok := s.GetSnapshot().HighestRating()
+	if !ok {
+		return TopCheck{Rating: rating, WouldBeTop: true}
+	}
+
+	margin := rating - top
+	return TopCheck{Rating: rating, TopRating: &top, WouldBeTop: margin > 0, Margin: &margin}
+}
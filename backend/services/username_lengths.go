@@ -0,0 +1,108 @@
+package services
+
+import (
+	"slices"
+	"unicode/utf8"
+)
+
+// UsernameLengthCount is one bar of the username length histogram.
+type UsernameLengthCount struct {
+	Length int `json:"length"` // in runes
+	Count  int `json:"count"`
+}
+
+// UsernameLengthStats describes username lengths, which drive how many
+// n-grams each user adds to the search index.
+type UsernameLengthStats struct {
+	Users     int                   `json:"users"`
+	Histogram []UsernameLengthCount `json:"histogram"` // shortest first
+	AvgLength float64               `json:"avg_length"`
+
+	// EstimatedGrams is the postings indexed for these lengths with the
+	// current gram lengths. It is an upper bound: a gram repeated within a
+	// username is indexed once, and names are counted before case folding.
+	EstimatedGrams int `json:"estimated_grams"`
+}
+
+// UsernameLengths returns the histogram of username lengths over every
+// user, excluded ones included, for sizing the search index.
+func (s *LeaderboardService) UsernameLengths() UsernameLengthStats {
+	s.mu.RLock()
+	counts := make(map[int]int)
+	for _, user := range s.users {
+		counts[utf8.RuneCountInString(user.Username)]++
+	}
+	s.mu.RUnlock()
+
+	stats := UsernameLengthStats{Histogram: make([]UsernameLengthCount, 0, len(counts))}
+	minN, maxN := s.gramLengths()
+	totalLength := 0
+
+	for length, count := range counts {
+		stats.Histogram = append(stats.Histogram, UsernameLengthCount{Length: length, Count: count})
+		stats.Users += count
+		totalLength += length * count
+		stats.EstimatedGrams += count * gramsPerLength(length, minN, maxN)
+	}
+	slices.SortFunc(stats.Histogram, func(a, b UsernameLengthCount) int {
+		return a.Length - b.Length
+	})
+
+	if stats.Users > 0 {
+		stats.AvgLength = float64(totalLength) / float64(stats.Users)
+	}
+	return stats
+}
+
+// GramLengthStats is the part of the search index made of grams of one
+// length.
+type GramLengthStats struct {
+	Length   int `json:"length"` // in runes
+	Grams    int `json:"grams"`  // distinct grams
+	Postings int `json:"postings"`
+}
+
+// IndexByLength breaks the search index down by gram length, for tuning
+// which lengths to index: how many distinct grams of each length there
+// are and how many postings they hold. Every indexed length is listed,
+// shortest first, even if no gram has it. It scans the whole index.
+func (s *LeaderboardService) IndexByLength() []GramLengthStats {
+	minN, maxN := s.gramLengths()
+
+	byLength := make(map[int]*GramLengthStats, maxN-minN+1)
+	for n := minN; n <= maxN; n++ {
+		byLength[n] = &GramLengthStats{Length: n}
+	}
+
+	s.mu.RLock()
+	for gram, userIDs := range s.searchIndex {
+		n := utf8.RuneCountInString(gram)
+		stats, ok := byLength[n]
+		if !ok {
+			stats = &GramLengthStats{Length: n}
+			byLength[n] = stats
+		}
+		stats.Grams++
+		stats.Postings += len(userIDs)
+	}
+	s.mu.RUnlock()
+
+	result := make([]GramLengthStats, 0, len(byLength))
+	for _, stats := range byLength {
+		result = append(result, *stats)
+	}
+	slices.SortFunc(result, func(a, b GramLengthStats) int {
+		return a.Length - b.Length
+	})
+	return result
+}
+
+// gramsPerLength counts the grams of minN to maxN runes in a string of
+// length runes, as generateNGramsRange would before removing repeats.
+func gramsPerLength(length, minN, maxN int) int {
+	grams := 0
+	for n := minN; n <= maxN && n <= length; n++ {
+		grams += length - n + 1
+	}
+	return grams
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"matiks-backend/models"
+)
+
+// ErrUsernameTaken reports a username already in use while
+// Config.UniqueUsernames is set.
+var ErrUsernameTaken = errors.New("username is already in use")
+
+// maxListedDuplicates bounds how many names duplicateUsernamesError lists.
+const maxListedDuplicates = 10
+
+// UsernameTaken reports whether a user already has exactly username (case
+// sensitive), found through the username index without a scan.
+func (s *LeaderboardService) UsernameTaken(username string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.usernameTaken(username)
+}
+
+// usernameTaken requires s.mu or the writer goroutine.
+func (s *LeaderboardService) usernameTaken(username string) bool {
+	for _, userID := range s.usernameIndex[s.config.CaseFolding.fold(username)] {
+		if user, ok := s.users[userID]; ok && user.Username == username {
+			return true
+		}
+	}
+	return false
+}
+
+// RankedUser is a user found by name, with their rank.
+type RankedUser struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Rating   int    `json:"rating"`
+	Rank     int    `json:"rank"`
+}
+
+// GetUsersByNames looks up every user named exactly (case sensitive) one of
+// names through the username index, e.g. to import a friends list keyed by
+// username. Names are not unique, so each maps to all its users, best
+// ranked first and ties by ID, all ranked from one snapshot. Names without
+// a match, including ones held only by excluded users, are returned in
+// unmatched in request order. Repeated names are looked up once.
+func (s *LeaderboardService) GetUsersByNames(names []string) (matches map[string][]RankedUser, unmatched []string) {
+	snap := s.GetSnapshot()
+	view := s.viewFor(0)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matches = make(map[string][]RankedUser, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		var found []RankedUser
+		for _, userID := range s.usernameIndex[s.config.CaseFolding.fold(name)] {
+			user, ok := s.users[userID]
+			if !ok || user.Username != name || view.hidden(userID) {
+				continue
+			}
+			rating, ok := snap.UserRatings[userID]
+			if !ok {
+				continue
+			}
+			found = append(found, RankedUser{ID: userID, Username: name, Rating: rating, Rank: snap.GetRank(rating)})
+		}
+
+		if len(found) == 0 {
+			unmatched = append(unmatched, name)
+			continue
+		}
+		slices.SortFunc(found, func(a, b RankedUser) int {
+			if c := cmp.Compare(b.Rating, a.Rating); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.ID, b.ID)
+		})
+		matches[name] = found
+	}
+	return matches, unmatched
+}
+
+// UsernameAvailable reports whether username could be given to a new user,
+// i.e. passes ValidateUsername. Without Config.UniqueUsernames users may
+// share names, so a taken name is still available.
+func (s *LeaderboardService) UsernameAvailable(username string) bool {
+	return s.ValidateUsername(username) == nil
+}
+
+// DuplicateUsernames returns the usernames (exact, case sensitive) used by
+// more than one row of users, each with the IDs using it, in row order.
+func DuplicateUsernames(users []models.UserSeed) map[string][]int {
+	ids := make(map[string][]int, len(users))
+	for _, seed := range users {
+		ids[seed.Username] = append(ids[seed.Username], seed.ID)
+	}
+
+	for username, userIDs := range ids {
+		if len(userIDs) < 2 {
+			delete(ids, username)
+		}
+	}
+	return ids
+}
+
+// duplicateUsernamesError lists the repeated usernames of users, or returns
+// nil if there are none.
+func duplicateUsernamesError(users []models.UserSeed) error {
+	duplicates := DuplicateUsernames(users)
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	usernames := make([]string, 0, len(duplicates))
+	for username := range duplicates {
+		usernames = append(usernames, username)
+	}
+	slices.Sort(usernames)
+
+	listed := make([]string, 0, maxListedDuplicates)
+	for _, username := range usernames[:min(len(usernames), maxListedDuplicates)] {
+		listed = append(listed, fmt.Sprintf("%q (%d users)", username, len(duplicates[username])))
+	}
+	msg := strings.Join(listed, ", ")
+	if len(usernames) > maxListedDuplicates {
+		msg += fmt.Sprintf(" and %d more", len(usernames)-maxListedDuplicates)
+	}
+
+	return fmt.Errorf("%w: %d usernames repeated: %s", ErrUsernameTaken, len(usernames), msg)
+}
+
+// UpdateUsername renames userID and publishes a snapshot showing the new
+// name before returning. The name must pass ValidateUsername, so blocked
+// names fail with ErrUsernameBlocked and, with Config.UniqueUsernames,
+// names in use with ErrUsernameTaken. Unknown users get ErrUnknownUser;
+// renaming a user to their current name changes nothing.
+func (s *LeaderboardService) UpdateUsername(userID int, username string) error {
+	var err error
+	runErr := s.runOnWriter(func() {
+		// Only the writer mutates users, so it may read them unlocked
+		user, ok := s.users[userID]
+		if !ok {
+			err = ErrUnknownUser
+			return
+		}
+		if user.Username == username {
+			return
+		}
+		if err = s.ValidateUsername(username); err != nil {
+			return
+		}
+
+		// Readers may hold the old *User, so it is replaced, not edited
+		s.mu.Lock()
+		s.unindexUsername(userID, user.Username)
+		s.users[userID] = &models.User{ID: userID, Username: username, Metadata: user.Metadata}
+		s.indexUsername(userID, username)
+		s.usersGeneration++
+		s.mu.Unlock()
+
+		s.rebuildSnapshot()
+	})
+	if runErr != nil {
+		return runErr
+	}
+	return err
+}
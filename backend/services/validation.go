@@ -0,0 +1,161 @@
+package services
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+
+	"matiks-backend/models"
+)
+
+// ValidationError describes one bad row of a bulk request.
+type ValidationError struct {
+	Index   int    `json:"index"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors collects every problem found in a bulk request so
+// clients can fix them all in one pass.
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	if len(errs) == 0 {
+		return "no validation errors"
+	}
+
+	first := errs[0]
+	msg := fmt.Sprintf("row %d: %s: %s", first.Index, first.Field, first.Message)
+	if len(errs) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(errs)-1)
+	}
+	return msg
+}
+
+// Rows returns the indexes that have at least one error.
+func (errs ValidationErrors) Rows() map[int]bool {
+	rows := make(map[int]bool, len(errs))
+	for _, err := range errs {
+		rows[err.Index] = true
+	}
+	return rows
+}
+
+func (errs *ValidationErrors) add(index int, field, format string, args ...interface{}) {
+	*errs = append(*errs, ValidationError{Index: index, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func validateRating(errs *ValidationErrors, index, rating int) {
+	if rating < MinRating || rating > MaxRating {
+		errs.add(index, "rating", "rating %d out of range [%d, %d]", rating, MinRating, MaxRating)
+	}
+}
+
+// ValidateSeeds checks the IDs, usernames, ratings and metadata of a population,
+// reporting every bad row. A repeated ID is reported on each occurrence
+// after the first. Ingest paths use ValidateUsers, which adds the service's
+// username rules.
+func ValidateSeeds(users []models.UserSeed) ValidationErrors {
+	var errs ValidationErrors
+	seen := make(map[int]bool, len(users))
+
+	for i, seed := range users {
+		if seed.ID <= 0 {
+			errs.add(i, "id", "invalid id %d", seed.ID)
+		} else if seen[seed.ID] {
+			errs.add(i, "id", "duplicate id %d", seed.ID)
+		}
+		if seed.Username == "" {
+			errs.add(i, "username", "empty username")
+		}
+		validateRating(&errs, i, seed.Rating)
+		validateMetadata(&errs, i, seed.Metadata)
+		seen[seed.ID] = true
+	}
+
+	return errs
+}
+
+// ValidateUsername checks a username for a new or renamed user against the
+// service's rules: it must not be empty, match Config.UsernameBlocklist
+// (ErrUsernameBlocked) or, with Config.UniqueUsernames, be in use
+// (ErrUsernameTaken).
+func (s *LeaderboardService) ValidateUsername(username string) error {
+	if username == "" {
+		return errors.New("empty username")
+	}
+	if s.config.UsernameBlocklist.Blocks(username) {
+		return fmt.Errorf("%w: %q", ErrUsernameBlocked, username)
+	}
+	if s.config.UniqueUsernames && s.UsernameTaken(username) {
+		return fmt.Errorf("%w: %q", ErrUsernameTaken, username)
+	}
+	return nil
+}
+
+// ValidateUsers is ValidateSeeds plus the service's username rules (see
+// ValidateUsername), for populations about to be ingested. With
+// Config.UniqueUsernames, a username repeated within users is reported on
+// each occurrence after the first; names already in use are not checked,
+// as an import replaces them. With Config.MaxUsers set, every row beyond
+// the cap is reported too.
+func (s *LeaderboardService) ValidateUsers(users []models.UserSeed) ValidationErrors {
+	errs := ValidateSeeds(users)
+	seedErrs := len(errs)
+
+	firstRow := make(map[string]int)
+	for i, seed := range users {
+		// Empty usernames are already reported by ValidateSeeds
+		if seed.Username == "" {
+			continue
+		}
+		if s.config.UsernameBlocklist.Blocks(seed.Username) {
+			errs.add(i, "username", "username %q is not allowed", seed.Username)
+		}
+		if !s.config.UniqueUsernames {
+			continue
+		}
+		if first, ok := firstRow[seed.Username]; ok {
+			errs.add(i, "username", "username %q already used by row %d", seed.Username, first)
+		} else {
+			firstRow[seed.Username] = i
+		}
+	}
+
+	if limit := s.config.MaxUsers; limit > 0 {
+		for i := limit; i < len(users); i++ {
+			errs.add(i, "id", "beyond the %d user cap", limit)
+		}
+	}
+
+	if len(errs) > seedErrs {
+		slices.SortStableFunc(errs, func(a, b ValidationError) int {
+			return cmp.Compare(a.Index, b.Index)
+		})
+	}
+	return errs
+}
+
+// ValidateUpdates checks a batch of rating updates against the current
+// population, reporting every bad row.
+func (s *LeaderboardService) ValidateUpdates(updates []RatingUpdate) ValidationErrors {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.validateUpdates(updates)
+}
+
+// validateUpdates requires s.mu or the writer goroutine.
+func (s *LeaderboardService) validateUpdates(updates []RatingUpdate) ValidationErrors {
+	var errs ValidationErrors
+
+	for i, update := range updates {
+		if _, ok := s.users[update.UserID]; !ok {
+			errs.add(i, "user_id", "unknown user %d", update.UserID)
+		}
+		validateRating(&errs, i, update.NewRating)
+	}
+
+	return errs
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"time"
+
+	"matiks-backend/models"
+)
+
+// startAsync loads seeds on the writer goroutine ahead of its loop, so
+// updates and writer commands submitted meanwhile queue behind the load,
+// and waits up to Config.InitGracePeriod for the first snapshot.
+func (s *LeaderboardService) startAsync(seeds []models.UserSeed) {
+	loaded := make(chan struct{})
+	go func() {
+		if hook := s.config.beforeAsyncLoad; hook != nil {
+			hook()
+		}
+		s.loadUsers(seeds)
+		close(loaded)
+		s.snapshotWriter()
+	}()
+
+	if grace := s.config.InitGracePeriod; grace > 0 {
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+
+		select {
+		case <-loaded:
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// writerMetrics measures how well the writer coalesces updates into
+// snapshot rebuilds, to guide tuning of SnapshotInterval. pending and
+// lastRebuild belong to the writer goroutine; the totals are atomics read
+// by GetStats.
+type writerMetrics struct {
+	start time.Time // zero for services not built by a constructor
+
+	pending     int       // updates applied since the last rebuild
+	lastRebuild time.Time // zero until the first rebuild
+
+	rebuilds      atomic.Uint64
+	coalesced     atomic.Uint64 // updates applied across all rebuilds
+	intervalNanos atomic.Int64  // summed time between consecutive rebuilds
+	buildNanos    atomic.Int64  // summed time spent inside rebuilds
+
+	// Rebuilds in progress and how often more than one was. Rebuilds only
+	// run on the writer, so overlaps stays zero unless that is broken.
+	active   atomic.Int32
+	overlaps atomic.Uint64
+}
+
+// recordUpdate counts an update consumed by the writer.
+func (m *writerMetrics) recordUpdate() {
+	m.pending++
+}
+
+// beginRebuild marks a rebuild as running and returns when it started,
+// for finishRebuild.
+func (m *writerMetrics) beginRebuild() time.Time {
+	if m.active.Add(1) > 1 {
+		m.overlaps.Add(1)
+	}
+	return time.Now()
+}
+
+// finishRebuild adds the time since started to the rebuild total.
+func (m *writerMetrics) finishRebuild(started time.Time) {
+	m.buildNanos.Add(int64(time.Since(started)))
+	m.active.Add(-1)
+}
+
+// recordRebuild closes the current coalescing window.
+func (m *writerMetrics) recordRebuild(now time.Time) {
+	if !m.lastRebuild.IsZero() {
+		m.intervalNanos.Add(int64(now.Sub(m.lastRebuild)))
+	}
+	m.lastRebuild = now
+
+	m.coalesced.Add(uint64(m.pending))
+	m.pending = 0
+	m.rebuilds.Add(1)
+}
+
+// stats reports the totals as averages for /stats.
+func (m *writerMetrics) stats(now time.Time) map[string]interface{} {
+	rebuilds := m.rebuilds.Load()
+
+	buildMs := float64(m.buildNanos.Load()) / float64(time.Millisecond)
+
+	var perRebuild, perSecond, intervalMs, avgBuildMs float64
+	if rebuilds > 0 {
+		perRebuild = float64(m.coalesced.Load()) / float64(rebuilds)
+		avgBuildMs = buildMs / float64(rebuilds)
+	}
+	if rebuilds > 1 {
+		intervalMs = float64(m.intervalNanos.Load()) / float64(rebuilds-1) / float64(time.Millisecond)
+	}
+	if !m.start.IsZero() {
+		perSecond = float64(rebuilds) / now.Sub(m.start).Seconds()
+	}
+
+	return map[string]interface{}{
+		"snapshot_rebuilds":       rebuilds,
+		"avg_updates_per_rebuild": perRebuild,
+		"avg_rebuild_interval_ms": intervalMs,
+		"rebuilds_per_second":     perSecond,
+		"rebuild_time_ms_total":   buildMs,
+		"avg_rebuild_ms":          avgBuildMs,
+		"rebuild_overlaps":        m.overlaps.Load(),
+	}
+}
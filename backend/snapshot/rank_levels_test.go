@@ -0,0 +1,161 @@
+package snapshot
+
+import (
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// Snapshots rank through dense RatingCount/PrefixHigher/CountAbove arrays
+// sized to the rating range. These benchmarks compare them with a sparse
+// index over the distinct ratings alone, at a 0-100000 range with 10,000
+// users, to show what a larger range would cost either way.
+
+// sparseLevels is the sparse alternative: distinct ratings, highest first,
+// with the number of users above each, looked up by binary search.
+type sparseLevels struct {
+	ratings    []int // distinct ratings, highest first
+	usersAbove []int // usersAbove[i] = users rated above ratings[i]
+	total      int
+}
+
+func newSparseLevels(userRatings map[int]int) *sparseLevels {
+	all := make([]int, 0, len(userRatings))
+	for _, rating := range userRatings {
+		all = append(all, rating)
+	}
+	slices.Sort(all)
+
+	levels := &sparseLevels{total: len(all)}
+
+	// Walk runs of equal ratings from the highest down
+	above := 0
+	for i := len(all) - 1; i >= 0; {
+		rating := all[i]
+		j := i
+		for j >= 0 && all[j] == rating {
+			j--
+		}
+		levels.ratings = append(levels.ratings, rating)
+		levels.usersAbove = append(levels.usersAbove, above)
+		above += i - j
+		i = j
+	}
+
+	return levels
+}
+
+// levelsAbove returns how many distinct ratings are strictly higher.
+func (l *sparseLevels) levelsAbove(rating int) int {
+	return sort.Search(len(l.ratings), func(i int) bool {
+		return l.ratings[i] <= rating
+	})
+}
+
+func (l *sparseLevels) denseRank(rating int) int {
+	return l.levelsAbove(rating) + 1
+}
+
+func (l *sparseLevels) countAbove(rating int) int {
+	i := l.levelsAbove(rating)
+	if i == len(l.ratings) {
+		return l.total
+	}
+	return l.usersAbove[i]
+}
+
+// TestSparseLevels_MatchDenseArrays keeps the benchmark honest: both sides
+// must answer the same ranks.
+func TestSparseLevels_MatchDenseArrays(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	builder := NewSnapshotBuilder()
+	userRatings := make(map[int]int)
+	for id := 1; id <= 2000; id++ {
+		rating := 100 + rng.Intn(300)*10 // gaps and ties
+		builder.AddUser(id, "user", rating)
+		userRatings[id] = rating
+	}
+
+	snap := builder.Build()
+	levels := newSparseLevels(userRatings)
+
+	// Every rating in range, populated or not
+	for rating := 0; rating <= 5000; rating++ {
+		if got, want := levels.denseRank(rating), snap.GetRank(rating); got != want {
+			t.Fatalf("denseRank(%d) = %d, dense array says %d", rating, got, want)
+		}
+		if got, want := levels.countAbove(rating), snap.CountAbove[rating]; got != want {
+			t.Fatalf("countAbove(%d) = %d, dense array says %d", rating, got, want)
+		}
+	}
+}
+
+const benchRatingRange = 100000
+
+func benchRatings() map[int]int {
+	rng := rand.New(rand.NewSource(1))
+	ratings := make(map[int]int, 10000)
+	for id := 1; id <= 10000; id++ {
+		ratings[id] = rng.Intn(benchRatingRange + 1)
+	}
+	return ratings
+}
+
+// denseLevels mirrors Build's RatingCount/PrefixHigher/CountAbove pass
+// with slices sized to benchRatingRange.
+func denseLevels(userRatings map[int]int) (prefixHigher, countAbove []int) {
+	counts := make([]int, benchRatingRange+1)
+	for _, rating := range userRatings {
+		counts[rating]++
+	}
+
+	prefixHigher = make([]int, benchRatingRange+1)
+	countAbove = make([]int, benchRatingRange+1)
+	levels, above := 0, 0
+	for rating := benchRatingRange; rating >= 0; rating-- {
+		prefixHigher[rating] = levels
+		countAbove[rating] = above
+		if counts[rating] > 0 {
+			levels++
+			above += counts[rating]
+		}
+	}
+	return prefixHigher, countAbove
+}
+
+func BenchmarkRankLevelsBuild(b *testing.B) {
+	ratings := benchRatings()
+
+	b.Run("dense", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			denseLevels(ratings)
+		}
+	})
+	b.Run("sparse", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			newSparseLevels(ratings)
+		}
+	})
+}
+
+func BenchmarkRankLevelsLookup(b *testing.B) {
+	ratings := benchRatings()
+
+	b.Run("dense", func(b *testing.B) {
+		prefixHigher, _ := denseLevels(ratings)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = prefixHigher[i%(benchRatingRange+1)] + 1
+		}
+	})
+	b.Run("sparse", func(b *testing.B) {
+		levels := newSparseLevels(ratings)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = levels.denseRank(i % (benchRatingRange + 1))
+		}
+	})
+}
@@ -0,0 +1,39 @@
+package snapshot
+
+// Ranker computes the rank shown for a rating from a snapshot's precomputed
+// arrays. Implementations must be O(1) or close to it: GetRank is on every
+// read path. rating is always within the snapshot's array bounds.
+type Ranker interface {
+	Rank(s *LeaderboardSnapshot, rating int) int
+}
+
+// RankerFunc adapts a plain function to the Ranker interface.
+type RankerFunc func(s *LeaderboardSnapshot, rating int) int
+
+func (f RankerFunc) Rank(s *LeaderboardSnapshot, rating int) int {
+	return f(s, rating)
+}
+
+// DenseRanker gives ties the same rank without gaps: 100, 100, 95 → 1, 1, 2.
+// This is the default when a snapshot has no Ranker.
+type DenseRanker struct{}
+
+func (DenseRanker) Rank(s *LeaderboardSnapshot, rating int) int {
+	return s.PrefixHigher[rating] + 1
+}
+
+// CompetitionRanker is standard competition ("1224") ranking: ties share the
+// best position and the following ranks are skipped. 100, 100, 95 → 1, 1, 3.
+type CompetitionRanker struct{}
+
+func (CompetitionRanker) Rank(s *LeaderboardSnapshot, rating int) int {
+	return s.CountAbove[rating] + 1
+}
+
+// ModifiedCompetitionRanker is modified competition ("1334") ranking: ties
+// share the worst position. 100, 100, 95 → 2, 2, 3.
+type ModifiedCompetitionRanker struct{}
+
+func (ModifiedCompetitionRanker) Rank(s *LeaderboardSnapshot, rating int) int {
+	return s.CountAbove[rating] + s.RatingCount[rating]
+}
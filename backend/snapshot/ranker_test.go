@@ -0,0 +1,121 @@
+package snapshot
+
+import (
+	"testing"
+)
+
+// buildRankerFixture: 2 users at 5000, 3 users at 4000, 1 user at 3000
+func buildRankerFixture(ranker Ranker) *LeaderboardSnapshot {
+	builder := NewSnapshotBuilder()
+	builder.SetRanker(ranker)
+	builder.AddUser(1, "a", 5000)
+	builder.AddUser(2, "b", 5000)
+	builder.AddUser(3, "c", 4000)
+	builder.AddUser(4, "d", 4000)
+	builder.AddUser(5, "e", 4000)
+	builder.AddUser(6, "f", 3000)
+	return builder.Build()
+}
+
+func TestRankers(t *testing.T) {
+	tests := []struct {
+		name     string
+		ranker   Ranker
+		expected map[int]int // rating -> rank
+	}{
+		{"nil defaults to dense", nil, map[int]int{5000: 1, 4000: 2, 3000: 3}},
+		{"dense", DenseRanker{}, map[int]int{5000: 1, 4000: 2, 3000: 3}},
+		{"standard competition", CompetitionRanker{}, map[int]int{5000: 1, 4000: 3, 3000: 6}},
+		{"modified competition", ModifiedCompetitionRanker{}, map[int]int{5000: 2, 4000: 5, 3000: 6}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snap := buildRankerFixture(tt.ranker)
+
+			for rating, want := range tt.expected {
+				if got := snap.GetRank(rating); got != want {
+					t.Errorf("Rating %d: expected rank %d, got %d", rating, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCustomRanker(t *testing.T) {
+	// Rank by rating band of 1000 points: 5000 → 1, 4000-4999 → 2, ...
+	byBand := RankerFunc(func(s *LeaderboardSnapshot, rating int) int {
+		return (5000-rating)/1000 + 1
+	})
+
+	snap := buildRankerFixture(byBand)
+
+	expected := map[int]int{5000: 1, 4500: 1, 4000: 2, 3000: 3}
+	for rating, want := range expected {
+		if got := snap.GetRank(rating); got != want {
+			t.Errorf("Rating %d: expected rank %d, got %d", rating, want, got)
+		}
+	}
+
+	// Out-of-range ratings never reach the ranker
+	if got := snap.GetRank(-1); got != 1 {
+		t.Errorf("Expected rank 1 for out-of-range rating, got %d", got)
+	}
+}
+
+func TestCountAbove(t *testing.T) {
+	snap := buildRankerFixture(nil)
+
+	expected := map[int]int{5000: 0, 4999: 2, 4000: 2, 3999: 5, 3000: 5, 0: 6}
+	for rating, want := range expected {
+		if got := snap.CountAbove[rating]; got != want {
+			t.Errorf("CountAbove[%d]: expected %d, got %d", rating, want, got)
+		}
+	}
+}
+
+func TestExcludingRating(t *testing.T) {
+	tests := []struct {
+		name     string
+		ranker   Ranker
+		excluded int
+		expected map[int]int // rating -> rank without one user at excluded
+	}{
+		// One of three at 4000 leaves: the level stays, and 3000 has one user
+		// fewer above it (7 → 6 with the leaver under competition ranking)
+		{"dense, tied", DenseRanker{}, 4000, map[int]int{5000: 1, 4000: 3, 3000: 4}},
+		{"competition, tied", CompetitionRanker{}, 4000, map[int]int{5000: 1, 4000: 4, 3000: 6}},
+		{"modified competition, tied", ModifiedCompetitionRanker{}, 4000, map[int]int{5000: 2, 4000: 5, 3000: 6}},
+		// The only user at 4500 leaves: the level goes too
+		{"dense, unique", DenseRanker{}, 4500, map[int]int{5000: 1, 4000: 2, 3000: 3}},
+		{"competition, unique", CompetitionRanker{}, 4500, map[int]int{5000: 1, 4000: 3, 3000: 6}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := NewSnapshotBuilder()
+			builder.SetRanker(tt.ranker)
+			for userID, rating := range []int{5000, 5000, 4500, 4000, 4000, 4000, 3000} {
+				builder.AddUser(userID+1, "user", rating)
+			}
+			snap := builder.Build()
+			before := snap.GetRank(3000)
+
+			view := snap.ExcludingRating(tt.excluded)
+			for rating, want := range tt.expected {
+				if got := view.GetRank(rating); got != want {
+					t.Errorf("Rating %d: expected rank %d, got %d", rating, want, got)
+				}
+			}
+
+			if snap.GetRank(3000) != before {
+				t.Error("Expected the original snapshot unchanged")
+			}
+		})
+	}
+
+	snap := buildRankerFixture(nil)
+	if snap.ExcludingRating(4500) != snap {
+		t.Error("Expected an unpopulated rating to return the snapshot itself")
+	}
+}
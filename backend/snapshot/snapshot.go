@@ -1,14 +1,26 @@
 package snapshot
 
 import (
-	"sort"
+	"fmt"
+	"maps"
+	"slices"
 	"time"
+
+	"matiks-backend/models"
 )
 
 type UserSummary struct {
 	ID       int    `json:"id"`
 	Username string `json:"username"`
 	Rating   int    `json:"rating"`
+
+	// UpdatedAt is when the user's rating last changed, in Unix
+	// nanoseconds; zero if it has not changed since the user was loaded.
+	UpdatedAt int64 `json:"-"`
+
+	// Inserted numbers users in the order they joined the population,
+	// starting at 1; zero if the builder was not given one.
+	Inserted uint64 `json:"-"`
 }
 
 type LeaderboardSnapshot struct {
@@ -31,15 +43,41 @@ type LeaderboardSnapshot struct {
 	//   PrefixHigher[4998] = 2    : rank 3 (1 + 2)
 	PrefixHigher [5001]int // rating -> distinct rating levels above
 
+	// CountAbove[rating] = number of USERS with a strictly higher rating.
+	// Used by competition-style rankers and for O(1) population counts.
+	CountAbove [5001]int // rating -> users above
+
+	// UsersByRating only has keys for populated ratings: a rating with no
+	// users has no entry rather than an empty slice. Each slice is ordered
+	// by the builder's TieBreak.
 	UsersByRating map[int][]UserSummary // rating -> users at that rating
 
+	// Ranker computes ranks from the arrays above. Nil means DenseRanker.
+	Ranker Ranker
+
+	// Top holds the first entries of the leaderboard (highest rating first,
+	// ties in UsersByRating order), precomputed at build time so the common top-N read
+	// is a copy. Its length is at most the builder's top-N size.
+	Top []models.LeaderboardEntry
+
+	// Metadata maps user IDs to their models.User.Metadata, for users that
+	// have any. The values are shared with the users and never modified.
+	Metadata map[int]*models.UserMetadata
+
 	GeneratedAt time.Time
+
+	// Version numbers snapshots in publication order, starting at 1. It is
+	// assigned when the snapshot is published; zero means unpublished.
+	Version uint64
 }
 
 func (s *LeaderboardSnapshot) GetRank(rating int) int {
 	if rating < 0 || rating >= len(s.PrefixHigher) {
 		return 1 // Default to rank 1 for out-of-bounds ratings
 	}
+	if s.Ranker != nil {
+		return s.Ranker.Rank(s, rating)
+	}
 	return s.PrefixHigher[rating] + 1
 }
 
@@ -51,10 +89,90 @@ func (s *LeaderboardSnapshot) TotalUsers() int {
 	return len(s.UserRatings)
 }
 
+// ExcludingRating returns a copy of s whose rank arrays count one user
+// fewer at rating, so GetRank on it ranks everyone as if that user had left
+// the leaderboard. Ranks above the excluded rating never change. Below it,
+// dense ranks move up one only if the user was alone at their rating, while
+// competition ranks always move up one; users tied with the excluded one
+// keep their rank except under modified competition ranking. UserRatings,
+// UsersByRating and Top are shared with s, unadjusted, so the copy is only
+// meant for GetRank. s itself is returned if no user holds rating.
+func (s *LeaderboardSnapshot) ExcludingRating(rating int) *LeaderboardSnapshot {
+	if rating < 0 || rating >= len(s.RatingCount) || s.RatingCount[rating] == 0 {
+		return s
+	}
+
+	view := *s
+	view.RatingCount[rating]--
+	lastAtLevel := view.RatingCount[rating] == 0
+	for r := rating - 1; r >= 0; r-- {
+		view.CountAbove[r]--
+		if lastAtLevel {
+			view.PrefixHigher[r]--
+		}
+	}
+	return &view
+}
+
+// CountInRange returns how many users have a rating in [low, high], in O(1)
+// from CountAbove and RatingCount. Bounds are clamped to the rating arrays.
+func (s *LeaderboardSnapshot) CountInRange(low, high int) int {
+	low = max(low, 0)
+	high = min(high, len(s.CountAbove)-1)
+	if low > high {
+		return 0
+	}
+	// Users at or above low, minus those strictly above high
+	return s.CountAbove[low] + s.RatingCount[low] - s.CountAbove[high]
+}
+
+// HighestRating returns the highest rating held by any user, or false if
+// the snapshot is empty.
+func (s *LeaderboardSnapshot) HighestRating() (int, bool) {
+	for rating := len(s.RatingCount) - 1; rating >= 0; rating-- {
+		if s.RatingCount[rating] > 0 {
+			return rating, true
+		}
+	}
+	return 0, false
+}
+
+// Validate checks that UsersByRating agrees with UserRatings and
+// RatingCount and holds no empty entries. It is O(users) and meant for
+// tests and consistency checks, not the request path.
+func (s *LeaderboardSnapshot) Validate() error {
+	grouped := 0
+	for rating, users := range s.UsersByRating {
+		if len(users) == 0 {
+			return fmt.Errorf("UsersByRating[%d] is empty", rating)
+		}
+		if rating >= 0 && rating < len(s.RatingCount) && len(users) != s.RatingCount[rating] {
+			return fmt.Errorf("UsersByRating[%d] has %d users, RatingCount has %d", rating, len(users), s.RatingCount[rating])
+		}
+		for _, user := range users {
+			if got, ok := s.UserRatings[user.ID]; !ok || got != rating {
+				return fmt.Errorf("user %d listed at rating %d, UserRatings has %d", user.ID, rating, got)
+			}
+		}
+		grouped += len(users)
+	}
+
+	if grouped != len(s.UserRatings) {
+		return fmt.Errorf("UsersByRating holds %d users, UserRatings %d", grouped, len(s.UserRatings))
+	}
+	return nil
+}
+
 // SnapshotBuilder helps construct a new immutable LeaderboardSnapshot.
 type SnapshotBuilder struct {
 	userRatings map[int]int
 	usernames   map[int]string
+	updatedAt   map[int]int64  // userID -> UserSummary.UpdatedAt
+	inserted    map[int]uint64 // userID -> UserSummary.Inserted
+	metadata    map[int]*models.UserMetadata
+	ranker      Ranker
+	tieBreak    TieBreak
+	topN        int
 }
 
 func NewSnapshotBuilder() *SnapshotBuilder {
@@ -69,11 +187,69 @@ func (b *SnapshotBuilder) AddUser(userID int, username string, rating int) {
 	b.usernames[userID] = username
 }
 
+// Reset removes every user so the builder can be reused for the next
+// snapshot, keeping its settings and the capacity of its maps. Built
+// snapshots share nothing with the builder, so they are unaffected.
+func (b *SnapshotBuilder) Reset() {
+	clear(b.userRatings)
+	clear(b.usernames)
+	clear(b.updatedAt)
+	clear(b.inserted)
+	clear(b.metadata)
+}
+
+// SetUpdatedAt records when a user's rating last changed, in Unix
+// nanoseconds, for recency tie-breaks. Users without one sort as oldest.
+func (b *SnapshotBuilder) SetUpdatedAt(userID int, unixNano int64) {
+	if b.updatedAt == nil {
+		b.updatedAt = make(map[int]int64)
+	}
+	b.updatedAt[userID] = unixNano
+}
+
+// SetInserted records a user's position in insertion order, for the
+// insertion tie-break. Users without one sort first.
+func (b *SnapshotBuilder) SetInserted(userID int, seq uint64) {
+	if b.inserted == nil {
+		b.inserted = make(map[int]uint64)
+	}
+	b.inserted[userID] = seq
+}
+
+// SetMetadata attaches a user's metadata to the snapshot. It is shared,
+// not copied, so it must not be modified afterwards.
+func (b *SnapshotBuilder) SetMetadata(userID int, metadata *models.UserMetadata) {
+	if b.metadata == nil {
+		b.metadata = make(map[int]*models.UserMetadata)
+	}
+	b.metadata[userID] = metadata
+}
+
+// SetTieBreak chooses how users sharing a rating are ordered. Empty means
+// TieBreakID.
+func (b *SnapshotBuilder) SetTieBreak(tieBreak TieBreak) {
+	b.tieBreak = tieBreak
+}
+
+// SetRanker chooses the rank formula for the built snapshot.
+func (b *SnapshotBuilder) SetRanker(ranker Ranker) {
+	b.ranker = ranker
+}
+
+// SetTopN makes Build precompute the first n leaderboard entries into
+// LeaderboardSnapshot.Top. Zero (the default) disables it.
+func (b *SnapshotBuilder) SetTopN(n int) {
+	b.topN = n
+}
+
 func (b *SnapshotBuilder) Build() *LeaderboardSnapshot {
 	snap := &LeaderboardSnapshot{
-		UserRatings:   make(map[int]int, len(b.userRatings)),
-		UsersByRating: make(map[int][]UserSummary),
-		GeneratedAt:   time.Now(),
+		UserRatings: make(map[int]int, len(b.userRatings)),
+		Ranker:      b.ranker,
+		GeneratedAt: time.Now(),
+	}
+	if len(b.metadata) > 0 {
+		snap.Metadata = maps.Clone(b.metadata)
 	}
 
 	// Copy user ratings and count rating frequencies
@@ -84,33 +260,74 @@ func (b *SnapshotBuilder) Build() *LeaderboardSnapshot {
 		}
 	}
 
-	// Compute PrefixHigher for dense ranking
+	// Compute PrefixHigher for dense ranking and CountAbove for user counts
 	distinctLevels := 0
+	usersAbove := 0
 	for rating := 5000; rating >= 0; rating-- {
 		snap.PrefixHigher[rating] = distinctLevels
+		snap.CountAbove[rating] = usersAbove
 		if snap.RatingCount[rating] > 0 {
 			distinctLevels++
+			usersAbove += snap.RatingCount[rating]
 		}
 	}
 
-	// Group users by rating for leaderboard generation
+	// Group users by rating for leaderboard generation. In-range users share
+	// one backing array ordered from the highest rating down, so a rating's
+	// users start at CountAbove[rating]; each bucket's capacity is capped so
+	// an append can never spill into its neighbour. An unpopulated rating
+	// never gets a key.
+	backing := make([]UserSummary, usersAbove)
+	next := snap.CountAbove // copy: per-rating fill position
+	snap.UsersByRating = make(map[int][]UserSummary, distinctLevels)
+	var outOfRange []int // ratings outside the arrays, grouped by appending
 	for userID, rating := range b.userRatings {
-		username := b.usernames[userID]
 		summary := UserSummary{
-			ID:       userID,
-			Username: username,
-			Rating:   rating,
+			ID:        userID,
+			Username:  b.usernames[userID],
+			Rating:    rating,
+			UpdatedAt: b.updatedAt[userID],
+			Inserted:  b.inserted[userID],
 		}
-		snap.UsersByRating[rating] = append(snap.UsersByRating[rating], summary)
+		if rating < 0 || rating >= len(next) {
+			if _, ok := snap.UsersByRating[rating]; !ok {
+				outOfRange = append(outOfRange, rating)
+			}
+			snap.UsersByRating[rating] = append(snap.UsersByRating[rating], summary)
+			continue
+		}
+		backing[next[rating]] = summary
+		next[rating]++
+	}
+
+	for rating, count := range snap.RatingCount {
+		if count == 0 {
+			continue
+		}
+		start := snap.CountAbove[rating]
+		users := backing[start : start+count : start+count]
+		if count > 1 {
+			slices.SortFunc(users, b.tieBreak.compare)
+		}
+		snap.UsersByRating[rating] = users
+	}
+	for _, rating := range outOfRange {
+		slices.SortFunc(snap.UsersByRating[rating], b.tieBreak.compare)
 	}
 
-	for rating := range snap.UsersByRating {
-		users := snap.UsersByRating[rating]
-		if len(users) > 1 {
-			sort.Slice(users, func(i, j int) bool {
-				return users[i].ID < users[j].ID
-			})
-			snap.UsersByRating[rating] = users
+	if b.topN > 0 {
+		snap.Top = make([]models.LeaderboardEntry, 0, min(b.topN, len(b.userRatings)))
+		for rating := 5000; rating >= 0 && len(snap.Top) < b.topN; rating-- {
+			for _, user := range snap.UsersByRating[rating] {
+				if len(snap.Top) == b.topN {
+					break
+				}
+				snap.Top = append(snap.Top, models.LeaderboardEntry{
+					Rank:     snap.GetRank(rating),
+					Username: user.Username,
+					Rating:   rating,
+				})
+			}
 		}
 	}
 
@@ -190,6 +190,67 @@ func TestUsersByRating(t *testing.T) {
 	}
 }
 
+// TestUsersByRating_NoEmptyEntries verifies that a rating every user has
+// left disappears from UsersByRating instead of lingering as an empty slice.
+func TestUsersByRating_NoEmptyEntries(t *testing.T) {
+	builder := NewSnapshotBuilder()
+
+	builder.AddUser(1, "alice", 3000)
+	builder.AddUser(2, "bob", 3000)
+	builder.AddUser(3, "charlie", 2000)
+	if err := builder.Build().Validate(); err != nil {
+		t.Fatalf("Initial snapshot invalid: %v", err)
+	}
+
+	// Move everyone off 3000
+	builder.AddUser(1, "alice", 3100)
+	builder.AddUser(2, "bob", 2000)
+	snap := builder.Build()
+
+	if users, ok := snap.UsersByRating[3000]; ok {
+		t.Errorf("Expected no entry for rating 3000, got %v", users)
+	}
+	if err := snap.Validate(); err != nil {
+		t.Errorf("Snapshot invalid after moving users: %v", err)
+	}
+
+	snap.UsersByRating[1234] = nil
+	if err := snap.Validate(); err == nil {
+		t.Error("Expected Validate to reject an empty UsersByRating entry")
+	}
+}
+
+// TestSnapshotBuilder_Reset verifies a reused builder leaves earlier
+// snapshots untouched and that rating buckets sharing one backing array
+// cannot overwrite each other.
+func TestSnapshotBuilder_Reset(t *testing.T) {
+	builder := NewSnapshotBuilder()
+	builder.AddUser(1, "alice", 3000)
+	builder.AddUser(2, "bob", 3000)
+	builder.AddUser(3, "charlie", 2999)
+	first := builder.Build()
+
+	builder.Reset()
+	builder.AddUser(4, "dave", 1000)
+	second := builder.Build()
+
+	if first.TotalUsers() != 3 || len(first.UsersByRating[3000]) != 2 {
+		t.Errorf("Reset changed an earlier snapshot: %v", first.UsersByRating)
+	}
+	if second.TotalUsers() != 1 || len(second.UsersByRating[3000]) != 0 {
+		t.Errorf("Expected only dave after Reset, got %v", second.UsersByRating)
+	}
+	if err := second.Validate(); err != nil {
+		t.Errorf("Snapshot after Reset invalid: %v", err)
+	}
+
+	// Appending to one bucket must not clobber the next one
+	_ = append(first.UsersByRating[3000], UserSummary{ID: 99})
+	if got := first.UsersByRating[2999]; len(got) != 1 || got[0].ID != 3 {
+		t.Errorf("Append to a bucket overwrote its neighbour: %v", got)
+	}
+}
+
 // TestRatingCountAccuracy verifies that RatingCount is accurate.
 func TestRatingCountAccuracy(t *testing.T) {
 	builder := NewSnapshotBuilder()
@@ -220,6 +281,35 @@ func TestRatingCountAccuracy(t *testing.T) {
 	}
 }
 
+// TestTopN verifies the precomputed top entries follow leaderboard order.
+func TestTopN(t *testing.T) {
+	builder := NewSnapshotBuilder()
+	builder.AddUser(3, "carol", 4000)
+	builder.AddUser(1, "alice", 4500)
+	builder.AddUser(2, "bob", 4000)
+	builder.AddUser(4, "dave", 3000)
+	builder.SetTopN(3)
+	snap := builder.Build()
+
+	want := []string{"alice", "bob", "carol"}
+	if len(snap.Top) != len(want) {
+		t.Fatalf("Expected %d top entries, got %d", len(want), len(snap.Top))
+	}
+	for i, username := range want {
+		if snap.Top[i].Username != username {
+			t.Errorf("Top[%d]: expected %s, got %s", i, username, snap.Top[i].Username)
+		}
+	}
+	if snap.Top[1].Rank != 2 || snap.Top[2].Rank != 2 {
+		t.Errorf("Expected tied users at rank 2, got %d and %d", snap.Top[1].Rank, snap.Top[2].Rank)
+	}
+
+	// Without SetTopN nothing is precomputed
+	if top := NewSnapshotBuilder().Build().Top; top != nil {
+		t.Errorf("Expected no precomputed entries by default, got %v", top)
+	}
+}
+
 // TestConcurrentSnapshotReads tests that snapshots can be read concurrently.
 func TestConcurrentSnapshotReads(t *testing.T) {
 	builder := NewSnapshotBuilder()
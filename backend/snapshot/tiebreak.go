@@ -0,0 +1,46 @@
+package snapshot
+
+import "cmp"
+
+// TieBreak orders users who share a rating. It only affects listing order
+// (UsersByRating and Top); tied users always share a rank.
+type TieBreak string
+
+const (
+	// TieBreakID lists tied users by ascending user ID. This is the default
+	// when a builder has no tie-break set.
+	TieBreakID TieBreak = "id"
+
+	// TieBreakRecentFirst lists the user who reached the rating most
+	// recently first.
+	TieBreakRecentFirst TieBreak = "recent-first"
+
+	// TieBreakRecentLast lists the user who has held the rating longest
+	// first.
+	TieBreakRecentLast TieBreak = "recent-last"
+
+	// TieBreakInsertion lists tied users in the order they were added to
+	// the population, earliest first.
+	TieBreakInsertion TieBreak = "insertion"
+)
+
+// compare orders a before b (negative) or after it (positive). Users with
+// equal timestamps (including users never updated, whose UpdatedAt is zero)
+// or insertion sequences fall back to ID order.
+func (t TieBreak) compare(a, b UserSummary) int {
+	switch t {
+	case TieBreakRecentFirst:
+		if c := cmp.Compare(b.UpdatedAt, a.UpdatedAt); c != 0 {
+			return c
+		}
+	case TieBreakRecentLast:
+		if c := cmp.Compare(a.UpdatedAt, b.UpdatedAt); c != 0 {
+			return c
+		}
+	case TieBreakInsertion:
+		if c := cmp.Compare(a.Inserted, b.Inserted); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
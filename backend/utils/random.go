@@ -3,17 +3,80 @@ package utils
 import (
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
+
+	"matiks-backend/models"
+)
+
+// Rating bounds used by GenerateUsers, matching services.MinRating and
+// services.MaxRating (utils cannot import services).
+const (
+	MinRating = 100
+	MaxRating = 5000
 )
 
-var rng *rand.Rand
+// Rand is a random source safe for concurrent use. The same seed always
+// yields the same sequence, so a service or test holding one Rand can be
+// replayed exactly.
+type Rand struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewRand returns a Rand seeded with seed.
+func NewRand(seed int64) *Rand {
+	return &Rand{rng: rand.New(rand.NewSource(seed))}
+}
 
-func init() {
-	rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+// Intn returns a random integer from 0 to n-1.
+func (r *Rand) Intn(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rng.Intn(n)
 }
 
+// Username generates a random username with potential collisions.
+func (r *Rand) Username(id int) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return randomUsername(r.rng, id)
+}
+
+// Rating generates a random rating between min and max (inclusive).
+func (r *Rand) Rating(min, max int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return randomRating(r.rng, min, max)
+}
+
+// Users returns n users with IDs 1..n, drawn from the same username and
+// rating distributions as the service's initial population.
+func (r *Rand) Users(n int) []models.UserSeed {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users := make([]models.UserSeed, n)
+	for i := range users {
+		id := i + 1
+		users[i] = models.UserSeed{
+			ID:       id,
+			Username: randomUsername(r.rng, id),
+			Rating:   randomRating(r.rng, MinRating, MaxRating),
+		}
+	}
+	return users
+}
+
+// defaultRand backs the package-level helpers.
+var defaultRand = NewRand(time.Now().UnixNano())
+
 // GenerateRandomUsername generates a random username with potential collisions
 func GenerateRandomUsername(id int) string {
+	return defaultRand.Username(id)
+}
+
+func randomUsername(rng *rand.Rand, id int) string {
 	firstNames := []string{
 		"rahul", "priya", "amit", "sneha", "vijay", "anita", "rohan", "kavya",
 		"arjun", "neha", "karan", "pooja", "aditya", "divya", "siddharth", "isha",
@@ -51,10 +114,22 @@ func GenerateRandomUsername(id int) string {
 
 // GenerateRandomRating generates a random rating between min and max (inclusive)
 func GenerateRandomRating(min, max int) int {
+	return defaultRand.Rating(min, max)
+}
+
+func randomRating(rng *rand.Rand, min, max int) int {
 	return min + rng.Intn(max-min+1)
 }
 
+// GenerateUsers returns n users with IDs 1..n, drawn from the same username
+// and rating distributions as the service's initial population. The same
+// seed always yields the same users, so tests and load tests can import an
+// identical dataset (see POST /admin/import).
+func GenerateUsers(n int, seed int64) []models.UserSeed {
+	return NewRand(seed).Users(n)
+}
+
 // GetRandomInt returns a random integer from 0 to n-1
 func GetRandomInt(n int) int {
-	return rng.Intn(n)
+	return defaultRand.Intn(n)
 }
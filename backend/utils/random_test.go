@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestGenerateUsers_Reproducible(t *testing.T) {
+	first := GenerateUsers(500, 42)
+	second := GenerateUsers(500, 42)
+
+	if !slices.Equal(first, second) {
+		t.Fatal("Expected identical users for the same seed")
+	}
+	if slices.Equal(first, GenerateUsers(500, 43)) {
+		t.Error("Expected different users for a different seed")
+	}
+
+	for i, user := range first {
+		if user.ID != i+1 {
+			t.Errorf("Expected ID %d, got %d", i+1, user.ID)
+		}
+		if user.Username == "" {
+			t.Errorf("User %d has an empty username", user.ID)
+		}
+		if user.Rating < MinRating || user.Rating > MaxRating {
+			t.Errorf("User %d rating %d outside [%d, %d]", user.ID, user.Rating, MinRating, MaxRating)
+		}
+	}
+}
+
+func TestGenerateRandom_ConcurrentUse(t *testing.T) {
+	// Run with -race: the package-level helpers share one source
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if GenerateRandomUsername(i) == "" {
+					t.Error("Expected a non-empty username")
+					return
+				}
+				if rating := GenerateRandomRating(MinRating, MaxRating); rating < MinRating || rating > MaxRating {
+					t.Errorf("Rating %d outside [%d, %d]", rating, MinRating, MaxRating)
+					return
+				}
+				GetRandomInt(10)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRand_SameSeedSameSequence(t *testing.T) {
+	draw := func() []int {
+		rng := NewRand(7)
+		values := []int{rng.Intn(1000), rng.Rating(MinRating, MaxRating)}
+		for _, user := range rng.Users(20) {
+			values = append(values, user.Rating)
+		}
+		return values
+	}
+
+	if first, second := draw(), draw(); !slices.Equal(first, second) {
+		t.Errorf("Expected the same draws for the same seed, got %v and %v", first, second)
+	}
+	if !slices.Equal(NewRand(42).Users(500), GenerateUsers(500, 42)) {
+		t.Error("Expected GenerateUsers to match a Rand with the same seed")
+	}
+}
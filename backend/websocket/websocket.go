@@ -0,0 +1,287 @@
+// Package websocket implements the subset of the WebSocket protocol
+// (https://www.rfc-editor.org/rfc/rfc6455) needed to push frames to
+// clients: the opening handshake, unfragmented data frames, and ping and
+// close handling. Dial is a matching minimal client for tests and tools.
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Opcodes of the frames Conn sends and receives.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// MaxMessageSize bounds the payload of a frame read by Conn. Clients of
+// this server only send control frames, which are far smaller.
+const MaxMessageSize = 1 << 20
+
+// acceptGUID is appended to the client's key to derive Sec-WebSocket-Accept.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrClosed is returned by ReadMessage once the peer has sent a close frame.
+var ErrClosed = errors.New("websocket: connection closed")
+
+// Conn is one WebSocket connection. Writes may come from several
+// goroutines; reads must come from one.
+type Conn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	client bool // clients mask the frames they send
+
+	writeMu sync.Mutex
+}
+
+// Upgrade completes the server side of the opening handshake and takes
+// over the connection. On a request that is not a valid WebSocket
+// handshake it replies 400 and returns an error.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet ||
+		!headerHasToken(r.Header, "Connection", "upgrade") ||
+		!headerHasToken(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		http.Error(w, "WebSocket handshake required", http.StatusBadRequest)
+		return nil, errors.New("websocket: not a handshake request")
+	}
+
+	netConn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+
+	// The connection outlives the request, so drop the server's timeouts
+	_ = netConn.SetDeadline(time.Time{})
+
+	fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", acceptKey(key))
+	if err := brw.Flush(); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+
+	return &Conn{conn: netConn, br: brw.Reader}, nil
+}
+
+// Dial opens a client connection to a ws:// URL.
+func Dial(rawURL string) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+	if u.Scheme != "ws" {
+		return nil, fmt.Errorf("websocket: unsupported scheme %q", u.Scheme)
+	}
+
+	netConn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Host:       u.Host,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-WebSocket-Key":     {key},
+			"Sec-WebSocket-Version": {"13"},
+		},
+	}
+	if err := req.Write(netConn); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+
+	br := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		netConn.Close()
+		return nil, fmt.Errorf("websocket: handshake failed with status %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		netConn.Close()
+		return nil, errors.New("websocket: bad Sec-WebSocket-Accept")
+	}
+
+	return &Conn{conn: netConn, br: br, client: true}, nil
+}
+
+// WriteMessage sends data as a single frame of the given opcode.
+func (c *Conn) WriteMessage(opcode int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	frame := make([]byte, 0, 14+len(data))
+	frame = append(frame, 0x80|byte(opcode)) // FIN: frames are never fragmented
+
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(data); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+
+	if !c.client {
+		frame = append(frame, data...)
+	} else {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return fmt.Errorf("websocket: %w", err)
+		}
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, data...)
+		maskBytes(frame[start:], mask)
+	}
+
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// ReadMessage returns the next text or binary message. Pings are answered
+// and pongs skipped along the way; a close frame is answered and reported
+// as ErrClosed.
+func (c *Conn) ReadMessage() (opcode int, data []byte, err error) {
+	for {
+		var header [2]byte
+		if _, err := io.ReadFull(c.br, header[:]); err != nil {
+			return 0, nil, err
+		}
+
+		fin := header[0]&0x80 != 0
+		opcode = int(header[0] & 0x0f)
+		masked := header[1]&0x80 != 0
+		if !fin || opcode == 0 {
+			return 0, nil, errors.New("websocket: fragmented messages are not supported")
+		}
+		if masked == c.client {
+			return 0, nil, errors.New("websocket: frame masking does not match the peer's role")
+		}
+
+		length := uint64(header[1] & 0x7f)
+		switch length {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+				return 0, nil, err
+			}
+			length = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+				return 0, nil, err
+			}
+			length = binary.BigEndian.Uint64(ext[:])
+		}
+		if length > MaxMessageSize {
+			return 0, nil, fmt.Errorf("websocket: %d byte frame exceeds %d", length, MaxMessageSize)
+		}
+
+		var mask [4]byte
+		if masked {
+			if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+				return 0, nil, err
+			}
+		}
+		data = make([]byte, length)
+		if _, err := io.ReadFull(c.br, data); err != nil {
+			return 0, nil, err
+		}
+		if masked {
+			maskBytes(data, mask)
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err := c.WriteMessage(PongMessage, data); err != nil {
+				return 0, nil, err
+			}
+		case PongMessage:
+		case CloseMessage:
+			_ = c.WriteMessage(CloseMessage, data)
+			return 0, nil, ErrClosed
+		default:
+			return opcode, data, nil
+		}
+	}
+}
+
+// SetReadDeadline bounds how long ReadMessage may block.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// Close closes the underlying connection without a closing handshake.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func maskBytes(data []byte, mask [4]byte) {
+	for i := range data {
+		data[i] ^= mask[i%4]
+	}
+}
+
+// headerHasToken reports whether the comma-separated header name contains
+// token, ignoring case.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package websocket
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newEchoServer upgrades every request and echoes messages back until the
+// client closes.
+func newEchoServer(t *testing.T) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			opcode, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(opcode, data); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestRoundTrip_PayloadSizes(t *testing.T) {
+	conn, err := Dial(newEchoServer(t))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	// One per length encoding: 7 bits, 16 bits and 64 bits
+	for _, size := range []int{0, 125, 126, 70000} {
+		payload := bytes.Repeat([]byte{byte(size)}, size)
+		if err := conn.WriteMessage(BinaryMessage, payload); err != nil {
+			t.Fatalf("WriteMessage(%d bytes) failed: %v", size, err)
+		}
+
+		opcode, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage(%d bytes) failed: %v", size, err)
+		}
+		if opcode != BinaryMessage || !bytes.Equal(data, payload) {
+			t.Errorf("Expected %d bytes echoed as binary, got opcode %d with %d bytes", size, opcode, len(data))
+		}
+	}
+}
+
+func TestReadMessage_AnswersPingAndClose(t *testing.T) {
+	conn, err := Dial(newEchoServer(t))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	// The server answers the ping before echoing the text, and the client
+	// skips the pong
+	if err := conn.WriteMessage(PingMessage, []byte("hi")); err != nil {
+		t.Fatalf("WriteMessage failed: %v", err)
+	}
+	if err := conn.WriteMessage(TextMessage, []byte("after ping")); err != nil {
+		t.Fatalf("WriteMessage failed: %v", err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "after ping" {
+		t.Fatalf("Expected the echoed text, got %q (%v)", data, err)
+	}
+
+	if err := conn.WriteMessage(CloseMessage, nil); err != nil {
+		t.Fatalf("WriteMessage failed: %v", err)
+	}
+	if _, _, err := conn.ReadMessage(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected the server's close frame, got %v", err)
+	}
+}
+
+func TestUpgrade_RejectsPlainRequests(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if _, err := Upgrade(rec, httptest.NewRequest(http.MethodGet, "/", nil)); err == nil {
+		t.Fatal("Expected an error for a request without a handshake")
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", rec.Code)
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// The example handshake from RFC 6455 section 1.3
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Expected the RFC's accept key, got %q", got)
+	}
+}